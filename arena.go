@@ -0,0 +1,80 @@
+package main
+
+const arenaChunkSize = 64
+
+// Arena hands out AST nodes from slabs so parsing many expressions costs a
+// few large allocations instead of one per node. Nodes stay valid for as
+// long as the expressions using them are reachable; Reset only stops new
+// nodes from sharing the current slabs.
+type Arena struct {
+	prefix []PrefixExpression
+	infix  []InfixExpression
+	calls  []CallExpression
+	args   []Expression
+}
+
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+func (a *Arena) Reset() {
+	*a = Arena{}
+}
+
+func WithArena(a *Arena) ParseOption {
+	return func(l *Lexer) {
+		l.arena = a
+	}
+}
+
+// The allocation methods below fall back to the heap on a nil *Arena, so the
+// parser can call them unconditionally.
+
+func (a *Arena) newPrefix() *PrefixExpression {
+	if a == nil {
+		return &PrefixExpression{}
+	}
+	if len(a.prefix) == cap(a.prefix) {
+		a.prefix = make([]PrefixExpression, 0, arenaChunkSize)
+	}
+	a.prefix = a.prefix[:len(a.prefix)+1]
+	return &a.prefix[len(a.prefix)-1]
+}
+
+func (a *Arena) newInfix() *InfixExpression {
+	if a == nil {
+		return &InfixExpression{}
+	}
+	if len(a.infix) == cap(a.infix) {
+		a.infix = make([]InfixExpression, 0, arenaChunkSize)
+	}
+	a.infix = a.infix[:len(a.infix)+1]
+	return &a.infix[len(a.infix)-1]
+}
+
+func (a *Arena) newCall() *CallExpression {
+	if a == nil {
+		return &CallExpression{}
+	}
+	if len(a.calls) == cap(a.calls) {
+		a.calls = make([]CallExpression, 0, arenaChunkSize)
+	}
+	a.calls = a.calls[:len(a.calls)+1]
+	return &a.calls[len(a.calls)-1]
+}
+
+func (a *Arena) newArgs(n int) []Expression {
+	if a == nil {
+		return make([]Expression, n)
+	}
+	if cap(a.args)-len(a.args) < n {
+		size := arenaChunkSize
+		if n > size {
+			size = n
+		}
+		a.args = make([]Expression, 0, size)
+	}
+	start := len(a.args)
+	a.args = a.args[:start+n]
+	return a.args[start : start+n : start+n]
+}
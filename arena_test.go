@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	arena := NewArena()
+	var sources []string
+	var parsed []Expression
+	for i := 0; i < 3*arenaChunkSize; i++ {
+		src := fmt.Sprintf("f(x, -%d, g(y * 2, z)) + %d ^ x", i, i)
+		e, err := Parse(src, WithArena(arena))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, src)
+		parsed = append(parsed, e)
+		if i%arenaChunkSize == 0 {
+			arena.Reset()
+		}
+	}
+	// Nodes handed out earlier must not be overwritten by later parses.
+	for i, src := range sources {
+		want, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalExpr(parsed[i], want) {
+			t.Errorf("%s parsed with an arena is %s, want %s", src, Format(parsed[i]), Format(want))
+		}
+	}
+}
+
+func TestArenaAllocations(t *testing.T) {
+	src := "a * (b + c) - f(d, -e, g(h)) / 2"
+	heap := testing.AllocsPerRun(100, func() {
+		Parse(src)
+	})
+	arena := NewArena()
+	pooled := testing.AllocsPerRun(100, func() {
+		Parse(src, WithArena(arena))
+	})
+	if pooled >= heap {
+		t.Errorf("parsing with an arena makes %g allocations, want fewer than the %g without", pooled, heap)
+	}
+}
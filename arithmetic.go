@@ -0,0 +1,86 @@
+package main
+
+// Arithmetic is implemented by the custom values of numeric types a host
+// defines, such as fixed-point or dual numbers, for the standard operators
+// to work on them. The operand of each method may be a custom value or a
+// built-in one, such as an integer, for the type to accept or reject. When
+// only the right operand of an operator is an Arithmetic, + and * are
+// taken to commute, a - b is computed as -(b - a), and / is left to the
+// dispatch table as ^ and the comparisons are, for Overload to implement.
+type Arithmetic interface {
+	Add(other Value) (Value, error)
+	Sub(other Value) (Value, error)
+	Mul(other Value) (Value, error)
+	Div(other Value) (Value, error)
+	Neg() (Value, error)
+}
+
+func arithmetic(v Value) (Arithmetic, bool) {
+	if v.Kind() != CustomKind {
+		return nil, false
+	}
+	a, ok := v.Custom().(Arithmetic)
+	return a, ok
+}
+
+// applyArithmeticPrefix applies + or - to rhs if it is an Arithmetic,
+// reporting whether it did. Other operators are left to the dispatch
+// table, where OverloadPrefix may implement them for custom values.
+func applyArithmeticPrefix(op OpKind, rhs Value) (Value, bool, error) {
+	a, ok := arithmetic(rhs)
+	if !ok {
+		return Value{}, false, nil
+	}
+	switch op {
+	case AddOp:
+		return rhs, true, nil
+	case SubOp:
+		value, err := a.Neg()
+		return value, true, err
+	}
+	return Value{}, false, nil
+}
+
+// applyArithmeticInfix applies an arithmetic operator to operands of which
+// at least one is an Arithmetic, reporting whether it did. Other operators,
+// such as the comparisons, are left to the dispatch table, where Overload
+// may implement them for custom values.
+func applyArithmeticInfix(op OpKind, lhs Value, rhs Value) (Value, bool, error) {
+	if a, ok := arithmetic(lhs); ok {
+		var value Value
+		var err error
+		switch op {
+		case AddOp:
+			value, err = a.Add(rhs)
+		case SubOp:
+			value, err = a.Sub(rhs)
+		case MulOp:
+			value, err = a.Mul(rhs)
+		case DivOp:
+			value, err = a.Div(rhs)
+		default:
+			return Value{}, false, nil
+		}
+		return value, true, err
+	}
+	b, ok := arithmetic(rhs)
+	if !ok {
+		return Value{}, false, nil
+	}
+	switch op {
+	case AddOp:
+		value, err := b.Add(lhs)
+		return value, true, err
+	case MulOp:
+		value, err := b.Mul(lhs)
+		return value, true, err
+	case SubOp:
+		difference, err := b.Sub(lhs)
+		if err != nil {
+			return Value{}, true, err
+		}
+		value, err := applyPrefix(SubOp, difference)
+		return value, true, err
+	}
+	return Value{}, false, nil
+}
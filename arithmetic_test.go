@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fixed is a fixed-point number in hundredths, implementing Arithmetic.
+type fixed int64
+
+func (a fixed) operand(v Value) (fixed, error) {
+	switch v.Kind() {
+	case CustomKind:
+		if b, ok := v.Custom().(fixed); ok {
+			return b, nil
+		}
+	case IntKind:
+		return fixed(v.Int() * 100), nil
+	}
+	return 0, fmt.Errorf("cannot combine fixed with %s", v.Kind())
+}
+
+func (a fixed) Add(v Value) (Value, error) {
+	b, err := a.operand(v)
+	return CustomValue(a + b), err
+}
+
+func (a fixed) Sub(v Value) (Value, error) {
+	b, err := a.operand(v)
+	return CustomValue(a - b), err
+}
+
+func (a fixed) Mul(v Value) (Value, error) {
+	b, err := a.operand(v)
+	return CustomValue(a * b / 100), err
+}
+
+func (a fixed) Div(v Value) (Value, error) {
+	b, err := a.operand(v)
+	if err == nil && b == 0 {
+		err = ErrDivisionByZero
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	return CustomValue(a * 100 / b), nil
+}
+
+func (a fixed) Neg() (Value, error) {
+	return CustomValue(-a), nil
+}
+
+func TestArithmetic(t *testing.T) {
+	env := Env{"a": CustomValue(fixed(150)), "b": CustomValue(fixed(25)), "p": CustomValue(struct{ X int }{1})}
+	tests := []struct {
+		src  string
+		want Value
+		err  string
+	}{
+		{src: "a + b", want: CustomValue(fixed(175))},
+		{src: "a - b", want: CustomValue(fixed(125))},
+		{src: "a * 2", want: CustomValue(fixed(300))},
+		{src: "2 * a", want: CustomValue(fixed(300))},
+		{src: "1 + a", want: CustomValue(fixed(250))},
+		{src: "1 - a", want: CustomValue(fixed(-50))},
+		{src: "a / b", want: CustomValue(fixed(600))},
+		{src: "-a", want: CustomValue(fixed(-150))},
+		{src: "+a", want: CustomValue(fixed(150))},
+		{src: "a / 0", err: "division by zero at column 3"},
+		{src: `a + "s"`, err: "cannot combine fixed with string at column 3"},
+		{src: "2 / a", err: "operator '/' not defined for int and custom at column 3"},
+		{src: "a ^ 2", err: "operator '^' not defined for custom and int at column 3"},
+		{src: "!a", err: "operator '!' not defined for custom at column 1"},
+		{src: "p + 1", err: "operator '+' not defined for custom and int at column 3"},
+		{src: "-p", err: "operator '-' not defined for custom at column 1"},
+	}
+	for _, tt := range tests {
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(mustParse(t, tt.src))
+			if errorString(err) != tt.err || err == nil && !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s, %q", tt.src, b, got, err, tt.want, tt.err)
+			}
+		}
+	}
+}
+
+func TestArithmeticLeavesOtherOperatorsToOverloads(t *testing.T) {
+	Overload("<", CustomKind, CustomKind, BoolKind, func(lhs, rhs Value) (Value, error) {
+		return BoolValue(lhs.Custom().(fixed) < rhs.Custom().(fixed)), nil
+	})
+	defer func() {
+		infixImpls[LtOp][CustomKind][CustomKind] = overload{}
+	}()
+	env := Env{"a": CustomValue(fixed(150)), "b": CustomValue(fixed(25))}
+	for src, want := range map[string]bool{"b < a": true, "a < b": false, "a - b < a": true} {
+		got, err := NewEvaluator(env).Eval(mustParse(t, src))
+		if err != nil || !got.Equal(BoolValue(want)) {
+			t.Errorf("%s = %s, %v, want %t", src, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// AssertionError is the error of an assert call whose condition is false,
+// at the call's column.
+type AssertionError struct {
+	Pos int
+	Msg string
+}
+
+func (e AssertionError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
+}
+
+func init() {
+	addBuiltin("assert", "assert(cond bool[, message string]) bool", docEntry{"true if cond is, and otherwise fails the evaluation with message", `assert(isprime(p), "p must be prime")`},
+		func(args []Value) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return Value{}, fmt.Errorf("assert takes a condition and a message")
+			}
+			if args[0].Kind() != BoolKind {
+				return Value{}, fmt.Errorf("assert takes a bool condition, not %s", args[0].Kind())
+			}
+			msg := "assertion failed"
+			if len(args) == 2 {
+				if args[1].Kind() != StringKind {
+					return Value{}, fmt.Errorf("assert takes a string message, not %s", args[1].Kind())
+				}
+				msg = args[1].Str()
+			}
+			if !args[0].Bool() {
+				// callFunction fills in the position.
+				return Value{}, AssertionError{Msg: msg}
+			}
+			return args[0], nil
+		})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAssert(t *testing.T) {
+	env := Env{"a": IntValue(2)}
+	passing := []string{
+		`assert(a == 2, "a must be 2")`,
+		"assert(a > 0)",
+		`assert(a == 2, "a must be 2") && a < 3`,
+	}
+	for _, src := range passing {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range allBackends {
+			if got, err := NewEvaluator(env, WithBackend(b)).Eval(e); err != nil || !got.Equal(BoolValue(true)) {
+				t.Errorf("%s with the %s backend = %s, %v, want true", src, b, got, err)
+			}
+		}
+	}
+	failing := []struct {
+		src string
+		msg string
+		pos int
+	}{
+		{`assert(a == 3, "a must be 3")`, "a must be 3", 0},
+		{"assert(a < 0)", "assertion failed", 0},
+		{`a + 1 > 0 && assert(a != 2, "a is 2")`, "a is 2", 13},
+	}
+	for _, tt := range failing {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range allBackends {
+			_, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			var assertion AssertionError
+			if !errors.As(err, &assertion) {
+				t.Errorf("%s with the %s backend fails with %v, want an AssertionError", tt.src, b, err)
+				continue
+			}
+			if assertion.Msg != tt.msg || assertion.Pos != tt.pos {
+				t.Errorf("%s with the %s backend fails with %q at %d, want %q at %d", tt.src, b, assertion.Msg, assertion.Pos, tt.msg, tt.pos)
+			}
+		}
+	}
+}
+
+func TestAssertArguments(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"assert(1)", "assert takes a bool condition, not int"},
+		{"assert(1 == 1, 2)", "assert takes a string message, not int"},
+		{"assert()", "assert takes a condition and a message"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewEvaluator(nil).Eval(e); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s fails with %v, want %q", tt.src, err, tt.want)
+		}
+	}
+	e, _ := Parse(`try(assert(1 > 2, "no"), 0)`)
+	if got, err := NewEvaluator(nil).Eval(e); err != nil || !got.Equal(IntValue(0)) {
+		t.Errorf("a failed assertion is not recovered by try: %s, %v", got, err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+func children(e Expression) []Expression {
+	switch v := e.(type) {
+	case *PrefixExpression:
+		return []Expression{v.rhs}
+	case *InfixExpression:
+		return []Expression{v.lhs, v.rhs}
+	case *CallExpression:
+		return v.args
+	}
+	return nil
+}
+
+// walk visits e and its descendants depth first, stopping descent into a
+// node's children when fn returns false.
+func walk(e Expression, depth int, fn func(e Expression, depth int) bool) {
+	if !fn(e, depth) {
+		return
+	}
+	for _, child := range children(e) {
+		walk(child, depth+1, fn)
+	}
+}
+
+type ExprStats struct {
+	Nodes     int
+	Depth     int
+	Operators map[string]int
+	Variables []string
+	Functions []string
+}
+
+// Stats summarises the size and shape of e.
+func Stats(e Expression) ExprStats {
+	stats := ExprStats{
+		Operators: make(map[string]int),
+		Variables: Variables(e),
+		Functions: Calls(e),
+	}
+	walk(e, 1, func(e Expression, depth int) bool {
+		stats.Nodes += 1
+		if depth > stats.Depth {
+			stats.Depth = depth
+		}
+		switch v := e.(type) {
+		case *PrefixExpression:
+			stats.Operators[v.op.String()] += 1
+		case *InfixExpression:
+			stats.Operators[v.op.String()] += 1
+		}
+		return true
+	})
+	return stats
+}
+
+// Variables returns the names of the variables e reads, each once, in order
+// of first appearance. Names read only where a let or the parameters of a
+// lambda bind them are local, and not included.
+func Variables(e Expression) []string {
+	variables, _ := references(e)
+	return variables
+}
+
+// Calls returns the names of the functions e calls, each once, in order of
+// first appearance. Like Variables it leaves out local functions, and it
+// leaves out the special forms let, try and fn.
+func Calls(e Expression) []string {
+	_, calls := references(e)
+	return calls
+}
+
+// references returns the global variables and functions e refers to.
+func references(e Expression) ([]string, []string) {
+	variables := make([]string, 0)
+	calls := make([]string, 0)
+	seen := make(map[string]bool)
+	called := make(map[string]bool)
+	walkScoped(e, func(e Expression, bound func(name string) bool) {
+		switch v := e.(type) {
+		case IdentifierToken:
+			if !bound(v.name) && !seen[v.name] {
+				seen[v.name] = true
+				variables = append(variables, v.name)
+			}
+		case *CallExpression:
+			if !isSpecialForm(v) && !bound(v.name) && !called[v.name] {
+				called[v.name] = true
+				calls = append(calls, v.name)
+			}
+		}
+	})
+	return variables, calls
+}
+
+// isSpecialForm reports whether e is a let, try or lambda rather than a call
+// of a function.
+func isSpecialForm(e *CallExpression) bool {
+	return isLet(e) || isTry(e) || isLambda(e)
+}
+
+// walkScoped visits e and its descendants depth first, telling fn whether a
+// name is bound there by an enclosing let or lambda. The name of a let is
+// not visited itself.
+func walkScoped(e Expression, fn func(e Expression, bound func(name string) bool)) {
+	scope := make(map[string]int)
+	bound := func(name string) bool {
+		return scope[name] > 0
+	}
+	var visit func(e Expression)
+	visit = func(e Expression) {
+		fn(e, bound)
+		if v, ok := e.(*CallExpression); ok {
+			if isLet(v) {
+				visit(v.args[1])
+				scope[letName(v)]++
+				visit(v.args[2])
+				scope[letName(v)]--
+				return
+			}
+			if isLambda(v) {
+				params := lambdaParams(v)
+				for _, param := range params {
+					scope[param.name]++
+				}
+				visit(lambdaBody(v))
+				for _, param := range params {
+					scope[param.name]--
+				}
+				return
+			}
+		}
+		for _, child := range children(e) {
+			visit(child)
+		}
+	}
+	visit(e)
+}
+
+// equalExpr reports whether a and b have the same structure, ignoring
+// source positions.
+func equalExpr(a Expression, b Expression) bool {
+	switch x := a.(type) {
+	case IntegerToken:
+		y, ok := b.(IntegerToken)
+		return ok && x.value == y.value
+	case FloatToken:
+		y, ok := b.(FloatToken)
+		return ok && math.Float64bits(x.value) == math.Float64bits(y.value)
+	case StringToken:
+		y, ok := b.(StringToken)
+		return ok && x.value == y.value
+	case Hole:
+		y, ok := b.(Hole)
+		return ok && x.index == y.index
+	case IdentifierToken:
+		y, ok := b.(IdentifierToken)
+		return ok && x.name == y.name
+	case *PrefixExpression:
+		y, ok := b.(*PrefixExpression)
+		return ok && x.op == y.op && equalExpr(x.rhs, y.rhs)
+	case *InfixExpression:
+		y, ok := b.(*InfixExpression)
+		return ok && x.op == y.op && equalExpr(x.lhs, y.lhs) && equalExpr(x.rhs, y.rhs)
+	case *CallExpression:
+		y, ok := b.(*CallExpression)
+		if !ok || x.name != y.name || len(x.args) != len(y.args) {
+			return false
+		}
+		for i := range x.args {
+			if !equalExpr(x.args[i], y.args[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// hashExpr hashes the structure of e so that equalExpr trees hash equally.
+func hashExpr(e Expression) uint64 {
+	h := fnv.New64a()
+	var writeNode func(e Expression)
+	writeNode = func(e Expression) {
+		switch v := e.(type) {
+		case IntegerToken:
+			h.Write([]byte{'i'})
+			binary.Write(h, binary.LittleEndian, v.value)
+		case FloatToken:
+			h.Write([]byte{'f'})
+			binary.Write(h, binary.LittleEndian, math.Float64bits(v.value))
+		case Hole:
+			h.Write([]byte{'h'})
+			binary.Write(h, binary.LittleEndian, int32(v.index))
+		case StringToken:
+			h.Write([]byte{'s'})
+			h.Write([]byte(v.value))
+			h.Write([]byte{0})
+		case IdentifierToken:
+			h.Write([]byte{'v'})
+			h.Write([]byte(v.name))
+			h.Write([]byte{0})
+		case *PrefixExpression:
+			h.Write([]byte{'p'})
+			h.Write([]byte{byte(v.op)})
+		case *InfixExpression:
+			h.Write([]byte{'b'})
+			h.Write([]byte{byte(v.op)})
+		case *CallExpression:
+			h.Write([]byte{'c'})
+			h.Write([]byte(v.name))
+			binary.Write(h, binary.LittleEndian, int32(len(v.args)))
+		}
+		for _, child := range children(e) {
+			writeNode(child)
+		}
+	}
+	writeNode(e)
+	return h.Sum64()
+}
+
+// isPure reports whether e can be evaluated without calling functions.
+func isPure(e Expression) bool {
+	pure := true
+	walk(e, 1, func(e Expression, depth int) bool {
+		switch v := e.(type) {
+		case *CallExpression:
+			pure = false
+		case *PrefixExpression:
+			pure = operatorFunction(v.op, false) == ""
+		case *InfixExpression:
+			pure = operatorFunction(v.op, true) == ""
+		}
+		return pure
+	})
+	return pure
+}
+
+// Holes returns the source offsets of the `_` placeholders in e, indexed by
+// hole number, so editors can highlight what is left to fill in.
+func Holes(e Expression) []int {
+	positions := make([]int, 0)
+	walk(e, 1, func(e Expression, depth int) bool {
+		if h, ok := e.(Hole); ok {
+			for len(positions) <= h.index {
+				positions = append(positions, 0)
+			}
+			positions[h.index] = h.pos
+		}
+		return true
+	})
+	return positions
+}
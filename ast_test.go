@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		src  string
+		want ExprStats
+	}{
+		{"1", ExprStats{Nodes: 1, Depth: 1, Operators: map[string]int{}, Variables: []string{}, Functions: []string{}}},
+		{"a + b * a - -c", ExprStats{
+			Nodes:     8,
+			Depth:     4,
+			Operators: map[string]int{"+": 1, "*": 1, "-": 2},
+			Variables: []string{"a", "b", "c"},
+			Functions: []string{},
+		}},
+		{"max(x, min(y, 2)) ^ 2", ExprStats{
+			Nodes:     7,
+			Depth:     4,
+			Operators: map[string]int{"^": 1},
+			Variables: []string{"x", "y"},
+			Functions: []string{"max", "min"},
+		}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Stats(e); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Stats(%s) = %+v, want %+v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestVariablesAndCalls(t *testing.T) {
+	tests := []struct {
+		src       string
+		variables []string
+		calls     []string
+	}{
+		{"1 + 2", nil, nil},
+		{"b + a * b", []string{"b", "a"}, nil},
+		{"f(x, g(y)) + f(x)", []string{"x", "y"}, []string{"f", "g"}},
+		{"{ t = a + 1; t * t }", []string{"a"}, nil},
+		{"{ t = t + 1; t * u }", []string{"t", "u"}, nil},
+		{"map(xs, fn(x, x * k))", []string{"xs", "k"}, []string{"map"}},
+		{"{ sq = fn(x, x * x); sq(n) + sqrt(n) }", []string{"n"}, []string{"sqrt"}},
+		{"try(1 / d, f(0))", []string{"d"}, []string{"f"}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Variables(e); !equalStrings(got, tt.variables) {
+			t.Errorf("Variables(%s) = %q, want %q", tt.src, got, tt.variables)
+		}
+		if got := Calls(e); !equalStrings(got, tt.calls) {
+			t.Errorf("Calls(%s) = %q, want %q", tt.src, got, tt.calls)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+// AuditKind says what an AuditEntry records.
+type AuditKind string
+
+const (
+	AuditRead     AuditKind = "read"
+	AuditOperator AuditKind = "operator"
+	AuditCall     AuditKind = "call"
+)
+
+// AuditEntry records one step of an audited evaluation: a variable read, an
+// operator applied to Args or a function called with Args. Name is the
+// variable, operator symbol or function, and Pos where it appears.
+type AuditEntry struct {
+	Kind   AuditKind `json:"kind"`
+	Name   string    `json:"name"`
+	Pos    int       `json:"pos"`
+	Args   []Value   `json:"args,omitempty"`
+	Result Value     `json:"result"`
+}
+
+// Audit evaluates e like Eval, also returning every variable read, operator
+// applied and function called, in the order evaluation made them. That
+// order depends only on e and the values read, so auditing a formula twice
+// against the same data gives the same log. On failure the steps completed
+// before the error are returned. Audited evaluations walk the tree, and
+// subexpressions reused by WithMemoization are not logged again.
+func (ev *Evaluator) Audit(e Expression) (Value, []AuditEntry, error) {
+	if ev.policy != nil {
+		if err := ev.policy.Check(e); err != nil {
+			return Value{}, nil, err
+		}
+	}
+	steps := make([]TraceStep, 0)
+	evaluation := ev.newEvaluation()
+	evaluation.steps = &steps
+	value, err := evaluation.eval(e)
+	log := make([]AuditEntry, len(steps))
+	for i, step := range steps {
+		log[i] = auditEntry(step)
+	}
+	return value, log, err
+}
+
+func auditEntry(step TraceStep) AuditEntry {
+	entry := AuditEntry{Pos: step.Expr.getPosition(), Args: step.Operands, Result: step.Result}
+	switch v := step.Expr.(type) {
+	case IdentifierToken:
+		entry.Kind, entry.Name = AuditRead, v.name
+	case *PrefixExpression:
+		entry.Kind, entry.Name = AuditOperator, v.op.String()
+	case *InfixExpression:
+		entry.Kind, entry.Name = AuditOperator, v.op.String()
+	case *CallExpression:
+		entry.Kind, entry.Name = AuditCall, v.name
+	}
+	return entry
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	env := Env{
+		"price": IntValue(10),
+		"qty":   IntValue(3),
+		"discount": FuncValue(func(args []Value) (Value, error) {
+			return IntValue(args[0].Int() - 5), nil
+		}),
+	}
+	value, log, err := NewEvaluator(env).Audit(mustParse(t, "discount(price * qty) - -1"))
+	if err != nil || !value.Equal(IntValue(26)) {
+		t.Fatalf("Audit = %s, %v, want 26", value, err)
+	}
+	want := []AuditEntry{
+		{Kind: AuditRead, Name: "price", Pos: 9, Result: IntValue(10)},
+		{Kind: AuditRead, Name: "qty", Pos: 17, Result: IntValue(3)},
+		{Kind: AuditOperator, Name: "*", Pos: 15, Args: []Value{IntValue(10), IntValue(3)}, Result: IntValue(30)},
+		{Kind: AuditCall, Name: "discount", Pos: 0, Args: []Value{IntValue(30)}, Result: IntValue(25)},
+		{Kind: AuditOperator, Name: "-", Pos: 24, Args: []Value{IntValue(1)}, Result: IntValue(-1)},
+		{Kind: AuditOperator, Name: "-", Pos: 22, Args: []Value{IntValue(25), IntValue(-1)}, Result: IntValue(26)},
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("log is\n%+v\nwant\n%+v", log, want)
+	}
+	_, again, _ := NewEvaluator(env).Audit(mustParse(t, "discount(price * qty) - -1"))
+	if !reflect.DeepEqual(again, log) {
+		t.Errorf("auditing again gives\n%+v\nwant\n%+v", again, log)
+	}
+}
+
+func TestAuditError(t *testing.T) {
+	_, log, err := NewEvaluator(Env{"a": IntValue(1)}).Audit(mustParse(t, "a + a / 0"))
+	if errorString(err) != "division by zero at column 7" {
+		t.Errorf("Audit(a + a / 0) = %v", err)
+	}
+	if len(log) != 2 || log[0].Name != "a" || log[1].Name != "a" {
+		t.Errorf("the steps before the error are %+v, want the two reads of a", log)
+	}
+	if _, log, err := NewEvaluator(nil, WithPolicy(Policy{DenyOperators: []string{"*"}})).Audit(mustParse(t, "1 + 2 * 3")); err == nil || log != nil {
+		t.Errorf("Audit with a failing policy = %+v, %v", log, err)
+	}
+}
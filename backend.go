@@ -0,0 +1,72 @@
+package main
+
+import "fmt"
+
+// Backend selects how expressions are evaluated: by walking the tree, or
+// by compiling them for the stack machine of Program or the register
+// machine of RegProgram. All three give the same results and errors.
+type Backend int
+
+const (
+	TreeBackend Backend = iota
+	StackBackend
+	RegisterBackend
+)
+
+var backendNames = []string{
+	TreeBackend:     "tree",
+	StackBackend:    "stack",
+	RegisterBackend: "register",
+}
+
+func (b Backend) String() string {
+	if b < 0 || int(b) >= len(backendNames) {
+		return fmt.Sprintf("backend(%d)", int(b))
+	}
+	return backendNames[b]
+}
+
+// treeProgram evaluates an expression by walking it, so the tree walker
+// can be used wherever a Compiled is.
+type treeProgram struct {
+	expr Expression
+}
+
+func (t treeProgram) Run(env Env) (Value, error) {
+	return evalExpression(t.expr, env)
+}
+
+// CompileBackend prepares e for repeated evaluation with backend. opts
+// apply to the compiling backends.
+func CompileBackend(e Expression, backend Backend, opts ...CompileOption) (Compiled, error) {
+	switch backend {
+	case TreeBackend:
+		return treeProgram{e}, nil
+	case StackBackend:
+		program, err := Compile(e, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return program, nil
+	case RegisterBackend:
+		program, err := CompileRegisters(e, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return program, nil
+	}
+	return nil, fmt.Errorf("unknown backend %s", backend)
+}
+
+// WithBackend makes Eval compile each expression for backend and run it,
+// for comparing backends. Memoization, holes, observers, output, step
+// limits and contexts given to EvalContext that can be done need the tree,
+// so an Evaluator using any of them walks it whatever the backend, as it
+// does expressions the backend cannot compile, such as ones with holes. To
+// run one expression many times, compile it once with CompileBackend
+// instead.
+func WithBackend(backend Backend) EvalOption {
+	return func(ev *Evaluator) {
+		ev.backend = backend
+	}
+}
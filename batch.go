@@ -0,0 +1,48 @@
+package main
+
+import "sync"
+
+// Compiled is an expression prepared for repeated evaluation, such as a
+// *Program.
+type Compiled interface {
+	Run(env Env) (Value, error)
+}
+
+type BatchResult struct {
+	Value Value
+	Err   error
+}
+
+// Batch evaluates every expression against env using at most parallelism
+// goroutines. Results are returned in the same order as exprs. env is only
+// read, so it is shared between all goroutines.
+func Batch(exprs []Compiled, env Env, parallelism int) []BatchResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > len(exprs) {
+		parallelism = len(exprs)
+	}
+	results := make([]BatchResult, len(exprs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				value, err := exprs[i].Run(env)
+				results[i] = BatchResult{
+					Value: value,
+					Err:   err,
+				}
+			}
+		}()
+	}
+	for i := range exprs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	sources := []string{"x + 1", "x / 0", "x * x", "y", "x - 1.5"}
+	exprs := make([]Compiled, len(sources))
+	for i, src := range sources {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exprs[i], err = Compile(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// x / 0 and y fail.
+	want := []Value{IntValue(5), {}, IntValue(16), {}, FloatValue(2.5)}
+	for _, parallelism := range []int{0, 1, 3, 100} {
+		results := Batch(exprs, Env{"x": IntValue(4)}, parallelism)
+		if len(results) != len(exprs) {
+			t.Fatalf("Batch with parallelism %d returned %d results, want %d", parallelism, len(results), len(exprs))
+		}
+		for i, result := range results {
+			if failed := i == 1 || i == 3; (result.Err != nil) != failed || !failed && !result.Value.Equal(want[i]) {
+				t.Errorf("with parallelism %d, %s = %s, %v, want %s", parallelism, sources[i], result.Value, result.Err, want[i])
+			}
+		}
+	}
+	if results := Batch(nil, nil, 4); len(results) != 0 {
+		t.Errorf("Batch of nothing returned %d results", len(results))
+	}
+}
+
+// concurrencyProbe is a Compiled that records how many of its kind run at
+// once.
+type concurrencyProbe struct {
+	mu      *sync.Mutex
+	running *int
+	most    *int
+}
+
+func (p concurrencyProbe) Run(env Env) (Value, error) {
+	p.mu.Lock()
+	*p.running++
+	if *p.running > *p.most {
+		*p.most = *p.running
+	}
+	p.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	p.mu.Lock()
+	*p.running--
+	p.mu.Unlock()
+	return IntValue(0), nil
+}
+
+func TestBatchParallelism(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	exprs := make([]Compiled, 20)
+	for i := range exprs {
+		exprs[i] = concurrencyProbe{mu: &mu, running: &running, most: &most}
+	}
+	Batch(exprs, nil, 3)
+	if most > 3 {
+		t.Errorf("%d expressions ran at once, want at most 3", most)
+	}
+}
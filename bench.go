@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// benchResult is what benchmarking a step measured over n iterations.
+type benchResult struct {
+	n       int
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+func reportBenchmark(w io.Writer, name string, result benchResult) {
+	n := uint64(result.n)
+	fmt.Fprintf(w, "%-24s %12d %12d ns/op %10d B/op %8d allocs/op\n",
+		name, result.n, result.elapsed.Nanoseconds()/int64(result.n), result.bytes/n, result.allocs/n)
+}
+
+// runBenchCommand implements the bench subcommand, which benchmarks
+// parsing, compiling and each way of evaluating an expression. The suite
+// comparing sizes of expression is run by go test -bench.
+func runBenchCommand(w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	iters := flags.Float64("iters", 0, "iterations per benchmark, as 1e6, instead of timing for about a second")
+	var positional []string
+	// Flags may follow the expression, as in bench "x * 2" --iters 1e6.
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: bench expression [-iters n]")
+	}
+	return benchExpression(w, positional[0], int(*iters))
+}
+
+// benchExpression reports the cost of parsing, compiling and evaluating
+// src, to help choose between tree-walking and compiled evaluation. Its
+// variables are bound to 1 and its functions return their first argument.
+// With iters above zero each step runs exactly that many times.
+func benchExpression(w io.Writer, src string, iters int) error {
+	expr, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	program, err := Compile(expr)
+	if err != nil {
+		return err
+	}
+	regProgram, err := CompileRegisters(expr)
+	if err != nil {
+		return err
+	}
+	env := make(Env)
+	for _, name := range Variables(expr) {
+		env[name] = IntValue(1)
+	}
+	for _, name := range Calls(expr) {
+		env[name] = FuncValue(func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return IntValue(1), nil
+			}
+			return args[0], nil
+		})
+	}
+	if _, err := evalExpression(expr, env); err != nil {
+		fmt.Fprintf(w, "note: evaluation fails with %v\n", err)
+	}
+	steps := []struct {
+		name string
+		fn   func(n int)
+	}{
+		{"parse", func(n int) {
+			for i := 0; i < n; i++ {
+				Parse(src)
+			}
+		}},
+		{"compile", func(n int) {
+			for i := 0; i < n; i++ {
+				Compile(expr)
+			}
+		}},
+		{"compile/register", func(n int) {
+			for i := 0; i < n; i++ {
+				CompileRegisters(expr)
+			}
+		}},
+		{"eval/tree", func(n int) {
+			for i := 0; i < n; i++ {
+				evalExpression(expr, env)
+			}
+		}},
+		{"eval/vm", func(n int) {
+			for i := 0; i < n; i++ {
+				program.Run(env)
+			}
+		}},
+		{"eval/regvm", func(n int) {
+			for i := 0; i < n; i++ {
+				regProgram.Run(env)
+			}
+		}},
+	}
+	for _, step := range steps {
+		reportBenchmark(w, step.name, measure(iters, step.fn))
+	}
+	return nil
+}
+
+// measure runs fn for iters iterations or, when iters is not positive,
+// for as many as take about a second, starting from one and growing the
+// count from the time taken so far.
+func measure(iters int, fn func(n int)) benchResult {
+	if iters > 0 {
+		return measureN(iters, fn)
+	}
+	n := 1
+	for {
+		result := measureN(n, fn)
+		if result.elapsed >= time.Second || n >= 1e9 {
+			return result
+		}
+		next := 100 * n
+		if result.elapsed > 0 {
+			next = int(1.2 * float64(n) * float64(time.Second) / float64(result.elapsed))
+		}
+		if next > 100*n {
+			next = 100 * n
+		}
+		if next <= n {
+			next = n + 1
+		}
+		n = next
+	}
+}
+
+func measureN(n int, fn func(n int)) benchResult {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	fn(n)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchResult{
+		n:       n,
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkSource builds an expression of roughly 12*n nodes mixing every
+// node kind.
+func benchmarkSource(n int) string {
+	terms := make([]string, n)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("x*%d - f(%d, y) / -(%d+z)", i%10, (i+3)%10, (i+7)%10)
+	}
+	return strings.Join(terms, " + ")
+}
+
+func benchmarkEnv() Env {
+	return Env{
+		"x": IntValue(3),
+		"y": IntValue(5),
+		"z": IntValue(2),
+		"f": FuncValue(func(args []Value) (Value, error) {
+			return applyInfix(AddOp, args[0], args[1])
+		}),
+	}
+}
+
+var benchmarkSizes = []struct {
+	name  string
+	terms int
+}{
+	{"small", 1},
+	{"medium", 20},
+	{"huge", 500},
+}
+
+// runSizes runs fn as a sub-benchmark for each size of expression.
+func runSizes(b *testing.B, fn func(b *testing.B, src string, expr Expression)) {
+	for _, size := range benchmarkSizes {
+		src := benchmarkSource(size.terms)
+		expr, err := Parse(src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(size.name, func(b *testing.B) {
+			b.ReportAllocs()
+			fn(b, src, expr)
+		})
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		l := &Lexer{}
+		for i := 0; i < b.N; i++ {
+			l.lex(src)
+		}
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		for i := 0; i < b.N; i++ {
+			Parse(src)
+		}
+	})
+}
+
+func BenchmarkParseArena(b *testing.B) {
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		arena := NewArena()
+		for i := 0; i < b.N; i++ {
+			if i%64 == 0 {
+				arena.Reset()
+			}
+			Parse(src, WithArena(arena))
+		}
+	})
+}
+
+func BenchmarkCompile(b *testing.B) {
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		for i := 0; i < b.N; i++ {
+			Compile(expr)
+		}
+	})
+}
+
+func BenchmarkEvalTree(b *testing.B) {
+	env := benchmarkEnv()
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		for i := 0; i < b.N; i++ {
+			evalExpression(expr, env)
+		}
+	})
+}
+
+func BenchmarkEvalVM(b *testing.B) {
+	env := benchmarkEnv()
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		program, err := Compile(expr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			program.Run(env)
+		}
+	})
+}
+
+func BenchmarkEvalRegisterVM(b *testing.B) {
+	env := benchmarkEnv()
+	runSizes(b, func(b *testing.B, src string, expr Expression) {
+		program, err := CompileRegisters(expr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			program.Run(env)
+		}
+	})
+}
+
+func TestBenchmarkSourceAgreesAcrossBackends(t *testing.T) {
+	env := benchmarkEnv()
+	for _, size := range benchmarkSizes {
+		expr, err := Parse(benchmarkSource(size.terms))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := evalExpression(expr, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, backend := range []Backend{StackBackend, RegisterBackend} {
+			program, err := CompileBackend(expr, backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := program.Run(env); err != nil || !got.Equal(want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", size.name, backend, got, err, want)
+			}
+		}
+	}
+}
+
+func TestBenchCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := runBenchCommand(&out, []string{"x * 2 + f(y)", "-iters", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []string{"parse", "compile", "compile/register", "eval/tree", "eval/vm", "eval/regvm"} {
+		if !strings.Contains(out.String(), step+" ") {
+			t.Errorf("no %s line in\n%s", step, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "allocs/op") {
+		t.Errorf("no allocs/op in\n%s", out.String())
+	}
+	if err := runBenchCommand(&out, nil); err == nil {
+		t.Error("bench with no expression succeeded")
+	}
+	if err := runBenchCommand(&out, []string{"1 +"}); err == nil {
+		t.Error("bench of a syntax error succeeded")
+	}
+}
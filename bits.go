@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// The bit functions treat integers as unsigned numbers of a width given as
+// their last argument, 8, 16, 32 or 64, which defaults to 64. Results wider
+// than 63 bits wrap to negative integers, as the arithmetic operators do.
+func init() {
+	addBuiltin("popcount", "popcount(x int[, width int]) int", docEntry{"the number of bits set in x", "popcount(255)"},
+		bitFunction("popcount", 0, func(x uint64, width int, _ []int64) uint64 {
+			return uint64(bits.OnesCount64(x))
+		}))
+	addBuiltin("clz", "clz(x int[, width int]) int", docEntry{"the number of leading zero bits of x", "clz(1, 8)"},
+		bitFunction("clz", 0, func(x uint64, width int, _ []int64) uint64 {
+			return uint64(bits.LeadingZeros64(x) - (64 - width))
+		}))
+	addBuiltin("ctz", "ctz(x int[, width int]) int", docEntry{"the number of trailing zero bits of x, or the width if x is 0", "ctz(8)"},
+		bitFunction("ctz", 0, func(x uint64, width int, _ []int64) uint64 {
+			if x == 0 {
+				return uint64(width)
+			}
+			return uint64(bits.TrailingZeros64(x))
+		}))
+	addBuiltin("rotl", "rotl(x, n int[, width int]) int", docEntry{"x rotated left by n bits", "rotl(129, 1, 8)"},
+		bitFunction("rotl", 1, func(x uint64, width int, args []int64) uint64 {
+			return rotate(x, width, args[0])
+		}))
+	addBuiltin("rotr", "rotr(x, n int[, width int]) int", docEntry{"x rotated right by n bits", "rotr(1, 1, 8)"},
+		bitFunction("rotr", 1, func(x uint64, width int, args []int64) uint64 {
+			return rotate(x, width, -args[0])
+		}))
+	addBuiltin("bswap", "bswap(x int[, width int]) int", docEntry{"x with the order of its bytes reversed", "bswap(258, 16)"},
+		bitFunction("bswap", 0, func(x uint64, width int, _ []int64) uint64 {
+			return bits.ReverseBytes64(x) >> (64 - width)
+		}))
+}
+
+// bitFunction checks the integer arguments of a bit function taking x and
+// extra more before the optional width, and applies fn to x truncated to
+// the width.
+func bitFunction(name string, extra int, fn func(x uint64, width int, args []int64) uint64) Function {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1+extra && len(args) != 2+extra {
+			return Value{}, fmt.Errorf("%s takes %d or %d arguments", name, 1+extra, 2+extra)
+		}
+		ints := make([]int64, len(args))
+		for i, arg := range args {
+			if arg.Kind() != IntKind {
+				return Value{}, fmt.Errorf("%s takes integers, not %s", name, arg.Kind())
+			}
+			ints[i] = arg.Int()
+		}
+		width := 64
+		if len(ints) == 2+extra {
+			switch ints[len(ints)-1] {
+			case 8, 16, 32, 64:
+				width = int(ints[len(ints)-1])
+			default:
+				return Value{}, fmt.Errorf("%s: width must be 8, 16, 32 or 64, not %d", name, ints[len(ints)-1])
+			}
+		}
+		x := uint64(ints[0]) & widthMask(width)
+		return IntValue(int64(fn(x, width, ints[1:1+extra]) & widthMask(width))), nil
+	}
+}
+
+func widthMask(width int) uint64 {
+	if width == 64 {
+		return ^uint64(0)
+	}
+	return 1<<uint(width) - 1
+}
+
+// rotate rotates the width-bit x left by n bits, or right if n is negative.
+func rotate(x uint64, width int, n int64) uint64 {
+	k := int(n % int64(width))
+	if k < 0 {
+		k += width
+	}
+	if k == 0 {
+		return x
+	}
+	return (x<<uint(k) | x>>uint(width-k)) & widthMask(width)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBitFunctions(t *testing.T) {
+	checkEvalCases(t, nil, []evalCase{
+		{src: "popcount(255)", want: IntValue(8)},
+		{src: "popcount(-1)", want: IntValue(64)},
+		{src: "popcount(-1, 8)", want: IntValue(8)},
+		{src: "clz(1, 8)", want: IntValue(7)},
+		{src: "clz(0, 16)", want: IntValue(16)},
+		{src: "clz(1)", want: IntValue(63)},
+		{src: "clz(256, 8)", want: IntValue(8)},
+		{src: "ctz(8)", want: IntValue(3)},
+		{src: "ctz(0, 16)", want: IntValue(16)},
+		{src: "rotl(129, 1, 8)", want: IntValue(3)},
+		{src: "rotl(1, 9, 8)", want: IntValue(2)},
+		{src: "rotl(1, -1, 8)", want: IntValue(128)},
+		{src: "rotr(1, 1, 8)", want: IntValue(128)},
+		{src: "rotr(1, 1)", want: IntValue(math.MinInt64)},
+		{src: "rotr(rotl(12345, 7, 32), 7, 32)", want: IntValue(12345)},
+		{src: "bswap(258, 16)", want: IntValue(513)},
+		{src: "bswap(1, 8)", want: IntValue(1)},
+		{src: "bswap(1)", want: IntValue(1 << 56)},
+		{src: "popcount()", err: "popcount takes 1 or 2 arguments"},
+		{src: "rotl(1)", err: "rotl takes 2 or 3 arguments"},
+		{src: "ctz(1.5)", err: "ctz takes integers, not float"},
+		{src: "rotl(1, 1, 12)", err: "rotl: width must be 8, 16, 32 or 64, not 12"},
+	})
+}
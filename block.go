@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A block, { t = a * b; t + t }, binds names to values for the expression
+// ending it. Its bindings are local: each is visible to the statements
+// after it, shadowing a variable of the same name, and none outlives the
+// block. A block is parsed as nested calls of let, let(t, a * b, t + t),
+// which can also be written so in source, with a block of one expression
+// being a group.
+func init() {
+	addBuiltin("let", "let(name, value, body) any", docEntry{"body evaluated with name bound to value", "let(t, a * b, t + t)"},
+		func(args []Value) (Value, error) {
+			return Value{}, fmt.Errorf("let takes a name, a value and a body")
+		})
+}
+
+// isLet reports whether e binds a local variable, which needs a name to
+// bind; any other call of let is an ordinary call, which the builtin
+// rejects.
+func isLet(e *CallExpression) bool {
+	if e.name != "let" || len(e.args) != 3 {
+		return false
+	}
+	_, ok := e.args[0].(IdentifierToken)
+	return ok
+}
+
+func letName(e *CallExpression) string {
+	return e.args[0].(IdentifierToken).name
+}
+
+// inlineLet returns the body of a let with its value in place of its name,
+// for converting to languages without local variables. The value is then
+// computed wherever the body reads it.
+func inlineLet(e *CallExpression) Expression {
+	return bind(e.args[2], map[string]Expression{letName(e): e.args[1]})
+}
+
+// scope is a chain of local variables, innermost first, consulted before
+// the environment.
+type scope struct {
+	name   string
+	value  Value
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s.value, true
+		}
+	}
+	return Value{}, false
+}
+
+// lookupLocal reads a variable from locals, and from env if it is not
+// local.
+func lookupLocal(name string, pos int, locals *scope, env Env) (Value, error) {
+	if value, ok := locals.lookup(name); ok {
+		return value, nil
+	}
+	if value, ok := env[name]; ok {
+		return value, nil
+	}
+	err := evalError(pos, ErrUndefinedVariable, CodeUndefinedVariable, name)
+	err.Suggestion = suggestVariable(name, locals, env)
+	return Value{}, err
+}
+
+// callLocal calls the function held by the local variable name, or the
+// function name if it is not local.
+func callLocal(name string, pos int, locals *scope, env Env, args []Value) (Value, error) {
+	fn, ok := locals.lookup(name)
+	if !ok {
+		return callFunction(name, pos, env, args)
+	}
+	if fn.Kind() != FuncKind {
+		return Value{}, evalError(pos, nil, CodeNotFunction, name)
+	}
+	return fn.Func()(args)
+}
+
+// evalLet evaluates the body of a let with its name bound. Memoized values
+// are keyed by expression, which means something else where a name is
+// bound, so the body is not memoized.
+func (ev *evaluation) evalLet(e *CallExpression) (Value, error) {
+	value, err := ev.eval(e.args[1])
+	if err != nil {
+		return Value{}, err
+	}
+	locals, memo := ev.locals, ev.memo
+	ev.locals = &scope{name: letName(e), value: value, parent: locals}
+	ev.memo = nil
+	defer func() {
+		ev.locals, ev.memo = locals, memo
+	}()
+	return ev.eval(e.args[2])
+}
+
+func (c *typeChecker) checkLet(e *CallExpression) Kind {
+	value := c.check(e.args[1])
+	schema := make(Schema, len(c.schema)+1)
+	for name, kind := range c.schema {
+		schema[name] = kind
+	}
+	schema[letName(e)] = value
+	outer := c.schema
+	c.schema = schema
+	defer func() {
+		c.schema = outer
+	}()
+	return c.check(e.args[2])
+}
+
+// compileLet binds the value of a let with OpBind for the body to load by
+// name, so the programs of try calls in the body see it too. Repeated
+// subexpressions are not shared with a body, where the same expression may
+// mean something else.
+func (c *compiler) compileLet(e *CallExpression) error {
+	if err := c.compile(e.args[1]); err != nil {
+		return err
+	}
+	c.emit(OpBind, c.name(letName(e)), 0, e.pos)
+	c.blocks++
+	err := c.compile(e.args[2])
+	c.blocks--
+	if err != nil {
+		return err
+	}
+	c.emit(OpUnbind, 0, 0, e.pos)
+	return nil
+}
+
+// compileLet compiles a let for the register machine, where the body reads
+// the value's operand directly. RegBind also binds it by name for the
+// programs of try calls in the body. A constant value is read as a new
+// constant each time instead, since folding replaces the last constants
+// added.
+func (c *regCompiler) compileLet(e *CallExpression) (int, error) {
+	value, err := c.compile(e.args[1])
+	if err != nil {
+		return 0, err
+	}
+	name := letName(e)
+	c.emit(RegInstruction{Op: RegBind, A: c.name(name), B: value, Pos: e.pos})
+	outer, shadowed := c.variables[name]
+	outerConstant, shadowedConstant := c.constants[name]
+	if value < 0 {
+		c.constants[name] = c.program.Consts[-1-value]
+		delete(c.variables, name)
+	} else {
+		c.variables[name] = value
+		delete(c.constants, name)
+	}
+	pinned := c.pinned[value]
+	c.pinned[value] = true
+	c.blocks++
+	result, err := c.compile(e.args[2])
+	c.blocks--
+	if shadowed {
+		c.variables[name] = outer
+	} else {
+		delete(c.variables, name)
+	}
+	if shadowedConstant {
+		c.constants[name] = outerConstant
+	} else {
+		delete(c.constants, name)
+	}
+	c.pinned[value] = pinned
+	if err != nil {
+		return 0, err
+	}
+	if result != value {
+		c.release(value)
+	}
+	c.emit(RegInstruction{Op: RegUnbind, Pos: e.pos})
+	return result, nil
+}
+
+// scanBlock appends the tokens of the block at input[i:to] to tokenArray
+// and returns its length, or 0 if there is no block there.
+func (l *Lexer) scanBlock(input string, i int, to int, tokenArray TokenArray) (TokenArray, int) {
+	if input[i] != '{' {
+		return tokenArray, 0
+	}
+	end := closingBrace(input, i+1, to)
+	if end < 0 {
+		panic(SyntaxError{Pos: i, End: to, Msg: "unterminated block"})
+	}
+	statements := splitStatements(input, i+1, end)
+	bindings := 0
+	for _, s := range statements[:len(statements)-1] {
+		name, value := binding(input, s[0], s[1])
+		tokenArray = append(tokenArray,
+			Token{Kind: Identifier, Lit: "let", Pos: s[0], End: s[1]},
+			Token{Kind: Operand, Lit: "(", Op: LParenOp, Pos: s[0], End: s[1]},
+			Token{Kind: Identifier, Lit: input[name : name+identifierLength(input[name:])], Pos: name, End: name + identifierLength(input[name:])},
+			Token{Kind: Operand, Lit: ",", Op: CommaOp, Pos: value - 1, End: value})
+		tokenArray = l.scan(input, value, s[1], tokenArray)
+		tokenArray = append(tokenArray, Token{Kind: Operand, Lit: ",", Op: CommaOp, Pos: s[1], End: s[1] + 1})
+		bindings++
+	}
+	last := statements[len(statements)-1]
+	if strings.TrimSpace(input[last[0]:last[1]]) == "" {
+		panic(SyntaxError{Pos: last[0], End: end + 1, Msg: "block must end with an expression"})
+	}
+	if bindings == 0 {
+		tokenArray = append(tokenArray, Token{Kind: Operand, Lit: "(", Op: LParenOp, Pos: i, End: i + 1})
+		bindings = 1
+	}
+	tokenArray = l.scan(input, last[0], last[1], tokenArray)
+	for ; bindings > 0; bindings-- {
+		tokenArray = append(tokenArray, Token{Kind: Operand, Lit: ")", Op: RParenOp, Pos: end, End: end + 1})
+	}
+	return tokenArray, end + 1 - i
+}
+
+// splitStatements returns the bounds of the statements separated by
+// semicolons in input[from:to], outside nested groups, blocks and strings.
+func splitStatements(input string, from int, to int) [][2]int {
+	var statements [][2]int
+	depth := 0
+	start := from
+	for k := from; k < to; k++ {
+		switch input[k] {
+		case '"':
+			for k++; k < to && input[k] != '"'; k++ {
+			}
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ';':
+			if depth == 0 {
+				statements = append(statements, [2]int{start, k})
+				start = k + 1
+			}
+		}
+	}
+	return append(statements, [2]int{start, to})
+}
+
+// binding returns the offsets of the name and value of the statement
+// name = value at input[from:to].
+func binding(input string, from int, to int) (name int, value int) {
+	name = from
+	for name < to && strings.IndexByte(" \t\r\n", input[name]) >= 0 {
+		name++
+	}
+	n := identifierLength(input[name:to])
+	eq := name + n
+	for eq < to && strings.IndexByte(" \t\r\n", input[eq]) >= 0 {
+		eq++
+	}
+	if n == 0 || input[name:name+n] == "_" || eq >= to || input[eq] != '=' || strings.HasPrefix(input[eq:to], "=~") || strings.HasPrefix(input[eq:to], "==") || strings.HasPrefix(input[eq:to], "=>") {
+		panic(SyntaxError{Pos: name, End: to, Msg: "expected name = value in block"})
+	}
+	if strings.TrimSpace(input[eq+1:to]) == "" {
+		panic(SyntaxError{Pos: name, End: to, Msg: "missing value for '" + input[name:name+n] + "' in block"})
+	}
+	return name, eq + 1
+}
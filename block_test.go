@@ -0,0 +1,100 @@
+package main
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestBindLet(t *testing.T) {
+	tests := []struct {
+		src      string
+		bindings map[string]string
+		want     string
+	}{
+		{"let(t, a * b, t + t)", map[string]string{"a": "t + 1", "t": "99"}, "let(t, (t + 1) * b, t + t)"},
+		{"let(t, 1, t + a)", map[string]string{"a": "t"}, "let(t_1, 1, t_1 + t)"},
+		{"{ x = a; x * a }", map[string]string{"a": "2", "x": "3"}, "let(x, 2, x * 2)"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bindings := make(map[string]Expression)
+		for name, src := range tt.bindings {
+			if bindings[name], err = Parse(src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := Format(Bind(e, bindings)); got != tt.want {
+			t.Errorf("Bind(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestLetRules(t *testing.T) {
+	rs, err := NewRuleSet([]Rule{
+		{Name: "area", Expr: "{ t = w * h; t > 10 }"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.Match(map[string]interface{}{"w": 3.0, "h": 4.0}); !equalStrings(got, []string{"area"}) {
+		t.Errorf("Match = %v, want [area]", got)
+	}
+	if got := rs.Match(map[string]interface{}{"w": 3.0, "h": 3.0}); got != nil {
+		t.Errorf("Match = %v, want none", got)
+	}
+}
+
+func TestLetTAC(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"{ t = a * b; t + t }", []string{"t1 = a * b", "t2 = t1 + t1"}},
+		{"{ t = 2; u = t * x; { t = u; t - u } }", []string{"t1 = 2 * x", "t2 = t1 - t1"}},
+		{"{ t = a; t }", []string{"t1 = a"}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		steps, err := TAC(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(steps))
+		for i, step := range steps {
+			got[i] = step.String()
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("TAC(%s) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestLetExports(t *testing.T) {
+	e, err := Parse("{ t = a + b; t * t }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := SQL(e, PostgreSQL); err != nil || got != `("a" + "b") * ("a" + "b")` {
+		t.Errorf("SQL = %q, %v", got, err)
+	}
+	expr, err := GoAST(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := types.ExprString(expr); got != "(a + b) * (a + b)" {
+		t.Errorf("GoAST = %s", got)
+	}
+	if got, err := JS(e); err != nil || got != "((t) => t * t)(a + b)" {
+		t.Errorf("JS = %q, %v", got, err)
+	}
+	if got := MathML(e); strings.Contains(got, "let") {
+		t.Errorf("MathML = %s", got)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cacheEntry struct {
+	src  string
+	expr Expression
+	err  error
+	// program is expr compiled for the stack machine, or nil if it could
+	// not be compiled.
+	program *Program
+}
+
+// Cache keeps the parse results of the most recently used size sources,
+// and their compiled programs, so formulas evaluated repeatedly are only
+// parsed and compiled once. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+	metrics Metrics
+}
+
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Parse returns the cached parse of src, parsing and caching it on a miss.
+// Syntax errors are cached too.
+func (c *Cache) Parse(src string) (Expression, error) {
+	entry := c.lookup(src)
+	return entry.expr, entry.err
+}
+
+// Eval evaluates src against vars with the cached program, compiled on a
+// miss for the stack machine. Expressions that cannot be compiled, such as
+// ones with holes, are evaluated by walking the tree.
+func (c *Cache) Eval(src string, vars Env) (Value, error) {
+	entry := c.lookup(src)
+	if entry.err != nil {
+		return Value{}, entry.err
+	}
+	if entry.program != nil {
+		return entry.program.Run(vars)
+	}
+	return evalExpression(entry.expr, vars)
+}
+
+// lookup returns the entry of src, parsing and compiling it on a miss.
+func (c *Cache) lookup(src string) *cacheEntry {
+	c.mu.Lock()
+	if element, ok := c.entries[src]; ok {
+		c.hits += 1
+		c.order.MoveToFront(element)
+		entry := element.Value.(*cacheEntry)
+		c.mu.Unlock()
+		if c.metrics != nil {
+			c.metrics.Count(MetricCacheHits, 1)
+		}
+		return entry
+	}
+	c.misses += 1
+	c.mu.Unlock()
+
+	entry := &cacheEntry{src: src}
+	if c.metrics != nil {
+		c.metrics.Count(MetricCacheMisses, 1)
+		entry.expr, entry.err = Parse(src, WithParseMetrics(c.metrics))
+	} else {
+		entry.expr, entry.err = Parse(src)
+	}
+	if entry.err == nil {
+		entry.program, _ = Compile(entry.expr)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[src]; ok {
+		return element.Value.(*cacheEntry)
+	}
+	c.entries[src] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).src)
+	}
+	return entry
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: c.order.Len(),
+	}
+}
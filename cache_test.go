@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestCacheEval(t *testing.T) {
+	c := NewCache(2)
+	env := Env{"x": IntValue(4)}
+	for i := 0; i < 3; i++ {
+		got, err := c.Eval("x * 2 + 1", env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(IntValue(9)) {
+			t.Errorf("Eval = %s, want 9", got)
+		}
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Stats() = %+v, want 2 hits, 1 miss and 1 entry", stats)
+	}
+}
+
+func TestCacheCompilesOnce(t *testing.T) {
+	c := NewCache(1)
+	if _, err := c.Eval("x + 1", Env{"x": IntValue(1)}); err != nil {
+		t.Fatal(err)
+	}
+	entry := c.lookup("x + 1")
+	if entry.program == nil {
+		t.Fatal("no program cached for x + 1")
+	}
+	if again := c.lookup("x + 1"); again.program != entry.program {
+		t.Error("program compiled again on a hit")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2)
+	for _, src := range []string{"1", "2", "1", "3"} {
+		if _, err := c.Parse(src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.entries["2"]; ok {
+		t.Error("2 was not evicted")
+	}
+	for _, src := range []string{"1", "3"} {
+		if _, ok := c.entries[src]; !ok {
+			t.Errorf("%s was evicted", src)
+		}
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	c := NewCache(4)
+	if _, err := c.Eval("1 +", nil); err == nil {
+		t.Error("Eval(1 +) succeeded")
+	}
+	if _, err := c.Eval("1 +", nil); err == nil {
+		t.Error("cached Eval(1 +) succeeded")
+	}
+	if stats := c.Stats(); stats.Hits != 1 {
+		t.Errorf("syntax error not cached: %+v", stats)
+	}
+	if _, err := c.Eval("y + 1", Env{}); err == nil {
+		t.Error("Eval(y + 1) with no y succeeded")
+	}
+	// Holes cannot be compiled, so they are evaluated by the tree walker.
+	if _, err := c.Eval("_ + 1", Env{}); err == nil {
+		t.Error("Eval(_ + 1) succeeded")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exit codes of the command, so scripts can tell why an input failed.
+const (
+	exitOK         = 0
+	exitParseError = 1
+	exitEvalError  = 2
+	exitIOError    = 3
+)
+
+// flagsExitCode is the exit code for a failure to parse a command's flags,
+// which is success when it is a request for the usage, as with -h, that
+// the flag package has already printed.
+func flagsExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitParseError
+}
+
+// jsonOutput is what --json prints for each input: the result, or the
+// error that prevented one.
+type jsonOutput struct {
+	Input  string     `json:"input,omitempty"`
+	Result *Value     `json:"result,omitempty"`
+	Error  *jsonError `json:"error,omitempty"`
+}
+
+// jsonError describes a failed input. Code is "parse", "eval", or "input"
+// for data that could not be read, and Pos is the byte offset the error
+// refers to when it has one. Suggestion is the name probably meant by a
+// missing one.
+type jsonError struct {
+	Code       string `json:"code"`
+	Pos        *int   `json:"pos,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// runEval implements the default mode, evaluating a line from in and
+// returning the exit code. Variables are set with --vars and --var, and
+// with --env read from the environment. With --json every line of in is
+// evaluated and printed as a JSON object; with --quiet errors are only
+// reported through the exit code, or the objects --json prints. When
+// several lines fail the exit code is that of the first.
+func runEval(args []string, in io.Reader, out io.Writer) int {
+	flags := flag.NewFlagSet("prattcalc", flag.ContinueOnError)
+	flags.SetOutput(out)
+	explain := flags.Bool("explain", false, "print each evaluation step before the result")
+	tac := flags.Bool("tac", false, "print the expression lowered to three-address code instead of its value")
+	pn := flags.Bool("pn", false, "print the expression in Polish (prefix) notation instead of its value")
+	bytecode := flags.Bool("bytecode", false, "print the stack machine code for the expression instead of its value")
+	noFold := flags.Bool("no-fold", false, "with --bytecode, compile constant subexpressions as written")
+	noCSE := flags.Bool("no-cse", false, "with --bytecode, compile repeated subexpressions each time they occur")
+	dryRun := flags.Bool("dry-run", false, "print the kind of value the expression would have, checking it against the variables without calling functions")
+	quiet := flags.Bool("quiet", false, "print no error messages")
+	asJSON := flags.Bool("json", false, "evaluate every line, printing one JSON object per line")
+	vars := addVarFlags(flags)
+	envRefs := flags.Bool("env", false, "let expressions read environment variables as env.NAME or ${NAME}")
+	if err := flags.Parse(args); err != nil {
+		return flagsExitCode(err)
+	}
+	env, err := vars.load()
+	if err != nil {
+		if !*quiet {
+			fmt.Fprintln(out, err)
+		}
+		return varsExitCode(err)
+	}
+	reader := bufio.NewReader(in)
+	if !*asJSON {
+		src, err := reader.ReadString('\n')
+		if err != nil && src == "" {
+			if !*quiet {
+				fmt.Fprintf(out, "reading input: %v\n", err)
+			}
+			return exitIOError
+		}
+		if *tac {
+			return printLowered(src, *envRefs, *quiet, out, func(e Expression) (string, error) {
+				steps, err := TAC(e)
+				if err != nil {
+					return "", err
+				}
+				var sb strings.Builder
+				for _, step := range steps {
+					sb.WriteString(step.String())
+					sb.WriteByte('\n')
+				}
+				return sb.String(), nil
+			})
+		}
+		if *pn {
+			return printLowered(src, *envRefs, *quiet, out, func(e Expression) (string, error) {
+				return PolishNotation(e) + "\n", nil
+			})
+		}
+		if *bytecode {
+			var opts []CompileOption
+			if *noFold {
+				opts = append(opts, WithoutConstantFolding())
+			}
+			if *noCSE {
+				opts = append(opts, WithoutCSE())
+			}
+			return printLowered(src, *envRefs, *quiet, out, func(e Expression) (string, error) {
+				program, err := Compile(e, opts...)
+				if err != nil {
+					return "", err
+				}
+				return program.Disassemble(), nil
+			})
+		}
+		if *dryRun {
+			return printLowered(src, *envRefs, *quiet, out, func(e Expression) (string, error) {
+				kind, typeErrors := DryRun(e, env, nil)
+				if len(typeErrors) > 0 {
+					return "", typeErrors[0]
+				}
+				return kind.String() + "\n", nil
+			})
+		}
+		return evalLine(src, env, *envRefs, *explain, *quiet, out)
+	}
+	encoder := json.NewEncoder(out)
+	code := exitOK
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return exitIOError
+		}
+		if src := strings.TrimSpace(line); src != "" {
+			output, lineCode := evalJSON(src, env, *envRefs)
+			if err := encoder.Encode(output); err != nil {
+				return exitIOError
+			}
+			if code == exitOK {
+				code = lineCode
+			}
+		}
+		if err == io.EOF {
+			return code
+		}
+	}
+}
+
+// evalLine evaluates src for the plain output mode.
+func evalLine(src string, env Env, envRefs, explain, quiet bool, out io.Writer) int {
+	report := func(err error, code int) int {
+		if !quiet {
+			fmt.Fprintln(out, err)
+		}
+		return code
+	}
+	parsed, err := parseInput(src, env, envRefs)
+	if err != nil {
+		return report(err, exitParseError)
+	}
+	if explain {
+		steps, result, err := Trace(parsed, env)
+		for _, step := range steps {
+			fmt.Fprintln(out, step)
+		}
+		if err != nil {
+			return report(err, exitEvalError)
+		}
+		fmt.Fprintln(out, result)
+		return exitOK
+	}
+	result, err := NewEvaluator(env, WithOutput(out)).Eval(parsed)
+	if err != nil {
+		return report(err, exitEvalError)
+	}
+	fmt.Fprintln(out, result)
+	return exitOK
+}
+
+// printLowered prints what lower turns the parsed src into, for the flags
+// showing how an expression is compiled.
+func printLowered(src string, envRefs, quiet bool, out io.Writer, lower func(Expression) (string, error)) int {
+	var opts []ParseOption
+	if envRefs {
+		opts = append(opts, WithEnvReferences())
+	}
+	parsed, err := Parse(src, opts...)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintln(out, err)
+		}
+		return exitParseError
+	}
+	lowered, err := lower(parsed)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintln(out, err)
+		}
+		return exitParseError
+	}
+	fmt.Fprint(out, lowered)
+	return exitOK
+}
+
+func evalJSON(src string, env Env, envRefs bool) (jsonOutput, int) {
+	output := jsonOutput{Input: src}
+	parsed, err := parseInput(src, env, envRefs)
+	if err != nil {
+		output.Error = newJSONError("parse", err)
+		return output, exitParseError
+	}
+	result, err := evalExpression(parsed, env)
+	if err != nil {
+		output.Error = newJSONError("eval", err)
+		return output, exitEvalError
+	}
+	output.Result = &result
+	return output, exitOK
+}
+
+// newJSONError splits the position out of the errors that carry one, so
+// the message does not repeat it as a column.
+func newJSONError(code string, err error) *jsonError {
+	var syntaxErr SyntaxError
+	var policyErr PolicyError
+	var typeErr TypeError
+	var assertionErr AssertionError
+	var evalErr EvalError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &jsonError{Code: code, Pos: &syntaxErr.Pos, Message: syntaxErr.Msg}
+	case errors.As(err, &policyErr):
+		return &jsonError{Code: code, Pos: &policyErr.Pos, Message: policyErr.Msg}
+	case errors.As(err, &typeErr):
+		return &jsonError{Code: code, Pos: &typeErr.Pos, Message: typeErr.Msg, Suggestion: typeErr.Suggestion}
+	case errors.As(err, &assertionErr):
+		return &jsonError{Code: code, Pos: &assertionErr.Pos, Message: assertionErr.Msg}
+	case errors.As(err, &evalErr):
+		return &jsonError{Code: code, Pos: &evalErr.Pos, Message: evalErr.Msg, Suggestion: evalErr.Suggestion}
+	}
+	return &jsonError{Code: code, Message: err.Error()}
+}
+
+// parseInput parses src, binding the environment variables it refers to in
+// env when envRefs is set.
+func parseInput(src string, env Env, envRefs bool) (Expression, error) {
+	if !envRefs {
+		return Parse(src)
+	}
+	parsed, err := Parse(src, WithEnvReferences())
+	if err == nil {
+		BindEnvVars(parsed, env)
+	}
+	return parsed, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cliCommands are the commands that take flags, each run with empty input.
+var cliCommands = []struct {
+	name string
+	run  func(args []string, out *bytes.Buffer) int
+}{
+	{"eval", func(args []string, out *bytes.Buffer) int {
+		return runEval(args, strings.NewReader(""), out)
+	}},
+	{"csv", func(args []string, out *bytes.Buffer) int {
+		return runCSV(args, strings.NewReader(""), out, out)
+	}},
+	{"jsonl", func(args []string, out *bytes.Buffer) int {
+		return runJSONLines(args, strings.NewReader(""), out, out)
+	}},
+	{"snapshot", func(args []string, out *bytes.Buffer) int {
+		return runSnapshot(args, out)
+	}},
+	{"run", func(args []string, out *bytes.Buffer) int {
+		return runScriptCommand(args, out)
+	}},
+}
+
+func TestHelpExitsZero(t *testing.T) {
+	for _, command := range cliCommands {
+		for _, flag := range []string{"-h", "--help"} {
+			var out bytes.Buffer
+			if code := command.run([]string{flag}, &out); code != exitOK {
+				t.Errorf("%s %s exits with %d, want %d", command.name, flag, code, exitOK)
+			}
+			if !strings.Contains(out.String(), "Usage") {
+				t.Errorf("%s %s printed %q, want the usage", command.name, flag, out.String())
+			}
+		}
+		var out bytes.Buffer
+		if code := command.run([]string{"-no-such-flag"}, &out); code != exitParseError {
+			t.Errorf("%s -no-such-flag exits with %d, want %d", command.name, code, exitParseError)
+		}
+	}
+}
+
+func TestEvalExitCodes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+		out   string
+	}{
+		{"1 + 2\n", exitOK, "3"},
+		{"1 +\n", exitParseError, "unexpected end"},
+		{"1 / 0\n", exitEvalError, "division by zero"},
+		{"", exitIOError, "reading input"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if code := runEval(nil, strings.NewReader(tt.input), &out); code != tt.want {
+			t.Errorf("%q exits with %d, want %d", tt.input, code, tt.want)
+		}
+		if !strings.Contains(out.String(), tt.out) {
+			t.Errorf("%q printed %q, want it to contain %q", tt.input, out.String(), tt.out)
+		}
+	}
+}
+
+func TestVarsFileExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`[1, 2]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want int
+	}{
+		{filepath.Join(dir, "missing.json"), exitIOError},
+		{dir, exitIOError},
+		{bad, exitParseError},
+	}
+	for _, command := range cliCommands {
+		if command.name == "snapshot" {
+			continue
+		}
+		for _, tt := range tests {
+			var out bytes.Buffer
+			if code := command.run([]string{"-vars", tt.path}, &out); code != tt.want {
+				t.Errorf("%s -vars %s exits with %d, want %d", command.name, tt.path, code, tt.want)
+			}
+			if !strings.Contains(out.String(), tt.path) || strings.Contains(out.String(), "Usage") {
+				t.Errorf("%s -vars %s printed %q, want the error without the usage", command.name, tt.path, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Comparisons, the logical operators and the shifts. == and != compare
+// values of the same kind as Value.Equal does, with integers and floats
+// compared as floats; <, <=, > and >= order numbers and strings. && and ||
+// take bools and short-circuit, so every backend evaluates the right
+// operand only when the left one does not decide the result.
+func init() {
+	compare := func(fn func(c int) bool) InfixImpl {
+		return func(lhs, rhs Value) (Value, error) {
+			c, ordered := compareValues(lhs, rhs)
+			return BoolValue(ordered && fn(c)), nil
+		}
+	}
+	for _, kind := range []Kind{IntKind, FloatKind, BoolKind, StringKind, ListKind, MapKind} {
+		setInfix(EqOp, kind, kind, BoolKind, func(lhs, rhs Value) (Value, error) {
+			return BoolValue(lhs.Equal(rhs)), nil
+		})
+		setInfix(NeOp, kind, kind, BoolKind, func(lhs, rhs Value) (Value, error) {
+			return BoolValue(!lhs.Equal(rhs)), nil
+		})
+	}
+	for _, kind := range []Kind{IntKind, FloatKind, StringKind} {
+		setInfix(LtOp, kind, kind, BoolKind, compare(func(c int) bool { return c < 0 }))
+		setInfix(LeOp, kind, kind, BoolKind, compare(func(c int) bool { return c <= 0 }))
+		setInfix(GtOp, kind, kind, BoolKind, compare(func(c int) bool { return c > 0 }))
+		setInfix(GeOp, kind, kind, BoolKind, compare(func(c int) bool { return c >= 0 }))
+	}
+	setInfix(AndOp, BoolKind, BoolKind, BoolKind, func(lhs, rhs Value) (Value, error) {
+		return BoolValue(lhs.Bool() && rhs.Bool()), nil
+	})
+	setInfix(OrOp, BoolKind, BoolKind, BoolKind, func(lhs, rhs Value) (Value, error) {
+		return BoolValue(lhs.Bool() || rhs.Bool()), nil
+	})
+	setPrefix(NotOp, BoolKind, BoolKind, func(rhs Value) (Value, error) {
+		return BoolValue(!rhs.Bool()), nil
+	})
+	shift := func(fn func(a int64, n uint) int64) InfixImpl {
+		return func(lhs, rhs Value) (Value, error) {
+			if rhs.Int() < 0 {
+				return Value{}, fmt.Errorf("negative shift count %d", rhs.Int())
+			}
+			return IntValue(fn(lhs.Int(), uint(rhs.Int()))), nil
+		}
+	}
+	setInfix(ShlOp, IntKind, IntKind, IntKind, shift(func(a int64, n uint) int64 { return a << n }))
+	setInfix(ShrOp, IntKind, IntKind, IntKind, shift(func(a int64, n uint) int64 { return a >> n }))
+}
+
+// compareValues orders two ints, two floats or two strings, returning a
+// negative number, zero or a positive number as lhs is less than, equal to
+// or greater than rhs. NaN is unordered, so no ordering holds for it.
+func compareValues(lhs Value, rhs Value) (int, bool) {
+	switch lhs.Kind() {
+	case IntKind:
+		a, b := lhs.Int(), rhs.Int()
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	case StringKind:
+		return strings.Compare(lhs.Str(), rhs.Str()), true
+	}
+	a, b := lhs.Float(), rhs.Float()
+	switch {
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	case a == b:
+		return 0, true
+	}
+	return 0, false
+}
+
+// isShortCircuit reports whether e is an && or || evaluated natively,
+// whose right operand is evaluated only if the left one is a bool that does
+// not decide the result.
+func isShortCircuit(e *InfixExpression) bool {
+	return (e.op == AndOp || e.op == OrOp) && operatorFunction(e.op, true) == ""
+}
+
+// shortCircuits reports whether lhs, the value of the left operand of op,
+// decides the result of an && or ||, which is then lhs.
+func shortCircuits(op OpKind, lhs Value) bool {
+	return lhs.Kind() == BoolKind && lhs.Bool() == (op == OrOp)
+}
+
+// compileShortCircuit compiles the right operand of && or || as a separate
+// program run by OpLogic only when the left operand does not decide the
+// result, as try's arguments are.
+func (c *compiler) compileShortCircuit(e *InfixExpression) error {
+	if err := c.compile(e.lhs); err != nil {
+		return err
+	}
+	rhs, err := compileWith(e.rhs, c.compileSettings)
+	if err != nil {
+		return err
+	}
+	c.program.Lazy = append(c.program.Lazy, rhs)
+	c.emit(OpLogic, len(c.program.Lazy)-1, int(e.op), e.pos)
+	return nil
+}
+
+// compileShortCircuit compiles && or || for the register machine as
+// compiler.compileShortCircuit does.
+func (c *regCompiler) compileShortCircuit(e *InfixExpression) (int, error) {
+	lhs, err := c.compile(e.lhs)
+	if err != nil {
+		return 0, err
+	}
+	rhs, err := compileRegistersWith(e.rhs, c.compileSettings)
+	if err != nil {
+		return 0, err
+	}
+	c.program.Lazy = append(c.program.Lazy, rhs)
+	c.release(lhs)
+	return c.emit(RegInstruction{Op: RegLogic, Dst: c.alloc(), A: len(c.program.Lazy) - 1, B: lhs, C: int(e.op), Pos: e.pos}), nil
+}
+
+// applyShortCircuit applies && or || to lhs and, unless lhs decides the
+// result, to the value of rhs.
+func applyShortCircuit(op OpKind, lhs Value, rhs func() (Value, error), pos int) (Value, error) {
+	if shortCircuits(op, lhs) {
+		return lhs, nil
+	}
+	value, err := rhs()
+	if err != nil {
+		return Value{}, err
+	}
+	return applyInfixAt(op, lhs, value, pos)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"go/printer"
+	"go/token"
+	"math"
+	"strings"
+	"testing"
+)
+
+var allBackends = []Backend{TreeBackend, StackBackend, RegisterBackend}
+
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	env := Env{
+		"x":     IntValue(7),
+		"seven": FloatValue(7),
+		"y":     FloatValue(2.5),
+		"s":     StringValue("abc"),
+		"yes":   BoolValue(true),
+		"no":    BoolValue(false),
+		"nan":   FloatValue(math.NaN()),
+	}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"x == 7", BoolValue(true)},
+		{"x != 7", BoolValue(false)},
+		{"x == seven", BoolValue(true)},
+		{"y < x", BoolValue(true)},
+		{"x <= 7", BoolValue(true)},
+		{"x > 7", BoolValue(false)},
+		{"x >= y", BoolValue(true)},
+		{`s < "abd"`, BoolValue(true)},
+		{`s == "abc"`, BoolValue(true)},
+		{"yes == no", BoolValue(false)},
+		{"nan == nan", BoolValue(false)},
+		{"nan != nan", BoolValue(true)},
+		{"nan <= nan", BoolValue(false)},
+		{"yes && no", BoolValue(false)},
+		{"yes || no", BoolValue(true)},
+		{"!no", BoolValue(true)},
+		{"!(x > 0) || s == \"abc\"", BoolValue(true)},
+		{"1 << 10", IntValue(1024)},
+		{"-16 >> 2", IntValue(-4)},
+		{"1 << 64", IntValue(0)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestComparisonErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`1 == "1"`, "operator '==' not defined for int and string"},
+		{`"a" < 1`, "operator '<' not defined for string and int"},
+		{"1 && 2", "operator '&&' not defined for int and int"},
+		{"!1", "operator '!' not defined for int"},
+		{"1 << -1", "negative shift count -1"},
+		{`"a" << 1`, "operator '<<' not defined for string and int"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			_, err := NewEvaluator(nil, WithBackend(b)).Eval(e)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s with the %s backend fails with %v, want %q", tt.src, b, err, tt.want)
+			}
+		}
+		if _, errs := Check(e, nil); len(errs) == 0 && !strings.Contains(tt.want, "shift") {
+			t.Errorf("Check(%s) found no errors", tt.src)
+		}
+	}
+}
+
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"1 > 2 && missing", BoolValue(false)},
+		{"1 < 2 || missing", BoolValue(true)},
+		{"1 > 2 && 1 / 0 == 0", BoolValue(false)},
+		{"{ t = 1; t < 2 || t / 0 == 0 }", BoolValue(true)},
+		{"try(1 < 2 && missing, 1 > 2 || 2 > 1)", BoolValue(true)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(nil, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	e, _ := Parse("1 < 2 && missing")
+	for _, b := range allBackends {
+		if _, err := NewEvaluator(nil, WithBackend(b)).Eval(e); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("1 < 2 && missing with the %s backend fails with %v, want an undefined variable", b, err)
+		}
+	}
+}
+
+func TestShortCircuitProgramsSerialize(t *testing.T) {
+	e, err := Parse("x > 0 && 10 / x > 2 || x == 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(program.Disassemble(), "right #0:") {
+		t.Errorf("no right operand program in\n%s", program.Disassemble())
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Program
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for x, want := range map[int64]bool{0: true, 2: true, 5: false} {
+		got, err := decoded.Run(Env{"x": IntValue(x)})
+		if err != nil || !got.Equal(BoolValue(want)) {
+			t.Errorf("with x = %d, decoded program = %s, %v, want %t", x, got, err, want)
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	env := Env{
+		"double": FuncValue(func(args []Value) (Value, error) {
+			return applyInfix(MulOp, args[0], IntValue(2))
+		}),
+		"sub": FuncValue(func(args []Value) (Value, error) {
+			return applyInfix(SubOp, args[0], args[1])
+		}),
+	}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"x |> double", "double(x)"},
+		{"x + 1 |> sub(3) |> double", "double(sub(x + 1, 3))"},
+		{"x |> sub()", "sub(x)"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+	e, _ := Parse("4 |> sub(1) |> double")
+	if got, err := NewEvaluator(env).Eval(e); err != nil || !got.Equal(IntValue(6)) {
+		t.Errorf("4 |> sub(1) |> double = %s, %v, want 6", got, err)
+	}
+	for _, src := range []string{"x |>", "x |> 1", "x |> (f)", "x |> f(1"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+	if _, err := Parse("x |> round(1)", WithSignatures(map[string]Signature{"round": {Result: FloatKind, Params: []Kind{FloatKind, IntKind}}})); err != nil {
+		t.Errorf("a piped argument is not counted against the signature: %v", err)
+	}
+}
+
+func TestBlockBindingIsNotEquality(t *testing.T) {
+	if _, err := Parse("{ x == 1; x }"); err == nil || !strings.Contains(err.Error(), "expected name = value") {
+		t.Errorf("Parse({ x == 1; x }) = %v, want a binding error", err)
+	}
+	e, err := Parse("{ x = 1 == 1; x }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewEvaluator(nil).Eval(e); err != nil || !got.Equal(BoolValue(true)) {
+		t.Errorf("{ x = 1 == 1; x } = %s, %v, want true", got, err)
+	}
+}
+
+func TestComparisonExports(t *testing.T) {
+	e, err := Parse(`a == b < c && !(s == "x") || d >= 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, err := SQL(e, PostgreSQL)
+	if want := `("a" = "b") < "c" AND NOT "s" = 'x' OR "d" >= 1`; err != nil || sql != want {
+		t.Errorf("SQL = %s, %v, want %s", sql, err, want)
+	}
+	js, err := JS(e)
+	if want := `(a === b) < c && !(s === "x") || d >= 1`; err != nil || js != want {
+		t.Errorf("JS = %s, %v, want %s", js, err, want)
+	}
+	expr, err := GoAST(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	if want := `a == b < c && !(s == "x") || d >= 1`; buf.String() != want {
+		t.Errorf("GoAST = %s, want %s", buf.String(), want)
+	}
+	shift, _ := Parse("a << 2")
+	if _, err := JS(shift); err == nil {
+		t.Error("JS(a << 2) succeeded")
+	}
+	if _, err := SQL(shift, MySQL); err == nil {
+		t.Error("SQL(a << 2) succeeded")
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type Opcode byte
+
+const (
+	OpConst Opcode = iota
+	OpLoad
+	OpPrefix
+	OpInfix
+	OpCall
+	OpStore
+	OpTemp
+	OpTry
+	OpBind
+	OpUnbind
+	OpLogic
+)
+
+var opcodeNames = map[Opcode]string{
+	OpConst:  "const",
+	OpLoad:   "load",
+	OpPrefix: "prefix",
+	OpInfix:  "infix",
+	OpCall:   "call",
+	OpStore:  "store",
+	OpTemp:   "temp",
+	OpTry:    "try",
+	OpBind:   "bind",
+	OpUnbind: "unbind",
+	OpLogic:  "logic",
+}
+
+func (o Opcode) String() string {
+	if name, ok := opcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("op(%d)", byte(o))
+}
+
+// Instruction operands depend on Op: A indexes Consts for OpConst, is the
+// OpKind of OpPrefix and OpInfix, indexes the temporaries for OpStore and
+// OpTemp, Tries for OpTry and Lazy for OpLogic, is unused by OpUnbind, and
+// indexes Names otherwise. OpBind pops a value into a local variable named
+// by A, and OpUnbind removes the innermost local. B is the argument count of
+// OpCall and the OpKind of OpLogic. Pos is the source column reported in
+// runtime errors.
+type Instruction struct {
+	Op  Opcode
+	A   int
+	B   int
+	Pos int
+}
+
+// Program is an expression compiled for a stack machine. A Program is never
+// modified after compilation and may be run concurrently.
+type Program struct {
+	Code   []Instruction
+	Consts []Value
+	Names  []string
+	// Temps is the number of temporaries holding the values of repeated
+	// subexpressions: OpStore copies the top of the stack into one and
+	// OpTemp pushes its value.
+	Temps int
+	// Tries holds the programs of each try call for OpTry, which runs the
+	// first and, if it fails, the second, and pushes the result.
+	Tries [][2]*Program
+	// Lazy holds the programs of the right operands of && and || for
+	// OpLogic, which replaces the left operand on the stack with the
+	// result, running the program only if the left operand does not decide
+	// it.
+	Lazy []*Program
+}
+
+type compiler struct {
+	compileSettings
+	program     *Program
+	nameIndexes map[string]int
+	subexprs    *subexpressions
+	// blocks counts the let bodies being compiled, where repeated
+	// subexpressions are not shared.
+	blocks int
+}
+
+// compileSettings are the options shared by the stack and register
+// compilers.
+type compileSettings struct {
+	noFold bool
+	noCSE  bool
+}
+
+type CompileOption func(*compileSettings)
+
+// WithoutConstantFolding compiles constant subexpressions as written
+// instead of evaluating them at compile time, so the code matches the
+// source when debugging the compiler.
+func WithoutConstantFolding() CompileOption {
+	return func(s *compileSettings) {
+		s.noFold = true
+	}
+}
+
+func (s *compileSettings) apply(opts []CompileOption) {
+	for _, opt := range opts {
+		opt(s)
+	}
+}
+
+// foldConstant returns the value of applying op to constant operands, if
+// it can be computed at compile time: the operator is built in, so it does
+// not depend on the environment, and it succeeds. Failing operations are
+// left to fail at run time with their usual error.
+func (s *compileSettings) foldConstant(op OpKind, operands ...Value) (Value, bool) {
+	if s.noFold || operatorFunction(op, len(operands) == 2) != "" {
+		return Value{}, false
+	}
+	var value Value
+	var err error
+	if len(operands) == 2 {
+		value, err = applyInfix(op, operands[0], operands[1])
+	} else {
+		value, err = applyPrefix(op, operands[0])
+	}
+	return value, err == nil
+}
+
+// Compile compiles e for the stack machine. Subexpressions of constants
+// and built-in operators are evaluated at compile time unless
+// WithoutConstantFolding is given, and a pure subexpression that occurs
+// more than once is computed once and kept in a temporary unless
+// WithoutCSE is given.
+func Compile(e Expression, opts ...CompileOption) (*Program, error) {
+	var s compileSettings
+	s.apply(opts)
+	return compileWith(e, s)
+}
+
+func compileWith(e Expression, s compileSettings) (*Program, error) {
+	c := &compiler{
+		compileSettings: s,
+		program:         &Program{},
+		nameIndexes:     make(map[string]int),
+	}
+	if !c.noCSE {
+		c.subexprs = findSubexpressions(e)
+	}
+	if err := c.compile(e); err != nil {
+		return nil, err
+	}
+	return c.program, nil
+}
+
+func (c *compiler) name(name string) int {
+	if index, ok := c.nameIndexes[name]; ok {
+		return index
+	}
+	c.program.Names = append(c.program.Names, name)
+	c.nameIndexes[name] = len(c.program.Names) - 1
+	return len(c.program.Names) - 1
+}
+
+func (c *compiler) constant(value Value) int {
+	c.program.Consts = append(c.program.Consts, value)
+	return len(c.program.Consts) - 1
+}
+
+func (c *compiler) emit(op Opcode, a int, b int, pos int) {
+	c.program.Code = append(c.program.Code, Instruction{Op: op, A: a, B: b, Pos: pos})
+}
+
+func (c *compiler) compile(e Expression) error {
+	if c.blocks > 0 {
+		return c.compileNode(e)
+	}
+	entry := c.subexprs.repeated(e)
+	if entry == nil {
+		return c.compileNode(e)
+	}
+	if entry.slot >= 0 {
+		c.emit(OpTemp, entry.slot, 0, e.getPosition())
+		return nil
+	}
+	if err := c.compileNode(e); err != nil {
+		return err
+	}
+	entry.slot = c.program.Temps
+	c.program.Temps++
+	c.emit(OpStore, entry.slot, 0, e.getPosition())
+	return nil
+}
+
+func (c *compiler) compileNode(e Expression) error {
+	switch v := e.(type) {
+	case IntegerToken:
+		c.emit(OpConst, c.constant(IntValue(int64(v.value))), 0, v.pos)
+	case FloatToken:
+		c.emit(OpConst, c.constant(FloatValue(v.value)), 0, v.pos)
+	case StringToken:
+		c.emit(OpConst, c.constant(StringValue(v.value)), 0, v.pos)
+	case IdentifierToken:
+		c.emit(OpLoad, c.name(v.name), 0, v.pos)
+	case Hole:
+		return fmt.Errorf("cannot compile unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case *PrefixExpression:
+		if err := c.compile(v.rhs); err != nil {
+			return err
+		}
+		if !c.foldTail(v.op, 1, v.pos) {
+			c.emit(OpPrefix, int(v.op), 0, v.pos)
+		}
+	case *InfixExpression:
+		if isShortCircuit(v) {
+			return c.compileShortCircuit(v)
+		}
+		if err := c.compile(v.lhs); err != nil {
+			return err
+		}
+		if err := c.compile(v.rhs); err != nil {
+			return err
+		}
+		if !c.foldTail(v.op, 2, v.pos) {
+			c.emit(OpInfix, int(v.op), 0, v.pos)
+		}
+	case *CallExpression:
+		if isTry(v) {
+			return c.compileTry(v)
+		}
+		if isLet(v) {
+			return c.compileLet(v)
+		}
+		if isLambda(v) {
+			return fmt.Errorf("cannot compile fn at column %d", v.pos+1)
+		}
+		for _, arg := range v.args {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(OpCall, c.name(v.name), len(v.args), v.pos)
+	default:
+		return fmt.Errorf("cannot compile %T", e)
+	}
+	return nil
+}
+
+// foldTail replaces the last n instructions with one constant if they
+// push the operands of op and op can be folded. Operands that compiled to a
+// single constant were constant, or have been folded already, so folding
+// works bottom-up as the tree is compiled.
+func (c *compiler) foldTail(op OpKind, n int, pos int) bool {
+	code := c.program.Code
+	if len(code) < n {
+		return false
+	}
+	tail := code[len(code)-n:]
+	operands := make([]Value, n)
+	for i, ins := range tail {
+		if ins.Op != OpConst {
+			return false
+		}
+		operands[i] = c.program.Consts[ins.A]
+	}
+	value, ok := c.foldConstant(op, operands...)
+	if !ok {
+		return false
+	}
+	// The operands' constants were the last added, so they can go too.
+	c.program.Consts = c.program.Consts[:tail[0].A]
+	c.program.Code = code[:len(code)-n]
+	c.emit(OpConst, c.constant(value), 0, pos)
+	return true
+}
+
+var stackPool = sync.Pool{
+	New: func() interface{} {
+		stack := make([]Value, 0, 16)
+		return &stack
+	},
+}
+
+func (p *Program) Run(env Env) (Value, error) {
+	return p.run(env, nil)
+}
+
+// run runs the program with the local variables bound around it, as for
+// the programs of try calls in a let.
+func (p *Program) run(env Env, locals *scope) (Value, error) {
+	pooled := stackPool.Get().(*[]Value)
+	stack := (*pooled)[:0]
+	var temps []Value
+	if p.Temps > 0 {
+		temps = make([]Value, p.Temps)
+	}
+	defer func() {
+		used := stack[:cap(stack)]
+		for i := range used {
+			used[i] = Value{}
+		}
+		*pooled = used[:0]
+		stackPool.Put(pooled)
+	}()
+	for _, ins := range p.Code {
+		switch ins.Op {
+		case OpConst:
+			stack = append(stack, p.Consts[ins.A])
+		case OpLoad:
+			value, err := lookupLocal(p.Names[ins.A], ins.Pos, locals, env)
+			if err != nil {
+				return Value{}, err
+			}
+			stack = append(stack, value)
+		case OpPrefix:
+			var value Value
+			var err error
+			if name := operatorFunction(OpKind(ins.A), false); name != "" {
+				value, err = callOperator(name, ins.Pos, env, []Value{stack[len(stack)-1]})
+			} else {
+				value, err = applyPrefixAt(OpKind(ins.A), stack[len(stack)-1], ins.Pos)
+			}
+			if err != nil {
+				return Value{}, err
+			}
+			stack[len(stack)-1] = value
+		case OpInfix:
+			var value Value
+			var err error
+			if name := operatorFunction(OpKind(ins.A), true); name != "" {
+				value, err = callOperator(name, ins.Pos, env, []Value{stack[len(stack)-2], stack[len(stack)-1]})
+			} else {
+				value, err = applyInfixAt(OpKind(ins.A), stack[len(stack)-2], stack[len(stack)-1], ins.Pos)
+			}
+			if err != nil {
+				return Value{}, err
+			}
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = value
+		case OpCall:
+			args := make([]Value, ins.B)
+			copy(args, stack[len(stack)-ins.B:])
+			stack = stack[:len(stack)-ins.B]
+			value, err := callLocal(p.Names[ins.A], ins.Pos, locals, env, args)
+			if err != nil {
+				return Value{}, err
+			}
+			stack = append(stack, value)
+		case OpStore:
+			temps[ins.A] = stack[len(stack)-1]
+		case OpTemp:
+			stack = append(stack, temps[ins.A])
+		case OpTry:
+			value, err := p.Tries[ins.A][0].run(env, locals)
+			if recoverable(err) {
+				value, err = p.Tries[ins.A][1].run(env, locals)
+			}
+			if err != nil {
+				return Value{}, err
+			}
+			stack = append(stack, value)
+		case OpLogic:
+			value, err := applyShortCircuit(OpKind(ins.B), stack[len(stack)-1], func() (Value, error) {
+				return p.Lazy[ins.A].run(env, locals)
+			}, ins.Pos)
+			if err != nil {
+				return Value{}, err
+			}
+			stack[len(stack)-1] = value
+		case OpBind:
+			locals = &scope{name: p.Names[ins.A], value: stack[len(stack)-1], parent: locals}
+			stack = stack[:len(stack)-1]
+		case OpUnbind:
+			locals = locals.parent
+		default:
+			return Value{}, fmt.Errorf("invalid opcode %s", ins.Op)
+		}
+	}
+	if len(stack) != 1 {
+		return Value{}, fmt.Errorf("invalid program: %d values left on stack", len(stack))
+	}
+	return stack[0], nil
+}
+
+// Disassemble renders the program one instruction per line.
+func (p *Program) Disassemble() string {
+	var sb strings.Builder
+	for i, ins := range p.Code {
+		fmt.Fprintf(&sb, "%04d %-6s", i, ins.Op)
+		switch ins.Op {
+		case OpConst:
+			fmt.Fprintf(&sb, " %s", p.Consts[ins.A])
+		case OpPrefix, OpInfix:
+			fmt.Fprintf(&sb, " %s", OpKind(ins.A))
+		case OpCall:
+			fmt.Fprintf(&sb, " %s/%d", p.Names[ins.A], ins.B)
+		case OpStore, OpTemp, OpTry:
+			fmt.Fprintf(&sb, " #%d", ins.A)
+		case OpLogic:
+			fmt.Fprintf(&sb, " %s #%d", OpKind(ins.B), ins.A)
+		case OpUnbind:
+		default:
+			fmt.Fprintf(&sb, " %s", p.Names[ins.A])
+		}
+		sb.WriteByte('\n')
+	}
+	for i, programs := range p.Tries {
+		fmt.Fprintf(&sb, "try #%d:\n%sdefault #%d:\n%s", i, indent(programs[0].Disassemble()), i, indent(programs[1].Disassemble()))
+	}
+	for i, program := range p.Lazy {
+		fmt.Fprintf(&sb, "right #%d:\n%s", i, indent(program.Disassemble()))
+	}
+	return sb.String()
+}
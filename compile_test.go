@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		src            string
+		folded, unfold string
+	}{
+		{"2 * 3 + x",
+			"0000 const  6\n0001 load   x\n0002 infix  +\n",
+			"0000 const  2\n0001 const  3\n0002 infix  *\n0003 load   x\n0004 infix  +\n"},
+		{"-(2) * x",
+			"0000 const  -2\n0001 load   x\n0002 infix  *\n",
+			"0000 const  2\n0001 prefix -\n0002 load   x\n0003 infix  *\n"},
+		{`"a" + "b"`, "0000 const  \"ab\"\n", "0000 const  \"a\"\n0001 const  \"b\"\n0002 infix  +\n"},
+		{"f(1 + 2)", "0000 const  3\n0001 call   f/1\n", "0000 const  1\n0001 const  2\n0002 infix  +\n0003 call   f/1\n"},
+		{"let(y, 2 * 3, y + x)",
+			"0000 const  6\n0001 bind   y\n0002 load   y\n0003 load   x\n0004 infix  +\n0005 unbind\n",
+			"0000 const  2\n0001 const  3\n0002 infix  *\n0003 bind   y\n0004 load   y\n0005 load   x\n0006 infix  +\n0007 unbind\n"},
+		// Only constant operands are folded, and failing operations are
+		// left to fail at run time.
+		{"x * (1 + 2) * 4",
+			"0000 load   x\n0001 const  3\n0002 infix  *\n0003 const  4\n0004 infix  *\n",
+			"0000 load   x\n0001 const  1\n0002 const  2\n0003 infix  +\n0004 infix  *\n0005 const  4\n0006 infix  *\n"},
+		{"1 / 0 + x",
+			"0000 const  1\n0001 const  0\n0002 infix  /\n0003 load   x\n0004 infix  +\n",
+			"0000 const  1\n0001 const  0\n0002 infix  /\n0003 load   x\n0004 infix  +\n"},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		folded, err := Compile(e)
+		if err != nil {
+			t.Errorf("Compile(%s) = %v", tt.src, err)
+			continue
+		}
+		if got := folded.Disassemble(); got != tt.folded {
+			t.Errorf("Compile(%s) =\n%swant\n%s", tt.src, got, tt.folded)
+		}
+		unfolded, err := Compile(e, WithoutConstantFolding())
+		if err != nil {
+			t.Errorf("Compile(%s, WithoutConstantFolding()) = %v", tt.src, err)
+			continue
+		}
+		if got := unfolded.Disassemble(); got != tt.unfold {
+			t.Errorf("Compile(%s, WithoutConstantFolding()) =\n%swant\n%s", tt.src, got, tt.unfold)
+		}
+	}
+}
+
+func TestConstantFoldingKeepsResults(t *testing.T) {
+	env := Env{"x": IntValue(4), "f": FuncValue(func(args []Value) (Value, error) { return args[0], nil })}
+	sources := []string{"2 * 3 + x", "-(2.5) * x", "f(1 + 2) * 2", "(1 < 2) && x > 3", "1 / 0 + x", `"a" * 2`}
+	for _, src := range sources {
+		e := mustParse(t, src)
+		want, wantErr := evalExpression(e, env)
+		for _, backend := range []Backend{StackBackend, RegisterBackend} {
+			for _, opts := range [][]CompileOption{nil, {WithoutConstantFolding()}} {
+				program, err := CompileBackend(e, backend, opts...)
+				if err != nil {
+					t.Errorf("CompileBackend(%s, %s) = %v", src, backend, err)
+					continue
+				}
+				got, err := program.Run(env)
+				if errorString(err) != errorString(wantErr) || wantErr == nil && !got.Equal(want) {
+					t.Errorf("%s with the %s backend and %d options = %s, %v, want %s, %v", src, backend, len(opts), got, err, want, wantErr)
+				}
+			}
+		}
+	}
+}
+
+func TestBytecodeFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-bytecode"}, "0000 const  6\n0001 load   x\n0002 infix  +\n"},
+		{[]string{"-bytecode", "-no-fold"}, "0000 const  2\n0001 const  3\n0002 infix  *\n0003 load   x\n0004 infix  +\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if code := runEval(tt.args, strings.NewReader("2 * 3 + x\n"), &out); code != exitOK || out.String() != tt.want {
+			t.Errorf("%q exits with %d printing\n%swant\n%s", tt.args, code, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// complete returns the word ending before the cursor and the completions
+// of it: REPL commands at the start of a line, and otherwise the names in
+// the environment, with a '(' after functions. Numbered results are only
+// offered once the word starts with '_'.
+func (s *replSession) complete(before string) (string, []string) {
+	if strings.HasPrefix(before, ":") && !strings.Contains(before, " ") {
+		var candidates []string
+		for name := range replCommands {
+			if strings.HasPrefix(name, before) {
+				candidates = append(candidates, name)
+			}
+		}
+		sort.Strings(candidates)
+		return before, candidates
+	}
+	start := len(before)
+	for start > 0 && (isIdentifierChar(before[start-1]) || before[start-1] == '.') {
+		start--
+	}
+	word := before[start:]
+	if word == "" || !isIdentifierStart(word[0]) {
+		return word, nil
+	}
+	var candidates []string
+	for _, name := range sortedNames(s.env) {
+		if !strings.HasPrefix(name, word) || isResultName(name) && !strings.HasPrefix(word, "_") {
+			continue
+		}
+		if s.env[name].Kind() == FuncKind {
+			name += "("
+		}
+		candidates = append(candidates, name)
+	}
+	return word, candidates
+}
+
+// isResultName reports whether name is a numbered result such as _3.
+func isResultName(name string) bool {
+	if len(name) < 2 || name[0] != '_' {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hint returns the signature of the function whose argument list the
+// cursor is in, or "" if there is none.
+func (s *replSession) hint(before string) string {
+	depth := 0
+	for i := len(before) - 1; i >= 0; i-- {
+		switch before[i] {
+		case ')':
+			depth++
+		case '(':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			start := i
+			for start > 0 && (isIdentifierChar(before[start-1]) || before[start-1] == '.') {
+				start--
+			}
+			name := before[start:i]
+			if name == "" {
+				// A grouping paren, so keep looking for a call around it.
+				continue
+			}
+			if value, ok := s.env[name]; !ok || value.Kind() != FuncKind {
+				return ""
+			}
+			if signature, ok := functionSignatures[name]; ok {
+				return signature
+			}
+			return name + "(...)"
+		}
+	}
+	return ""
+}
+
+// commonPrefix returns the longest prefix shared by all of words.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
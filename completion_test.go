@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	s := newReplSession()
+	s.run("1")
+	s.run("2")
+	s.env["maximum"] = IntValue(9)
+	tests := []struct {
+		before     string
+		word       string
+		candidates []string
+	}{
+		{":l", ":l", []string{":load"}},
+		{":", ":", []string{":doc", ":help", ":load", ":ops", ":quit", ":save", ":set", ":vars"}},
+		{"1 + ma", "ma", []string{"max(", "maximum"}},
+		{"mo", "mo", []string{"mod("}},
+		{"a", "a", []string{"ans"}},
+		{"_", "_", []string{"_1", "_2"}},
+		{"2 * ", "", nil},
+		{"12", "12", nil},
+	}
+	for _, tt := range tests {
+		word, candidates := s.complete(tt.before)
+		if word != tt.word || !equalStrings(candidates, tt.candidates) {
+			t.Errorf("complete(%q) = %q, %q, want %q, %q", tt.before, word, candidates, tt.word, tt.candidates)
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	s := newReplSession()
+	s.env["f"] = FuncValue(nil)
+	tests := []struct {
+		before string
+		want   string
+	}{
+		{"max(", "max(a, b number) number"},
+		{"max(1, (2 + ", "max(a, b number) number"},
+		{"max(1, pow(2, ", "pow(x, y number) float"},
+		{"max(1, pow(2, 3), ", "max(a, b number) number"},
+		{"f(", "f(...)"},
+		{"g(", ""},
+		{"(1 + ", ""},
+	}
+	for _, tt := range tests {
+		if got := s.hint(tt.before); got != tt.want {
+			t.Errorf("hint(%q) = %q, want %q", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestTabCompletion(t *testing.T) {
+	s := newReplSession()
+	e := newLineEditor(strings.NewReader("1 + mi\t1, 2)\r"), io.Discard, NewHistory("", 10))
+	e.complete = s.complete
+	if got, err := e.ReadLine("> "); err != nil || got != "1 + min(1, 2)" {
+		t.Errorf("ReadLine = %q, %v, want the completion inserted", got, err)
+	}
+}
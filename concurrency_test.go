@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentParseAndEval(t *testing.T) {
+	shared, err := Parse("x * (x + 1) - max(x, 3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				x := int64(g*50 + i)
+				local := Env{"x": IntValue(x), "max": FuncValue(operatorFunctions["max"])}
+				want := IntValue(x*(x+1) - max64(x, 3))
+				e, err := Parse(fmt.Sprintf("%d * (%d + 1) - max(%d, 3)", x, x, x))
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, got := range []func() (Value, error){
+					func() (Value, error) { return NewEvaluator(local).Eval(e) },
+					func() (Value, error) { return NewEvaluator(local).Eval(shared) },
+					func() (Value, error) { return program.Run(local) },
+				} {
+					if value, err := got(); err != nil || !value.Equal(want) {
+						errs <- fmt.Errorf("with x = %d, got %s, %v, want %s", x, value, err, want)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func TestPooledLexersForgetOptions(t *testing.T) {
+	tests := []struct {
+		src  string
+		opts []ParseOption
+	}{
+		{"1 + $ 2", []ParseOption{Permissive()}},
+		{"1 + 2 + 3", []ParseOption{WithInputLimits(0, 3)}},
+		{`"a" & "b"`, []ParseOption{ExcelSyntax()}},
+	}
+	for _, tt := range tests {
+		_, withErr := Parse(tt.src, tt.opts...)
+		_, withoutErr := Parse(tt.src)
+		if (withErr == nil) == (withoutErr == nil) {
+			t.Errorf("Parse(%s) fails with %v with options and %v without, want one to fail", tt.src, withErr, withoutErr)
+		}
+		// The next parse must not see the options either.
+		if _, err := Parse(tt.src); (err == nil) != (withoutErr == nil) {
+			t.Errorf("Parse(%s) fails with %v after %v", tt.src, err, withoutErr)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+// builtinFunctions are callable without the environment providing them,
+// unless it defines the same name: those added by addBuiltin and the
+// constructors of registered literals.
+var builtinFunctions = make(map[string]Function)
+
+// builtinSignatures declares the functions added by addBuiltin to the type
+// checker, read from the signatures they are documented with. The special
+// forms, whose arguments are not values, have none.
+var builtinSignatures = make(map[string]Signature)
+
+// conversionKinds are the kinds the conversion functions return.
+var conversionKinds = map[string]Kind{
+	"int":   IntKind,
+	"float": FloatKind,
+	"str":   StringKind,
+	"bool":  BoolKind,
+}
+
+// addBuiltin adds fn to builtinFunctions with the signature and
+// documentation :doc and call hints show.
+func addBuiltin(name string, signature string, doc docEntry, fn Function) {
+	builtinFunctions[name] = fn
+	functionSignatures[name] = signature
+	if s, ok := parseSignature(signature); ok {
+		builtinSignatures[name] = s
+	}
+	functionDocs[name] = doc
+}
+
+func init() {
+	for name, doc := range map[string]docEntry{
+		"int":   {"x converted to an integer: floats are truncated towards zero, true is 1 and false 0, and strings must spell an integer", `int("42") + 1`},
+		"float": {"x converted to a float: true is 1 and false 0, and strings must spell a number", `float(7) / 2`},
+		"str":   {"x as a string: strings are unchanged and other values are written as they print", `str(42) + "%"`},
+		"bool":  {"x converted to a bool: numbers are true unless zero, and strings must spell a bool such as true or 0", `bool("true")`},
+	} {
+		kind := conversionKinds[name]
+		addBuiltin(name, name+"(x) "+kind.String(), doc, convertFunction(name, kind))
+	}
+}
+
+// convertFunction converts its one argument to kind with the Value.As
+// methods, whose rules it follows.
+func convertFunction(name string, kind Kind) Function {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return Value{}, fmt.Errorf("%s takes one argument", name)
+		}
+		x := args[0]
+		switch kind {
+		case IntKind:
+			i, err := x.AsInt()
+			return IntValue(i), err
+		case FloatKind:
+			f, err := x.AsFloat()
+			return FloatValue(f), err
+		case BoolKind:
+			b, err := x.AsBool()
+			return BoolValue(b), err
+		}
+		s, err := x.AsString()
+		return StringValue(s), err
+	}
+}
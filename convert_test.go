@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestConversions(t *testing.T) {
+	checkEvalCases(t, Env{"yes": BoolValue(true), "no": BoolValue(false)}, []evalCase{
+		{src: `int("42") + 1`, want: IntValue(43)},
+		{src: `int(" -7 ")`, want: IntValue(-7)},
+		{src: "int(2.9)", want: IntValue(2)},
+		{src: "int(-2.9)", want: IntValue(-2)},
+		{src: "int(yes)", want: IntValue(1)},
+		{src: "float(7) / 2", want: FloatValue(3.5)},
+		{src: `float("1e3")`, want: FloatValue(1000)},
+		{src: "float(no)", want: FloatValue(0)},
+		{src: `str(42) + "%"`, want: StringValue("42%")},
+		{src: "str(yes)", want: StringValue("true")},
+		{src: `str("s")`, want: StringValue("s")},
+		{src: "bool(0.0)", want: BoolValue(false)},
+		{src: "bool(-1)", want: BoolValue(true)},
+		{src: `bool("0")`, want: BoolValue(false)},
+		{src: "int(float(int(3.7)))", want: IntValue(3)},
+		{src: `int("4.5")`, err: `cannot convert "4.5" to int`},
+		{src: "int(1e300)", err: "cannot convert 1e+300 to int"},
+		{src: "int(-1e19)", err: "to int"},
+		{src: "int(9223372036854775808.0)", err: "to int"},
+		{src: "int(-9223372036854775808.0)", want: IntValue(-9223372036854775808)},
+		{src: `int(float("NaN"))`, err: "cannot convert NaN to int"},
+		{src: `int(float("-Inf"))`, err: "to int"},
+		{src: `float("x")`, err: `cannot convert "x" to float`},
+		{src: `bool("yes")`, err: `cannot convert "yes" to bool`},
+		{src: "int(1, 2)", err: "int takes one argument"},
+		{src: "str()", err: "str takes one argument"},
+	})
+}
+
+func TestConversionsDefer(t *testing.T) {
+	env := Env{"int": FuncValue(func(args []Value) (Value, error) {
+		return IntValue(0), nil
+	})}
+	if got, err := NewEvaluator(env).Eval(mustParse(t, "int(2.5)")); err != nil || !got.Equal(IntValue(0)) {
+		t.Errorf("int(2.5) with int in the environment = %s, %v, want 0", got, err)
+	}
+}
+
+func TestCheckConversions(t *testing.T) {
+	schema := Schema{"x": FloatKind, "s": StringKind, "b": BoolKind}
+	tests := map[string]Kind{
+		"int(x) + 1":    IntKind,
+		"float(s) * 2":  FloatKind,
+		`str(x) + "%"`:  StringKind,
+		"bool(s) && !b": BoolKind,
+	}
+	for src, want := range tests {
+		if got, errs := Check(mustParse(t, src), schema); got != want || len(errs) > 0 {
+			t.Errorf("Check(%s) = %s, %v, want %s", src, got, errs, want)
+		}
+	}
+	if _, errs := Check(mustParse(t, `int(x) + "s"`), schema); len(errs) == 0 {
+		t.Errorf(`Check(int(x) + "s") reported no errors`)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+// WithoutCSE compiles every occurrence of a repeated subexpression instead
+// of computing it once, to compare the code before and after elimination.
+func WithoutCSE() CompileOption {
+	return func(s *compileSettings) {
+		s.noCSE = true
+	}
+}
+
+// subexpressions records the operator subexpressions of an expression
+// that occur more than once and can be computed once and reused: pure, so
+// every occurrence has the same value, and not constant, since folding
+// already computes those.
+type subexpressions struct {
+	entries map[uint64][]*subexpression
+}
+
+type subexpression struct {
+	expr  Expression
+	count int
+	// slot is where the compiler keeps the value once computed, or -1
+	// before the first occurrence is compiled.
+	slot int
+}
+
+func findSubexpressions(e Expression) *subexpressions {
+	s := &subexpressions{entries: make(map[uint64][]*subexpression)}
+	walk(e, 1, func(e Expression, depth int) bool {
+		switch v := e.(type) {
+		case *PrefixExpression:
+			if isPure(e) && !isConstant(e) {
+				s.add(e)
+			}
+		case *InfixExpression:
+			if isPure(e) && !isConstant(e) {
+				s.add(e)
+			}
+			// The right operand of && and || is compiled separately, and
+			// is not always evaluated.
+			return !isShortCircuit(v)
+		case *CallExpression:
+			// The arguments of try are compiled separately, and names
+			// bound by let and fn change what the same expression means.
+			return !isTry(v) && !isLet(v) && !isLambda(v)
+		}
+		return true
+	})
+	return s
+}
+
+func (s *subexpressions) add(e Expression) {
+	key := hashExpr(e)
+	for _, entry := range s.entries[key] {
+		if equalExpr(entry.expr, e) {
+			entry.count++
+			return
+		}
+	}
+	s.entries[key] = append(s.entries[key], &subexpression{expr: e, count: 1, slot: -1})
+}
+
+// repeated returns the entry for e if it occurs more than once.
+func (s *subexpressions) repeated(e Expression) *subexpression {
+	if s == nil {
+		return nil
+	}
+	switch e.(type) {
+	case *PrefixExpression, *InfixExpression:
+	default:
+		return nil
+	}
+	for _, entry := range s.entries[hashExpr(e)] {
+		if entry.count > 1 && equalExpr(entry.expr, e) {
+			return entry
+		}
+	}
+	return nil
+}
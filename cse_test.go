@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSE(t *testing.T) {
+	tests := []struct {
+		src   string
+		temps int
+		want  string
+	}{
+		{"(a + b) * (a + b)", 1, "0000 load   a\n0001 load   b\n0002 infix  +\n0003 store  #0\n0004 temp   #0\n0005 infix  *\n"},
+		{"-x * -x", 1, "0000 load   x\n0001 prefix -\n0002 store  #0\n0003 temp   #0\n0004 infix  *\n"},
+		// Calls may have side effects, so each is made.
+		{"f(x) + f(x)", 0, "0000 load   x\n0001 call   f/1\n0002 load   x\n0003 call   f/1\n0004 infix  +\n"},
+		// Constants are folded instead.
+		{"(1 + 2) * (1 + 2) + x", 0, "0000 const  9\n0001 load   x\n0002 infix  +\n"},
+		// The right operand of && is a program of its own, which
+		// eliminates its own repeats.
+		{"x > 0 && x + 1 > (x + 1)", 0, "0000 load   x\n0001 const  0\n0002 infix  >\n0003 logic  && #0\nright #0:\n" +
+			"    0000 load   x\n    0001 const  1\n    0002 infix  +\n    0003 store  #0\n    0004 temp   #0\n    0005 infix  >\n"},
+		// The body of a let is not shared with the expression around it.
+		{"let(y, x + 1, y) + (x + 1)", 0, "0000 load   x\n0001 const  1\n0002 infix  +\n0003 bind   y\n0004 load   y\n0005 unbind\n" +
+			"0006 load   x\n0007 const  1\n0008 infix  +\n0009 infix  +\n"},
+	}
+	for _, tt := range tests {
+		program, err := Compile(mustParse(t, tt.src))
+		if err != nil {
+			t.Errorf("Compile(%s) = %v", tt.src, err)
+			continue
+		}
+		if got := program.Disassemble(); got != tt.want || program.Temps != tt.temps {
+			t.Errorf("Compile(%s) has %d temporaries and\n%swant %d and\n%s", tt.src, program.Temps, got, tt.temps, tt.want)
+		}
+	}
+
+	program, err := Compile(mustParse(t, "(a + b) * (a + b)"), WithoutCSE())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0000 load   a\n0001 load   b\n0002 infix  +\n0003 load   a\n0004 load   b\n0005 infix  +\n0006 infix  *\n"
+	if got := program.Disassemble(); got != want || program.Temps != 0 {
+		t.Errorf("Compile with WithoutCSE() has %d temporaries and\n%swant none and\n%s", program.Temps, got, want)
+	}
+}
+
+func TestCSEKeepsResults(t *testing.T) {
+	env := Env{"a": IntValue(2), "b": FloatValue(0.5), "x": IntValue(3)}
+	sources := []string{
+		"(a + b) * (a + b)",
+		"(x + 1) * 2 + (x + 1) * 2 - (x + 1)",
+		"-x * -x + -x",
+		"x > 0 && x + 1 > (x + 1) || (x + 1) == 4",
+		"let(y, x + 1, y * (x + 1)) + (x + 1)",
+		"try((a - 2) / (a - 2), (a - 2))",
+		"(a / (x - 3)) + (a / (x - 3))",
+	}
+	for _, src := range sources {
+		e := mustParse(t, src)
+		want, wantErr := evalExpression(e, env)
+		for _, backend := range []Backend{StackBackend, RegisterBackend} {
+			for _, opts := range [][]CompileOption{nil, {WithoutCSE()}} {
+				program, err := CompileBackend(e, backend, opts...)
+				if err != nil {
+					t.Errorf("CompileBackend(%s, %s) = %v", src, backend, err)
+					continue
+				}
+				got, err := program.Run(env)
+				if errorString(err) != errorString(wantErr) || wantErr == nil && !got.Equal(want) {
+					t.Errorf("%s with the %s backend and %d options = %s, %v, want %s, %v", src, backend, len(opts), got, err, want, wantErr)
+				}
+			}
+		}
+	}
+}
+
+func TestCSERoundTrips(t *testing.T) {
+	program, err := Compile(mustParse(t, "(a + b) * (a + b)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Program
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := decoded.Run(Env{"a": IntValue(1), "b": IntValue(2)})
+	if err != nil || !got.Equal(IntValue(9)) || decoded.Temps != 1 {
+		t.Errorf("decoded program has %d temporaries and gives %s, %v, want 1 and 9", decoded.Temps, got, err)
+	}
+
+	// A temporary read before it is stored is rejected.
+	program.Code[3], program.Code[4] = program.Code[4], program.Code[3]
+	if data, err = program.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Program).UnmarshalBinary(data); err == nil {
+		t.Errorf("program reading a temporary before storing it was accepted")
+	}
+}
+
+func TestNoCSEFlag(t *testing.T) {
+	var out bytes.Buffer
+	if code := runEval([]string{"-bytecode", "-no-cse"}, strings.NewReader("-x * -x\n"), &out); code != exitOK {
+		t.Errorf("-bytecode -no-cse exits with %d", code)
+	}
+	if want := "0000 load   x\n0001 prefix -\n0002 load   x\n0003 prefix -\n0004 infix  *\n"; out.String() != want {
+		t.Errorf("-bytecode -no-cse printed\n%swant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runCSV implements the csv subcommand, evaluating an expression for every
+// row of a CSV file with the row's cells bound to its column headers, over
+// any variables set with -vars and -var. Rows are streamed to out with the
+// result appended as a new column, or with -only as the result alone. A row
+// that fails to evaluate gets an empty result and its error is reported to
+// errOut. It returns the exit code.
+func runCSV(args []string, in io.Reader, out, errOut io.Writer) int {
+	flags := flag.NewFlagSet("csv", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	src := flags.String("expr", "", "expression to evaluate for each row")
+	column := flags.String("column", "result", "header of the computed column")
+	only := flags.Bool("only", false, "write only the computed column")
+	varArgs := addVarFlags(flags)
+	envRefs := flags.Bool("env", false, "let the expression read environment variables as env.NAME or ${NAME}")
+	if err := flags.Parse(args); err != nil {
+		return flagsExitCode(err)
+	}
+	vars, err := varArgs.load()
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return varsExitCode(err)
+	}
+	if *src == "" || flags.NArg() > 1 {
+		fmt.Fprintln(errOut, "usage: csv -expr expression [-column name] [-only] [-vars file] [-var name=value] [-env] [file]")
+		return exitParseError
+	}
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return exitIOError
+		}
+		defer f.Close()
+		in = f
+	}
+	expr, err := parseInput(*src, vars, *envRefs)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitParseError
+	}
+	program, err := Compile(expr)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitParseError
+	}
+	reader := csv.NewReader(in)
+	writer := csv.NewWriter(out)
+	defer writer.Flush()
+	header, err := reader.Read()
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitIOError
+	}
+	names := make([]string, len(header))
+	for i, h := range header {
+		names[i] = columnVariable(h)
+	}
+	if err := writer.Write(csvRow(header, *column, *only)); err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitIOError
+	}
+	code := exitOK
+	env := make(Env, len(vars)+len(names))
+	for name, value := range vars {
+		env[name] = value
+	}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+			fmt.Fprintln(errOut, err)
+			code = exitEvalError
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return exitIOError
+		}
+		for i, cell := range record {
+			env[names[i]] = cellValue(cell)
+		}
+		result := ""
+		value, err := program.Run(env)
+		if err != nil {
+			fmt.Fprintf(errOut, "row %d: %v\n", row, err)
+			code = exitEvalError
+		} else {
+			result = cellText(value)
+		}
+		if err := writer.Write(csvRow(record, result, *only)); err != nil {
+			fmt.Fprintln(errOut, err)
+			return exitIOError
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitIOError
+	}
+	return code
+}
+
+func csvRow(record []string, result string, only bool) []string {
+	if only {
+		return []string{result}
+	}
+	return append(record, result)
+}
+
+// columnVariable returns the variable a column is bound to: its header with
+// surrounding space trimmed and any other character that cannot appear in
+// an identifier replaced by '_', so "unit price" is unit_price.
+func columnVariable(header string) string {
+	b := []byte(strings.TrimSpace(header))
+	for i, c := range b {
+		if !isIdentifierChar(c) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || !isIdentifierStart(b[0]) {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b)
+}
+
+// cellValue converts a cell to the value it most likely holds: an integer,
+// a float, true or false, or failing those the text as a string.
+func cellValue(cell string) Value {
+	text := strings.TrimSpace(cell)
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return IntValue(i)
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return FloatValue(f)
+	}
+	if text == "true" || text == "false" {
+		return BoolValue(text == "true")
+	}
+	return StringValue(cell)
+}
+
+// cellText is the CSV form of a value, which leaves strings unquoted.
+func cellText(v Value) string {
+	if v.Kind() == StringKind {
+		return v.Str()
+	}
+	return v.String()
+}
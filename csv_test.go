@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	input := "item,unit price,qty\n" +
+		"pen,1.5,4\n" +
+		"ink,2,x\n" +
+		"pad,3,2,extra\n" +
+		"\"a, b\",0.25,8\n"
+	tests := []struct {
+		args   []string
+		code   int
+		out    string
+		errOut string
+	}{
+		{[]string{"-expr", "unit_price * qty * (1 + tax)", "-var", "tax=0.5"}, exitEvalError,
+			"item,unit price,qty,result\npen,1.5,4,9\nink,2,x,\n\"a, b\",0.25,8,3\n",
+			"row 3: operator '*' not defined for int and string at column 12\nrecord on line 4: wrong number of fields\n"},
+		{[]string{"-expr", "qty + 0", "-only", "-column", "n"}, exitEvalError,
+			"n\n4\n\n8\n",
+			"row 3: operator '+' not defined for string and int at column 5\nrecord on line 4: wrong number of fields\n"},
+		{[]string{"-expr", "1 +"}, exitParseError, "", "unexpected end of expression after '+' at column 3\n"},
+		{[]string{"-expr", "1", "a.csv", "b.csv"}, exitParseError, "", "usage: csv"},
+		{[]string{"-expr", "1", "no-such.csv"}, exitIOError, "", "no-such.csv"},
+	}
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		if code := runCSV(tt.args, strings.NewReader(input), &out, &errOut); code != tt.code {
+			t.Errorf("csv %q exits with %d, want %d: %s", tt.args, code, tt.code, errOut.String())
+		}
+		if out.String() != tt.out || !strings.Contains(errOut.String(), tt.errOut) {
+			t.Errorf("csv %q wrote\n%s\nand\n%s\nwant\n%s\nand\n%s", tt.args, out.String(), errOut.String(), tt.out, tt.errOut)
+		}
+	}
+}
+
+func TestColumnVariable(t *testing.T) {
+	tests := map[string]string{
+		"price":        "price",
+		" unit price ": "unit_price",
+		"2021 total":   "_2021_total",
+		"cost ($)":     "cost____",
+		"":             "_",
+	}
+	for header, want := range tests {
+		if got := columnVariable(header); got != want {
+			t.Errorf("columnVariable(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
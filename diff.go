@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type ChangeKind int
+
+const (
+	// OperatorChanged is an operator replaced by another over the same
+	// operands, which are compared separately.
+	OperatorChanged ChangeKind = iota
+	// FunctionChanged is a call to another function, whose arguments are
+	// compared separately.
+	FunctionChanged
+	// ValueChanged is a literal, variable or placeholder replaced by another
+	// of the same kind.
+	ValueChanged
+	// Replaced is a subexpression replaced by an unrelated one.
+	Replaced
+	// Added is an operand or argument only in the new expression.
+	Added
+	// Removed is an operand or argument only in the old expression.
+	Removed
+)
+
+var changeKindNames = [...]string{
+	OperatorChanged: "changed operator",
+	FunctionChanged: "changed function",
+	ValueChanged:    "changed value",
+	Replaced:        "replaced",
+	Added:           "added",
+	Removed:         "removed",
+}
+
+func (k ChangeKind) String() string {
+	if k < 0 || int(k) >= len(changeKindNames) {
+		return "unknown"
+	}
+	return changeKindNames[k]
+}
+
+// Change is one structural difference found by Diff. Path locates the
+// changed node from the root, as in "rhs.args[1]", in the old expression,
+// or in the new one for Added. Old is nil for Added and New for Removed.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Old  Expression
+	New  Expression
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "root"
+	}
+	switch c.Kind {
+	case OperatorChanged:
+		return fmt.Sprintf("%s at %s: %s -> %s", c.Kind, path, changedOperator(c.Old), changedOperator(c.New))
+	case FunctionChanged:
+		return fmt.Sprintf("%s at %s: %s -> %s", c.Kind, path, c.Old.(*CallExpression).name, c.New.(*CallExpression).name)
+	case Added:
+		return fmt.Sprintf("%s %s at %s", c.Kind, Format(c.New), path)
+	case Removed:
+		return fmt.Sprintf("%s %s at %s", c.Kind, Format(c.Old), path)
+	}
+	return fmt.Sprintf("%s at %s: %s -> %s", c.Kind, path, Format(c.Old), Format(c.New))
+}
+
+func changedOperator(e Expression) string {
+	switch v := e.(type) {
+	case *PrefixExpression:
+		return "prefix " + v.op.String()
+	case *InfixExpression:
+		return v.op.String()
+	}
+	return Format(e)
+}
+
+// Diff reports how b differs from a as structural changes rather than text,
+// so reformatting or reparenthesising without changing the tree yields no
+// changes. Wrapping an expression in an operator, as x becoming x + 1, is
+// reported as the added operand.
+func Diff(a Expression, b Expression) []Change {
+	changes := make([]Change, 0)
+	return diffExpr(changes, "", a, b)
+}
+
+func diffExpr(changes []Change, path string, a Expression, b Expression) []Change {
+	if equalExpr(a, b) {
+		return changes
+	}
+	switch x := a.(type) {
+	case *PrefixExpression:
+		if y, ok := b.(*PrefixExpression); ok {
+			if x.op != y.op {
+				changes = append(changes, Change{Kind: OperatorChanged, Path: path, Old: x, New: y})
+			}
+			return diffExpr(changes, childPath(path, "rhs"), x.rhs, y.rhs)
+		}
+	case *InfixExpression:
+		if y, ok := b.(*InfixExpression); ok {
+			if x.op != y.op {
+				changes = append(changes, Change{Kind: OperatorChanged, Path: path, Old: x, New: y})
+			}
+			changes = diffExpr(changes, childPath(path, "lhs"), x.lhs, y.lhs)
+			return diffExpr(changes, childPath(path, "rhs"), x.rhs, y.rhs)
+		}
+	case *CallExpression:
+		if y, ok := b.(*CallExpression); ok {
+			if x.name != y.name {
+				changes = append(changes, Change{Kind: FunctionChanged, Path: path, Old: x, New: y})
+			}
+			return diffArgs(changes, path, x.args, y.args)
+		}
+	case IntegerToken, FloatToken, StringToken, IdentifierToken, Hole:
+		if sameLeafKind(a, b) {
+			return append(changes, Change{Kind: ValueChanged, Path: path, Old: a, New: b})
+		}
+	}
+	if change, ok := wrapped(path, a, b); ok {
+		return append(changes, change)
+	}
+	return append(changes, Change{Kind: Replaced, Path: path, Old: a, New: b})
+}
+
+// wrapped reports an operand added around a or removed from around b.
+func wrapped(path string, a Expression, b Expression) (Change, bool) {
+	if y, ok := b.(*InfixExpression); ok {
+		if equalExpr(a, y.lhs) {
+			return Change{Kind: Added, Path: childPath(path, "rhs"), New: y.rhs}, true
+		}
+		if equalExpr(a, y.rhs) {
+			return Change{Kind: Added, Path: childPath(path, "lhs"), New: y.lhs}, true
+		}
+	}
+	if x, ok := a.(*InfixExpression); ok {
+		if equalExpr(x.lhs, b) {
+			return Change{Kind: Removed, Path: childPath(path, "rhs"), Old: x.rhs}, true
+		}
+		if equalExpr(x.rhs, b) {
+			return Change{Kind: Removed, Path: childPath(path, "lhs"), Old: x.lhs}, true
+		}
+	}
+	return Change{}, false
+}
+
+func sameLeafKind(a Expression, b Expression) bool {
+	switch a.(type) {
+	case IntegerToken:
+		_, ok := b.(IntegerToken)
+		return ok
+	case FloatToken:
+		_, ok := b.(FloatToken)
+		return ok
+	case StringToken:
+		_, ok := b.(StringToken)
+		return ok
+	case IdentifierToken:
+		_, ok := b.(IdentifierToken)
+		return ok
+	case Hole:
+		_, ok := b.(Hole)
+		return ok
+	}
+	return false
+}
+
+// diffArgs aligns two argument lists on their longest common subsequence
+// of equal arguments, so inserting an argument reports one addition rather
+// than a change to every argument after it. Unmatched arguments between
+// two matches are compared pairwise, and the rest are added or removed.
+func diffArgs(changes []Change, path string, a []Expression, b []Expression) []Change {
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if equalExpr(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// Find the next pair of matched arguments.
+		nextI, nextJ := i, j
+		for nextI < len(a) && nextJ < len(b) && !(equalExpr(a[nextI], b[nextJ]) && lcs[nextI][nextJ] == lcs[nextI+1][nextJ+1]+1) {
+			if lcs[nextI+1][nextJ] >= lcs[nextI][nextJ+1] {
+				nextI++
+			} else {
+				nextJ++
+			}
+		}
+		if nextI == len(a) || nextJ == len(b) {
+			nextI, nextJ = len(a), len(b)
+		}
+		for ; i < nextI && j < nextJ; i, j = i+1, j+1 {
+			changes = diffExpr(changes, argPath(path, i), a[i], b[j])
+		}
+		for ; i < nextI; i++ {
+			changes = append(changes, Change{Kind: Removed, Path: argPath(path, i), Old: a[i]})
+		}
+		for ; j < nextJ; j++ {
+			changes = append(changes, Change{Kind: Added, Path: argPath(path, j), New: b[j]})
+		}
+		i, j = i+1, j+1
+	}
+	return changes
+}
+
+func childPath(path string, child string) string {
+	if path == "" {
+		return child
+	}
+	return path + "." + child
+}
+
+func argPath(path string, i int) string {
+	return childPath(path, "args["+strconv.Itoa(i)+"]")
+}
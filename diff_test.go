@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{"a + b * c", "a + (b * c)", nil},
+		{"a + b", "a - b", []string{"changed operator at root: + -> -"}},
+		{"-a", "!a", []string{"changed operator at root: prefix - -> prefix !"}},
+		{"min(x, 1)", "max(x, 2)", []string{
+			"changed function at root: min -> max",
+			"changed value at args[1]: 1 -> 2",
+		}},
+		{"a * (b + 1)", "a * (c + 1)", []string{"changed value at rhs.lhs: b -> c"}},
+		{"x", "x + 1", []string{"added 1 at rhs"}},
+		{"x * 2", "2", []string{"removed x at lhs"}},
+		{"f(a, c)", "f(a, b, c)", []string{"added b at args[1]"}},
+		{"f(a, b, c)", "f(a, c)", []string{"removed b at args[1]"}},
+		{"f(a, b)", "f(a, d(e))", []string{"replaced at args[1]: b -> d(e)"}},
+		{"1", `"1"`, []string{`replaced at root: 1 -> "1"`}},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := Diff(a, b)
+		got := make([]string, len(changes))
+		for i, change := range changes {
+			got[i] = change.String()
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("Diff(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// InfixImpl implements an infix operator for operands of particular kinds,
+// and PrefixImpl a prefix operator.
+type (
+	InfixImpl  func(lhs Value, rhs Value) (Value, error)
+	PrefixImpl func(rhs Value) (Value, error)
+)
+
+const kindCount = int(CustomKind) + 1
+
+// overload is an implementation with the kind of value it returns, which
+// the type checker infers.
+type overload struct {
+	result Kind
+	infix  InfixImpl
+	prefix PrefixImpl
+}
+
+// The dispatch tables hold the implementations of the natively evaluated
+// operators by OpKind and operand kinds, filled in by init and Overload.
+// An operator missing for mixed numeric operands is applied to both as
+// floats.
+var (
+	infixImpls  [][kindCount][kindCount]overload
+	prefixImpls [][kindCount]overload
+)
+
+func init() {
+	intOp := func(fn func(a, b int64) (Value, error)) InfixImpl {
+		return func(lhs, rhs Value) (Value, error) {
+			return fn(lhs.Int(), rhs.Int())
+		}
+	}
+	floatOp := func(fn func(a, b float64) float64) InfixImpl {
+		return func(lhs, rhs Value) (Value, error) {
+			return FloatValue(fn(lhs.Float(), rhs.Float())), nil
+		}
+	}
+	setInfix(AddOp, IntKind, IntKind, IntKind, intOp(func(a, b int64) (Value, error) { return IntValue(a + b), nil }))
+	setInfix(SubOp, IntKind, IntKind, IntKind, intOp(func(a, b int64) (Value, error) { return IntValue(a - b), nil }))
+	setInfix(MulOp, IntKind, IntKind, IntKind, intOp(func(a, b int64) (Value, error) { return IntValue(a * b), nil }))
+	setInfix(DivOp, IntKind, IntKind, IntKind, intOp(func(a, b int64) (Value, error) {
+		if b == 0 {
+			return Value{}, ErrDivisionByZero
+		}
+		return IntValue(a / b), nil
+	}))
+	setInfix(PowOp, IntKind, IntKind, IntKind, intOp(intPow))
+	setInfix(AddOp, FloatKind, FloatKind, FloatKind, floatOp(func(a, b float64) float64 { return a + b }))
+	setInfix(SubOp, FloatKind, FloatKind, FloatKind, floatOp(func(a, b float64) float64 { return a - b }))
+	setInfix(MulOp, FloatKind, FloatKind, FloatKind, floatOp(func(a, b float64) float64 { return a * b }))
+	setInfix(DivOp, FloatKind, FloatKind, FloatKind, floatOp(func(a, b float64) float64 { return a / b }))
+	setInfix(PowOp, FloatKind, FloatKind, FloatKind, floatOp(math.Pow))
+	setInfix(AddOp, StringKind, StringKind, StringKind, func(lhs, rhs Value) (Value, error) {
+		return StringValue(lhs.Str() + rhs.Str()), nil
+	})
+	for _, kind := range []Kind{IntKind, FloatKind} {
+		setPrefix(AddOp, kind, kind, func(rhs Value) (Value, error) {
+			return rhs, nil
+		})
+	}
+	setPrefix(SubOp, IntKind, IntKind, func(rhs Value) (Value, error) {
+		return IntValue(-rhs.Int()), nil
+	})
+	setPrefix(SubOp, FloatKind, FloatKind, func(rhs Value) (Value, error) {
+		return FloatValue(-rhs.Float()), nil
+	})
+}
+
+// Overload implements the infix operator symbol for operands of kinds lhs
+// and rhs with fn, which returns values of kind result, replacing any
+// implementation for those kinds. Integer operands are not converted to
+// floats for an implementation taking them. Like the Register functions,
+// it must not be called while expressions are parsed or evaluated.
+func Overload(symbol string, lhs Kind, rhs Kind, result Kind, fn InfixImpl) {
+	setInfix(overloadedOperator(symbol), lhs, rhs, result, fn)
+}
+
+// OverloadPrefix implements the prefix operator symbol for an operand of
+// kind rhs as Overload does.
+func OverloadPrefix(symbol string, rhs Kind, result Kind, fn PrefixImpl) {
+	setPrefix(overloadedOperator(symbol), rhs, result, fn)
+}
+
+func overloadedOperator(symbol string) OpKind {
+	op := lookupOperator(symbol)
+	if op == NoOp {
+		panic(fmt.Sprintf("overload of unknown operator %q", symbol))
+	}
+	return op
+}
+
+func checkKind(k Kind) {
+	if k < 0 || int(k) >= kindCount {
+		panic(fmt.Sprintf("invalid kind %d", int(k)))
+	}
+}
+
+func setInfix(op OpKind, lhs Kind, rhs Kind, result Kind, fn InfixImpl) {
+	checkKind(lhs)
+	checkKind(rhs)
+	for len(infixImpls) <= int(op) {
+		infixImpls = append(infixImpls, [kindCount][kindCount]overload{})
+	}
+	infixImpls[op][lhs][rhs] = overload{result: result, infix: fn}
+}
+
+func setPrefix(op OpKind, rhs Kind, result Kind, fn PrefixImpl) {
+	checkKind(rhs)
+	for len(prefixImpls) <= int(op) {
+		prefixImpls = append(prefixImpls, [kindCount]overload{})
+	}
+	prefixImpls[op][rhs] = overload{result: result, prefix: fn}
+}
+
+// infixOverload returns the implementation of op for operands of kinds lhs
+// and rhs, and whether the operands must be converted to floats for it.
+func infixOverload(op OpKind, lhs Kind, rhs Kind) (overload, bool) {
+	if op < 0 || int(op) >= len(infixImpls) || lhs < 0 || rhs < 0 || int(lhs) >= kindCount || int(rhs) >= kindCount {
+		return overload{}, false
+	}
+	if o := infixImpls[op][lhs][rhs]; o.infix != nil {
+		return o, false
+	}
+	if isNumericKind(lhs) && isNumericKind(rhs) {
+		if o := infixImpls[op][FloatKind][FloatKind]; o.infix != nil {
+			return o, true
+		}
+	}
+	return overload{}, false
+}
+
+func prefixOverload(op OpKind, rhs Kind) overload {
+	if op < 0 || int(op) >= len(prefixImpls) || rhs < 0 || int(rhs) >= kindCount {
+		return overload{}
+	}
+	return prefixImpls[op][rhs]
+}
+
+func applyPrefix(op OpKind, rhs Value) (Value, error) {
+	if value, ok, err := applyArithmeticPrefix(op, rhs); ok {
+		return value, err
+	}
+	if o := prefixOverload(op, rhs.Kind()); o.prefix != nil {
+		return o.prefix(rhs)
+	}
+	return Value{}, mismatchError(CodePrefixMismatch, op.String(), rhs.Kind().String())
+}
+
+func applyInfix(op OpKind, lhs Value, rhs Value) (Value, error) {
+	if value, ok, err := applyArithmeticInfix(op, lhs, rhs); ok {
+		return value, err
+	}
+	o, toFloat := infixOverload(op, lhs.Kind(), rhs.Kind())
+	if o.infix == nil {
+		return Value{}, mismatchError(CodeInfixMismatch, op.String(), lhs.Kind().String(), rhs.Kind().String())
+	}
+	if toFloat {
+		a, _ := lhs.AsFloat()
+		b, _ := rhs.AsFloat()
+		return o.infix(FloatValue(a), FloatValue(b))
+	}
+	return o.infix(lhs, rhs)
+}
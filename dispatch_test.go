@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		op       OpKind
+		lhs, rhs Value
+		want     Value
+		err      string
+	}{
+		{op: AddOp, lhs: IntValue(1), rhs: IntValue(2), want: IntValue(3)},
+		{op: DivOp, lhs: IntValue(7), rhs: IntValue(2), want: IntValue(3)},
+		{op: DivOp, lhs: IntValue(7), rhs: FloatValue(2), want: FloatValue(3.5)},
+		{op: PowOp, lhs: FloatValue(4), rhs: IntValue(-1), want: FloatValue(0.25)},
+		{op: SubOp, lhs: FloatValue(1), rhs: FloatValue(0.25), want: FloatValue(0.75)},
+		{op: AddOp, lhs: StringValue("a"), rhs: StringValue("b"), want: StringValue("ab")},
+		{op: DivOp, lhs: IntValue(1), rhs: IntValue(0), err: "division by zero"},
+		{op: AddOp, lhs: BoolValue(true), rhs: StringValue("a"), err: "operator '+' not defined for bool and string"},
+		{op: AddOp, lhs: StringValue("a"), rhs: IntValue(1), err: "operator '+' not defined for string and int"},
+		{op: MulOp, lhs: ListValue(nil), rhs: ListValue(nil), err: "operator '*' not defined for list and list"},
+	}
+	for _, tt := range tests {
+		got, err := applyInfix(tt.op, tt.lhs, tt.rhs)
+		if errorString(err) != tt.err || err == nil && (!got.Equal(tt.want) || got.Kind() != tt.want.Kind()) {
+			t.Errorf("%s %s %s = %s, %v, want %s, %q", tt.lhs, tt.op, tt.rhs, got, err, tt.want, tt.err)
+		}
+	}
+	if got, err := applyPrefix(SubOp, FloatValue(2)); err != nil || !got.Equal(FloatValue(-2)) {
+		t.Errorf("-2.0 = %s, %v", got, err)
+	}
+	if _, err := applyPrefix(SubOp, StringValue("a")); errorString(err) != "operator '-' not defined for string" {
+		t.Errorf(`-"a" = %v`, err)
+	}
+}
+
+func TestOverload(t *testing.T) {
+	repeat := func(lhs, rhs Value) (Value, error) {
+		return StringValue(strings.Repeat(lhs.Str(), int(rhs.Int()))), nil
+	}
+	Overload("*", StringKind, IntKind, StringKind, repeat)
+	// An implementation for an int and a float gets the int as it is.
+	Overload("+", IntKind, FloatKind, StringKind, func(lhs, rhs Value) (Value, error) {
+		return StringValue(lhs.Kind().String() + "+" + rhs.Kind().String()), nil
+	})
+	OverloadPrefix("-", StringKind, StringKind, func(rhs Value) (Value, error) {
+		return StringValue(strings.ToUpper(rhs.Str())), nil
+	})
+	defer func() {
+		infixImpls[MulOp][StringKind][IntKind] = overload{}
+		infixImpls[AddOp][IntKind][FloatKind] = overload{}
+		prefixImpls[SubOp][StringKind] = overload{}
+	}()
+	env := Env{"s": StringValue("ab"), "n": IntValue(3), "x": FloatValue(0.5)}
+	schema := Schema{"s": StringKind, "n": IntKind, "x": FloatKind}
+	tests := map[string]Value{
+		"s * n":     StringValue("ababab"),
+		"-s":        StringValue("AB"),
+		"n + x":     StringValue("int+float"),
+		"x + n":     FloatValue(3.5),
+		"n * x * 2": FloatValue(3),
+	}
+	for src, want := range tests {
+		e := mustParse(t, src)
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(want) || got.Kind() != want.Kind() {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", src, b, got, err, want)
+			}
+		}
+		if kind, errs := Check(e, schema); kind != want.Kind() || len(errs) > 0 {
+			t.Errorf("Check(%s) = %s, %v, want %s", src, kind, errs, want.Kind())
+		}
+	}
+}
+
+func TestOverloadPanics(t *testing.T) {
+	tests := map[string]func(){
+		"unknown operator": func() {
+			Overload("<~>", IntKind, IntKind, IntKind, nil)
+		},
+		"invalid kind": func() {
+			OverloadPrefix("-", Kind(100), IntKind, nil)
+		},
+	}
+	for name, fn := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("overload with an %s did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+// DryRun checks e as evaluating it against env would, without calling any
+// function, for validating formulas against production data where calls
+// may have side effects. Variables are typed by their values in env, and
+// calls of the functions in functions, which need not be in env, are
+// checked against their signatures and typed by their Result; calls of
+// undeclared functions have an unknown kind. It returns the kind e would
+// evaluate to and every problem found.
+func DryRun(e Expression, env Env, functions map[string]Signature) (Kind, []TypeError) {
+	schema := make(Schema, len(env))
+	for name, value := range env {
+		schema[name] = value.Kind()
+	}
+	c := &typeChecker{schema: schema, functions: functions}
+	kind := c.check(e)
+	return kind, c.errors
+}
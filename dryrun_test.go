@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	called := false
+	env := Env{
+		"price": FloatValue(9.5),
+		"qty":   IntValue(3),
+		"sku":   StringValue("A1"),
+		"charge": FuncValue(func(args []Value) (Value, error) {
+			called = true
+			return FloatValue(0), nil
+		}),
+	}
+	functions := map[string]Signature{
+		"charge": {Result: FloatKind, Params: []Kind{FloatKind}},
+		"lookup": {Result: StringKind, Params: []Kind{StringKind}},
+	}
+	tests := []struct {
+		src    string
+		kind   Kind
+		errors []string
+	}{
+		{"charge(price * qty) + 1", FloatKind, nil},
+		{`lookup(sku) + "!"`, StringKind, nil},
+		{"charge(qty) > price", BoolKind, nil},
+		{"int(charge(price))", IntKind, nil},
+		{"audit(price)", unknownKind, []string{"undefined function 'audit' at column 1"}},
+		{"charge(sku)", FloatKind, []string{"argument 1 of 'charge' must be float, not string at column 8"}},
+		{"lookup(sku) * qty", unknownKind, []string{"operator '*' not defined for string and int at column 13"}},
+		{"charge(price) + qtty", unknownKind, []string{"undefined variable 'qtty' at column 17; did you mean 'qty'?"}},
+	}
+	for _, tt := range tests {
+		kind, errs := DryRun(mustParse(t, tt.src), env, functions)
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = err.Error()
+		}
+		if kind != tt.kind || !equalStrings(got, tt.errors) {
+			t.Errorf("DryRun(%s) = %s, %q, want %s, %q", tt.src, kind, got, tt.kind, tt.errors)
+		}
+	}
+	if called {
+		t.Errorf("DryRun called a function")
+	}
+}
+
+func TestDryRunFlag(t *testing.T) {
+	tests := []struct {
+		input string
+		code  int
+		out   string
+	}{
+		{"x * 2\n", exitOK, "float\n"},
+		{`str(x) + "!"` + "\n", exitOK, "string\n"},
+		{"f(x)\n", exitParseError, "'f' is not a function at column 1\n"},
+		{`x + "a"` + "\n", exitParseError, "operator '+' not defined for float and string at column 3\n"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		code := runEval([]string{"-dry-run", "-var", "x=1.5", "-var", "f=1"}, strings.NewReader(tt.input), &out)
+		if code != tt.code || out.String() != tt.out {
+			t.Errorf("-dry-run of %q exits with %d printing %q, want %d, %q", tt.input, code, out.String(), tt.code, tt.out)
+		}
+	}
+}
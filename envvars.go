@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// WithEnvReferences lets expressions name process environment variables,
+// as env.PRICE or ${PRICE}. Each reference is parsed as a variable named as
+// written, which BindEnvVars then binds.
+func WithEnvReferences() ParseOption {
+	return func(l *Lexer) {
+		l.envReferences = true
+	}
+}
+
+// envReferenceAt returns the length of the environment variable reference
+// at the start of s, or 0 if there is none or references are not enabled.
+func (l *Lexer) envReferenceAt(s string) int {
+	if !l.envReferences {
+		return 0
+	}
+	if strings.HasPrefix(s, "${") {
+		n := identifierLength(s[2:])
+		if n > 0 && 2+n < len(s) && s[2+n] == '}' {
+			return n + 3
+		}
+	} else if strings.HasPrefix(s, "env.") {
+		if n := identifierLength(s[4:]); n > 0 {
+			return n + 4
+		}
+	}
+	return 0
+}
+
+func identifierLength(s string) int {
+	if s == "" || !isIdentifierStart(s[0]) {
+		return 0
+	}
+	n := 1
+	for n < len(s) && isIdentifierChar(s[n]) {
+		n++
+	}
+	return n
+}
+
+// envVarName returns the environment variable a reference such as env.PRICE
+// or ${PRICE} names.
+func envVarName(name string) (string, bool) {
+	if strings.HasPrefix(name, "${") && strings.HasSuffix(name, "}") {
+		return name[2 : len(name)-1], true
+	}
+	if strings.HasPrefix(name, "env.") {
+		return name[4:], true
+	}
+	return "", false
+}
+
+// BindEnvVars binds each environment variable reference in e to the value
+// of the variable, coerced as a CSV cell is: an integer, then a float, then
+// true or false, and otherwise the text as a string. References to unset
+// variables are left unbound so evaluating them fails. Variables already in
+// env are kept, so they can be overridden.
+func BindEnvVars(e Expression, env Env) {
+	for _, name := range Variables(e) {
+		if _, bound := env[name]; bound {
+			continue
+		}
+		if key, ok := envVarName(name); ok {
+			if text, set := os.LookupEnv(key); set {
+				env[name] = cellValue(text)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnvReferences(t *testing.T) {
+	t.Setenv("PRATT_PRICE", "12")
+	t.Setenv("PRATT_RATE", "0.5")
+	t.Setenv("PRATT_NAME", "pen")
+	tests := []struct {
+		src  string
+		env  Env
+		want Value
+	}{
+		{"env.PRATT_PRICE * 2", nil, IntValue(24)},
+		{"${PRATT_PRICE} + ${PRATT_RATE}", nil, FloatValue(12.5)},
+		{"env.PRATT_NAME == \"pen\"", nil, BoolValue(true)},
+		{"env.PRATT_PRICE + 1", Env{"env.PRATT_PRICE": IntValue(1)}, IntValue(2)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src, WithEnvReferences())
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", tt.src, err)
+			continue
+		}
+		env := Env{}
+		for name, value := range tt.env {
+			env[name] = value
+		}
+		BindEnvVars(e, env)
+		got, err := NewEvaluator(env).Eval(e)
+		if err != nil || !got.Equal(tt.want) || got.Kind() != tt.want.Kind() {
+			t.Errorf("%s = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+
+	e, err := Parse("${PRATT_UNSET_VARIABLE} + 1", WithEnvReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := Env{}
+	BindEnvVars(e, env)
+	if _, err := NewEvaluator(env).Eval(e); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("unset variable gives %v, want %v", err, ErrUndefinedVariable)
+	}
+
+	for _, src := range []string{"${PRATT_PRICE}", "${PRATT_PRICE"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) without WithEnvReferences did not fail", src)
+		}
+	}
+}
+
+func TestEnvVarName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"env.PRICE", "PRICE", true},
+		{"${PRICE}", "PRICE", true},
+		{"PRICE", "", false},
+		{"environment", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := envVarName(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("envVarName(%q) = %q, %t, want %q, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
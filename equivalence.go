@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Domain draws sample values for a variable when checking equivalence.
+type Domain func(rng *rand.Rand) Value
+
+// IntRange samples integers in [lo, hi].
+func IntRange(lo int64, hi int64) Domain {
+	return func(rng *rand.Rand) Value {
+		return IntValue(lo + rng.Int63n(hi-lo+1))
+	}
+}
+
+// FloatRange samples floats in [lo, hi).
+func FloatRange(lo float64, hi float64) Domain {
+	return func(rng *rand.Rand) Value {
+		return FloatValue(lo + rng.Float64()*(hi-lo))
+	}
+}
+
+// OneOf samples from the given values.
+func OneOf(values ...Value) Domain {
+	return func(rng *rand.Rand) Value {
+		return values[rng.Intn(len(values))]
+	}
+}
+
+// defaultDomain samples variables without a declared domain.
+var defaultDomain = IntRange(-100, 100)
+
+// equivalenceSamples is how many assignments Equivalent tries before
+// calling two expressions equivalent.
+const equivalenceSamples = 200
+
+type Equivalence int
+
+const (
+	// Identical expressions have the same tree and differ at most in
+	// formatting and redundant parentheses.
+	Identical Equivalence = iota
+	// SameNormalForm expressions have the same tree once constants are
+	// folded and the operands of commutative operators are ordered.
+	SameNormalForm
+	// ProbablyEquivalent expressions agreed on every sampled assignment.
+	ProbablyEquivalent
+	// NotEquivalent expressions disagreed on the assignment reported.
+	NotEquivalent
+)
+
+func (e Equivalence) String() string {
+	switch e {
+	case Identical:
+		return "identical"
+	case SameNormalForm:
+		return "same normal form"
+	case ProbablyEquivalent:
+		return "probably equivalent"
+	case NotEquivalent:
+		return "not equivalent"
+	}
+	return "unknown"
+}
+
+// EquivalenceResult reports how two expressions compare. For NotEquivalent,
+// Counterexample holds the assignment they disagree on and Left and Right
+// what each evaluated to, with LeftErr or RightErr set if it failed.
+type EquivalenceResult struct {
+	Verdict        Equivalence
+	Counterexample Env
+	Left           Value
+	Right          Value
+	LeftErr        error
+	RightErr       error
+}
+
+// Equivalent reports whether a and b compute the same thing. Trees that
+// match after normalization are equivalent outright; otherwise both are
+// evaluated on assignments drawn from varDomains, with variables missing
+// from it drawn from integers in [-100, 100]. Functions without a domain
+// are treated as unknown but pure, returning the same random integer for
+// the same arguments within an assignment. Two failed evaluations count as
+// agreeing, and floats agree within a relative 1e-9.
+func Equivalent(a Expression, b Expression, varDomains map[string]Domain) EquivalenceResult {
+	if equalExpr(a, b) {
+		return EquivalenceResult{Verdict: Identical}
+	}
+	if equalExpr(normalize(a, varDomains), normalize(b, varDomains)) {
+		return EquivalenceResult{Verdict: SameNormalForm}
+	}
+	names := append(Variables(a), Variables(b)...)
+	functions := append(Calls(a), Calls(b)...)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < equivalenceSamples; i++ {
+		env := make(Env)
+		for _, name := range names {
+			env[name] = sampleDomain(rng, varDomains, name)
+		}
+		for _, name := range functions {
+			if domain, ok := varDomains[name]; ok {
+				env[name] = domain(rng)
+			} else {
+				env[name] = FuncValue(uninterpretedFunction(rng))
+			}
+		}
+		left, leftErr := evalExpression(a, env)
+		right, rightErr := evalExpression(b, env)
+		if leftErr != nil && rightErr != nil {
+			continue
+		}
+		if leftErr == nil && rightErr == nil && valuesClose(left, right) {
+			continue
+		}
+		return EquivalenceResult{
+			Verdict:        NotEquivalent,
+			Counterexample: env,
+			Left:           left,
+			Right:          right,
+			LeftErr:        leftErr,
+			RightErr:       rightErr,
+		}
+	}
+	return EquivalenceResult{Verdict: ProbablyEquivalent}
+}
+
+func sampleDomain(rng *rand.Rand, domains map[string]Domain, name string) Value {
+	if domain, ok := domains[name]; ok {
+		return domain(rng)
+	}
+	return defaultDomain(rng)
+}
+
+// uninterpretedFunction returns a function answering each distinct argument
+// list with its own random integer.
+func uninterpretedFunction(rng *rand.Rand) Function {
+	seed := rng.Int63()
+	results := make(map[string]Value)
+	return func(args []Value) (Value, error) {
+		key := ListValue(args).String()
+		if v, ok := results[key]; ok {
+			return v, nil
+		}
+		v := defaultDomain(rand.New(rand.NewSource(seed + int64(len(results)))))
+		results[key] = v
+		return v, nil
+	}
+}
+
+func valuesClose(a Value, b Value) bool {
+	if a.Kind() == FloatKind || b.Kind() == FloatKind {
+		x, errX := a.AsFloat()
+		y, errY := b.AsFloat()
+		if errX != nil || errY != nil || !a.IsNumeric() || !b.IsNumeric() {
+			return false
+		}
+		if x == y || math.IsNaN(x) && math.IsNaN(y) {
+			return true
+		}
+		return math.Abs(x-y) <= 1e-9*math.Max(math.Abs(x), math.Abs(y))
+	}
+	return a.Equal(b)
+}
+
+// normalize folds constants in e and orders the operands of * and of a +
+// whose operands are both numbers, by their formatted text. A + that may
+// join strings is left alone since concatenation does not commute.
+func normalize(e Expression, domains map[string]Domain) Expression {
+	schema := make(Schema)
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range Variables(e) {
+		schema[name] = sampleDomain(rng, domains, name).Kind()
+	}
+	return sortOperands(Fold(e), schema)
+}
+
+func sortOperands(e Expression, schema Schema) Expression {
+	switch v := e.(type) {
+	case *PrefixExpression:
+		return &PrefixExpression{op: v.op, rhs: sortOperands(v.rhs, schema), pos: v.pos}
+	case *InfixExpression:
+		lhs := sortOperands(v.lhs, schema)
+		rhs := sortOperands(v.rhs, schema)
+		if commutes(v.op, lhs, rhs, schema) && Format(rhs) < Format(lhs) {
+			lhs, rhs = rhs, lhs
+		}
+		return &InfixExpression{lhs: lhs, rhs: rhs, op: v.op, pos: v.pos}
+	case *CallExpression:
+		args := make([]Expression, len(v.args))
+		for i, arg := range v.args {
+			args[i] = sortOperands(arg, schema)
+		}
+		return &CallExpression{name: v.name, args: args, pos: v.pos}
+	}
+	return e
+}
+
+func commutes(op OpKind, lhs Expression, rhs Expression, schema Schema) bool {
+	if operatorFunction(op, true) != "" {
+		return false
+	}
+	switch op {
+	case MulOp:
+		return true
+	case AddOp:
+		lhsKind, lhsErrs := Check(lhs, schema)
+		rhsKind, rhsErrs := Check(rhs, schema)
+		return len(lhsErrs) == 0 && len(rhsErrs) == 0 && isNumericKind(lhsKind) && isNumericKind(rhsKind)
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		domains map[string]Domain
+		want    Equivalence
+	}{
+		{"a + b * c", "a + (b*c)", nil, Identical},
+		{"b + a", "a + b", nil, SameNormalForm},
+		{"a * (1 + 2)", "3 * a", nil, SameNormalForm},
+		{"(a + b) * 2", "2 * a + 2 * b", nil, ProbablyEquivalent},
+		{"f(x) + f(x)", "2 * f(x)", nil, ProbablyEquivalent},
+		{"x - y", "y - x", nil, NotEquivalent},
+		{"a / 2", "a * 0.5", nil, NotEquivalent},
+		{"a / 2", "a * 0.5", map[string]Domain{"a": FloatRange(-10, 10)}, ProbablyEquivalent},
+		{"a == 1 || a == 2", "a < 3", map[string]Domain{"a": OneOf(IntValue(1), IntValue(2))}, ProbablyEquivalent},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := Equivalent(a, b, tt.domains)
+		if result.Verdict != tt.want {
+			t.Errorf("Equivalent(%s, %s) = %s, want %s", tt.a, tt.b, result.Verdict, tt.want)
+			continue
+		}
+		if result.Verdict != NotEquivalent {
+			continue
+		}
+		// The counterexample must reproduce the disagreement.
+		left, leftErr := evalExpression(a, result.Counterexample)
+		right, rightErr := evalExpression(b, result.Counterexample)
+		if (leftErr == nil) == (rightErr == nil) && left.Equal(right) {
+			t.Errorf("%s and %s agree on the counterexample %v", tt.a, tt.b, result.Counterexample)
+		}
+		if !left.Equal(result.Left) || !right.Equal(result.Right) {
+			t.Errorf("Equivalent(%s, %s) reports %s and %s, want %s and %s", tt.a, tt.b, result.Left, result.Right, left, right)
+		}
+	}
+}
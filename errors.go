@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The categories of error that parsing and evaluating report, for callers
+// to test for with errors.Is. The errors returned carry the position and
+// details, and match the category they belong to.
+var (
+	ErrSyntax            = errors.New("syntax error")
+	ErrUndefinedVariable = errors.New("undefined variable")
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrTypeMismatch      = errors.New("type mismatch")
+	// ErrInputTooLarge is wrapped by the SyntaxError of input over the
+	// limits set by WithInputLimits.
+	ErrInputTooLarge = errors.New("input too large")
+)
+
+// EvalError is an evaluation error at Pos. Err is the error it wraps,
+// which is or wraps one of the categories above where one applies.
+// Suggestion is a name that may have been meant instead of a missing one.
+type EvalError struct {
+	Pos        int
+	Msg        string
+	Err        error
+	Suggestion string
+	// message is Msg by code, for Localize.
+	message *message
+}
+
+func (e EvalError) Error() string {
+	return didYouMean(fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1), e.Suggestion, English)
+}
+
+func (e EvalError) Unwrap() error {
+	return e.Err
+}
+
+func (e SyntaxError) Is(target error) bool {
+	return target == ErrSyntax
+}
+
+func (e SyntaxError) Unwrap() error {
+	return e.cause
+}
+
+// categoryError gives an error of a category its own message.
+type categoryError struct {
+	message  *message
+	category error
+}
+
+func (e *categoryError) Error() string {
+	return e.message.format(English)
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.category
+}
+
+func mismatchError(code string, args ...interface{}) error {
+	return &categoryError{message: &message{code: code, args: args}, category: ErrTypeMismatch}
+}
+
+// applyPrefixAt applies op as applyPrefix does, placing any error at pos.
+func applyPrefixAt(op OpKind, rhs Value, pos int) (Value, error) {
+	value, err := applyPrefix(op, rhs)
+	if err != nil {
+		return Value{}, EvalError{Pos: pos, Msg: err.Error(), Err: err}
+	}
+	return value, nil
+}
+
+// applyInfixAt applies op as applyInfix does, placing any error at pos.
+func applyInfixAt(op OpKind, lhs Value, rhs Value, pos int) (Value, error) {
+	value, err := applyInfix(op, lhs, rhs)
+	if err != nil {
+		return Value{}, EvalError{Pos: pos, Msg: err.Error(), Err: err}
+	}
+	return value, nil
+}
+
+// errorKind names the kind of an evaluation error for metrics.
+func errorKind(err error) string {
+	var policyErr PolicyError
+	var assertionErr AssertionError
+	switch {
+	case errors.Is(err, ErrUndefinedVariable):
+		return "undefined_variable"
+	case errors.Is(err, ErrDivisionByZero):
+		return "division_by_zero"
+	case errors.Is(err, ErrTypeMismatch):
+		return "type_mismatch"
+	case errors.Is(err, ErrStepLimit):
+		return "step_limit"
+	case errors.As(err, new(RecursionLimitError)):
+		return "recursion_limit"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "stopped"
+	case errors.As(err, &policyErr):
+		return "policy"
+	case errors.As(err, &assertionErr):
+		return "assertion"
+	}
+	return "other"
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorCategories(t *testing.T) {
+	env := Env{"n": IntValue(1), "s": StringValue("a")}
+	tests := []struct {
+		src      string
+		category error
+		pos      int
+		msg      string
+		kind     string
+	}{
+		{"n + m", ErrUndefinedVariable, 4, "undefined variable 'm'", "undefined_variable"},
+		{"2 * (n / 0)", ErrDivisionByZero, 7, "division by zero", "division_by_zero"},
+		{"1 + s * 2", ErrTypeMismatch, 6, "operator '*' not defined for string and int", "type_mismatch"},
+		{"-s", ErrTypeMismatch, 0, "operator '-' not defined for string", "type_mismatch"},
+	}
+	for _, tt := range tests {
+		for _, b := range allBackends {
+			_, err := NewEvaluator(env, WithBackend(b)).Eval(mustParse(t, tt.src))
+			var evalErr EvalError
+			if !errors.Is(err, tt.category) || !errors.As(err, &evalErr) || evalErr.Pos != tt.pos || evalErr.Msg != tt.msg {
+				t.Errorf("%s with the %s backend = %#v, want %v at %d: %s", tt.src, b, err, tt.category, tt.pos, tt.msg)
+			}
+			if got := errorKind(err); got != tt.kind {
+				t.Errorf("errorKind(%v) = %s, want %s", err, got, tt.kind)
+			}
+		}
+	}
+	_, err := NewEvaluator(env).Eval(mustParse(t, "n / 0"))
+	for _, category := range []error{ErrUndefinedVariable, ErrTypeMismatch, ErrSyntax} {
+		if errors.Is(err, category) {
+			t.Errorf("division by zero is %v", category)
+		}
+	}
+}
+
+func TestSyntaxErrorCategory(t *testing.T) {
+	_, err := Parse("1 + * 2")
+	var syntaxErr SyntaxError
+	if !errors.Is(err, ErrSyntax) || !errors.As(err, &syntaxErr) || syntaxErr.Pos != 4 {
+		t.Errorf("Parse(1 + * 2) = %#v, want a syntax error at 4", err)
+	}
+	if errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("a syntax error is a type mismatch")
+	}
+}
+
+func TestJSONErrorPositions(t *testing.T) {
+	var out strings.Builder
+	runEval([]string{"-json"}, strings.NewReader("1 / 0\nx + 1\n"), &out)
+	want := `{"input":"1 / 0","error":{"code":"eval","pos":2,"message":"division by zero"}}` + "\n" +
+		`{"input":"x + 1","error":{"code":"eval","pos":0,"message":"undefined variable 'x'"}}` + "\n"
+	if out.String() != want {
+		t.Errorf("-json printed\n%swant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Evaluator holds the environment and options shared by many evaluations.
+// Eval may be called concurrently as long as the Env is not modified.
+type Evaluator struct {
+	env      Env
+	policy   *Policy
+	memoize  bool
+	holes    func(index int) (Value, bool)
+	observer EvalObserver
+	metrics  Metrics
+	backend  Backend
+	output   io.Writer
+	// stepLimit is set by WithStepLimit.
+	stepLimit int
+	// recursionLimit is set by WithRecursionLimit.
+	recursionLimit int
+	// includePath is set by WithIncludePath.
+	includePath []string
+}
+
+type EvalOption func(*Evaluator)
+
+func NewEvaluator(env Env, opts ...EvalOption) *Evaluator {
+	ev := &Evaluator{
+		env: env,
+	}
+	for _, opt := range opts {
+		opt(ev)
+	}
+	return ev
+}
+
+func (ev *Evaluator) Eval(e Expression) (Value, error) {
+	return ev.EvalContext(context.Background(), e)
+}
+
+// EvalContext evaluates e like Eval, stopping with an error wrapping
+// ctx.Err() once ctx is done, so that a service can give each request a
+// deadline. ctx is checked before each node is evaluated, so a context that
+// can be done makes the Evaluator walk the tree whatever the backend.
+func (ev *Evaluator) EvalContext(ctx context.Context, e Expression) (Value, error) {
+	if ev.metrics != nil {
+		start := time.Now()
+		value, err := ev.eval(ctx, e)
+		ev.metrics.Observe(MetricEvalDuration, time.Since(start))
+		ev.metrics.Count(MetricEvals, 1)
+		if err != nil {
+			ev.metrics.Count(MetricEvalErrors, 1, Label{Name: "kind", Value: errorKind(err)})
+		}
+		return value, err
+	}
+	return ev.eval(ctx, e)
+}
+
+func (ev *Evaluator) eval(ctx context.Context, e Expression) (Value, error) {
+	if ev.policy != nil {
+		if err := ev.policy.Check(e); err != nil {
+			return Value{}, err
+		}
+	}
+	if ev.backend != TreeBackend && !ev.memoize && ev.holes == nil && ev.observer == nil && ev.output == nil && ev.stepLimit <= 0 && ctx.Done() == nil {
+		if program, err := CompileBackend(e, ev.backend); err == nil {
+			return program.Run(ev.env)
+		}
+	}
+	evaluation := ev.newEvaluation()
+	evaluation.ctx = ctx
+	return evaluation.eval(e)
+}
+
+// newEvaluation prepares a tree-walking evaluation with ev's options.
+func (ev *Evaluator) newEvaluation() *evaluation {
+	evaluation := &evaluation{
+		env:            ev.env,
+		holes:          ev.holes,
+		observer:       ev.observer,
+		output:         ev.output,
+		stepLimit:      ev.stepLimit,
+		recursionLimit: ev.recursionLimit,
+		budget:         &evalBudget{},
+	}
+	if ev.memoize {
+		evaluation.memo = make(map[uint64]memoEntry)
+	}
+	return evaluation
+}
+
+// WithMemoization computes each distinct pure subexpression once per Eval.
+// Subtrees containing calls are always evaluated, since functions may have
+// side effects.
+func WithMemoization() EvalOption {
+	return func(ev *Evaluator) {
+		ev.memoize = true
+	}
+}
+
+// WithHoles supplies values for `_` placeholders by index. Evaluating a hole
+// for which fill returns false is an error.
+func WithHoles(fill func(index int) (Value, bool)) EvalOption {
+	return func(ev *Evaluator) {
+		ev.holes = fill
+	}
+}
+
+// EvalObserver is told about every node the Evaluator evaluates, for
+// profilers, debuggers and audit logs of the variables and functions a
+// formula touched. OnNodeStart is called before a node's operands are
+// evaluated and OnNodeResult once it has a value or has failed, with the
+// time taken including its operands. Nodes whose value is reused by
+// WithMemoization are reported with the time taken to look it up.
+type EvalObserver interface {
+	OnNodeStart(e Expression)
+	OnNodeResult(e Expression, value Value, err error, duration time.Duration)
+}
+
+// WithObserver reports the evaluation of each node to o. Evaluations may
+// run concurrently, so o must be safe for concurrent use if Eval is.
+func WithObserver(o EvalObserver) EvalOption {
+	return func(ev *Evaluator) {
+		ev.observer = o
+	}
+}
+
+// Policy restricts the operators and functions an untrusted expression may
+// use. A nil allow list permits everything not explicitly denied.
+type Policy struct {
+	AllowOperators []string
+	DenyOperators  []string
+	AllowFunctions []string
+	DenyFunctions  []string
+}
+
+func WithPolicy(p Policy) EvalOption {
+	return func(ev *Evaluator) {
+		ev.policy = &p
+	}
+}
+
+type PolicyError struct {
+	Pos int
+	Msg string
+}
+
+func (e PolicyError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
+}
+
+func permitted(name string, allow []string, deny []string) bool {
+	for _, denied := range deny {
+		if denied == name {
+			return false
+		}
+	}
+	if allow == nil {
+		return true
+	}
+	for _, allowed := range allow {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a PolicyError for the first node in e that uses an operator
+// or function the policy does not permit. Lets, lambdas, try and calls of
+// functions they bind locally are not calls of the host's functions, so
+// the function lists do not apply to them.
+func (p Policy) Check(e Expression) error {
+	var err error
+	walkScoped(e, func(e Expression, bound func(name string) bool) {
+		if err != nil {
+			return
+		}
+		switch v := e.(type) {
+		case *PrefixExpression:
+			if !permitted(v.op.String(), p.AllowOperators, p.DenyOperators) {
+				err = PolicyError{Pos: v.pos, Msg: fmt.Sprintf("operator '%s' is not permitted", v.op)}
+			}
+		case *InfixExpression:
+			if !permitted(v.op.String(), p.AllowOperators, p.DenyOperators) {
+				err = PolicyError{Pos: v.pos, Msg: fmt.Sprintf("operator '%s' is not permitted", v.op)}
+			}
+		case *CallExpression:
+			if !isSpecialForm(v) && !bound(v.name) && !permitted(v.name, p.AllowFunctions, p.DenyFunctions) {
+				err = PolicyError{Pos: v.pos, Msg: fmt.Sprintf("function '%s' is not permitted", v.name)}
+			}
+		}
+	})
+	return err
+}
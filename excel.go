@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxRangeCells bounds the cells a range such as A1:C3 expands to.
+const maxRangeCells = 10000
+
+// ExcelSyntax accepts the parts of spreadsheet formula syntax that map onto
+// expressions, for formulas pasted from a spreadsheet. A formula may start
+// with =. & joins values as text: a & b is parsed as str(a) + str(b), so
+// A1 & "x" is "1x" when A1 is 1. Percent literals such as 5% are parsed as
+// calls of percent. A range such as A1:B3 expands to its cells, A1, A2, A3,
+// B1, B2, B3, and is only allowed as an argument of a call. As in
+// spreadsheets, a sign binds tighter than ^, so -A1^2 is (-A1)^2.
+//
+// Cells are variables named as in the spreadsheet. ExcelFunctions provides
+// percent and common spreadsheet functions such as SUM.
+func ExcelSyntax() ParseOption {
+	return func(l *Lexer) {
+		l.excel = true
+	}
+}
+
+// scanExcel appends the tokens of the spreadsheet syntax at input[i:to]
+// to tokenArray and returns the number of bytes it covers, or 0 if there
+// is none or spreadsheet syntax is not enabled.
+func (l *Lexer) scanExcel(input string, i int, to int, tokenArray TokenArray) (TokenArray, int) {
+	if !l.excel {
+		return tokenArray, 0
+	}
+	s := input[i:to]
+	switch {
+	case s[0] == '=' && strings.TrimSpace(input[:i]) == "":
+		return tokenArray, 1
+	case s[0] >= '0' && s[0] <= '9':
+		n := 1
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		n += fractionLength(s[n:])
+		if n == len(s) || s[n] != '%' {
+			return tokenArray, 0
+		}
+		// The number is lexed by the usual rules.
+		digits := l.scan(input, i, i+n, nil)
+		return appendCallTokens(tokenArray, "percent", digits[0]), n + 1
+	}
+	first, firstRow, n := cellAt(s)
+	if n == 0 || n == len(s) || s[n] != ':' {
+		return tokenArray, 0
+	}
+	last, lastRow, m := cellAt(s[n+1:])
+	if m == 0 {
+		return tokenArray, 0
+	}
+	end := i + n + 1 + m
+	if first > last {
+		first, last = last, first
+	}
+	if firstRow > lastRow {
+		firstRow, lastRow = lastRow, firstRow
+	}
+	if !isArgument(input[:i], input[end:]) {
+		panic(SyntaxError{Pos: i, End: end, Msg: "range is only allowed as a function argument"})
+	}
+	if (last-first+1)*(lastRow-firstRow+1) > maxRangeCells {
+		panic(SyntaxError{Pos: i, End: end, Msg: fmt.Sprintf("range has more than %d cells", maxRangeCells)})
+	}
+	for column := first; column <= last; column++ {
+		for row := firstRow; row <= lastRow; row++ {
+			if column > first || row > firstRow {
+				tokenArray = append(tokenArray, Token{Kind: Operand, Lit: ",", Op: CommaOp, Pos: i, End: end})
+			}
+			name := fmt.Sprintf("%s%d", columnName(column), row)
+			tokenArray = append(tokenArray, Token{Kind: Identifier, Lit: name, Pos: i, End: end})
+		}
+	}
+	return tokenArray, end - i
+}
+
+// isArgument reports whether text between before and after is a whole
+// argument of a call: it follows the call's '(' or a ',' and comes before
+// a ',' or the closing ')'.
+func isArgument(before string, after string) bool {
+	before = strings.TrimRight(before, " \t\r\n")
+	after = strings.TrimLeft(after, " \t\r\n")
+	if after == "" || after[0] != ',' && after[0] != ')' || before == "" {
+		return false
+	}
+	switch before[len(before)-1] {
+	case ',':
+		return true
+	case '(':
+		callee := strings.TrimRight(before[:len(before)-1], " \t\r\n")
+		return callee != "" && isIdentifierChar(callee[len(callee)-1])
+	}
+	return false
+}
+
+// parseSign is the prefix rule for + and -, which bind tighter than ^ in
+// spreadsheet formulas and looser elsewhere.
+func parseSign(p *Parser) Expression {
+	tok := p.Token()
+	bp := prefixRules[tok.Op].bp
+	if (*Lexer)(p).excel {
+		bp = infixRules[PowOp].lbp + 1
+	}
+	rhs := p.Expression(bp)
+	prefix := p.arena.newPrefix()
+	*prefix = PrefixExpression{op: tok.Op, rhs: rhs, pos: tok.Pos}
+	return prefix
+}
+
+// parseExcelConcat is the infix rule for &, which converts both operands
+// to strings and adds them.
+func parseExcelConcat(p *Parser, lhs Expression) Expression {
+	l := (*Lexer)(p)
+	tok := l.prev
+	if !l.excel {
+		panic(tok.errorf("'&' joins strings only in spreadsheet formulas"))
+	}
+	rhs := p.Expression(infixRules[tok.Op].rbp)
+	infix := l.arena.newInfix()
+	*infix = InfixExpression{
+		lhs: newCall(l, IdentifierToken{name: "str", pos: lhs.getPosition()}, []Expression{lhs}),
+		rhs: newCall(l, IdentifierToken{name: "str", pos: rhs.getPosition()}, []Expression{rhs}),
+		op:  AddOp,
+		pos: tok.Pos,
+	}
+	return infix
+}
+
+// cellAt returns the column, counted from 1 for A, and row of the cell
+// reference such as B12 at the start of s, and its length, or a length of
+// 0 if there is none.
+func cellAt(s string) (int, int, int) {
+	column, n := 0, 0
+	for n < len(s) && s[n] >= 'A' && s[n] <= 'Z' && n < 3 {
+		column = column*26 + int(s[n]-'A') + 1
+		n++
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+	row, digits := 0, 0
+	for n+digits < len(s) && s[n+digits] >= '0' && s[n+digits] <= '9' && digits < 7 {
+		row = row*10 + int(s[n+digits]-'0')
+		digits++
+	}
+	if digits == 0 || row == 0 || n+digits < len(s) && isIdentifierChar(s[n+digits]) {
+		return 0, 0, 0
+	}
+	return column, row, n + digits
+}
+
+// columnName spells a column counted from 1 as the letters A to Z, AA and
+// so on.
+func columnName(column int) string {
+	var name []byte
+	for column > 0 {
+		column--
+		name = append([]byte{byte('A' + column%26)}, name...)
+		column /= 26
+	}
+	return string(name)
+}
+
+// ExcelFunctions returns the functions formulas parsed with ExcelSyntax
+// commonly call, to merge into an environment: percent, for percent
+// literals, and SUM, AVERAGE, MIN, MAX and CONCAT. SUM, MIN and MAX keep
+// integers integers.
+func ExcelFunctions() Env {
+	return Env{
+		"percent": FuncValue(func(args []Value) (Value, error) {
+			if len(args) != 1 || !args[0].IsNumeric() {
+				return Value{}, fmt.Errorf("percent takes a number")
+			}
+			f, _ := args[0].AsFloat()
+			return FloatValue(f / 100), nil
+		}),
+		"SUM": FuncValue(func(args []Value) (Value, error) {
+			return excelFold("SUM", args, func(a, b Value) (Value, error) {
+				return applyInfix(AddOp, a, b)
+			})
+		}),
+		"AVERAGE": FuncValue(func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return Value{}, fmt.Errorf("AVERAGE takes at least one number")
+			}
+			sum, err := excelFold("AVERAGE", args, func(a, b Value) (Value, error) {
+				return applyInfix(AddOp, a, b)
+			})
+			if err != nil {
+				return Value{}, err
+			}
+			f, _ := sum.AsFloat()
+			return FloatValue(f / float64(len(args))), nil
+		}),
+		"MIN": FuncValue(func(args []Value) (Value, error) {
+			return excelFold("MIN", args, func(a, b Value) (Value, error) {
+				return pick([]Value{a, b}, func(a, b float64) bool { return a < b })
+			})
+		}),
+		"MAX": FuncValue(func(args []Value) (Value, error) {
+			return excelFold("MAX", args, func(a, b Value) (Value, error) {
+				return pick([]Value{a, b}, func(a, b float64) bool { return a > b })
+			})
+		}),
+		"CONCAT": FuncValue(func(args []Value) (Value, error) {
+			var sb strings.Builder
+			for _, arg := range args {
+				if arg.Kind() == StringKind {
+					sb.WriteString(arg.Str())
+				} else {
+					sb.WriteString(arg.String())
+				}
+			}
+			return StringValue(sb.String()), nil
+		}),
+	}
+}
+
+// excelFold combines numeric args from the left, giving 0 for none.
+func excelFold(name string, args []Value, combine func(a, b Value) (Value, error)) (Value, error) {
+	result := IntValue(0)
+	for i, arg := range args {
+		if !arg.IsNumeric() {
+			return Value{}, fmt.Errorf("%s takes numbers, not %s", name, arg.Kind())
+		}
+		if i == 0 {
+			result = arg
+			continue
+		}
+		var err error
+		if result, err = combine(result, arg); err != nil {
+			return Value{}, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcelSyntax(t *testing.T) {
+	env := ExcelFunctions()
+	env["A1"] = IntValue(1)
+	env["A2"] = IntValue(2)
+	env["A3"] = IntValue(3)
+	env["B1"] = IntValue(10)
+	env["C1"] = StringValue("x")
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"=SUM(A1:A3)*B1", IntValue(60)},
+		{"=A1^2 + B1", IntValue(11)},
+		{`=A1&"x"`, StringValue("1x")},
+		{`=A1 + A2 & C1`, StringValue("3x")},
+		{`=C1 & A1 + A2`, StringValue("x3")},
+		{`=A1 & A2 & A3`, StringValue("123")},
+		{`=A1 & "" == "1"`, BoolValue(true)},
+		{"=B1 * 5%", FloatValue(0.5)},
+		{"=MAX(A1:A3) + MIN(A2, B1)", IntValue(5)},
+		// Signs bind tighter than ^ in spreadsheets.
+		{"=-A1^2", IntValue(1)},
+		{"=-A3^2 + B1", IntValue(19)},
+		{"=4.0^-A1", FloatValue(0.25)},
+		{"=B1 - -A2^2", IntValue(6)},
+		{"=SUM( A1:A2 , A3:A3 )", IntValue(6)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src, ExcelSyntax())
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestExcelConcatFormat(t *testing.T) {
+	e, err := Parse(`=A1&"x"`, ExcelSyntax())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Format(e), `str(A1) + str("x")`; got != want {
+		t.Errorf("Format = %s, want %s", got, want)
+	}
+}
+
+func TestConcatOnlyInExcelSyntax(t *testing.T) {
+	_, err := Parse(`a & "x"`)
+	if err == nil || !strings.Contains(err.Error(), "only in spreadsheet formulas") {
+		t.Errorf(`Parse(a & "x") = %v, want an error`, err)
+	}
+}
+
+func TestExcelRanges(t *testing.T) {
+	e, err := Parse("=SUM(B2:A1)", ExcelSyntax())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Format(e), "SUM(A1, A2, B1, B2)"; got != want {
+		t.Errorf("Format = %s, want %s", got, want)
+	}
+	if _, err := Parse("=SUM(A1:ZZ9999)", ExcelSyntax()); err == nil {
+		t.Error("a range of more than the maximum cells parsed")
+	}
+	for _, src := range []string{"=A3:A1", "=(A1:A2)", "=SUM(A1:A2 + 1)", "=SUM(1 + A1:A2)", "=A1:A2, B1"} {
+		if _, err := Parse(src, ExcelSyntax()); err == nil || !strings.Contains(err.Error(), "range is only allowed as a function argument") {
+			t.Errorf("Parse(%q) = %v, want the range refused", src, err)
+		}
+	}
+}
+
+func TestExcelSignsOnlyInExcelSyntax(t *testing.T) {
+	e := mustParse(t, "-2^2")
+	if got := parenthesize(e); got != "(-(2 ^ 2))" {
+		t.Errorf("Parse(-2^2) = %s, want (-(2 ^ 2))", got)
+	}
+	e, err := Parse("=-2^2", ExcelSyntax())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Format(e); got != "(-2) ^ 2" {
+		t.Errorf("Format(=-2^2) = %s, want (-2) ^ 2", got)
+	}
+}
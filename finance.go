@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The financial functions follow the spreadsheet conventions: rates are per
+// period, money paid out is negative and money received positive, and the
+// optional when argument is 0 for payments at the end of each period or 1
+// for payments at the start.
+func init() {
+	addBuiltin("pmt", "pmt(rate, nper, pv number[, fv, when number]) float", docEntry{"the payment each period that pays off a loan of pv over nper periods, leaving fv", "pmt(rate / 12, 360, 200000)"},
+		floatFunction("pmt", 3, 5, func(xs []float64) (float64, error) {
+			rate, nper, pv, fv, when, err := annuityArgs("pmt", xs)
+			if err != nil {
+				return 0, err
+			}
+			if nper == 0 {
+				return 0, fmt.Errorf("pmt: the number of periods must not be 0")
+			}
+			if rate == 0 {
+				return -(pv + fv) / nper, nil
+			}
+			growth := math.Pow(1+rate, nper)
+			return -rate * (fv + pv*growth) / ((1 + rate*when) * (growth - 1)), nil
+		}))
+	addBuiltin("fv", "fv(rate, nper, pmt number[, pv, when number]) float", docEntry{"the value after nper periods of paying pmt each period into an investment of pv", "fv(rate / 12, 120, -100)"},
+		floatFunction("fv", 3, 5, func(xs []float64) (float64, error) {
+			rate, nper, pmt, pv, when, err := annuityArgs("fv", xs)
+			if err != nil {
+				return 0, err
+			}
+			if rate == 0 {
+				return -(pv + pmt*nper), nil
+			}
+			growth := math.Pow(1+rate, nper)
+			return -(pv*growth + pmt*(1+rate*when)*(growth-1)/rate), nil
+		}))
+	addBuiltin("npv", "npv(rate number, values list) float", docEntry{"the present value of the cash flows at the ends of successive periods", "npv(rate, -1000, 300, 400, 500)"},
+		func(args []Value) (Value, error) {
+			if len(args) < 2 || !args[0].IsNumeric() {
+				return Value{}, fmt.Errorf("npv takes a rate and cash flows")
+			}
+			rate, _ := args[0].AsFloat()
+			flows, err := statValues("npv", args[1:], 1)
+			if err != nil {
+				return Value{}, err
+			}
+			return FloatValue(presentValue(rate, flows) / (1 + rate)), nil
+		})
+	addBuiltin("irr", "irr(values list) float", docEntry{"the rate at which the cash flows of successive periods, starting now, have a present value of 0", "irr(-1000, 300, 400, 500)"},
+		func(args []Value) (Value, error) {
+			flows, err := statValues("irr", args, 2)
+			if err != nil {
+				return Value{}, err
+			}
+			rate, err := irr(flows)
+			if err != nil {
+				return Value{}, err
+			}
+			return FloatValue(rate), nil
+		})
+}
+
+// floatFunction checks that a function is given between min and max numeric
+// arguments and applies fn to them as floats.
+func floatFunction(name string, min int, max int, fn func(xs []float64) (float64, error)) Function {
+	return func(args []Value) (Value, error) {
+		if len(args) < min || len(args) > max {
+			return Value{}, fmt.Errorf("%s takes %d to %d arguments", name, min, max)
+		}
+		xs := make([]float64, len(args))
+		for i, arg := range args {
+			if !arg.IsNumeric() {
+				return Value{}, fmt.Errorf("%s takes numbers, not %s", name, arg.Kind())
+			}
+			xs[i], _ = arg.AsFloat()
+		}
+		x, err := fn(xs)
+		if err != nil {
+			return Value{}, err
+		}
+		return FloatValue(x), nil
+	}
+}
+
+// annuityArgs returns the arguments of pmt or fv with the optional last two
+// defaulting to 0.
+func annuityArgs(name string, xs []float64) (rate, nper, amount, value, when float64, err error) {
+	xs = append(xs, 0, 0)
+	if xs[4] != 0 && xs[4] != 1 {
+		return 0, 0, 0, 0, 0, fmt.Errorf("%s: when must be 0 or 1, not %v", name, xs[4])
+	}
+	if xs[0] <= -1 {
+		return 0, 0, 0, 0, 0, fmt.Errorf("%s: rate must be greater than -1, not %v", name, xs[0])
+	}
+	return xs[0], xs[1], xs[2], xs[3], xs[4], nil
+}
+
+// presentValue returns the value at the time of the first of flows, which
+// are a period apart.
+func presentValue(rate float64, flows []float64) float64 {
+	pv := 0.0
+	for i := len(flows) - 1; i >= 0; i-- {
+		pv = pv/(1+rate) + flows[i]
+	}
+	return pv
+}
+
+// irr finds the rate at which flows have a present value of 0 with
+// Newton's method, starting from 10%.
+func irr(flows []float64) (float64, error) {
+	positive, negative := false, false
+	for _, f := range flows {
+		positive = positive || f > 0
+		negative = negative || f < 0
+	}
+	if !positive || !negative {
+		return 0, fmt.Errorf("irr needs both positive and negative cash flows")
+	}
+	rate := 0.1
+	for i := 0; i < 100; i++ {
+		pv, slope := 0.0, 0.0
+		for t, f := range flows {
+			d := math.Pow(1+rate, float64(t))
+			pv += f / d
+			slope -= float64(t) * f / (d * (1 + rate))
+		}
+		if slope == 0 {
+			break
+		}
+		next := rate - pv/slope
+		if next <= -1 || math.IsNaN(next) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-12 {
+			return next, nil
+		}
+		rate = next
+	}
+	return 0, fmt.Errorf("irr did not converge")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFinancialFunctions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"pmt(0.05, 10, 1000)", -129.5045749654566},
+		{"pmt(0, 10, 1000)", -100},
+		{"fv(0.05, 10, -100)", 1257.789253554884},
+		{"npv(0.1, -1000, 300, 400, 500)", -19.124376750222098},
+		{"npv(10%, -1000, 300, 400, 500)", -19.124376750222098},
+		{"npv(1e-1, flows)", -19.124376750222098},
+	}
+	env := ExcelFunctions()
+	env["flows"] = ListValue([]Value{IntValue(-1000), IntValue(300), IntValue(400), IntValue(500)})
+	for _, tt := range tests {
+		e, err := Parse(tt.src, ExcelSyntax())
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || got.Kind() != FloatKind || math.Abs(got.Float()-tt.want) > 1e-9 {
+				t.Errorf("%s with the %s backend = %s, %v, want %v", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestIRR(t *testing.T) {
+	e, err := Parse("npv(irr(-1000, 300, 400, 500), 300, 400, 500) - 1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewEvaluator(nil).Eval(e)
+	if err != nil || math.Abs(got.Float()) > 1e-6 {
+		t.Errorf("the cash flows discounted at their irr = %s, %v, want 0", got, err)
+	}
+	e, _ = Parse("irr(100, 200)")
+	if _, err := NewEvaluator(nil).Eval(e); err == nil || !strings.Contains(err.Error(), "positive and negative") {
+		t.Errorf("irr(100, 200) fails with %v", err)
+	}
+}
+
+func TestFinancialArguments(t *testing.T) {
+	for _, src := range []string{"pmt(0.05, 0, 1000)", "pmt(-1.5, 10, 1000)", "fv(0.05, 10, -100, 0, 2)", `pmt("a", 10, 1000)`, "pmt(0.05, 10)"} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if _, err := NewEvaluator(nil).Eval(e); err == nil {
+			t.Errorf("%s succeeded", src)
+		}
+	}
+}
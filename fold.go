@@ -0,0 +1,83 @@
+package main
+
+import "math"
+
+// Fold returns e with every operator whose operands are all constants
+// replaced by its result. Subtrees whose evaluation fails are left as they
+// are so the error still surfaces at evaluation time.
+func Fold(e Expression) Expression {
+	folded, _, _ := fold(e)
+	return folded
+}
+
+func fold(e Expression) (Expression, Value, bool) {
+	switch v := e.(type) {
+	case IntegerToken:
+		return v, IntValue(v.value), true
+	case FloatToken:
+		return v, FloatValue(v.value), true
+	case StringToken:
+		return v, StringValue(v.value), true
+	case *PrefixExpression:
+		rhs, rhsValue, constant := fold(v.rhs)
+		if constant {
+			if value, err := applyPrefix(v.op, rhsValue); err == nil {
+				if node, ok := valueNode(value, v.pos); ok {
+					return node, value, true
+				}
+			}
+		}
+		return &PrefixExpression{op: v.op, rhs: rhs, pos: v.pos}, Value{}, false
+	case *InfixExpression:
+		lhs, lhsValue, lhsConstant := fold(v.lhs)
+		rhs, rhsValue, rhsConstant := fold(v.rhs)
+		if lhsConstant && rhsConstant {
+			if value, err := applyInfix(v.op, lhsValue, rhsValue); err == nil {
+				if node, ok := valueNode(value, v.pos); ok {
+					return node, value, true
+				}
+			}
+		}
+		return &InfixExpression{lhs: lhs, rhs: rhs, op: v.op, pos: v.pos}, Value{}, false
+	case *CallExpression:
+		args := make([]Expression, len(v.args))
+		for i, arg := range v.args {
+			args[i], _, _ = fold(arg)
+		}
+		return &CallExpression{name: v.name, args: args, pos: v.pos}, Value{}, false
+	}
+	return e, Value{}, false
+}
+
+// valueNode converts a constant back into source-representable nodes.
+// Negative numbers become a negated literal, exactly as the parser builds
+// them; infinities and NaN have no literal.
+func valueNode(v Value, pos int) (Expression, bool) {
+	switch v.Kind() {
+	case IntKind:
+		if v.Int() >= 0 {
+			return IntegerToken{value: v.Int(), pos: pos}, true
+		}
+		if v.Int() == math.MinInt64 {
+			return nil, false
+		}
+		return &PrefixExpression{op: SubOp, rhs: IntegerToken{value: -v.Int(), pos: pos}, pos: pos}, true
+	case FloatKind:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, false
+		}
+		if !math.Signbit(f) {
+			return FloatToken{value: f, pos: pos}, true
+		}
+		return &PrefixExpression{op: SubOp, rhs: FloatToken{value: -f, pos: pos}, pos: pos}, true
+	case StringKind:
+		for i := 0; i < len(v.Str()); i++ {
+			if v.Str()[i] == '"' {
+				return nil, false
+			}
+		}
+		return StringToken{value: v.Str(), pos: pos}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format renders e as source text that parses back to the same tree, adding
+// parentheses only where the binding powers require them.
+func Format(e Expression) string {
+	var f formatter
+	f.format(e)
+	return f.sb.String()
+}
+
+// FormatMapped renders e like Format, also returning where each node was
+// written, so that problems found in the text, as when it is parsed and
+// checked again, can be reported at the positions the nodes had in the
+// source e was parsed from. Fold and the other rewrites keep the positions
+// of the nodes they rebuild, so this holds for their results too.
+func FormatMapped(e Expression) (string, SourceMap) {
+	f := formatter{sourceMap: &SourceMap{}}
+	f.format(e)
+	return f.sb.String(), *f.sourceMap
+}
+
+// MinifyExpr renders e like Format but without optional whitespace, for
+// storing formulas compactly. A space is kept only between two operators
+// that would otherwise lex as a longer one, as in "a- -b" once "--" is
+// registered.
+func MinifyExpr(e Expression) string {
+	f := formatter{compact: true}
+	f.format(e)
+	return f.sb.String()
+}
+
+// Minify parses src and returns it minified.
+func Minify(src string) (string, error) {
+	expr, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return MinifyExpr(expr), nil
+}
+
+type formatter struct {
+	sb      strings.Builder
+	compact bool
+	// lastOp is the operator written last in compact mode, or "" if the
+	// last token was not an operator.
+	lastOp string
+	// sourceMap records where nodes are written, if set.
+	sourceMap *SourceMap
+}
+
+// SourceMap maps offsets in text written by FormatMapped to the positions
+// in the original source of the nodes written there.
+type SourceMap struct {
+	formatted []int
+	original  []int
+}
+
+// Original returns the position in the original source of offset pos in
+// the formatted text: that of the node written at pos, or of the nearest
+// one written before it.
+func (m SourceMap) Original(pos int) int {
+	i := sort.SearchInts(m.formatted, pos+1) - 1
+	if i < 0 {
+		return pos
+	}
+	return m.original[i]
+}
+
+func (f *formatter) format(e Expression) {
+	switch v := e.(type) {
+	case IntegerToken:
+		f.word(strconv.FormatInt(v.value, 10), v.pos)
+	case FloatToken:
+		f.word(formatFloatLiteral(v.value), v.pos)
+	case StringToken:
+		f.word(`"`+v.value+`"`, v.pos)
+	case IdentifierToken:
+		f.word(v.name, v.pos)
+	case Hole:
+		f.word("_", v.pos)
+	case *PrefixExpression:
+		r_bp, _ := prefixBindingPower(v.op)
+		f.operator(v.op.String(), v.pos)
+		f.formatOperand(v.rhs, needsParens(v.rhs, r_bp, false))
+	case *InfixExpression:
+		l_bp, r_bp, _ := infixBindingPower(v.op)
+		f.formatOperand(v.lhs, needsParens(v.lhs, l_bp, true))
+		f.space()
+		f.operator(v.op.String(), v.pos)
+		f.space()
+		f.formatOperand(v.rhs, needsParens(v.rhs, r_bp, false))
+	case *CallExpression:
+		f.word(v.name, v.pos)
+		f.operator("(", -1)
+		for i, arg := range v.args {
+			if i > 0 {
+				f.operator(",", -1)
+				f.space()
+			}
+			f.format(arg)
+		}
+		f.operator(")", -1)
+	}
+}
+
+func (f *formatter) formatOperand(e Expression, parens bool) {
+	if parens {
+		f.operator("(", -1)
+	}
+	f.format(e)
+	if parens {
+		f.operator(")", -1)
+	}
+}
+
+// word writes text, the node at pos in the original source.
+func (f *formatter) word(text string, pos int) {
+	f.mark(pos)
+	f.sb.WriteString(text)
+	f.lastOp = ""
+}
+
+// operator writes symbol, the node at pos in the original source or
+// punctuation if pos is -1.
+func (f *formatter) operator(symbol string, pos int) {
+	if f.compact && f.lastOp != "" {
+		if _, size := operatorAt(f.lastOp + symbol); size != len(f.lastOp) {
+			f.sb.WriteByte(' ')
+		}
+	}
+	f.mark(pos)
+	f.sb.WriteString(symbol)
+	f.lastOp = symbol
+}
+
+// mark records that the node at pos is written next.
+func (f *formatter) mark(pos int) {
+	if f.sourceMap != nil && pos >= 0 {
+		f.sourceMap.formatted = append(f.sourceMap.formatted, f.sb.Len())
+		f.sourceMap.original = append(f.sourceMap.original, pos)
+	}
+}
+
+// space writes the whitespace Format puts around infix operators and after
+// commas.
+func (f *formatter) space() {
+	if !f.compact {
+		f.sb.WriteByte(' ')
+	}
+}
+
+// needsParens reports whether operand e must be parenthesised next to an
+// operator. For a left operand bp is the operator's left binding power and
+// e would otherwise swallow the operator if its own right binding power is
+// not above it; for a right operand bp is the operator's right binding power
+// and e would otherwise be cut short if its left binding power is below it.
+func needsParens(e Expression, bp int, left bool) bool {
+	switch v := e.(type) {
+	case *InfixExpression:
+		l_bp, r_bp, _ := infixBindingPower(v.op)
+		if left {
+			return bp >= r_bp
+		}
+		return l_bp < bp
+	case *PrefixExpression:
+		r_bp, _ := prefixBindingPower(v.op)
+		return left && bp >= r_bp
+	}
+	return false
+}
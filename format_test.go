@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a  +  b * ( c - 1 )", "a+b*(c-1)"},
+		{"(a + b) * c", "(a+b)*c"},
+		{"a - -b", "a--b"},
+		{"a < = b", ""},
+		{"a < -b && !c", "a<-b&&!c"},
+		{"f( x , g( y ) )", "f(x,g(y))"},
+		{`"a b" + c`, `"a b"+c`},
+		{"2 ^ (3 ^ 2)", "2^3^2"},
+		{"(2 ^ 3) ^ 2", "(2^3)^2"},
+	}
+	for _, tt := range tests {
+		got, err := Minify(tt.src)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Minify(%q) = %q, want an error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Minify(%q) = %q, %v, want %q", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestMinifyRoundTrips(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		e := Random(rng, 5)
+		src := MinifyExpr(e)
+		parsed, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(MinifyExpr(%s)) = %q: %v", Format(e), src, err)
+		}
+		if !equalExpr(parsed, e) {
+			t.Fatalf("MinifyExpr(%s) = %q, which parses as %s", Format(e), src, Format(parsed))
+		}
+	}
+}
+
+func TestFormatMapped(t *testing.T) {
+	tests := []struct {
+		src, formatted string
+		fold           bool
+		// offsets maps positions in formatted to those in src.
+		offsets map[int]int
+	}{
+		{"price   *(qty+ bogus)", "price * (qty + bogus)", false, map[int]int{0: 0, 6: 8, 8: 8, 9: 10, 13: 13, 15: 15, 20: 15}},
+		{"f( a ,b )", "f(a, b)", false, map[int]int{0: 0, 2: 3, 5: 6}},
+		{"1+2 + qtyy", "3 + qtyy", true, map[int]int{2: 4, 4: 6}},
+		{"-  x", "-x", false, map[int]int{0: 0, 1: 3}},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		if tt.fold {
+			e = Fold(e)
+		}
+		got, sourceMap := FormatMapped(e)
+		if got != tt.formatted || got != Format(e) {
+			t.Errorf("FormatMapped(%s) = %s, want %s", tt.src, got, tt.formatted)
+		}
+		for pos, want := range tt.offsets {
+			if original := sourceMap.Original(pos); original != want {
+				t.Errorf("offset %d of %s is at %d of %s, want %d", pos, got, original, tt.src, want)
+			}
+		}
+	}
+	if got := (SourceMap{}).Original(3); got != 3 {
+		t.Errorf("an empty source map maps 3 to %d", got)
+	}
+}
+
+// A problem found in the formatted text is reported where it was written.
+func TestSourceMapDiagnostics(t *testing.T) {
+	src := "1 +  2 *  3 + qtyy"
+	formatted, sourceMap := FormatMapped(Fold(mustParse(t, src)))
+	_, errs := Check(mustParse(t, formatted), Schema{"qty": IntKind})
+	if len(errs) != 1 {
+		t.Fatalf("Check(%s) = %v", formatted, errs)
+	}
+	if pos := sourceMap.Original(errs[0].Pos); src[pos:] != "qtyy" {
+		t.Errorf("%v in %s maps to %q of %s", errs[0], formatted, src[pos:], src)
+	}
+}
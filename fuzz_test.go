@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+var fuzzSeeds = []string{
+	"1 + 2 * 3",
+	"-(x - 4) / y ^ 2 ^ 3",
+	`f(x, "a" + s, 3)`,
+	"{ t = x * 2; t + t }",
+	"try(1 / 0, x)",
+	`s =~ "^a.*"`,
+	"((((1))))",
+	"x ** 2 × 3 ÷ y",
+	`x > 1 && y <= 3 || !(s == "a")`,
+	"x << 2 >> 1 != 0",
+	"x |> f(1) |> f",
+	"_ + 1",
+	"f(",
+	"1 +",
+	")",
+	"{ t = ; t }",
+	`"unterminated`,
+	"99999999999999999999",
+}
+
+// FuzzParse checks that no input panics the parser and that whatever
+// parses is formatted as text parsing back to the same tree.
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		e, err := Parse(src)
+		if err != nil {
+			if _, ok := err.(SyntaxError); !ok {
+				t.Fatalf("Parse(%q) returned %T, not a SyntaxError", src, err)
+			}
+			return
+		}
+		text := Format(e)
+		again, err := Parse(text)
+		if err != nil {
+			t.Fatalf("Parse(%q) formatted as %q, which fails to parse: %v", src, text, err)
+		}
+		if !equalExpr(e, again) {
+			t.Fatalf("Parse(%q) formatted as %q, which parses as %s", src, text, Format(again))
+		}
+	})
+}
+
+// FuzzEval checks that no input panics the evaluators and that the
+// backends agree on every expression that parses.
+func FuzzEval(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	env := Env{
+		"x": IntValue(7),
+		"y": FloatValue(2.5),
+		"s": StringValue("abc"),
+		"f": FuncValue(func(args []Value) (Value, error) {
+			return IntValue(int64(len(args))), nil
+		}),
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		e, err := Parse(src)
+		if err != nil {
+			return
+		}
+		Check(e, Schema{"x": IntKind, "y": FloatKind, "s": StringKind, "f": FuncKind})
+		want, wantErr := NewEvaluator(env).Eval(e)
+		for _, backend := range []Backend{StackBackend, RegisterBackend} {
+			got, err := NewEvaluator(env, WithBackend(backend)).Eval(e)
+			if (err == nil) != (wantErr == nil) || err != nil && err.Error() != wantErr.Error() {
+				t.Fatalf("%q with the %s backend fails with %v, the tree with %v", src, backend, err, wantErr)
+			}
+			if err == nil && !sameValue(got, want) {
+				t.Fatalf("%q with the %s backend = %s, the tree %s", src, backend, got, want)
+			}
+		}
+	})
+}
+
+// sameValue is Equal, except that NaN is the same as itself and functions,
+// which cannot be compared, are the same as each other.
+func sameValue(a Value, b Value) bool {
+	if a.Kind() == FloatKind && b.Kind() == FloatKind && a.Float() != a.Float() {
+		return b.Float() != b.Float()
+	}
+	if a.Kind() == FuncKind {
+		return b.Kind() == FuncKind
+	}
+	return a.Equal(b)
+}
+
+func TestParseDoesNotRecoverBugs(t *testing.T) {
+	op := RegisterPrefix("@@", 5, func(p *Parser) Expression {
+		panic("bug in a grammar rule")
+	})
+	defer func() {
+		prefixRules[op] = prefixRule{}
+		if r := recover(); r != "bug in a grammar rule" {
+			t.Errorf("recovered %v, want the rule's panic", r)
+		}
+	}()
+	Parse("@@ 1")
+	t.Error("Parse(@@ 1) returned")
+}
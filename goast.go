@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/token"
+	"strconv"
+)
+
+var goBinaryOps = map[OpKind]token.Token{
+	AddOp: token.ADD,
+	SubOp: token.SUB,
+	MulOp: token.MUL,
+	DivOp: token.QUO,
+	ShlOp: token.SHL,
+	ShrOp: token.SHR,
+	EqOp:  token.EQL,
+	NeOp:  token.NEQ,
+	LtOp:  token.LSS,
+	LeOp:  token.LEQ,
+	GtOp:  token.GTR,
+	GeOp:  token.GEQ,
+	AndOp: token.LAND,
+	OrOp:  token.LOR,
+}
+
+// GoAST converts e to a go/ast expression, so code generators can splice
+// formulas into generated Go files and print them with go/printer.
+// Variables and functions keep their names, ^ becomes math.Pow, whose
+// operands and result are float64, and operators evaluated by functions
+// become calls of those functions. Parentheses are added where Go's
+// precedence would group the operands differently. Names that are not Go
+// identifiers, such as environment references, and holes are errors.
+func GoAST(e Expression) (goast.Expr, error) {
+	switch v := e.(type) {
+	case IntegerToken:
+		return &goast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(v.value, 10)}, nil
+	case FloatToken:
+		return &goast.BasicLit{Kind: token.FLOAT, Value: formatFloatLiteral(v.value)}, nil
+	case StringToken:
+		return &goast.BasicLit{Kind: token.STRING, Value: strconv.Quote(v.value)}, nil
+	case IdentifierToken:
+		return goIdent(v.name, v.pos)
+	case Hole:
+		return nil, fmt.Errorf("cannot convert unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case *PrefixExpression:
+		rhs, err := GoAST(v.rhs)
+		if err != nil {
+			return nil, err
+		}
+		if name := operatorFunction(v.op, false); name != "" {
+			return goCall(name, v.pos, rhs)
+		}
+		var op token.Token
+		switch v.op {
+		case SubOp:
+			op = token.SUB
+		case AddOp:
+			op = token.ADD
+		case NotOp:
+			op = token.NOT
+		default:
+			return nil, fmt.Errorf("operator '%s' has no Go equivalent at column %d", v.op, v.pos+1)
+		}
+		switch rhs.(type) {
+		case *goast.BinaryExpr, *goast.UnaryExpr:
+			rhs = &goast.ParenExpr{X: rhs}
+		}
+		return &goast.UnaryExpr{Op: op, X: rhs}, nil
+	case *InfixExpression:
+		lhs, err := GoAST(v.lhs)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := GoAST(v.rhs)
+		if err != nil {
+			return nil, err
+		}
+		if name := operatorFunction(v.op, true); name != "" {
+			return goCall(name, v.pos, lhs, rhs)
+		}
+		if v.op == PowOp {
+			pow := &goast.SelectorExpr{X: goast.NewIdent("math"), Sel: goast.NewIdent("Pow")}
+			return &goast.CallExpr{Fun: pow, Args: []goast.Expr{lhs, rhs}}, nil
+		}
+		op, ok := goBinaryOps[v.op]
+		if !ok {
+			return nil, fmt.Errorf("operator '%s' has no Go equivalent at column %d", v.op, v.pos+1)
+		}
+		return &goast.BinaryExpr{
+			X:  goOperand(lhs, op.Precedence(), false),
+			Op: op,
+			Y:  goOperand(rhs, op.Precedence(), true),
+		}, nil
+	case *CallExpression:
+		if isTry(v) || isLambda(v) {
+			return nil, fmt.Errorf("%s has no Go equivalent at column %d", v.name, v.pos+1)
+		}
+		if isLet(v) {
+			return GoAST(inlineLet(v))
+		}
+		args := make([]goast.Expr, len(v.args))
+		for i, arg := range v.args {
+			converted, err := GoAST(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = converted
+		}
+		return goCall(v.name, v.pos, args...)
+	}
+	return nil, fmt.Errorf("cannot convert %T", e)
+}
+
+func goIdent(name string, pos int) (*goast.Ident, error) {
+	if !token.IsIdentifier(name) {
+		return nil, fmt.Errorf("'%s' is not a Go identifier at column %d", name, pos+1)
+	}
+	return goast.NewIdent(name), nil
+}
+
+func goCall(name string, pos int, args ...goast.Expr) (goast.Expr, error) {
+	fn, err := goIdent(name, pos)
+	if err != nil {
+		return nil, err
+	}
+	return &goast.CallExpr{Fun: fn, Args: args}, nil
+}
+
+// goOperand parenthesises an operand of a binary operator of precedence
+// prec that Go would otherwise group differently. Go's binary operators are
+// left-associative, so a right operand needs parentheses at equal
+// precedence too.
+func goOperand(e goast.Expr, prec int, right bool) goast.Expr {
+	if b, ok := e.(*goast.BinaryExpr); ok {
+		if p := b.Op.Precedence(); p < prec || right && p == prec {
+			return &goast.ParenExpr{X: e}
+		}
+	}
+	return e
+}
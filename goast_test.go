@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"testing"
+)
+
+func TestGoAST(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 + 2 * x", "1 + 2*x"},
+		{"(1 + 2) * x", "(1 + 2) * x"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"x - -1", "x - -1"},
+		{"-(-x)", "-(-x)"},
+		{"-(a + b)", "-(a + b)"},
+		{"!(a && b)", "!(a && b)"},
+		{"a == b || c < 1 && d", "a == b || c < 1 && d"},
+		// Shifts bind more tightly than + in Go.
+		{"a << 1 + 2", "a<<1 + 2"},
+		{"a << (1 + 2)", "a << (1 + 2)"},
+		{"1 + 2 << a", "1 + 2<<a"},
+		{"2 ^ 3 ^ 2", "math.Pow(2, math.Pow(3, 2))"},
+		{`f(x, "s", 1.5)`, `f(x, "s", 1.5)`},
+		{"let(y, x + 1, y * y)", "(x + 1) * (x + 1)"},
+	}
+	for _, tt := range tests {
+		e, err := GoAST(mustParse(t, tt.src))
+		if err != nil {
+			t.Errorf("GoAST(%s) = %v", tt.src, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, token.NewFileSet(), e); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("GoAST(%s) prints %s, want %s", tt.src, got, tt.want)
+		}
+		if _, err := parser.ParseExpr(buf.String()); err != nil {
+			t.Errorf("GoAST(%s) prints %s, which is not Go: %v", tt.src, buf.String(), err)
+		}
+	}
+}
+
+func TestGoASTErrors(t *testing.T) {
+	tests := map[string]string{
+		"try(x, 1)":    "try has no Go equivalent at column 1",
+		"1 + fn(x, x)": "fn has no Go equivalent at column 5",
+		"_ + 1":        "cannot convert unfilled hole #1 at column 1",
+		"${HOME} + 1":  "'${HOME}' is not a Go identifier at column 1",
+	}
+	for src, want := range tests {
+		e, err := Parse(src, WithEnvReferences())
+		if err != nil {
+			t.Errorf("Parse(%s) = %v", src, err)
+			continue
+		}
+		if _, err := GoAST(e); errorString(err) != want {
+			t.Errorf("GoAST(%s) = %v, want %s", src, err, want)
+		}
+	}
+}
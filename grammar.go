@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PrefixFunc parses an expression starting with an operator, the nud of
+// Pratt parsing. The operator has been consumed and is p.Token().
+type PrefixFunc func(p *Parser) Expression
+
+// InfixFunc parses the rest of an expression whose operator follows lhs,
+// the led of Pratt parsing. The operator has been consumed and is p.Token().
+type InfixFunc func(p *Parser, lhs Expression) Expression
+
+// The eval fields of rules name the function evaluating operators loaded
+// by LoadOperators.
+type prefixRule struct {
+	bp   int
+	fn   PrefixFunc
+	eval string
+}
+
+type infixRule struct {
+	lbp  int
+	rbp  int
+	fn   InfixFunc
+	eval string
+	// binary is set for rules building an InfixExpression from both
+	// operands.
+	binary bool
+}
+
+type Associativity int
+
+const (
+	LeftAssociative Associativity = iota
+	RightAssociative
+)
+
+// bindingPowers maps precedence level n, counted from 1 for the loosest
+// operators, to the binding powers of an infix operator: 2n-1 on the left,
+// and 2n on the right when left associative so an equal operator to the
+// right stops the operand, or 2n-1 when right associative so it continues
+// it. A prefix operator at level n binds its operand with 2n-1.
+func bindingPowers(precedence int, assoc Associativity) (int, int) {
+	lbp := 2*precedence - 1
+	if assoc == RightAssociative {
+		return lbp, lbp
+	}
+	return lbp, lbp + 1
+}
+
+func (a Associativity) String() string {
+	if a == RightAssociative {
+		return "right associative"
+	}
+	return "left associative"
+}
+
+// associativity derives an infix operator's associativity from its binding
+// powers.
+func (r infixRule) associativity() Associativity {
+	if r.rbp <= r.lbp {
+		return RightAssociative
+	}
+	return LeftAssociative
+}
+
+type operatorText struct {
+	text string
+	op   OpKind
+}
+
+// The grammar tables are filled in by init and the Register functions,
+// which must not be called while parsing. operatorKinds holds one-byte
+// operator spellings and longOperators longer ones by first byte, longest
+// first for maximal munch. operatorBytes marks the bytes of the longer
+// spellings, which may join with neighbouring text into another token. The
+// rule tables are indexed by OpKind.
+var (
+	operatorKinds [256]OpKind
+	longOperators [256][]operatorText
+	operatorBytes [256]bool
+	prefixRules   []prefixRule
+	infixRules    []infixRule
+)
+
+// callBindingPower makes calls bind tighter than any operator, and
+// minBindingPower, which whole expressions and arguments are parsed with,
+// lets any operator continue them.
+const (
+	callBindingPower = 100
+	minBindingPower  = -100
+)
+
+func init() {
+	for op, symbol := range opKindSymbols {
+		if symbol != "" {
+			addOperatorText(symbol, OpKind(op))
+		}
+	}
+	prefixRules = make([]prefixRule, len(opKindSymbols))
+	infixRules = make([]infixRule, len(opKindSymbols))
+	RegisterPrefix("+", 5, parseSign)
+	RegisterPrefix("-", 5, parseSign)
+	RegisterPrefix("!", 5, nil)
+	RegisterPrefix("(", 0, parseGroup)
+	RegisterOperator("+", 1, LeftAssociative, nil)
+	RegisterOperator("-", 1, LeftAssociative, nil)
+	RegisterOperator("*", 2, LeftAssociative, nil)
+	RegisterOperator("/", 2, LeftAssociative, nil)
+	RegisterOperator("<<", 2, LeftAssociative, nil)
+	RegisterOperator(">>", 2, LeftAssociative, nil)
+	RegisterOperator("^", 4, RightAssociative, nil)
+	// Matching takes a whole sum on either side, so name =~ prefix + ".*"
+	// matches against the concatenation.
+	RegisterInfix("=~", 1, 1, nil)
+	RegisterInfix("!~", 1, 1, nil)
+	for _, symbol := range []string{"==", "!=", "<", "<=", ">", ">="} {
+		RegisterOperator(symbol, 0, LeftAssociative, nil)
+	}
+	RegisterOperator("&&", -1, LeftAssociative, nil)
+	RegisterOperator("||", -2, LeftAssociative, nil)
+	// & joins strings in spreadsheet formulas, binding looser than sums
+	// and tighter than comparisons as it does in spreadsheets.
+	RegisterInfix("&", 0, 1, parseExcelConcat)
+	pipe, _ := bindingPowers(-3, LeftAssociative)
+	RegisterInfix("|>", pipe, callBindingPower, parsePipe)
+	// => takes just the fn(...) before it and the rest of the argument
+	// after it.
+	RegisterInfix("=>", callBindingPower, minBindingPower, parseLambda)
+	RegisterInfix("(", callBindingPower, 0, parseCallRule)
+	RegisterAlias("**", "^")
+	RegisterAlias("×", "*")
+	RegisterAlias("÷", "/")
+	RegisterAlias("−", "-")
+}
+
+func addOperatorText(text string, op OpKind) {
+	if len(text) == 1 {
+		operatorKinds[text[0]] = op
+		return
+	}
+	texts := longOperators[text[0]]
+	for i := range texts {
+		if texts[i].text == text {
+			texts[i].op = op
+			return
+		}
+	}
+	texts = append(texts, operatorText{text: text, op: op})
+	sort.SliceStable(texts, func(i, j int) bool {
+		return len(texts[i].text) > len(texts[j].text)
+	})
+	longOperators[text[0]] = texts
+	for i := 0; i < len(text); i++ {
+		operatorBytes[text[i]] = true
+	}
+}
+
+// operatorAt returns the kind and length of the longest operator spelling
+// at the start of s, or NoOp.
+func operatorAt(s string) (OpKind, int) {
+	if longOperators[s[0]] == nil {
+		return operatorKinds[s[0]], 1
+	}
+	return longOperatorAt(s)
+}
+
+func longOperatorAt(s string) (OpKind, int) {
+	for _, t := range longOperators[s[0]] {
+		if strings.HasPrefix(s, t.text) {
+			return t.op, len(t.text)
+		}
+	}
+	return operatorKinds[s[0]], 1
+}
+
+// lookupOperator returns the kind of the operator spelled exactly text.
+func lookupOperator(text string) OpKind {
+	if text == "" {
+		return NoOp
+	}
+	if op, size := operatorAt(text); size == len(text) {
+		return op
+	}
+	return NoOp
+}
+
+// RegisterSymbol returns the operator kind of symbol, making the lexer
+// recognise it if it is new. Symbols that only close other constructs, such
+// as ']' for an indexing rule, need registering with RegisterSymbol alone.
+// It panics if symbol is not made of ASCII punctuation.
+func RegisterSymbol(symbol string) OpKind {
+	if op := lookupOperator(symbol); op != NoOp {
+		return op
+	}
+	if !isOperatorSymbol(symbol) {
+		panic(fmt.Sprintf("operator symbol %q must be ASCII punctuation", symbol))
+	}
+	opKindSymbols = append(opKindSymbols, symbol)
+	prefixRules = append(prefixRules, prefixRule{})
+	infixRules = append(infixRules, infixRule{})
+	op := OpKind(len(opKindSymbols) - 1)
+	addOperatorText(symbol, op)
+	return op
+}
+
+// RegisterAlias makes the lexer read alias as the operator symbol, as in
+// "×" for "*". Unlike symbols, aliases may be any UTF-8 text not starting
+// like a number, identifier or string. It panics if symbol is unknown, or
+// if alias is invalid or already spells another operator.
+func RegisterAlias(alias string, symbol string) OpKind {
+	op := lookupOperator(symbol)
+	if op == NoOp {
+		panic(fmt.Sprintf("alias %q of unknown operator %q", alias, symbol))
+	}
+	if !isOperatorAlias(alias) {
+		panic(fmt.Sprintf("invalid operator alias %q", alias))
+	}
+	if existing := lookupOperator(alias); existing != NoOp && existing != op {
+		panic(fmt.Sprintf("alias %q already spells operator %q", alias, existing))
+	}
+	addOperatorText(alias, op)
+	return op
+}
+
+func isOperatorChar(c byte) bool {
+	return c > ' ' && c < 0x7f && c != '"' && !isIdentifierChar(c)
+}
+
+func isOperatorSymbol(symbol string) bool {
+	if symbol == "" {
+		return false
+	}
+	for i := 0; i < len(symbol); i++ {
+		if !isOperatorChar(symbol[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isOperatorAlias(alias string) bool {
+	if alias == "" || !utf8.ValidString(alias) {
+		return false
+	}
+	for i := 0; i < len(alias); i++ {
+		if alias[i] < utf8.RuneSelf && !isOperatorChar(alias[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterPrefix registers the rule for symbol at the start of an
+// expression, replacing any earlier one. A nil fn parses the operand with
+// binding power bp into a PrefixExpression.
+func RegisterPrefix(symbol string, bp int, fn PrefixFunc) OpKind {
+	op := RegisterSymbol(symbol)
+	if fn == nil {
+		fn = func(p *Parser) Expression {
+			tok := p.Token()
+			rhs := p.Expression(prefixRules[tok.Op].bp)
+			prefix := p.arena.newPrefix()
+			*prefix = PrefixExpression{op: tok.Op, rhs: rhs, pos: tok.Pos}
+			return prefix
+		}
+	}
+	prefixRules[op] = prefixRule{bp: bp, fn: fn}
+	return op
+}
+
+// RegisterInfix registers the rule for symbol after an expression,
+// replacing any earlier one. The rule applies while lbp is at least the
+// binding power of the enclosing operator. A nil fn parses the right operand
+// with binding power rbp into an InfixExpression, so lbp < rbp makes the
+// operator left associative and rbp <= lbp right associative.
+func RegisterInfix(symbol string, lbp int, rbp int, fn InfixFunc) OpKind {
+	op := RegisterSymbol(symbol)
+	binary := fn == nil
+	if fn == nil {
+		fn = func(p *Parser, lhs Expression) Expression {
+			tok := p.Token()
+			rhs := p.Expression(infixRules[tok.Op].rbp)
+			infix := p.arena.newInfix()
+			*infix = InfixExpression{lhs: lhs, rhs: rhs, op: tok.Op, pos: tok.Pos}
+			return infix
+		}
+	}
+	infixRules[op] = infixRule{lbp: lbp, rbp: rbp, fn: fn, binary: binary}
+	return op
+}
+
+// RegisterOperator registers symbol as an infix operator at a precedence
+// level, where + and - are at 1, * and / at 2, prefix operators at 3 and ^
+// at 4. Levels below 1 are looser than arithmetic: comparisons are at 0,
+// && at -1, || at -2 and |> at -3.
+func RegisterOperator(symbol string, precedence int, assoc Associativity, fn InfixFunc) OpKind {
+	lbp, rbp := bindingPowers(precedence, assoc)
+	return RegisterInfix(symbol, lbp, rbp, fn)
+}
+
+// parsePipe is the infix rule for |>, which passes its left operand to the
+// function on its right: x |> f is f(x) and x |> f(y) is f(x, y).
+func parsePipe(p *Parser, lhs Expression) Expression {
+	l := (*Lexer)(p)
+	tok := l.next()
+	if tok == nil {
+		if l.partial {
+			l.expect(ExpectOperand)
+			return lhs
+		}
+		panic(l.endError())
+	}
+	if tok.Kind != Identifier {
+		panic(tok.errorf("expected a function after '|>'"))
+	}
+	callee := tok.node().(IdentifierToken)
+	if !l.peek().isOp(LParenOp) {
+		return newCall(l, callee, []Expression{lhs})
+	}
+	l.next()
+	return parseCall(l, callee, lhs)
+}
+
+func infixBindingPower(op OpKind) (int, int, bool) {
+	if op < 0 || int(op) >= len(infixRules) || infixRules[op].fn == nil {
+		return 0, 0, false
+	}
+	return infixRules[op].lbp, infixRules[op].rbp, true
+}
+
+func prefixBindingPower(op OpKind) (int, bool) {
+	if op < 0 || int(op) >= len(prefixRules) || prefixRules[op].fn == nil {
+		return 0, false
+	}
+	return prefixRules[op].bp, true
+}
+
+// Parser is the view of a parse in progress given to grammar rules. Rules
+// report errors by panicking with a SyntaxError, as from Errorf.
+type Parser Lexer
+
+// Expression parses an expression whose operators bind at least as tightly
+// as bp.
+func (p *Parser) Expression(bp int) Expression {
+	return parse((*Lexer)(p), bp)
+}
+
+// Token returns the token consumed last, which is the rule's operator when
+// the rule starts.
+func (p *Parser) Token() *Token {
+	return p.prev
+}
+
+// Peek returns the next token without consuming it, or nil at the end.
+func (p *Parser) Peek() *Token {
+	return (*Lexer)(p).peek()
+}
+
+// Next consumes and returns the next token, or nil at the end.
+func (p *Parser) Next() *Token {
+	return (*Lexer)(p).next()
+}
+
+// Expect consumes the next token, failing unless it is symbol.
+func (p *Parser) Expect(symbol string) *Token {
+	l := (*Lexer)(p)
+	tok := l.next()
+	if tok == nil {
+		panic(SyntaxError{Pos: l.end, End: l.end, Msg: fmt.Sprintf("expected '%s'", symbol)})
+	}
+	if tok.Kind != Operand || tok.Op.String() != symbol {
+		panic(tok.errorf("expected '%s'", symbol))
+	}
+	return tok
+}
+
+// Errorf fails the parse with an error spanning tok.
+func (p *Parser) Errorf(tok *Token, format string, args ...interface{}) {
+	panic(tok.errorf(format, args...))
+}
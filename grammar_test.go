@@ -0,0 +1,227 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// saveGrammar returns a function restoring the grammar tables and operator
+// documentation as they are now, for tests that register operators. Defer
+// it so that symbols a test adds are unknown again to the tests after it.
+func saveGrammar() func() {
+	symbols := append([]string(nil), opKindSymbols...)
+	kinds, joining := operatorKinds, operatorBytes
+	var long [256][]operatorText
+	for i, texts := range longOperators {
+		long[i] = append([]operatorText(nil), texts...)
+	}
+	prefix := append([]prefixRule(nil), prefixRules...)
+	infix := append([]infixRule(nil), infixRules...)
+	docs := make(map[operatorKey]docEntry, len(operatorDocs))
+	for key, doc := range operatorDocs {
+		docs[key] = doc
+	}
+	return func() {
+		opKindSymbols = symbols
+		operatorKinds, operatorBytes = kinds, joining
+		longOperators = long
+		prefixRules, infixRules = prefix, infix
+		operatorDocs = docs
+	}
+}
+
+// parenthesize writes e with every operator application in parentheses,
+// showing how the parser grouped it.
+func parenthesize(e Expression) string {
+	switch v := e.(type) {
+	case *PrefixExpression:
+		return "(" + v.op.String() + parenthesize(v.rhs) + ")"
+	case *InfixExpression:
+		return "(" + parenthesize(v.lhs) + " " + v.op.String() + " " + parenthesize(v.rhs) + ")"
+	case *CallExpression:
+		args := make([]string, len(v.args))
+		for i, arg := range v.args {
+			args[i] = parenthesize(arg)
+		}
+		return v.name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return Format(e)
+}
+
+func TestAssociativity(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a + b + c", "((a + b) + c)"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a / b / c", "((a / b) / c)"},
+		{"a ^ b ^ c", "(a ^ (b ^ c))"},
+		{"a ** b ** c", "(a ^ (b ^ c))"},
+		{"a =~ b =~ c", "(a =~ (b =~ c))"},
+		{"a !~ b !~ c", "(a !~ (b !~ c))"},
+		{"a - b + c", "((a - b) + c)"},
+		{"a / b * c", "((a / b) * c)"},
+		{"a == b != c", "((a == b) != c)"},
+		{"a < b <= c", "((a < b) <= c)"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a || b || c", "((a || b) || c)"},
+		{"a << b >> c", "((a << b) >> c)"},
+		{"x |> f |> g(y)", "g(f(x), y)"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := parenthesize(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a + b * c", "(a + (b * c))"},
+		{"a * b + c", "((a * b) + c)"},
+		{"a * b ^ c", "(a * (b ^ c))"},
+		{"-a ^ b", "(-(a ^ b))"},
+		{"-a * b", "((-a) * b)"},
+		{"a ^ -b", "(a ^ (-b))"},
+		{"--a", "(-(-a))"},
+		{"(a + b) * c", "((a + b) * c)"},
+		{"f(a) + g(b, c) * d", "(f(a) + (g(b, c) * d))"},
+		{`s =~ p + ".*"`, `(s =~ (p + ".*"))`},
+		{"a + b < c * d", "((a + b) < (c * d))"},
+		{`s =~ p == t !~ q`, `((s =~ p) == (t !~ q))`},
+		{"a < b && c > d || e", "(((a < b) && (c > d)) || e)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"!a && b", "((!a) && b)"},
+		{"!a == b", "((!a) == b)"},
+		{"a << b + c", "((a << b) + c)"},
+		{"a * b << c", "((a * b) << c)"},
+		{"a + b |> f", "f((a + b))"},
+		{"a || b |> f(c)", "f((a || b), c)"},
+		{"f(a < b, c)", "f((a < b), c)"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := parenthesize(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluationFollowsAssociativity(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"10 - 4 - 3", IntValue(3)},
+		{"64 / 8 / 2", IntValue(4)},
+		{"2 ^ 3 ^ 2", IntValue(512)},
+		{"-2 ^ 2", IntValue(-4)},
+		{"1 << 2 << 3", IntValue(32)},
+		{"64 >> 2 >> 1", IntValue(8)},
+		{"1 + 1 == 2 == (3 == 3)", BoolValue(true)},
+		{"1 > 2 && 1 / 0 == 1 || 2 > 1", BoolValue(true)},
+	}
+	for _, tt := range tests {
+		for _, b := range []Backend{TreeBackend, StackBackend, RegisterBackend} {
+			e, err := Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewEvaluator(nil, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestBindingPowers(t *testing.T) {
+	tests := []struct {
+		precedence int
+		assoc      Associativity
+		lbp, rbp   int
+	}{
+		{1, LeftAssociative, 1, 2},
+		{2, LeftAssociative, 3, 4},
+		{4, RightAssociative, 7, 7},
+		{0, LeftAssociative, -1, 0},
+		{-2, LeftAssociative, -5, -4},
+	}
+	for _, tt := range tests {
+		lbp, rbp := bindingPowers(tt.precedence, tt.assoc)
+		if lbp != tt.lbp || rbp != tt.rbp {
+			t.Errorf("bindingPowers(%d, %s) = %d, %d, want %d, %d", tt.precedence, tt.assoc, lbp, rbp, tt.lbp, tt.rbp)
+		}
+		if got := (infixRule{lbp: lbp, rbp: rbp}).associativity(); got != tt.assoc {
+			t.Errorf("associativity of %d, %d = %s, want %s", lbp, rbp, got, tt.assoc)
+		}
+	}
+}
+
+func TestRegisterRules(t *testing.T) {
+	// a[i] indexes a by i, as a call of at, and #name is the string "name".
+	RegisterSymbol("]")
+	index := RegisterInfix("[", callBindingPower, 0, func(p *Parser, lhs Expression) Expression {
+		pos := p.Token().Pos
+		i := p.Expression(0)
+		p.Expect("]")
+		return &CallExpression{name: "at", args: []Expression{lhs, i}, pos: pos}
+	})
+	symbol := RegisterPrefix("#", 0, func(p *Parser) Expression {
+		tok := p.Next()
+		if tok == nil || tok.Kind != Identifier {
+			p.Errorf(p.Token(), "expected a name after '#'")
+		}
+		return StringToken{value: tok.Lit, pos: p.Token().Pos}
+	})
+	defer func() {
+		infixRules[index] = infixRule{}
+		prefixRules[symbol] = prefixRule{}
+	}()
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a[1] + b", "(at(a, 1) + b)"},
+		{"-a[i + 1]", "(-at(a, (i + 1)))"},
+		{"a[b[0]][1]", "at(at(a, at(b, 0)), 1)"},
+		{"#red == c", `("red" == c)`},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := parenthesize(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+	errors := []struct {
+		src  string
+		want string
+	}{
+		{"a[1", "expected ']' at column 4"},
+		{"a[1)", "expected ']' at column 4"},
+		{"#1", "expected a name after '#' at column 2"},
+	}
+	for _, tt := range errors {
+		if _, err := Parse(tt.src); err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) fails with %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
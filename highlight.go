@@ -0,0 +1,138 @@
+package main
+
+type HighlightClass int
+
+const (
+	HighlightNumber HighlightClass = iota
+	HighlightString
+	HighlightIdentifier
+	HighlightFunction
+	HighlightPlaceholder
+	HighlightOperator
+	HighlightParen
+	HighlightSeparator
+	HighlightError
+)
+
+var highlightClassNames = [...]string{
+	HighlightNumber:      "number",
+	HighlightString:      "string",
+	HighlightIdentifier:  "identifier",
+	HighlightFunction:    "function",
+	HighlightPlaceholder: "placeholder",
+	HighlightOperator:    "operator",
+	HighlightParen:       "paren",
+	HighlightSeparator:   "separator",
+	HighlightError:       "error",
+}
+
+func (c HighlightClass) String() string {
+	if c < 0 || int(c) >= len(highlightClassNames) {
+		return "unknown"
+	}
+	return highlightClassNames[c]
+}
+
+// HighlightSpan classifies the source bytes [Start, End).
+type HighlightSpan struct {
+	Start int
+	End   int
+	Class HighlightClass
+}
+
+// Classify splits src into highlight spans using the parser's own lexer,
+// so editors colour formulas exactly as they will be read. Text the lexer
+// rejects is classified as an error and lexing resumes after it. Whitespace
+// is not covered by any span.
+func Classify(src string) []HighlightSpan {
+	var spans []HighlightSpan
+	from := 0
+	for from < len(src) {
+		tokens, err := lexTokens(src, from, len(src))
+		if err == nil {
+			spans = appendHighlights(spans, tokens, src)
+			break
+		}
+		syntaxErr := err.(SyntaxError)
+		// Everything before the error lexed cleanly, so lex it again to
+		// recover its tokens.
+		tokens, _ = lexTokens(src, from, syntaxErr.Pos)
+		spans = appendHighlights(spans, tokens, src)
+		spans = append(spans, HighlightSpan{Start: syntaxErr.Pos, End: syntaxErr.End, Class: HighlightError})
+		from = syntaxErr.End
+	}
+	return spans
+}
+
+// appendHighlights classifies tokens. The lexer reads a block such as
+// { t = a * 2; t + t } as let(t, a * 2, t + t), with tokens that do not
+// spell their source: the let and its '(' span the whole binding, and the
+// '{' has none. Those are skipped, the '{' classified from the let, and
+// the = and ; read as commas and the } read as parentheses classified as
+// they are written.
+func appendHighlights(spans []HighlightSpan, tokens TokenArray, src string) []HighlightSpan {
+	for i, tok := range tokens {
+		span := HighlightSpan{Start: tok.Pos, End: tok.End}
+		text := src[tok.Pos:tok.End]
+		switch {
+		case tok.Kind == StringLiteral || text == tok.Lit || !isBlockToken(tok):
+			span.Class = tokenHighlight(tokens, i)
+		case text == "{" || text == "}":
+			span.Class = HighlightParen
+		case text == ";":
+			span.Class = HighlightSeparator
+		case text == "=":
+			span.Class = HighlightOperator
+		default:
+			if tok.Lit == "let" && tok.Pos > 0 && src[tok.Pos-1] == '{' {
+				spans = append(spans, HighlightSpan{Start: tok.Pos - 1, End: tok.Pos, Class: HighlightParen})
+			}
+			continue
+		}
+		// A block of several bindings closes them all at its }.
+		if n := len(spans); n > 0 && spans[n-1] == span {
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// isBlockToken reports whether tok may be one the lexer makes up for a
+// block.
+func isBlockToken(tok Token) bool {
+	switch tok.Lit {
+	case "let", "(", ")", ",":
+		return true
+	}
+	return false
+}
+
+func tokenHighlight(tokens TokenArray, i int) HighlightClass {
+	switch tokens[i].Kind {
+	case Integer, Float:
+		return HighlightNumber
+	case StringLiteral:
+		return HighlightString
+	case Identifier:
+		if i+1 < len(tokens) && tokens[i+1].isOp(LParenOp) {
+			return HighlightFunction
+		}
+		return HighlightIdentifier
+	case Placeholder:
+		return HighlightPlaceholder
+	case Operand:
+		return operatorHighlight(tokens[i].Op)
+	}
+	return HighlightError
+}
+
+func operatorHighlight(op OpKind) HighlightClass {
+	switch op.String() {
+	case "(", ")", "[", "]", "{", "}":
+		return HighlightParen
+	case ",":
+		return HighlightSeparator
+	}
+	return HighlightOperator
+}
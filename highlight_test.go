@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"f(x, 2.5) * _", "0-1 function, 1-2 paren, 2-3 identifier, 3-4 separator, 5-8 number, 8-9 paren, 10-11 operator, 12-13 placeholder"},
+		{`"a" & b`, "0-3 string, 4-5 operator, 6-7 identifier"},
+		{"1 $ 2", "0-1 number, 2-3 error, 4-5 number"},
+		{`x + "open`, "0-1 identifier, 2-3 operator, 4-9 error"},
+		{"   ", ""},
+		{"{ t = a * 2; t + t }", "0-1 paren, 2-3 identifier, 4-5 operator, 6-7 identifier, 8-9 operator, 10-11 number, 11-12 separator, 13-14 identifier, 15-16 operator, 17-18 identifier, 19-20 paren"},
+		{"{x=1;y=x;f(y)}", "0-1 paren, 1-2 identifier, 2-3 operator, 3-4 number, 4-5 separator, 5-6 identifier, 6-7 operator, 7-8 identifier, 8-9 separator, 9-10 function, 10-11 paren, 11-12 identifier, 12-13 paren, 13-14 paren"},
+		{"{ 1 } + x", "0-1 paren, 2-3 number, 4-5 paren, 6-7 operator, 8-9 identifier"},
+		{"2 × 3 ** 2", "0-1 number, 2-4 operator, 5-6 number, 7-9 operator, 10-11 number"},
+	}
+	for _, tt := range tests {
+		spans := Classify(tt.src)
+		got := make([]string, len(spans))
+		for i, span := range spans {
+			got[i] = fmt.Sprintf("%d-%d %s", span.Start, span.End, span.Class)
+		}
+		if strings.Join(got, ", ") != tt.want {
+			t.Errorf("Classify(%q) = %s, want %s", tt.src, strings.Join(got, ", "), tt.want)
+		}
+	}
+}
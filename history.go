@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultHistorySize = 1000
+
+// History holds the most recent lines entered in the REPL, oldest first,
+// and persists them to a file with one line per entry.
+type History struct {
+	lines []string
+	size  int
+	path  string
+}
+
+// NewHistory returns a history keeping at most size lines, stored in path
+// unless path is empty.
+func NewHistory(path string, size int) *History {
+	if size < 1 {
+		size = 1
+	}
+	return &History{size: size, path: path}
+}
+
+// defaultHistoryPath returns ~/.prattcalc_history, or "" if there is no home
+// directory.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".prattcalc_history")
+}
+
+func (h *History) Len() int {
+	return len(h.lines)
+}
+
+// At returns the ith line, counting from the oldest.
+func (h *History) At(i int) string {
+	return h.lines[i]
+}
+
+// Add records line, ignoring blank lines and repeats of the previous one,
+// and drops the oldest lines beyond the size limit.
+func (h *History) Add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.size {
+		h.lines = append(h.lines[:0], h.lines[len(h.lines)-h.size:]...)
+	}
+}
+
+// Load reads the history file, if there is one.
+func (h *History) Load() error {
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Save rewrites the history file with the current lines.
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+	var sb strings.Builder
+	for _, line := range h.lines {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return os.WriteFile(h.path, []byte(sb.String()), 0600)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := NewHistory(path, 3)
+	for _, line := range []string{"1 + 1", "1 + 1", "  ", "x * 2", "ans - 1", "f(3)"} {
+		h.Add(line)
+	}
+	want := []string{"x * 2", "ans - 1", "f(3)"}
+	if got := historyLines(h); !equalStrings(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewHistory(path, 2)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyLines(loaded); !equalStrings(got, want[1:]) {
+		t.Errorf("loaded history = %q, want %q", got, want[1:])
+	}
+	if err := NewHistory(filepath.Join(t.TempDir(), "missing"), 10).Load(); err != nil {
+		t.Errorf("loading a missing history file: %v", err)
+	}
+}
+
+func historyLines(h *History) []string {
+	lines := make([]string, h.Len())
+	for i := range lines {
+		lines[i] = h.At(i)
+	}
+	return lines
+}
+
+func TestLineEditor(t *testing.T) {
+	history := NewHistory("", 10)
+	for _, line := range []string{"a + 1", "sqrt(b)", "a * 2"} {
+		history.Add(line)
+	}
+	const (
+		up    = "\x1b[A"
+		down  = "\x1b[B"
+		left  = "\x1b[D"
+		ctrlR = "\x12"
+		ctrlA = "\x01"
+		ctrlK = "\x0b"
+	)
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"typing", "1 + 2\r", "1 + 2"},
+		{"backspace", "1 + 23\x7f\r", "1 + 2"},
+		{"up recalls the newest line", up + "\r", "a * 2"},
+		{"up twice", up + up + "\r", "sqrt(b)"},
+		{"down returns to the draft", "x" + up + up + down + down + "\r", "x"},
+		{"editing a recalled line", up + left + left + "\x7f3\r", "a 3 2"},
+		{"ctrl-r finds the newest match", ctrlR + "a\r", "a * 2"},
+		{"ctrl-r again finds an older one", ctrlR + "a" + ctrlR + "\r", "a + 1"},
+		{"ctrl-r then editing", ctrlR + "sq" + ctrlA + "2 * \r", "2 * sqrt(b)"},
+		{"kill to end", "abc" + left + left + ctrlK + "\r", "a"},
+	}
+	for _, tt := range tests {
+		e := newLineEditor(strings.NewReader(tt.input), io.Discard, history)
+		got, err := e.ReadLine("> ")
+		if err != nil || got != tt.want {
+			t.Errorf("%s: ReadLine = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+	e := newLineEditor(strings.NewReader("\x04"), io.Discard, history)
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line gives %v, want io.EOF", err)
+	}
+	e = newLineEditor(strings.NewReader("12\x03"), io.Discard, history)
+	if _, err := e.ReadLine("> "); err != errInterrupted {
+		t.Errorf("Ctrl-C gives %v, want errInterrupted", err)
+	}
+}
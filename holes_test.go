@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHoles(t *testing.T) {
+	e, err := Parse("_ * (x + _) - f(_)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Holes(e), []int{0, 9, 16}; !equalInts(got, want) {
+		t.Errorf("Holes = %v, want %v", got, want)
+	}
+	if got := Format(e); got != "_ * (x + _) - f(_)" {
+		t.Errorf("Format = %s, want the holes kept", got)
+	}
+	env := Env{
+		"x": IntValue(1),
+		"f": FuncValue(func(args []Value) (Value, error) { return args[0], nil }),
+	}
+	fill := []Value{IntValue(2), IntValue(3), IntValue(5)}
+	got, err := NewEvaluator(env, WithHoles(func(index int) (Value, bool) {
+		return fill[index], true
+	})).Eval(e)
+	if err != nil || !got.Equal(IntValue(3)) {
+		t.Errorf("filled = %s, %v, want 3", got, err)
+	}
+	_, err = NewEvaluator(env, WithHoles(func(index int) (Value, bool) {
+		return fill[index], index != 1
+	})).Eval(e)
+	if err == nil || !strings.Contains(err.Error(), "unfilled hole #2 at column 10") {
+		t.Errorf("with hole #2 left unfilled, evaluation fails with %v", err)
+	}
+	for _, b := range allBackends {
+		if _, err := NewEvaluator(env, WithBackend(b)).Eval(e); err == nil || !strings.Contains(err.Error(), "unfilled hole #1 at column 1") {
+			t.Errorf("with the %s backend and no holes filled, evaluation fails with %v", b, err)
+		}
+	}
+}
+
+func equalInts(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// parsedGroup records a parenthesised group or call argument list from a
+// previous parse. Its contents depend only on the tokens between the parens,
+// so it can be reused while those tokens are untouched by an edit.
+type parsedGroup struct {
+	end   int // offset one past the closing paren
+	holes int // holes numbered before the group
+	count int // holes inside the group
+	shift int // offset still to be added to the positions in expr or args
+	call  bool
+	expr  Expression
+	args  []Expression
+}
+
+// reuseState describes an edit replacing the old bytes [start, oldEnd) with
+// the new bytes [start, newEnd).
+type reuseState struct {
+	old    map[int]*parsedGroup
+	start  int
+	oldEnd int
+	newEnd int
+	delta  int
+}
+
+func (l *Lexer) recordGroup(open *Token, holes int, group parsedGroup) {
+	if l.groups == nil {
+		return
+	}
+	recorded := group
+	recorded.end = l.prev.End
+	recorded.holes = holes
+	recorded.count = l.holes - holes
+	l.groups[open.Pos] = &recorded
+}
+
+// reusedGroup looks up the group opened by open in the previous parse and,
+// if it is still valid here, skips its tokens and returns it with positions
+// adjusted for the edit.
+func (l *Lexer) reusedGroup(open *Token, call bool) (parsedGroup, bool) {
+	r := l.reuse
+	if r == nil {
+		return parsedGroup{}, false
+	}
+	oldPos, shift := open.Pos, 0
+	if open.Pos >= r.newEnd {
+		oldPos, shift = open.Pos-r.delta, r.delta
+	} else if open.Pos >= r.start {
+		return parsedGroup{}, false
+	}
+	group, ok := r.old[oldPos]
+	if !ok || group.call != call || group.holes != l.holes {
+		return parsedGroup{}, false
+	}
+	if open.Pos < r.start && group.end > r.start {
+		return parsedGroup{}, false
+	}
+	end := group.end + shift
+	m := sort.Search(len(l.tokens), func(k int) bool {
+		return l.tokens[k].Pos < end
+	})
+	if m == len(l.tokens) || !l.tokens[m].isOp(RParenOp) || l.tokens[m].End != end {
+		return parsedGroup{}, false
+	}
+	l.prev = &l.tokens[m]
+	l.tokens = l.tokens[:m]
+	l.holes += group.count
+	reused := *group
+	shift += group.shift
+	if shift != 0 {
+		reused.expr = shiftExpr(group.expr, shift)
+		reused.args = make([]Expression, len(group.args))
+		for i, arg := range group.args {
+			reused.args[i] = shiftExpr(arg, shift)
+		}
+	}
+	return reused, true
+}
+
+// shiftExpr copies e with every position moved by delta.
+func shiftExpr(e Expression, delta int) Expression {
+	switch v := e.(type) {
+	case IntegerToken:
+		v.pos += delta
+		return v
+	case FloatToken:
+		v.pos += delta
+		return v
+	case IdentifierToken:
+		v.pos += delta
+		return v
+	case StringToken:
+		v.pos += delta
+		return v
+	case Hole:
+		v.pos += delta
+		return v
+	case *PrefixExpression:
+		return &PrefixExpression{op: v.op, rhs: shiftExpr(v.rhs, delta), pos: v.pos + delta}
+	case *InfixExpression:
+		return &InfixExpression{lhs: shiftExpr(v.lhs, delta), rhs: shiftExpr(v.rhs, delta), op: v.op, pos: v.pos + delta}
+	case *CallExpression:
+		args := make([]Expression, len(v.args))
+		for i, arg := range v.args {
+			args[i] = shiftExpr(arg, delta)
+		}
+		return &CallExpression{name: v.name, args: args, pos: v.pos + delta}
+	}
+	return e
+}
+
+// Document keeps a source text parsed across edits. An edit re-lexes only
+// the tokens it touches and reuses every parenthesised group and call
+// argument list lying wholly outside it, so editors can reparse large
+// formulas on each keystroke. Blocks and registered literals are lexed into
+// tokens that stand for syntax they do not spell, such as the let calls of
+// a block, which cannot be re-lexed piecemeal, so a source with any is
+// re-lexed whole. A Document is not safe for concurrent use.
+type Document struct {
+	src    string
+	tokens TokenArray // reversed, as the parser consumes them; nil when src does not lex
+	groups map[int]*parsedGroup
+	expr   Expression
+	err    error
+	// desugared is set when some token does not spell its source.
+	desugared bool
+}
+
+func NewDocument(src string) *Document {
+	d := &Document{src: src}
+	d.lexAll()
+	d.reparse(nil)
+	return d
+}
+
+func (d *Document) Source() string {
+	return d.src
+}
+
+// Expr returns the tree for the current source, or its syntax error.
+func (d *Document) Expr() (Expression, error) {
+	return d.expr, d.err
+}
+
+// Edit replaces the bytes [start, end) of the source with text and returns
+// the reparsed tree.
+func (d *Document) Edit(start int, end int, text string) (Expression, error) {
+	if start < 0 || start > end || end > len(d.src) {
+		return nil, fmt.Errorf("edit [%d, %d) out of range for source of length %d", start, end, len(d.src))
+	}
+	old := d.src
+	d.src = old[:start] + text + old[end:]
+	if d.tokens == nil || d.desugared || strings.ContainsAny(old[start:end], "{};") || strings.ContainsAny(text, "{};") || !d.relex(start, end, len(text)-(end-start)) {
+		d.lexAll()
+	}
+	d.reparse(&reuseState{old: d.groups, start: start, oldEnd: end, newEnd: start + len(text), delta: len(text) - (end - start)})
+	return d.expr, d.err
+}
+
+// lexTokens returns the tokens of src[from:to] in source order.
+func lexTokens(src string, from int, to int) (tokens TokenArray, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			tokens, err = nil, syntaxErr
+		}
+	}()
+	var l Lexer
+	return l.scan(src, from, to, TokenArray{}), nil
+}
+
+func (d *Document) lexAll() {
+	d.tokens, d.err = lexTokens(d.src, 0, len(d.src))
+	d.tokens.Reverse()
+	d.desugared = false
+	for i := range d.tokens {
+		if !spellsSource(&d.tokens[i], d.src) {
+			d.desugared = true
+			break
+		}
+	}
+}
+
+// spellsSource reports whether tok is the text it spans in src, as every
+// token is except those standing for blocks and literals.
+func spellsSource(tok *Token, src string) bool {
+	text := src[tok.Pos:tok.End]
+	if tok.Kind == StringLiteral {
+		return len(text) == len(tok.Lit)+2 && text[0] == '"' && text[1:len(text)-1] == tok.Lit
+	}
+	return text == tok.Lit
+}
+
+// canJoin reports whether tok may lex differently with text added next to
+// it, as a word grows or "*" becomes "**". A "+" or "-" may become the sign
+// of an exponent, as in "1e-2".
+func canJoin(tok *Token) bool {
+	switch tok.Kind {
+	case Integer, Float, Identifier, Placeholder:
+		return true
+	case Operand, Illegal:
+		if tok.Lit == "+" || tok.Lit == "-" {
+			return true
+		}
+		for i := 0; i < len(tok.Lit); i++ {
+			if operatorBytes[tok.Lit[i]] || tok.Lit[i] == '.' || tok.Lit[i] >= utf8.RuneSelf {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relex re-lexes the tokens touched by an edit in place and shifts the ones
+// after it by delta. It reports false, leaving the tokens alone, when the
+// edited region does not lex on its own, as when a quote is inserted, or
+// lexes into tokens that do not spell it, as a literal does.
+func (d *Document) relex(start int, end int, delta int) bool {
+	n := len(d.tokens)
+	// at indexes the reversed tokens in source order.
+	at := func(k int) *Token {
+		return &d.tokens[n-1-k]
+	}
+	i := sort.Search(n, func(k int) bool {
+		return at(k).End >= start
+	})
+	j := sort.Search(n, func(k int) bool {
+		return at(k).Pos > end
+	})
+	from, to := start, len(d.src)
+	if i < n && at(i).Pos < from {
+		from = at(i).Pos
+	}
+	// A token touching the region may join up with what is typed into it,
+	// so widen the region over adjacent tokens that can.
+	for i > 0 && at(i-1).End == from && canJoin(at(i-1)) {
+		i -= 1
+		from = at(i).Pos
+	}
+	for j > 0 && j < n && at(j).Pos == at(j-1).End && canJoin(at(j)) {
+		j += 1
+	}
+	if j < n {
+		to = at(j).Pos + delta
+	}
+	window, err := lexTokens(d.src, from, to)
+	if err != nil {
+		return false
+	}
+	for k := range window {
+		if !spellsSource(&window[k], d.src) {
+			return false
+		}
+	}
+	window.Reverse()
+	after, before := n-j, n-i
+	for k := range d.tokens[:after] {
+		d.tokens[k].Pos += delta
+		d.tokens[k].End += delta
+	}
+	if grow := len(window) - (before - after); grow > 0 {
+		d.tokens = append(d.tokens, make(TokenArray, grow)...)
+		copy(d.tokens[before+grow:], d.tokens[before:n])
+	} else {
+		copy(d.tokens[before+grow:], d.tokens[before:n])
+		d.tokens = d.tokens[:n+grow]
+	}
+	copy(d.tokens[after:], window)
+	return true
+}
+
+// reparse parses d.tokens, reusing groups from the previous parse described
+// by reuse, and records the groups of the new tree. Groups carried over from
+// the previous parse are updated in place.
+func (d *Document) reparse(reuse *reuseState) {
+	if d.tokens == nil {
+		d.expr, d.groups = nil, nil
+		return
+	}
+	// The lexer only reslices its tokens, so it can parse d.tokens directly.
+	l := &Lexer{tokens: d.tokens, groups: make(map[int]*parsedGroup, len(d.groups)), reuse: reuse}
+	l.reset(len(d.src))
+	d.expr, d.err = parseLexed(l)
+	if reuse != nil {
+		for pos, group := range reuse.old {
+			if pos >= reuse.oldEnd {
+				pos += reuse.delta
+				group.end += reuse.delta
+				group.shift += reuse.delta
+			} else if group.end > reuse.start {
+				continue
+			}
+			if _, ok := l.groups[pos]; !ok {
+				l.groups[pos] = group
+			}
+		}
+	}
+	d.groups = l.groups
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkDocument compares d with a fresh parse of its source.
+func checkDocument(t *testing.T, d *Document, edit string) {
+	t.Helper()
+	got, gotErr := d.Expr()
+	want, wantErr := Parse(d.Source())
+	if (gotErr == nil) != (wantErr == nil) {
+		t.Fatalf("%s: Document gives %v, Parse(%q) gives %v", edit, gotErr, d.Source(), wantErr)
+	}
+	if wantErr != nil {
+		return
+	}
+	if !equalExpr(got, want) {
+		t.Fatalf("%s: Document gives %s, Parse(%q) gives %s", edit, Format(got), d.Source(), Format(want))
+	}
+	var gotPos, wantPos []int
+	walk(got, 1, func(e Expression, depth int) bool {
+		gotPos = append(gotPos, e.getPosition())
+		return true
+	})
+	walk(want, 1, func(e Expression, depth int) bool {
+		wantPos = append(wantPos, e.getPosition())
+		return true
+	})
+	for i := range wantPos {
+		if gotPos[i] != wantPos[i] {
+			t.Fatalf("%s: Document has positions %v, Parse(%q) %v", edit, gotPos, d.Source(), wantPos)
+		}
+	}
+}
+
+func TestDocumentEdits(t *testing.T) {
+	tests := []struct {
+		src   string
+		start int
+		end   int
+		text  string
+		want  string
+	}{
+		{"a + b", 4, 5, "(c * d)", "a + (c * d)"},
+		{"f(x, y) * 2", 5, 6, "y + 1", "f(x, y + 1) * 2"},
+		{"{ x = 1; x }", 7, 7, "0", "{ x = 10; x }"},
+		{"{ x = 1; x }", 7, 8, ",", "{ x = 1, x }"},
+		{"{ x = 1; x }", 0, 1, "", " x = 1; x }"},
+		{"{ x = 1; x }", 2, 3, "y", "{ y = 1; x }"},
+		{"x + 1", 0, 0, "{ x = 2; ", "{ x = 2; x + 1"},
+		{"{ x = 2; x + 1", 14, 14, " }", "{ x = 2; x + 1 }"},
+		{"tbcbc", 0, 0, "{", "{tbcbc"},
+		{"a * b", 1, 1, ".5", "a.5 * b"},
+		{"1 * b", 1, 1, ".5", "1.5 * b"},
+		{"e-2", 0, 0, "1", "1e-2"},
+		{"x * e+3", 4, 4, "2", "x * 2e+3"},
+		{"1e-2 * x", 1, 2, "", "1-2 * x"},
+		{"1e 2", 2, 3, "-", "1e-2"},
+		{"0.05 + 1e-3", 9, 10, "+", "0.05 + 1e+3"},
+	}
+	for _, tt := range tests {
+		d := NewDocument(tt.src)
+		d.Edit(tt.start, tt.end, tt.text)
+		if d.Source() != tt.want {
+			t.Fatalf("Edit gives %q, want %q", d.Source(), tt.want)
+		}
+		checkDocument(t, d, tt.src+" -> "+tt.want)
+	}
+}
+
+func TestDocumentRandomEdits(t *testing.T) {
+	seeds := []string{
+		"{ x = 1; x }",
+		"{ t = a * b; u = t + 1; t + u }",
+		"f({ x = 2; x * x }, y) + (z - 1)",
+		"try(a / b, 0) * 2.5 + g(h(1), 2)",
+		"x > 0 && fn(y) => y + 1",
+		"1e-3 * x + 0.05 - e-2",
+	}
+	pieces := []string{"{", "}", ";", "=", "x", "1", "(", ")", ",", " ", "+", "-", ".", "e", "\""}
+	rng := rand.New(rand.NewSource(1))
+	for _, seed := range seeds {
+		d := NewDocument(seed)
+		for step := 0; step < 300; step++ {
+			src := d.Source()
+			start := rng.Intn(len(src) + 1)
+			end := start
+			if rng.Intn(3) == 0 && start < len(src) {
+				end = start + 1 + rng.Intn(len(src)-start)
+				if end-start > 3 {
+					end = start + 1
+				}
+			}
+			text := pieces[rng.Intn(len(pieces))]
+			if rng.Intn(4) == 0 {
+				text = ""
+			}
+			d.Edit(start, end, text)
+			checkDocument(t, d, src)
+			if len(d.Source()) > 60 || len(d.Source()) == 0 {
+				d = NewDocument(seed)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// WithInputLimits rejects input of more than maxBytes bytes or maxTokens
+// tokens, where a limit of 0 is no limit, with a SyntaxError wrapping
+// ErrInputTooLarge. Input is rejected before it takes more memory than the
+// limits allow, so services can parse expressions from untrusted clients.
+func WithInputLimits(maxBytes int, maxTokens int) ParseOption {
+	return func(l *Lexer) {
+		l.maxBytes = maxBytes
+		l.maxTokens = maxTokens
+	}
+}
+
+func (l *Lexer) checkBytes(n int) {
+	if l.maxBytes > 0 && n > l.maxBytes {
+		panic(SyntaxError{Pos: l.maxBytes, End: n, Msg: fmt.Sprintf("input longer than %d bytes", l.maxBytes), cause: ErrInputTooLarge})
+	}
+}
+
+func (l *Lexer) checkTokens(tokens TokenArray) {
+	if l.maxTokens > 0 && len(tokens) > l.maxTokens {
+		panic(tooManyTokens(l.maxTokens, tokens[l.maxTokens].Pos, tokens[len(tokens)-1].End))
+	}
+}
+
+func tooManyTokens(max int, pos int, end int) SyntaxError {
+	return SyntaxError{Pos: pos, End: end, Msg: fmt.Sprintf("input longer than %d tokens", max), cause: ErrInputTooLarge}
+}
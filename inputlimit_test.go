@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInputLimits(t *testing.T) {
+	tests := []struct {
+		src               string
+		maxBytes, maxToks int
+		err               string
+	}{
+		{"1 + 2", 0, 0, ""},
+		{"1 + 2", 5, 3, ""},
+		{"1 + 2", 4, 0, "input longer than 4 bytes at column 5"},
+		{"1 + 2", 0, 2, "input longer than 2 tokens at column 5"},
+		{"f(1, 2, 3, 4)", 0, 5, "input longer than 5 tokens at column 7"},
+		{strings.Repeat("1 + ", 1<<20) + "1", 1 << 10, 0, "input longer than 1024 bytes at column 1025"},
+		{strings.Repeat("1+", 1<<20) + "1", 0, 100, "input longer than 100 tokens at column 101"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src, WithInputLimits(tt.maxBytes, tt.maxToks))
+		name := tt.src
+		if len(name) > 20 {
+			name = name[:20] + "..."
+		}
+		if errorString(err) != tt.err {
+			t.Errorf("Parse(%s) with limits %d, %d = %v, want %q", name, tt.maxBytes, tt.maxToks, err, tt.err)
+		}
+		if err != nil && (!errors.Is(err, ErrInputTooLarge) || !errors.Is(err, ErrSyntax)) {
+			t.Errorf("Parse(%s) fails with %v, want ErrInputTooLarge and ErrSyntax", name, err)
+		}
+	}
+	// Limits do not carry over to the next parse of a reused lexer.
+	if _, err := Parse("1 + 2 + 3"); err != nil {
+		t.Errorf("Parse without limits = %v", err)
+	}
+	if _, err := Parse("1 / 0"); errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("an ordinary expression is too large")
+	}
+}
+
+func TestInputLimitsInterpolated(t *testing.T) {
+	_, err := Parse(`"${a} ${b} ${c}"`, WithInterpolation(), WithInputLimits(0, 8))
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Parse of an interpolated string over the token limit = %v", err)
+	}
+}
+
+func TestParseTokensLimit(t *testing.T) {
+	tokens := []Token{
+		{Kind: Integer, Lit: "1", Int: 1, Pos: 0, End: 1},
+		{Kind: Operand, Lit: "+", Op: AddOp, Pos: 2, End: 3},
+		{Kind: Integer, Lit: "2", Int: 2, Pos: 4, End: 5},
+	}
+	if _, err := ParseTokens(tokens, WithInputLimits(0, 3)); err != nil {
+		t.Errorf("ParseTokens within the limit = %v", err)
+	}
+	_, err := ParseTokens(tokens, WithInputLimits(0, 2))
+	if errorString(err) != "input longer than 2 tokens at column 5" || !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("ParseTokens over the limit = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import "strings"
+
+// WithInterpolation lets string literals embed expressions, as in
+// "total: ${price * qty}". A string with embedded expressions is parsed as
+// the concatenation of its text with the embedded values converted by str,
+// ("total: " + str(price * qty)), so it formats and compiles like any other
+// expression. Without the option ${ is ordinary text in strings.
+func WithInterpolation() ParseOption {
+	return func(l *Lexer) {
+		l.interpolation = true
+	}
+}
+
+// scanInterpolated appends the tokens of the string literal at input[i:to]
+// to tokenArray and returns its length, or 0 if it embeds no expressions
+// or interpolation is not enabled.
+func (l *Lexer) scanInterpolated(input string, i int, to int, tokenArray TokenArray) (TokenArray, int) {
+	if !l.interpolation || input[i] != '"' || !strings.Contains(input[i+1:to], "${") {
+		return tokenArray, 0
+	}
+	type segment struct {
+		start, end int
+		expr       bool
+	}
+	var segments []segment
+	interpolated := false
+	text := i + 1
+	j := i + 1
+	for ; j < to && input[j] != '"'; j++ {
+		if !strings.HasPrefix(input[j:to], "${") {
+			continue
+		}
+		end := closingBrace(input, j+2, to)
+		if end < 0 {
+			panic(SyntaxError{Pos: j, End: to, Msg: "unterminated ${ in string literal"})
+		}
+		if strings.TrimSpace(input[j+2:end]) == "" {
+			panic(SyntaxError{Pos: j, End: end + 1, Msg: "empty ${} in string literal"})
+		}
+		if j > text {
+			segments = append(segments, segment{text, j, false})
+		}
+		segments = append(segments, segment{j + 2, end, true})
+		interpolated = true
+		j = end
+		text = end + 1
+	}
+	if j >= to {
+		panic(SyntaxError{Pos: i, End: to, Msg: "unterminated string literal"})
+	}
+	if !interpolated {
+		return tokenArray, 0
+	}
+	if j > text {
+		segments = append(segments, segment{text, j, false})
+	}
+	tokenArray = append(tokenArray, Token{Kind: Operand, Lit: "(", Op: LParenOp, Pos: i, End: i + 1})
+	for n, s := range segments {
+		if n > 0 {
+			tokenArray = append(tokenArray, Token{Kind: Operand, Lit: "+", Op: AddOp, Pos: s.start, End: s.start})
+		}
+		if !s.expr {
+			tokenArray = append(tokenArray, Token{Kind: StringLiteral, Lit: input[s.start:s.end], Pos: s.start, End: s.end})
+			continue
+		}
+		tokenArray = append(tokenArray,
+			Token{Kind: Identifier, Lit: "str", Pos: s.start - 2, End: s.end + 1},
+			Token{Kind: Operand, Lit: "(", Op: LParenOp, Pos: s.start - 2, End: s.start})
+		tokenArray = l.scan(input, s.start, s.end, tokenArray)
+		tokenArray = append(tokenArray, Token{Kind: Operand, Lit: ")", Op: RParenOp, Pos: s.end, End: s.end + 1})
+	}
+	tokenArray = append(tokenArray, Token{Kind: Operand, Lit: ")", Op: RParenOp, Pos: j, End: j + 1})
+	return tokenArray, j + 1 - i
+}
+
+// closingBrace returns the offset of the } closing an embedded expression
+// starting at from, skipping nested braces and string literals, or -1 if
+// there is none before to.
+func closingBrace(input string, from int, to int) int {
+	depth := 0
+	for k := from; k < to; k++ {
+		switch input[k] {
+		case '"':
+			for k++; k < to && input[k] != '"'; k++ {
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return k
+			}
+			depth--
+		}
+	}
+	return -1
+}
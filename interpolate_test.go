@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestInterpolation(t *testing.T) {
+	env := Env{"price": FloatValue(2.5), "qty": IntValue(4), "name": StringValue("tea")}
+	tests := []struct {
+		src, formatted string
+		want           Value
+	}{
+		{`"total: ${price * qty}"`, `"total: " + str(price * qty)`, StringValue("total: 10")},
+		{`"${qty} x ${name}"`, `str(qty) + " x " + str(name)`, StringValue("4 x tea")},
+		{`"${qty}"`, `str(qty)`, StringValue("4")},
+		{`"${name + "!"}" + "?"`, `str(name + "!") + "?"`, StringValue("tea!?")},
+		{`"in ${"${qty}"}"`, `"in " + str(str(qty))`, StringValue("in 4")},
+		{`"$qty {qty}"`, `"$qty {qty}"`, StringValue("$qty {qty}")},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src, WithInterpolation())
+		if err != nil {
+			t.Errorf("Parse(%s) = %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.formatted {
+			t.Errorf("Parse(%s) = %s, want %s", tt.src, got, tt.formatted)
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := map[string]string{
+		`"a ${qty"`:      "unterminated ${ in string literal at column 4",
+		`"a ${ }"`:       "empty ${} in string literal at column 4",
+		`"a ${qty} b`:    "unterminated string literal at column 1",
+		`"a ${qty +} b"`: "unmatched ')' at column 11",
+	}
+	for src, want := range tests {
+		if _, err := Parse(src, WithInterpolation()); errorString(err) != want {
+			t.Errorf("Parse(%s) = %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestInterpolationIsOptIn(t *testing.T) {
+	e := mustParse(t, `"total: ${price}"`)
+	if got, err := NewEvaluator(nil).Eval(e); err != nil || !got.Equal(StringValue("total: ${price}")) {
+		t.Errorf(`"total: ${price}" without WithInterpolation = %s, %v`, got, err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsMathFunctions are the functions called as methods of JavaScript's Math
+// object, where hosts conventionally provide them under the same names.
+var jsMathFunctions = map[string]bool{
+	"abs": true, "sign": true, "sqrt": true, "cbrt": true, "exp": true,
+	"log": true, "log2": true, "log10": true, "floor": true, "ceil": true,
+	"trunc": true, "sin": true, "cos": true, "tan": true,
+	"asin": true, "acos": true, "atan": true, "atan2": true, "hypot": true,
+	"min": true, "max": true, "pow": true,
+}
+
+// jsReserved are the JavaScript reserved words, which cannot name a
+// variable or function.
+var jsReserved = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true,
+	"const": true, "continue": true, "debugger": true, "default": true,
+	"delete": true, "do": true, "else": true, "enum": true, "export": true,
+	"extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "implements": true, "import": true,
+	"in": true, "instanceof": true, "interface": true, "let": true,
+	"new": true, "null": true, "package": true, "private": true,
+	"protected": true, "public": true, "return": true, "static": true,
+	"super": true, "switch": true, "this": true, "throw": true, "true": true,
+	"try": true, "typeof": true, "var": true, "void": true, "while": true,
+	"with": true, "yield": true,
+}
+
+// jsComparisons spells the comparisons in JavaScript, where == and !=
+// would convert operands of different types.
+var jsComparisons = map[OpKind]string{
+	EqOp: "===",
+	NeOp: "!==",
+	LtOp: "<",
+	LeOp: "<=",
+	GtOp: ">",
+	GeOp: ">=",
+}
+
+// JS converts e to a JavaScript expression, so a formula validated here can
+// also run in the browser. Variables and functions keep their names, with
+// functions that Math provides, such as sqrt, and the min, max and pow
+// operator functions called as Math methods; ^ becomes Math.pow and the
+// mod operator function %; == and != become === and !==, and the shifts,
+// which JavaScript applies to 32 bits, are errors. JavaScript has no
+// integers, so a division of operands known to be integers, which is when
+// they are built from integer literals, is truncated with Math.trunc, and
+// one of operands of unknown kind, such as variables, is truncated when
+// both are whole numbers when it runs. A float variable holding a whole
+// number is then divided as an integer. Names that are not JavaScript
+// identifiers, and holes, are errors.
+func JS(e Expression) (string, error) {
+	s, _, _, err := jsExpr(e)
+	return s, err
+}
+
+// jsExpr returns the JavaScript for e with its precedence and kind, which
+// is unknownKind unless it is fixed by literals.
+func jsExpr(e Expression) (string, int, Kind, error) {
+	switch v := e.(type) {
+	case IntegerToken:
+		return strconv.FormatInt(v.value, 10), precPrimary, IntKind, nil
+	case FloatToken:
+		return formatFloatLiteral(v.value), precPrimary, FloatKind, nil
+	case StringToken:
+		quoted, err := json.Marshal(v.value)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return string(quoted), precPrimary, StringKind, nil
+	case IdentifierToken:
+		if err := jsIdentifier(v.name, v.pos); err != nil {
+			return "", 0, 0, err
+		}
+		return v.name, precPrimary, unknownKind, nil
+	case Hole:
+		return "", 0, 0, fmt.Errorf("cannot convert unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case *PrefixExpression:
+		rhs, prec, kind, err := jsExpr(v.rhs)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if name := operatorFunction(v.op, false); name != "" {
+			return jsCall(name, v.pos, rhs)
+		}
+		if v.op == NotOp {
+			if prec < precUnary {
+				rhs = "(" + rhs + ")"
+			}
+			return "!" + rhs, precUnary, BoolKind, nil
+		}
+		if v.op != SubOp && v.op != AddOp {
+			return "", 0, 0, fmt.Errorf("operator '%s' has no JavaScript equivalent at column %d", v.op, v.pos+1)
+		}
+		// Parenthesise nested signs too, since -- and ++ are operators.
+		if prec <= precUnary {
+			rhs = "(" + rhs + ")"
+		}
+		return v.op.String() + rhs, precUnary, kind, nil
+	case *InfixExpression:
+		lhs, lhsPrec, lhsKind, err := jsExpr(v.lhs)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		rhs, rhsPrec, rhsKind, err := jsExpr(v.rhs)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if name := operatorFunction(v.op, true); name == "mod" {
+			return joinBinary(lhs, lhsPrec, "%", rhs, rhsPrec, precMultiplicative), precMultiplicative, unknownKind, nil
+		} else if name != "" {
+			return jsCall(name, v.pos, lhs, rhs)
+		}
+		kind := unknownKind
+		if lhsKind == IntKind && rhsKind == IntKind {
+			kind = IntKind
+		} else if v.op == AddOp && lhsKind == StringKind && rhsKind == StringKind {
+			kind = StringKind
+		}
+		switch v.op {
+		case AddOp, SubOp:
+			return joinBinary(lhs, lhsPrec, v.op.String(), rhs, rhsPrec, precAdditive), precAdditive, kind, nil
+		case MulOp:
+			return joinBinary(lhs, lhsPrec, "*", rhs, rhsPrec, precMultiplicative), precMultiplicative, kind, nil
+		case DivOp:
+			quotient := joinBinary(lhs, lhsPrec, "/", rhs, rhsPrec, precMultiplicative)
+			switch {
+			case kind == IntKind:
+				return "Math.trunc(" + quotient + ")", precPrimary, IntKind, nil
+			case lhsKind == FloatKind || rhsKind == FloatKind:
+				return quotient, precMultiplicative, kind, nil
+			}
+			// The operands are passed to an arrow function so that each
+			// is computed once.
+			return "((a, b) => Number.isInteger(a) && Number.isInteger(b) ? Math.trunc(a / b) : a / b)(" + lhs + ", " + rhs + ")", precPrimary, kind, nil
+		case PowOp:
+			return "Math.pow(" + lhs + ", " + rhs + ")", precPrimary, kind, nil
+		case MatchOp:
+			return "new RegExp(" + rhs + ").test(" + lhs + ")", precPrimary, BoolKind, nil
+		case NotMatchOp:
+			return "!new RegExp(" + rhs + ").test(" + lhs + ")", precUnary, BoolKind, nil
+		case EqOp, NeOp, LtOp, LeOp, GtOp, GeOp:
+			return joinComparison(lhs, lhsPrec, jsComparisons[v.op], rhs, rhsPrec), precComparison, BoolKind, nil
+		case AndOp:
+			return joinBinary(lhs, lhsPrec, "&&", rhs, rhsPrec, precAnd), precAnd, BoolKind, nil
+		case OrOp:
+			return joinBinary(lhs, lhsPrec, "||", rhs, rhsPrec, precOr), precOr, BoolKind, nil
+		}
+		return "", 0, 0, fmt.Errorf("operator '%s' has no JavaScript equivalent at column %d", v.op, v.pos+1)
+	case *CallExpression:
+		if isTry(v) {
+			return "", 0, 0, fmt.Errorf("try has no JavaScript equivalent at column %d", v.pos+1)
+		}
+		if isLambda(v) {
+			return jsArrow(v)
+		}
+		if isLet(v) {
+			return jsLet(v)
+		}
+		args := make([]string, len(v.args))
+		for i, arg := range v.args {
+			s, _, _, err := jsExpr(arg)
+			if err != nil {
+				return "", 0, 0, err
+			}
+			args[i] = s
+		}
+		return jsCall(v.name, v.pos, args...)
+	}
+	return "", 0, 0, fmt.Errorf("cannot convert %T", e)
+}
+
+// jsArrow writes a lambda as an arrow function, which binds looser than
+// any operator.
+func jsArrow(e *CallExpression) (string, int, Kind, error) {
+	params := make([]string, 0, len(e.args)-1)
+	for _, param := range lambdaParams(e) {
+		if err := jsIdentifier(param.name, param.pos); err != nil {
+			return "", 0, 0, err
+		}
+		params = append(params, param.name)
+	}
+	body, _, _, err := jsExpr(lambdaBody(e))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return "(" + strings.Join(params, ", ") + ") => " + body, 0, FuncKind, nil
+}
+
+// jsLet writes a let as an arrow function of the name called with the
+// value, so the value is computed once.
+func jsLet(e *CallExpression) (string, int, Kind, error) {
+	name := e.args[0].(IdentifierToken)
+	if err := jsIdentifier(name.name, name.pos); err != nil {
+		return "", 0, 0, err
+	}
+	value, _, _, err := jsExpr(e.args[1])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	body, _, kind, err := jsExpr(e.args[2])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return "((" + name.name + ") => " + body + ")(" + value + ")", precPrimary, kind, nil
+}
+
+func jsCall(name string, pos int, args ...string) (string, int, Kind, error) {
+	call := "(" + strings.Join(args, ", ") + ")"
+	if jsMathFunctions[name] {
+		return "Math." + name + call, precPrimary, unknownKind, nil
+	}
+	if err := jsIdentifier(name, pos); err != nil {
+		return "", 0, 0, err
+	}
+	return name + call, precPrimary, unknownKind, nil
+}
+
+func jsIdentifier(name string, pos int) error {
+	if identifierLength(name) != len(name) || jsReserved[name] {
+		return fmt.Errorf("'%s' is not a JavaScript identifier at column %d", name, pos+1)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestJS(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`price * qty > 100 && region == "EU"`, `price * qty > 100 && region === "EU"`},
+		{"a != b", "a !== b"},
+		{"a < b == c", "(a < b) === c"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"!(a && b)", "!(a && b)"},
+		// Nested signs would be -- or ++.
+		{"-(-x)", "-(-x)"},
+		// Divisions of integers truncate, and those of variables do when
+		// they hold whole numbers.
+		{"7 / 2", "Math.trunc(7 / 2)"},
+		{"x / 2", "((a, b) => Number.isInteger(a) && Number.isInteger(b) ? Math.trunc(a / b) : a / b)(x, 2)"},
+		{"f(a) / b * 2", "((a, b) => Number.isInteger(a) && Number.isInteger(b) ? Math.trunc(a / b) : a / b)(f(a), b) * 2"},
+		{"7.0 / 2", "7.0 / 2"},
+		{"x / 2.5", "x / 2.5"},
+		{"sqrt(x) + f(y)", "Math.sqrt(x) + f(y)"},
+		{"2 ^ x", "Math.pow(2, x)"},
+		{`name =~ "^a"`, `new RegExp("^a").test(name)`},
+		{`name !~ "^a" || x`, `!new RegExp("^a").test(name) || x`},
+		// Strings are escaped for embedding in HTML too.
+		{`"a'b" + "</"`, `"a'b" + "\u003c/"`},
+		{"let(y, x + 1, y * y)", "((y) => y * y)(x + 1)"},
+		{"map(xs, fn(x, x + 1))", "map(xs, (x) => x + 1)"},
+		{"fn(x, x) == f", "((x) => x) === f"},
+	}
+	for _, tt := range tests {
+		if got, err := JS(mustParse(t, tt.src)); err != nil || got != tt.want {
+			t.Errorf("JS(%s) = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestJSErrors(t *testing.T) {
+	tests := map[string]string{
+		"try(x, 1)":        "try has no JavaScript equivalent at column 1",
+		"_ + 1":            "cannot convert unfilled hole #1 at column 1",
+		"a << 1":           "operator '<<' has no JavaScript equivalent at column 3",
+		"new + 1":          "'new' is not a JavaScript identifier at column 1",
+		"delete(x)":        "'delete' is not a JavaScript identifier at column 1",
+		"let(var, 1, var)": "'var' is not a JavaScript identifier at column 5",
+		"fn(this, this)":   "'this' is not a JavaScript identifier at column 4",
+		"${HOME} + 1":      "'${HOME}' is not a JavaScript identifier at column 1",
+	}
+	for src, want := range tests {
+		e, err := Parse(src, WithEnvReferences())
+		if err != nil {
+			t.Errorf("Parse(%s) = %v", src, err)
+			continue
+		}
+		if _, err := JS(e); errorString(err) != want {
+			t.Errorf("JS(%s) = %v, want %s", src, err, want)
+		}
+	}
+}
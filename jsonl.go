@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runJSONLines implements the jsonl subcommand, evaluating an expression
+// against every JSON object read from in, one per line, with the object's
+// fields bound as variables over any set with -vars and -var. Each result
+// is written to out as a JSON object shaped as --json prints them, in the
+// order read, so the output lines up with the input. Null fields are left
+// unbound. It returns the exit code of the first line that failed: 2 if it
+// failed to evaluate and 3 if it is not a JSON object.
+func runJSONLines(args []string, in io.Reader, out, errOut io.Writer) int {
+	flags := flag.NewFlagSet("jsonl", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	src := flags.String("expr", "", "expression to evaluate for each object")
+	varArgs := addVarFlags(flags)
+	envRefs := flags.Bool("env", false, "let the expression read environment variables as env.NAME or ${NAME}")
+	if err := flags.Parse(args); err != nil {
+		return flagsExitCode(err)
+	}
+	vars, err := varArgs.load()
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return varsExitCode(err)
+	}
+	if *src == "" || flags.NArg() > 0 {
+		fmt.Fprintln(errOut, "usage: jsonl -expr expression [-vars file] [-var name=value] [-env]")
+		return exitParseError
+	}
+	expr, err := parseInput(*src, vars, *envRefs)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitParseError
+	}
+	program, err := Compile(expr)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitParseError
+	}
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+	encoder := json.NewEncoder(writer)
+	reader := bufio.NewReader(in)
+	code := exitOK
+	fail := func(lineCode int) {
+		if code == exitOK {
+			code = lineCode
+		}
+	}
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			fmt.Fprintln(errOut, readErr)
+			return exitIOError
+		}
+		if strings.TrimSpace(line) != "" {
+			var output jsonOutput
+			env, err := objectEnv(line, vars)
+			if err != nil {
+				output.Error = &jsonError{Code: "input", Message: err.Error()}
+				fail(exitIOError)
+			} else if value, err := program.Run(env); err != nil {
+				output.Error = newJSONError("eval", err)
+				fail(exitEvalError)
+			} else {
+				output.Result = &value
+			}
+			if err := encoder.Encode(output); err != nil {
+				fmt.Fprintln(errOut, err)
+				return exitIOError
+			}
+		}
+		if readErr == io.EOF {
+			return code
+		}
+	}
+}
+
+// objectEnv returns vars with the fields of the JSON object in line bound
+// over them.
+func objectEnv(line string, vars Env) (Env, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(line)))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	env := make(Env, len(vars)+len(fields))
+	for name, value := range vars {
+		env[name] = value
+	}
+	for name, raw := range fields {
+		if raw == nil {
+			continue
+		}
+		value, err := valueFromJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
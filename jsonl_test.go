@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONLines(t *testing.T) {
+	tests := []struct {
+		args   []string
+		input  string
+		code   int
+		out    string
+		errOut string
+	}{
+		{[]string{"-expr", "price * qty * k", "-var", "k=2"},
+			"{\"price\": 2, \"qty\": 3}\n\n{\"price\": 1.5, \"qty\": null}\n[1]\n{\"price\": \"a\", \"qty\": 2}\n{\"price\": 1, \"qty\": 1}",
+			exitEvalError,
+			`{"result":{"kind":"int","value":12}}` + "\n" +
+				`{"error":{"code":"eval","pos":8,"message":"undefined variable 'qty'"}}` + "\n" +
+				`{"error":{"code":"input","message":"json: cannot unmarshal array into Go value of type map[string]interface {}"}}` + "\n" +
+				`{"error":{"code":"eval","pos":6,"message":"operator '*' not defined for string and int"}}` + "\n" +
+				`{"result":{"kind":"int","value":2}}` + "\n",
+			""},
+		{[]string{"-expr", "qty + 1"}, "null\n{\"qty\": 1}\n{\"qty\": 0.5}\n", exitIOError,
+			`{"error":{"code":"input","message":"expected a JSON object"}}` + "\n" +
+				`{"result":{"kind":"int","value":2}}` + "\n" +
+				`{"result":{"kind":"float","value":1.5}}` + "\n",
+			""},
+		{[]string{"-expr", "qty"}, "{\"qty\": 1}\n{\"x\": 2}\n", exitEvalError,
+			`{"result":{"kind":"int","value":1}}` + "\n" +
+				`{"error":{"code":"eval","pos":0,"message":"undefined variable 'qty'"}}` + "\n", ""},
+		{[]string{"-expr", "qty", "-var", "qty=1"}, "{\"qty\": 2}\n{}\n", exitOK,
+			`{"result":{"kind":"int","value":2}}` + "\n" + `{"result":{"kind":"int","value":1}}` + "\n", ""},
+		{[]string{"-expr", "1 +"}, "", exitParseError, "", "unexpected end of expression"},
+		{[]string{"-expr", "1", "extra"}, "", exitParseError, "", "usage: jsonl"},
+		{[]string{}, "", exitParseError, "", "usage: jsonl"},
+	}
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		if code := runJSONLines(tt.args, strings.NewReader(tt.input), &out, &errOut); code != tt.code {
+			t.Errorf("jsonl %q exits with %d, want %d: %s", tt.args, code, tt.code, errOut.String())
+		}
+		if out.String() != tt.out || !strings.Contains(errOut.String(), tt.errOut) {
+			t.Errorf("jsonl %q wrote\n%s\nand\n%s\nwant\n%s\nand\n%s", tt.args, out.String(), errOut.String(), tt.out, tt.errOut)
+		}
+	}
+}
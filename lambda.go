@@ -0,0 +1,122 @@
+package main
+
+import "fmt"
+
+// A lambda, fn(x, y) => x + y, is a function value for built-ins such as
+// sumif to call. Its body sees the parameters and, like a block, the
+// variables bound where the lambda is written. A lambda is parsed as a call
+// of fn with the parameters followed by the body, fn(x, y, x + y), which
+// can also be written so in source. It is evaluated by walking the tree, so
+// the compiling backends leave expressions with lambdas to the tree walker.
+func init() {
+	addBuiltin("fn", "fn(params..., body) func", docEntry{"a function of the parameters returning body, written fn(x) => body", "sumif(prices, fn(p) => p > 100)"},
+		func(args []Value) (Value, error) {
+			return Value{}, fmt.Errorf("fn takes parameter names and a body")
+		})
+}
+
+// isLambda reports whether e is a lambda: a call of fn whose arguments
+// before the body are all names.
+func isLambda(e *CallExpression) bool {
+	if e.name != "fn" || len(e.args) == 0 {
+		return false
+	}
+	for _, param := range e.args[:len(e.args)-1] {
+		if _, ok := param.(IdentifierToken); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func lambdaParams(e *CallExpression) []IdentifierToken {
+	params := make([]IdentifierToken, len(e.args)-1)
+	for i, param := range e.args[:len(e.args)-1] {
+		params[i] = param.(IdentifierToken)
+	}
+	return params
+}
+
+func lambdaBody(e *CallExpression) Expression {
+	return e.args[len(e.args)-1]
+}
+
+// parseLambda is the infix rule for =>, whose left operand must be fn
+// applied to distinct parameter names. The body extends as far as an
+// argument would.
+func parseLambda(p *Parser, lhs Expression) Expression {
+	l := (*Lexer)(p)
+	arrow := l.prev
+	call, ok := lhs.(*CallExpression)
+	if !ok || call.name != "fn" {
+		panic(arrow.errorf("expected fn(parameters) before '=>'"))
+	}
+	seen := make(map[string]bool, len(call.args))
+	for _, arg := range call.args {
+		param, ok := arg.(IdentifierToken)
+		if !ok {
+			panic(SyntaxError{Pos: arg.getPosition(), End: arrow.Pos, Msg: "expected a parameter name"})
+		}
+		if seen[param.name] {
+			panic(SyntaxError{Pos: param.pos, End: param.pos + len(param.name), Msg: fmt.Sprintf("duplicate parameter '%s'", param.name)})
+		}
+		seen[param.name] = true
+	}
+	body := p.Expression(minBindingPower)
+	return newCall(l, IdentifierToken{name: call.name, pos: call.pos}, append(call.args[:len(call.args):len(call.args)], body))
+}
+
+// evalLambda returns the function a lambda denotes, which captures the
+// variables bound where the lambda is: those of enclosing lets and
+// lambdas keep the values they had then, however the lambda is called
+// later, while the environment is read when the body is evaluated.
+func (ev *evaluation) evalLambda(e *CallExpression) (Value, error) {
+	return ev.function(fmt.Sprintf("fn at column %d", e.pos+1), lambdaParams(e), lambdaBody(e), ev.locals), nil
+}
+
+// function returns a function evaluating body with params bound over
+// locals. Like a let body, the body is not memoized. The function may
+// escape the evaluation and be called from other goroutines, so it keeps a
+// copy of the evaluation as it is now, and each call evaluates the body
+// with its own copy of that, sharing only the budget.
+func (ev *evaluation) function(name string, params []IdentifierToken, body Expression, locals *scope) Value {
+	if ev.budget == nil {
+		ev.budget = &evalBudget{}
+	}
+	captured := *ev
+	captured.locals, captured.memo = locals, nil
+	return FuncValue(func(args []Value) (Value, error) {
+		if len(args) != len(params) {
+			return Value{}, fmt.Errorf("%s takes %d arguments, not %d", name, len(params), len(args))
+		}
+		call := captured
+		leave, err := call.enter(name)
+		if err != nil {
+			return Value{}, err
+		}
+		defer leave()
+		for i, param := range params {
+			call.locals = &scope{name: param.name, value: args[i], parent: call.locals}
+		}
+		return call.eval(body)
+	})
+}
+
+// checkLambda types the body of a lambda with the parameters of unknown
+// kind, since they depend on the caller.
+func (c *typeChecker) checkLambda(e *CallExpression) Kind {
+	schema := make(Schema, len(c.schema)+len(e.args)-1)
+	for name, kind := range c.schema {
+		schema[name] = kind
+	}
+	for _, param := range lambdaParams(e) {
+		schema[param.name] = unknownKind
+	}
+	outer := c.schema
+	c.schema = schema
+	defer func() {
+		c.schema = outer
+	}()
+	c.check(lambdaBody(e))
+	return FuncKind
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLambdaParsing(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"fn(x) => x > 0", "fn(x, x > 0)"},
+		{"fn(x, y) => x + y * 2", "fn(x, y, x + y * 2)"},
+		{"fn() => 1", "fn(1)"},
+		{"f(xs, fn(x) => x + 1, 2)", "f(xs, fn(x, x + 1), 2)"},
+		{"fn(x) => fn(y) => x + y", "fn(x, fn(y, x + y))"},
+		{"a + fn(x) => x", "a + fn(x, x)"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+		again, err := Parse(Format(e))
+		if err != nil || !equalExpr(again, e) {
+			t.Errorf("%s does not parse back: %v", Format(e), err)
+		}
+	}
+	errors := []struct {
+		src  string
+		want string
+	}{
+		{"g(x) => x", "expected fn(parameters) before '=>'"},
+		{"fn(1) => x", "expected a parameter name"},
+		{"fn(x, x) => x", "duplicate parameter 'x'"},
+		{"fn(x) =>", "unexpected end"},
+		{"{ x => 1; x }", "expected name = value"},
+	}
+	for _, tt := range errors {
+		if _, err := Parse(tt.src); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestLambdaEvaluation(t *testing.T) {
+	env := Env{
+		"apply": FuncValue(func(args []Value) (Value, error) {
+			return args[0].Func()(args[1:])
+		}),
+		"double": FuncValue(func(args []Value) (Value, error) {
+			return applyInfix(MulOp, args[0], IntValue(2))
+		}),
+		"n": IntValue(10),
+	}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"apply(fn(x) => x * 2, 4)", IntValue(8)},
+		{"apply(fn(x, y) => x - y, 4, 1)", IntValue(3)},
+		{"apply(fn() => n)", IntValue(10)},
+		{"apply(fn(x) => x + n, 1)", IntValue(11)},
+		{"{ n = 1; apply(fn(x) => x + n, 1) }", IntValue(2)},
+		{"apply(fn(n) => n * n, 3)", IntValue(9)},
+		{"apply(apply(fn(x) => fn(y) => x - y, 5), 2)", IntValue(3)},
+		{"{ f = fn(x) => x * 3; f(2) }", IntValue(6)},
+		{"{ f = double; f(n) }", IntValue(20)},
+		{"{ double = fn(x) => x; double(n) }", IntValue(10)},
+		{"apply(fn(f) => f(4), double)", IntValue(8)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	e, _ := Parse("apply(fn() => n, 1)")
+	if _, err := NewEvaluator(env).Eval(e); err == nil || !strings.Contains(err.Error(), "fn at column 7 takes 0 arguments, not 1") {
+		t.Errorf("calling a lambda with too many arguments fails with %v", err)
+	}
+	e, _ = Parse("{ n = 5; n(1) }")
+	for _, b := range allBackends {
+		if _, err := NewEvaluator(env, WithBackend(b)).Eval(e); err == nil || !strings.Contains(err.Error(), "not a function") {
+			t.Errorf("calling a local number with the %s backend fails with %v", b, err)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Each lambda keeps the n of the call that made it.
+		{"make_adder(n) = fn(x) => x + n\nadd2 = make_adder(2)\nadd10 = make_adder(10)\nn = 100\nadd2(1) + add10(1)", "14"},
+		// A lambda keeps the locals where it is written, not where it is called.
+		{"{ k = 10; f = fn(x) => x + k; { k = 20; f(1) } }", "11"},
+		{"k = 10\nf = { k = 1; fn(x) => x + k }\nf(1)", "2"},
+		// Parameters shadow variables of the environment and captured ones.
+		{"x = 5\nsquare(x) = x * x\nsquare(3) + x", "14"},
+		{"make(n) = fn(n) => n * 2\ntwice = make(5)\ntwice(3)", "6"},
+		// The environment is read when the body is evaluated.
+		{"rate = 2\nscale(x) = x * rate\nrate = 3\nscale(2)", "6"},
+		// Functions are values, passed and called through parameters.
+		{"compose(f, g) = fn(x) => f(g(x))\ninc(x) = x + 1\ndouble(x) = x * 2\nh = compose(inc, double)\nh(5)", "11"},
+		{"f(x) = x + 1\nf(f(1)) == 3", "true"},
+	}
+	for _, tt := range tests {
+		got, err := runScriptSource(t, tt.src, Env{})
+		if err != nil {
+			t.Errorf("Run(%q): %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Run(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+	failures := []struct {
+		src  string
+		want string
+	}{
+		// A function does not see the locals of its caller.
+		{"f(x) = x + k\n{ k = 1; f(1) }", "undefined variable"},
+		{"f(x) = x\nf(1, 2)", "line 2: f takes 1 arguments, not 2"},
+	}
+	for _, tt := range failures {
+		if _, err := runScriptSource(t, tt.src, Env{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Run(%q) = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+	if _, err := ParseScript("f(x, x) = x"); err == nil || !strings.Contains(err.Error(), "duplicate parameter 'x' of f") {
+		t.Errorf("ParseScript with a duplicate parameter = %v", err)
+	}
+}
+
+func TestLambdaStepLimit(t *testing.T) {
+	e, err := Parse("sumif(xs, fn(x) => x > 0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs := make([]Value, 100)
+	for i := range xs {
+		xs[i] = IntValue(int64(i))
+	}
+	_, err = NewEvaluator(Env{"xs": ListValue(xs)}, WithStepLimit(50)).Eval(e)
+	if err == nil || !strings.Contains(err.Error(), "step") {
+		t.Errorf("the lambda's steps are not counted: %v", err)
+	}
+}
+
+// TestEscapedLambdaConcurrently calls a lambda returned by Eval from many
+// goroutines, which go test -race checks do not share evaluation state.
+func TestEscapedLambdaConcurrently(t *testing.T) {
+	e := mustParse(t, "{ k = 10; fn(x) => { t = x * k; sumif(xs, fn(y) => y < t) + t } }")
+	env := Env{"xs": ListValue([]Value{IntValue(5), IntValue(50), IntValue(500)})}
+	f, err := NewEvaluator(env, WithMemoization(), WithStepLimit(1000000)).Eval(e)
+	if err != nil || f.Kind() != FuncKind {
+		t.Fatalf("Eval = %s, %v, want a function", f, err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				x := int64(g*50 + i)
+				want := x * 10
+				for _, y := range []int64{5, 50, 500} {
+					if y < x*10 {
+						want += y
+					}
+				}
+				if got, err := f.Func()([]Value{IntValue(x)}); err != nil || !got.Equal(IntValue(want)) {
+					t.Errorf("f(%d) = %s, %v, want %d", x, got, err, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestLambdaAnalysis(t *testing.T) {
+	e, err := Parse("fn(x) => x + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Variables(e); !equalStrings(got, []string{"y"}) {
+		t.Errorf("Variables = %v, want [y]", got)
+	}
+	if kind, errs := Check(e, Schema{"y": IntKind}); kind != FuncKind || len(errs) != 0 {
+		t.Errorf("Check = %s, %v, want func", kind, errs)
+	}
+	if _, errs := Check(e, nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "'y'") {
+		t.Errorf("Check without y = %v, want y undefined", errs)
+	}
+	if _, err := Compile(e); err == nil {
+		t.Error("Compile(fn) succeeded")
+	}
+}
+
+func TestBindLambda(t *testing.T) {
+	tests := []struct {
+		src      string
+		bindings map[string]string
+		want     string
+	}{
+		{"fn(x) => x + a", map[string]string{"a": "b * 2", "x": "99"}, "fn(x, x + b * 2)"},
+		{"fn(x) => x + a", map[string]string{"a": "x"}, "fn(x_1, x_1 + x)"},
+		{"fn(x) => x + a + x_1", map[string]string{"a": "x"}, "fn(x_2, x_2 + x + x_1)"},
+		{"fn(x) => x", map[string]string{"x": "1"}, "fn(x, x)"},
+		{"f(x, fn(x) => x)", map[string]string{"x": "y"}, "f(y, fn(x, x))"},
+		{"{ f = fn(x) => x; f(a) }", map[string]string{"a": "f"}, "let(f_1, fn(x, x), f_1(f))"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bindings := make(map[string]Expression)
+		for name, src := range tt.bindings {
+			if bindings[name], err = Parse(src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := Format(Bind(e, bindings)); got != tt.want {
+			t.Errorf("Bind(%s, %v) = %s, want %s", tt.src, tt.bindings, got, tt.want)
+		}
+	}
+}
+
+func TestLambdaExports(t *testing.T) {
+	e, err := Parse("f(xs, fn(x, y) => x > y)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js, err := JS(e); err != nil || js != "f(xs, (x, y) => x > y)" {
+		t.Errorf("JS = %s, %v", js, err)
+	}
+	if _, err := SQL(e, PostgreSQL); err == nil || !strings.Contains(err.Error(), "fn has no SQL equivalent") {
+		t.Errorf("SQL fails with %v", err)
+	}
+	if _, err := GoAST(e); err == nil || !strings.Contains(err.Error(), "fn has no Go equivalent") {
+		t.Errorf("GoAST fails with %v", err)
+	}
+	if got := MathML(e); !strings.Contains(got, "<mo>&#x21A6;</mo>") {
+		t.Errorf("MathML = %s, want a mapping", got)
+	}
+	steps, err := TAC(e)
+	if err != nil || len(steps) != 1 || steps[0].String() != "t1 = f(xs, fn(x, y, x > y))" {
+		t.Errorf("TAC = %v", steps)
+	}
+}
+
+func TestLambdaRules(t *testing.T) {
+	rs, err := NewRuleSet([]Rule{{Name: "big", Expr: "countif(xs, fn(x) => x > limit) > 1"}}, Env{"limit": IntValue(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.Match(map[string]interface{}{"xs": []interface{}{1.0, 7.0, 9.0}}); !equalStrings(got, []string{"big"}) {
+		t.Errorf("Match = %v, want [big]", got)
+	}
+	if got := rs.Match(map[string]interface{}{"xs": []interface{}{1.0, 7.0}, "limit": 0.0}); !equalStrings(got, []string{"big"}) {
+		t.Errorf("Match with the limit in the event = %v, want [big]", got)
+	}
+	if got := rs.Match(map[string]interface{}{"xs": []interface{}{1.0, 7.0}}); len(got) != 0 {
+		t.Errorf("Match = %v, want none", got)
+	}
+}
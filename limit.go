@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter is middleware that bounds the load clients put on the handler it
+// wraps, such as a PrometheusMetrics or an EnvRegistry, or one that
+// evaluates expressions. Requests over a limit are refused rather than
+// queued: with 429 Too Many Requests and a Retry-After header for a client
+// over its rate, and with 503 Service Unavailable while the concurrency cap
+// is reached. A request let through carries the deadline set by
+// RequestTimeout in its context, for the handler to pass to EvalContext.
+// A Limiter is safe for concurrent use.
+type Limiter struct {
+	handler http.Handler
+	// rate is the requests a second each client may make, with bursts of
+	// burst, if positive.
+	rate  float64
+	burst int
+	// slots holds a value for each request running, if there is a cap.
+	slots   chan struct{}
+	timeout time.Duration
+	metrics Metrics
+	now     func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+}
+
+// bucket holds the requests a client may still make at once, as of when.
+type bucket struct {
+	tokens float64
+	when   time.Time
+}
+
+// maxIdleClients is how many clients a Limiter tracks before it forgets
+// those whose buckets have refilled, which behave as new clients would.
+const maxIdleClients = 10000
+
+type LimitOption func(*Limiter)
+
+// PerClientRate lets each client, identified by its remote IP address,
+// make perSecond requests a second on average and burst requests at once.
+func PerClientRate(perSecond float64, burst int) LimitOption {
+	return func(l *Limiter) {
+		l.rate = perSecond
+		l.burst = burst
+	}
+}
+
+// MaxConcurrent lets at most n requests run at once across all clients.
+func MaxConcurrent(n int) LimitOption {
+	return func(l *Limiter) {
+		l.slots = make(chan struct{}, n)
+	}
+}
+
+// RequestTimeout gives each request a deadline d after it is let through.
+func RequestTimeout(d time.Duration) LimitOption {
+	return func(l *Limiter) {
+		l.timeout = d
+	}
+}
+
+// LimitMetrics counts the requests the Limiter receives to m as
+// MetricRequests, labelled with an outcome of served, rate_limited or
+// over_capacity.
+func LimitMetrics(m Metrics) LimitOption {
+	return func(l *Limiter) {
+		l.metrics = m
+	}
+}
+
+// NewLimiter returns a Limiter serving h within the limits of opts. With
+// no options every request is let through.
+func NewLimiter(h http.Handler, opts ...LimitOption) *Limiter {
+	l := &Limiter{
+		handler: h,
+		now:     time.Now,
+		clients: make(map[string]*bucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *Limiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wait, ok := l.allow(client(r)); !ok {
+		l.count("rate_limited")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() {
+				<-l.slots
+			}()
+		default:
+			l.count("over_capacity")
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	l.count("served")
+	if l.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	l.handler.ServeHTTP(w, r)
+}
+
+func (l *Limiter) count(outcome string) {
+	if l.metrics != nil {
+		l.metrics.Count(MetricRequests, 1, Label{Name: "outcome", Value: outcome})
+	}
+}
+
+// allow takes a request from the bucket of client, or reports how long it
+// must wait for one.
+func (l *Limiter) allow(client string) (time.Duration, bool) {
+	if l.rate <= 0 {
+		return 0, true
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= maxIdleClients {
+			l.forgetIdle(now)
+		}
+		b = &bucket{tokens: float64(l.burst), when: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.when).Seconds()*l.rate)
+	b.when = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// forgetIdle removes the clients whose buckets have refilled by now.
+func (l *Limiter) forgetIdle(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.when).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// client identifies the client making r by its IP address.
+func client(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
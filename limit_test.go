@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serve(h http.Handler, remote string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.RemoteAddr = remote
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestLimiterRate(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(NewPrometheusMetrics(), PerClientRate(2, 3))
+	l.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve(l, "10.0.0.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d in the burst = %d", i+1, w.Code)
+		}
+	}
+	w := serve(l, "10.0.0.1:1001")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("request over the burst = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(l, "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("another client = %d", w.Code)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if w := serve(l, "10.0.0.1:1000"); w.Code != http.StatusOK {
+		t.Errorf("request after refilling one = %d", w.Code)
+	}
+	if w := serve(l, "10.0.0.1:1000"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request after refilling one = %d", w.Code)
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	l := NewLimiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}), MaxConcurrent(2))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(l, "10.0.0.1:1000")
+		}()
+		<-started
+	}
+	if w := serve(l, "10.0.0.2:1000"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request over the cap = %d", w.Code)
+	}
+	close(release)
+	wg.Wait()
+	go func() {
+		<-started
+	}()
+	if w := serve(l, "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("request after the others finished = %d", w.Code)
+	}
+}
+
+func TestLimiterTimeout(t *testing.T) {
+	e, err := Parse("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLimiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("the request has no deadline")
+		}
+		<-r.Context().Done()
+		if _, err := NewEvaluator(Env{"x": IntValue(1)}).EvalContext(r.Context(), e); err == nil {
+			t.Error("evaluating after the deadline succeeds")
+		}
+	}), RequestTimeout(time.Millisecond))
+	serve(l, "10.0.0.1:1000")
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// Keys the line editor handles, as read from a raw terminal.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// Escape sequences are decoded to keys past the end of Unicode.
+const (
+	keyUp rune = 0x110000 + iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDeleteForward
+	keyUnknown
+)
+
+// lineEditor reads lines from a terminal in raw mode with readline-style
+// editing: cursor movement, up and down to recall history, and Ctrl-R to
+// search it.
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+	// complete, if set, returns the word before the cursor and its
+	// completions for Tab.
+	complete func(before string) (string, []string)
+	// hint, if set, returns text shown dimmed after the line, given the
+	// text before the cursor.
+	hint func(before string) string
+}
+
+func newLineEditor(in io.Reader, out io.Writer, history *History) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, history: history}
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+	// recall is the index of the history line shown, or the history
+	// length while editing a new line, which is kept in draft.
+	recall int
+	draft  []rune
+}
+
+// ReadLine shows prompt and returns the line entered, without its newline.
+// It returns io.EOF for Ctrl-D on an empty line and errInterrupted for
+// Ctrl-C. The line is not added to the history.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	s := &lineState{prompt: prompt, recall: e.history.Len()}
+	e.refresh(s)
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(s.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case keyCtrlR:
+			line, submit := e.search(s)
+			if submit {
+				fmt.Fprint(e.out, "\n")
+				return line, nil
+			}
+		default:
+			e.edit(s, key)
+		}
+		e.refresh(s)
+	}
+}
+
+// edit applies a key that neither submits nor abandons the line.
+func (e *lineEditor) edit(s *lineState, key rune) {
+	switch key {
+	case keyLeft, keyCtrlB:
+		if s.pos > 0 {
+			s.pos--
+		}
+	case keyRight, keyCtrlF:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+	case keyHome, keyCtrlA:
+		s.pos = 0
+	case keyEnd, keyCtrlE:
+		s.pos = len(s.buf)
+	case keyBackspace, keyDelete:
+		if s.pos > 0 {
+			s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+			s.pos--
+		}
+	case keyDeleteForward:
+		s.deleteForward()
+	case keyCtrlK:
+		s.buf = s.buf[:s.pos]
+	case keyCtrlU:
+		s.buf = append(s.buf[:0], s.buf[s.pos:]...)
+		s.pos = 0
+	case keyUp, keyCtrlP:
+		e.recall(s, s.recall-1)
+	case keyDown, keyCtrlN:
+		e.recall(s, s.recall+1)
+	case keyCtrlL:
+		fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+	case keyTab:
+		e.completeWord(s)
+	default:
+		if key >= ' ' && key < keyUp && key != keyDelete {
+			s.insert(key)
+		}
+	}
+}
+
+func (s *lineState) deleteForward() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+// recall replaces the line with history line i, or with the draft once i
+// moves past the newest line.
+func (e *lineEditor) recall(s *lineState, i int) {
+	if i < 0 || i > e.history.Len() {
+		return
+	}
+	if s.recall == e.history.Len() {
+		s.draft = append(s.draft[:0], s.buf...)
+	}
+	s.recall = i
+	if i == e.history.Len() {
+		s.buf = append(s.buf[:0], s.draft...)
+	} else {
+		s.buf = []rune(e.history.At(i))
+	}
+	s.pos = len(s.buf)
+}
+
+// search runs an incremental reverse search of the history, started by
+// Ctrl-R. Ctrl-R again finds the next older match, Enter runs the match,
+// Ctrl-G or Ctrl-C restores the line, and any other key keeps the match for
+// editing and is then handled as usual.
+func (e *lineEditor) search(s *lineState) (string, bool) {
+	var query []rune
+	match := ""
+	from := e.history.Len() - 1
+	failed := false
+	find := func(start int) {
+		for i := start; i >= 0; i-- {
+			if strings.Contains(e.history.At(i), string(query)) {
+				match, from, failed = e.history.At(i), i, false
+				return
+			}
+		}
+		failed = true
+	}
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failing " + label
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), match)
+		key, err := e.readKey()
+		if err != nil {
+			return "", false
+		}
+		switch key {
+		case keyCtrlR:
+			if len(query) > 0 {
+				find(from - 1)
+			}
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(e.history.Len() - 1)
+			}
+		case keyEnter, '\n':
+			if match == "" {
+				return string(s.buf), true
+			}
+			return match, true
+		case keyCtrlG, keyCtrlC:
+			return "", false
+		default:
+			if key >= ' ' && key < keyUp {
+				query = append(query, key)
+				find(from)
+				continue
+			}
+			if match != "" {
+				s.buf = []rune(match)
+				s.pos = len(s.buf)
+				s.recall = from
+			}
+			e.edit(s, key)
+			return "", false
+		}
+	}
+}
+
+// completeWord completes the word before the cursor as far as its
+// completions agree, listing them if that adds nothing.
+func (e *lineEditor) completeWord(s *lineState) {
+	if e.complete == nil {
+		return
+	}
+	word, candidates := e.complete(string(s.buf[:s.pos]))
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		for _, r := range prefix[len(word):] {
+			s.insert(r)
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+	}
+}
+
+func (s *lineState) insert(r rune) {
+	s.buf = append(s.buf, 0)
+	copy(s.buf[s.pos+1:], s.buf[s.pos:])
+	s.buf[s.pos] = r
+	s.pos++
+}
+
+// refresh redraws the prompt, line and hint and places the cursor.
+func (e *lineEditor) refresh(s *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", s.prompt, string(s.buf))
+	back := len(s.buf) - s.pos
+	if e.hint != nil {
+		if hint := e.hint(string(s.buf[:s.pos])); hint != "" {
+			fmt.Fprintf(e.out, "  \x1b[2m%s\x1b[0m", hint)
+			back += 2 + len([]rune(hint))
+		}
+	}
+	if back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// readKey reads one key, decoding the escape sequences of arrow and editing
+// keys.
+func (e *lineEditor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	}
+	if r < '0' || r > '9' {
+		return keyUnknown, nil
+	}
+	// Sequences such as ESC [ 3 ~ end at the first byte outside 0-9 and ;.
+	code := r
+	for r >= '0' && r <= '9' || r == ';' {
+		if r, _, err = e.in.ReadRune(); err != nil {
+			return 0, err
+		}
+	}
+	if r != '~' {
+		return keyUnknown, nil
+	}
+	switch code {
+	case '1', '7':
+		return keyHome, nil
+	case '4', '8':
+		return keyEnd, nil
+	case '3':
+		return keyDeleteForward, nil
+	}
+	return keyUnknown, nil
+}
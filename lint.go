@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// LintIssue is a suspicious but valid construct at the source bytes
+// [Pos, End). Rule names the check that found it.
+type LintIssue struct {
+	Pos      int
+	End      int
+	Severity Severity
+	Rule     string
+	Msg      string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s at column %d [%s]", i.Severity, i.Msg, i.Pos+1, i.Rule)
+}
+
+// Lint parses src and reports redundant parentheses along with the issues
+// found by LintExpr, ordered by position. A syntax error is returned as a
+// single error issue.
+func Lint(src string) []LintIssue {
+	expr, groups, err := parseGroups(src)
+	if err != nil {
+		syntaxErr := err.(SyntaxError)
+		return []LintIssue{{Pos: syntaxErr.Pos, End: syntaxErr.End, Severity: SeverityError, Rule: "syntax", Msg: syntaxErr.Msg}}
+	}
+	issues := LintExpr(expr)
+	for open, group := range groups {
+		// A block of one expression is parsed as a group, but its braces
+		// are not parentheses.
+		if group.call || src[open] != '(' {
+			continue
+		}
+		// Parentheses are redundant when blanking them out leaves the tree
+		// unchanged.
+		blanked := src[:open] + " " + src[open+1:group.end-1] + " " + src[group.end:]
+		if reparsed, err := Parse(blanked); err == nil && equalExpr(expr, reparsed) {
+			issues = append(issues, LintIssue{
+				Pos:      open,
+				End:      group.end,
+				Severity: SeverityInfo,
+				Rule:     "redundant-parens",
+				Msg:      "redundant parentheses",
+			})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Pos < issues[j].Pos
+	})
+	return issues
+}
+
+// parseGroups parses src, also returning its parenthesised groups and call
+// argument lists keyed by the offset of their opening paren.
+func parseGroups(src string) (Expression, map[int]*parsedGroup, error) {
+	l := lexerPool.Get().(*Lexer)
+	defer releaseLexer(l)
+	groups := make(map[int]*parsedGroup)
+	l.groups = groups
+	expr, err := parseSource(l, src)
+	return expr, groups, err
+}
+
+// LintExpr reports constant subexpressions, comparisons of an expression
+// with itself, division by zero and block bindings shadowing an enclosing
+// one in e. Constant subexpressions are reported at their outermost
+// operator.
+func LintExpr(e Expression) []LintIssue {
+	var issues []LintIssue
+	lintShadowing(e, nil, &issues)
+	walk(e, 1, func(e Expression, depth int) bool {
+		if infix, ok := e.(*InfixExpression); ok && !isConstant(e) && isPure(infix.lhs) && equalExpr(infix.lhs, infix.rhs) {
+			if always, ok := selfComparisons[infix.op]; ok && operatorFunction(infix.op, true) == "" {
+				issues = append(issues, LintIssue{
+					Pos:      infix.pos,
+					End:      infix.pos + len(infix.op.String()),
+					Severity: SeverityWarning,
+					Rule:     "self-comparison",
+					Msg:      fmt.Sprintf("%s compares %s with itself, so it is always %t unless it is NaN", Format(e), Format(infix.lhs), always),
+				})
+			}
+		}
+		if infix, ok := e.(*InfixExpression); ok && infix.op == DivOp && isConstant(infix.rhs) {
+			if divisor, err := evalExpression(infix.rhs, nil); err == nil && divisor.IsNumeric() {
+				if f, _ := divisor.AsFloat(); f == 0 {
+					issues = append(issues, LintIssue{
+						Pos:      infix.pos,
+						End:      infix.pos + 1,
+						Severity: SeverityError,
+						Rule:     "division-by-zero",
+						Msg:      "division by zero",
+					})
+				}
+			}
+		}
+		if !isConstant(e) || isLiteral(e) {
+			return true
+		}
+		value, err := evalExpression(e, nil)
+		if err != nil {
+			return true
+		}
+		issues = append(issues, LintIssue{
+			Pos:      e.getPosition(),
+			End:      e.getPosition(),
+			Severity: SeverityWarning,
+			Rule:     "constant",
+			Msg:      fmt.Sprintf("constant subexpression %s is always %s", Format(e), value),
+		})
+		return false
+	})
+	return issues
+}
+
+// selfComparisons are the results of comparing a number or string with
+// itself.
+var selfComparisons = map[OpKind]bool{
+	EqOp: true,
+	NeOp: false,
+	LtOp: false,
+	LeOp: true,
+	GtOp: false,
+	GeOp: true,
+}
+
+// lintShadowing reports the lets in e binding a name that an enclosing let
+// in bound already binds, which makes the outer binding unreachable in the
+// body.
+func lintShadowing(e Expression, bound []string, issues *[]LintIssue) {
+	switch v := e.(type) {
+	case *PrefixExpression:
+		lintShadowing(v.rhs, bound, issues)
+	case *InfixExpression:
+		lintShadowing(v.lhs, bound, issues)
+		lintShadowing(v.rhs, bound, issues)
+	case *CallExpression:
+		if !isLet(v) {
+			for _, arg := range v.args {
+				lintShadowing(arg, bound, issues)
+			}
+			return
+		}
+		name := v.args[0].(IdentifierToken)
+		for _, outer := range bound {
+			if outer == name.name {
+				*issues = append(*issues, LintIssue{
+					Pos:      name.pos,
+					End:      name.pos + len(name.name),
+					Severity: SeverityWarning,
+					Rule:     "shadowed-let",
+					Msg:      fmt.Sprintf("'%s' shadows an enclosing binding of the same name", name.name),
+				})
+				break
+			}
+		}
+		lintShadowing(v.args[1], bound, issues)
+		lintShadowing(v.args[2], append(bound[:len(bound):len(bound)], name.name), issues)
+	}
+}
+
+// isConstant reports whether e depends on nothing but literals and
+// natively evaluated operators.
+func isConstant(e Expression) bool {
+	constant := true
+	walk(e, 1, func(e Expression, depth int) bool {
+		switch v := e.(type) {
+		case IdentifierToken, Hole, *CallExpression:
+			constant = false
+		case *PrefixExpression:
+			constant = operatorFunction(v.op, false) == ""
+		case *InfixExpression:
+			constant = operatorFunction(v.op, true) == ""
+		}
+		return constant
+	})
+	return constant
+}
+
+// isLiteral reports whether e is written as a single value, counting a
+// negated number as one.
+func isLiteral(e Expression) bool {
+	switch v := e.(type) {
+	case IntegerToken, FloatToken, StringToken:
+		return true
+	case *PrefixExpression:
+		switch v.rhs.(type) {
+		case IntegerToken, FloatToken:
+			return v.op == SubOp || v.op == AddOp
+		}
+	}
+	return false
+}
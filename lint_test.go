@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		src   string
+		rules []string
+	}{
+		{"a + b", nil},
+		{"(a * b) + c", []string{"redundant-parens"}},
+		{"(a + b) * c", nil},
+		{"f((a))", []string{"redundant-parens"}},
+		{"{a}", nil},
+		{"{ t = a; t * 2 }", nil},
+		{"a + 2 * 3", []string{"constant"}},
+		{"a / 0", []string{"division-by-zero"}},
+		{"a / (1 - 1)", []string{"division-by-zero", "constant"}},
+		{"{ x = 1; { x = 2; x } }", []string{"shadowed-let"}},
+		{"{ x = { x = 2; x }; x + 1 }", nil},
+		{"{ x = 1; x = x + 1; x }", []string{"shadowed-let"}},
+		{"a + 1 == a + 1", []string{"self-comparison"}},
+		{"a < a", []string{"self-comparison"}},
+		{"f(a) == f(a)", nil},
+		{"a == b", nil},
+		{"1 +", []string{"syntax"}},
+	}
+	for _, tt := range tests {
+		issues := Lint(tt.src)
+		var rules []string
+		for _, issue := range issues {
+			rules = append(rules, issue.Rule)
+		}
+		if !equalStrings(rules, tt.rules) {
+			t.Errorf("Lint(%q) = %v, want rules %v", tt.src, issues, tt.rules)
+		}
+	}
+}
+
+func TestLintShadowedLetPosition(t *testing.T) {
+	src := "{ x = 1; { x = 2; x } }"
+	issues := Lint(src)
+	if len(issues) != 1 {
+		t.Fatalf("Lint(%q) = %v, want one issue", src, issues)
+	}
+	if got := src[issues[0].Pos:issues[0].End]; got != "x" || issues[0].Pos != 11 {
+		t.Errorf("issue spans %q at %d, want the inner x at 11", got, issues[0].Pos)
+	}
+	if issues[0].Severity != SeverityWarning {
+		t.Errorf("severity %s, want warning", issues[0].Severity)
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
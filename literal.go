@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// literalRecognizer is a kind of literal registered with RegisterLiteral or
+// RegisterLiteralScanner.
+type literalRecognizer struct {
+	name  string
+	scan  func(s string) int
+	parse func(text string) (Value, error)
+}
+
+// literalRecognizers are tried in registration order at the start of every
+// token. Their constructors are added to builtinFunctions.
+var literalRecognizers []literalRecognizer
+
+// RegisterLiteral adds a kind of literal, written as text matching pattern,
+// such as #FF00AA for colors or 2024-01-01 for dates. A literal is parsed as
+// a call of name with its text as a string, as in name("#FF00AA"), which
+// parse evaluates, typically to a CustomValue for the host's functions to
+// operate on; name can also be called so in source. Literals are checked
+// with parse while parsing, and its errors are syntax errors. An
+// environment defining name takes precedence over parse when evaluating.
+// Like the Register functions, RegisterLiteral must not be called while
+// parsing.
+func RegisterLiteral(name string, pattern string, parse func(text string) (Value, error)) error {
+	re, err := regexp.Compile(`^(?:` + pattern + `)`)
+	if err != nil {
+		return err
+	}
+	RegisterLiteralScanner(name, func(s string) int {
+		loc := re.FindStringIndex(s)
+		if loc == nil {
+			return 0
+		}
+		return loc[1]
+	}, parse)
+	return nil
+}
+
+// RegisterLiteralScanner adds a kind of literal as RegisterLiteral does,
+// recognized by scan, which returns the length of the literal at the start
+// of s or 0 if there is none.
+func RegisterLiteralScanner(name string, scan func(s string) int, parse func(text string) (Value, error)) {
+	if identifierLength(name) != len(name) || name == "" {
+		panic(fmt.Sprintf("literal function name %q is not an identifier", name))
+	}
+	literalRecognizers = append(literalRecognizers, literalRecognizer{name: name, scan: scan, parse: parse})
+	builtinFunctions[name] = func(args []Value) (Value, error) {
+		if len(args) != 1 || args[0].Kind() != StringKind {
+			return Value{}, fmt.Errorf("%s takes a string", name)
+		}
+		return parse(args[0].Str())
+	}
+}
+
+// scanLiteral appends the tokens of a registered literal at input[i:to] to
+// tokenArray and returns its length, or 0 if none is there.
+func scanLiteral(input string, i int, to int, tokenArray TokenArray) (TokenArray, int) {
+	for _, r := range literalRecognizers {
+		n := r.scan(input[i:to])
+		if n <= 0 {
+			continue
+		}
+		text := input[i : i+n]
+		if _, err := r.parse(text); err != nil {
+			panic(SyntaxError{Pos: i, End: i + n, Msg: fmt.Sprintf("invalid %s literal: %v", r.name, err)})
+		}
+		arg := Token{Kind: StringLiteral, Lit: text, Pos: i, End: i + n}
+		return appendCallTokens(tokenArray, r.name, arg), n
+	}
+	return tokenArray, 0
+}
+
+// appendCallTokens appends the tokens of a call of name with one argument,
+// for syntax that is parsed as a call. The tokens span the argument's
+// source.
+func appendCallTokens(tokenArray TokenArray, name string, arg Token) TokenArray {
+	return append(tokenArray,
+		Token{Kind: Identifier, Lit: name, Pos: arg.Pos, End: arg.End},
+		Token{Kind: Operand, Lit: "(", Op: LParenOp, Pos: arg.Pos, End: arg.End},
+		arg,
+		Token{Kind: Operand, Lit: ")", Op: RParenOp, Pos: arg.Pos, End: arg.End})
+}
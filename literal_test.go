@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type rgb struct{ R, G, B int64 }
+
+func TestRegisterLiteral(t *testing.T) {
+	n := len(literalRecognizers)
+	defer func() {
+		literalRecognizers = literalRecognizers[:n]
+		delete(builtinFunctions, "color")
+		delete(builtinFunctions, "date")
+	}()
+	err := RegisterLiteral("color", `#[0-9A-Fa-f]{6}\b`, func(text string) (Value, error) {
+		v, err := strconv.ParseInt(text[1:], 16, 64)
+		if err != nil {
+			return Value{}, err
+		}
+		return CustomValue(rgb{v >> 16, v >> 8 & 255, v & 255}), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterLiteralScanner("date", func(s string) int {
+		if len(s) >= 10 && s[4] == '-' && s[7] == '-' {
+			return 10
+		}
+		return 0
+	}, func(text string) (Value, error) {
+		d, err := time.Parse("2006-01-02", text)
+		if err != nil {
+			return Value{}, err
+		}
+		return CustomValue(d), nil
+	})
+	env := Env{
+		"red": FuncValue(func(args []Value) (Value, error) {
+			return IntValue(args[0].Custom().(rgb).R), nil
+		}),
+		"year": FuncValue(func(args []Value) (Value, error) {
+			return IntValue(int64(args[0].Custom().(time.Time).Year())), nil
+		}),
+	}
+	tests := []struct {
+		src, formatted string
+		want           Value
+		err            string
+	}{
+		{"red(#FF00AA)", `red(color("#FF00AA"))`, IntValue(255), ""},
+		{`red(color("#010203"))`, `red(color("#010203"))`, IntValue(1), ""},
+		{"year(2024-01-31) + 1", `year(date("2024-01-31")) + 1`, IntValue(2025), ""},
+		{"2024 - 1", "2024 - 1", IntValue(2023), ""},
+		{"#FF00AA + 1", `color("#FF00AA") + 1`, Value{}, "operator '+' not defined for custom and int at column 9"},
+		{"color(1)", "color(1)", Value{}, "color takes a string"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%s) = %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.formatted {
+			t.Errorf("Parse(%s) = %s, want %s", tt.src, got, tt.formatted)
+		}
+		got, err := NewEvaluator(env).Eval(e)
+		if errorString(err) != tt.err || err == nil && !got.Equal(tt.want) {
+			t.Errorf("%s = %s, %v, want %s, %q", tt.src, got, err, tt.want, tt.err)
+		}
+	}
+
+	want := `invalid date literal: parsing time "2024-13-01": month out of range at column 1`
+	if _, err := Parse("2024-13-01"); errorString(err) != want {
+		t.Errorf("Parse(2024-13-01) = %v, want %s", err, want)
+	}
+	if _, err := Parse("#FF00AAB"); err == nil {
+		t.Errorf("Parse(#FF00AAB) did not fail")
+	}
+
+	// An environment's function of the same name evaluates the literal.
+	env["color"] = FuncValue(func(args []Value) (Value, error) {
+		return CustomValue(rgb{1, 1, 1}), nil
+	})
+	e := mustParse(t, "red(#FF00AA)")
+	if got, err := NewEvaluator(env).Eval(e); err != nil || !got.Equal(IntValue(1)) {
+		t.Errorf("red(#FF00AA) with color in the environment = %s, %v, want 1", got, err)
+	}
+}
+
+func TestRegisterLiteralErrors(t *testing.T) {
+	if err := RegisterLiteral("bad", "(", nil); err == nil {
+		t.Errorf("RegisterLiteral with a bad pattern did not fail")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterLiteralScanner with a bad name did not panic")
+		}
+	}()
+	RegisterLiteralScanner("1x", nil, nil)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	}
+	return "unknown"
+}
+
+// Logger receives diagnostics from the parser, each about the source byte
+// at pos: debug messages for the tokens lexed and each binding power
+// decision, and warnings for input skipped in permissive mode.
+type Logger interface {
+	Log(level LogLevel, pos int, msg string)
+}
+
+// WithLogger sends diagnostics at level and above to logger. Below that
+// level they cost nothing, so debug logging can stay wired in.
+func WithLogger(logger Logger, level LogLevel) ParseOption {
+	return func(l *Lexer) {
+		l.logger = logger
+		l.logLevel = level
+	}
+}
+
+// logging reports whether messages at level are wanted. Callers check it
+// before logf so the parser does not box arguments it will not log.
+func (l *Lexer) logging(level LogLevel) bool {
+	return l.logger != nil && level >= l.logLevel
+}
+
+func (l *Lexer) logf(level LogLevel, pos int, format string, args ...interface{}) {
+	l.logger.Log(level, pos, fmt.Sprintf(format, args...))
+}
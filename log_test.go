@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingLogger records what it is told as "level pos: msg".
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Log(level LogLevel, pos int, msg string) {
+	l.lines = append(l.lines, fmt.Sprintf("%s %d: %s", level, pos, msg))
+}
+
+func TestLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	if _, err := Parse("1 + 2 * 3", WithLogger(logger, LogDebug)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"debug 0: lexed integer '1'",
+		"debug 2: lexed operator '+'",
+		"debug 4: lexed integer '2'",
+		"debug 6: lexed operator '*'",
+		"debug 8: lexed integer '3'",
+		fmt.Sprintf("debug 2: '+' takes the operand: left binding power 1 >= %d", minBindingPower),
+		"debug 6: '*' takes the operand: left binding power 3 >= 2",
+	}
+	if !equalStrings(logger.lines, want) {
+		t.Errorf("debug log of 1 + 2 * 3 is\n%q\nwant\n%q", logger.lines, want)
+	}
+	logger.lines = nil
+	if _, err := Parse("1 $ + 2", Permissive(), WithLogger(logger, LogWarn)); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"warn 2: skipped unrecognised character '$'"}
+	if !equalStrings(logger.lines, want) {
+		t.Errorf("warnings for 1 $ + 2 are %q, want %q", logger.lines, want)
+	}
+}
@@ -1,14 +1,22 @@
 package main
 
 /*
-  SPECS: Very minimal pratt parser: only parses single digit addition subtraction multiplication and division
+  SPECS: Very minimal pratt parser: parses integer addition subtraction multiplication and division
 */
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -31,222 +39,1167 @@ const (
 	Integer TokenType = iota
 	Operand
 	Prefix
+	Identifier
+	StringLiteral
+	Illegal
+	Placeholder
+	Float
 )
 
-type Token interface {
-	getTokenType() TokenType
-	getExpressionValue() string
+func (t TokenType) String() string {
+	switch t {
+	case Integer:
+		return "integer"
+	case Operand:
+		return "operator"
+	case Prefix:
+		return "prefix"
+	case Identifier:
+		return "identifier"
+	case StringLiteral:
+		return "string"
+	case Illegal:
+		return "illegal character"
+	case Placeholder:
+		return "placeholder"
+	case Float:
+		return "float"
+	}
+	return "unknown"
+}
+
+type OpKind int
+
+const (
+	NoOp OpKind = iota
+	AddOp
+	SubOp
+	MulOp
+	DivOp
+	LParenOp
+	RParenOp
+	CommaOp
+	PowOp
+	MatchOp
+	NotMatchOp
+	EqOp
+	NeOp
+	LtOp
+	LeOp
+	GtOp
+	GeOp
+	AndOp
+	OrOp
+	NotOp
+	ShlOp
+	ShrOp
+	PipeOp
+	ConcatOp
+	ArrowOp
+)
+
+var opKindSymbols = []string{
+	NoOp:       "",
+	AddOp:      "+",
+	SubOp:      "-",
+	MulOp:      "*",
+	DivOp:      "/",
+	LParenOp:   "(",
+	RParenOp:   ")",
+	CommaOp:    ",",
+	PowOp:      "^",
+	MatchOp:    "=~",
+	NotMatchOp: "!~",
+	EqOp:       "==",
+	NeOp:       "!=",
+	LtOp:       "<",
+	LeOp:       "<=",
+	GtOp:       ">",
+	GeOp:       ">=",
+	AndOp:      "&&",
+	OrOp:       "||",
+	NotOp:      "!",
+	ShlOp:      "<<",
+	ShrOp:      ">>",
+	PipeOp:     "|>",
+	ConcatOp:   "&",
+	ArrowOp:    "=>",
+}
+
+func (o OpKind) String() string {
+	if o < 0 || int(o) >= len(opKindSymbols) {
+		return fmt.Sprintf("op(%d)", int(o))
+	}
+	return opKindSymbols[o]
+}
+
+// Token is a lexed token, stored by value so lexing does not allocate per
+// token. Int holds the value of Integer tokens, Float that of Float tokens
+// and Op the kind of Operand tokens; Lit holds the source text, unquoted for
+// string literals. Pos and End are the byte offsets of the token's first
+// byte and one past its last.
+type Token struct {
+	Kind  TokenType
+	Lit   string
+	Int   int64
+	Float float64
+	Op    OpKind
+	Pos   int
+	End   int
+}
+
+func (t *Token) errorf(format string, args ...interface{}) SyntaxError {
+	return SyntaxError{Pos: t.Pos, End: t.End, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (t *Token) isOp(op OpKind) bool {
+	return t != nil && t.Kind == Operand && t.Op == op
 }
 
 type Expression interface {
 	getExpressionValue() string
+	getPosition() int
 }
 
+// The literal node types below predate Token and keep their token accessors
+// so code written against the old token interfaces keeps working.
+
 type IntegerToken struct {
-	value int32
+	value int64
+	pos   int
 }
 
-type OperatorToken struct {
-	literal string
+// FloatToken is a literal with a fraction or an exponent, as 0.05 or 1e6.
+type FloatToken struct {
+	value float64
+	pos   int
+}
+
+type IdentifierToken struct {
+	name string
+	pos  int
+}
+
+type StringToken struct {
+	value string
+	pos   int
+}
+
+// Hole is a `_` placeholder in an incomplete expression. Holes are numbered
+// from zero in source order.
+type Hole struct {
+	index int
+	pos   int
+}
+
+func (h Hole) getExpressionValue() string {
+	return "_"
+}
+
+func (h Hole) getPosition() int {
+	return h.pos
+}
+
+func (t Token) getTokenType() TokenType {
+	return t.Kind
+}
+
+func (t Token) getExpressionValue() string {
+	switch t.Kind {
+	case Integer:
+		return strconv.FormatInt(t.Int, 10)
+	case StringLiteral:
+		return strconv.Quote(t.Lit)
+	}
+	return t.Lit
 }
 
-type PrefixToken struct {
-	literal string
+func (t Token) getPosition() int {
+	return t.Pos
 }
 
-func (i PrefixToken) getTokenType() TokenType {
-	return Prefix
+// node converts a literal or identifier token into its AST leaf.
+func (t Token) node() Expression {
+	switch t.Kind {
+	case Integer:
+		return IntegerToken{value: t.Int, pos: t.Pos}
+	case Float:
+		return FloatToken{value: t.Float, pos: t.Pos}
+	case Identifier:
+		return IdentifierToken{name: t.Lit, pos: t.Pos}
+	case StringLiteral:
+		return StringToken{value: t.Lit, pos: t.Pos}
+	}
+	return nil
 }
 
 func (i IntegerToken) getTokenType() TokenType {
 	return Integer
 }
 
-func (i OperatorToken) getTokenType() TokenType {
-	return Operand
+func (i FloatToken) getTokenType() TokenType {
+	return Float
+}
+
+func (i IdentifierToken) getTokenType() TokenType {
+	return Identifier
+}
+
+func (i StringToken) getTokenType() TokenType {
+	return StringLiteral
 }
 
 func (i IntegerToken) getExpressionValue() string {
-	return strconv.Itoa(int(i.value))
+	return strconv.FormatInt(i.value, 10)
+}
+
+func (i FloatToken) getExpressionValue() string {
+	return formatFloatLiteral(i.value)
 }
 
-func (i OperatorToken) getExpressionValue() string {
-	return i.literal
+func (i IdentifierToken) getExpressionValue() string {
+	return i.name
 }
 
-func (i PrefixToken) getExpressionValue() string {
-	return i.literal
+func (i StringToken) getExpressionValue() string {
+	return strconv.Quote(i.value)
+}
+
+func (i IntegerToken) getPosition() int {
+	return i.pos
+}
+
+func (i FloatToken) getPosition() int {
+	return i.pos
+}
+
+func (i IdentifierToken) getPosition() int {
+	return i.pos
+}
+
+func (i StringToken) getPosition() int {
+	return i.pos
 }
 
 type Lexer struct {
-	tokens TokenArray
+	tokens     TokenArray
+	prev       *Token
+	end        int
+	arena      *Arena
+	depth      int
+	holes      int
+	permissive bool
+	partial    bool
+	// envReferences enables env.NAME and ${NAME}; see WithEnvReferences.
+	envReferences bool
+	// excel enables spreadsheet formula syntax; see ExcelSyntax.
+	excel bool
+	// interpolation enables ${expr} in strings; see WithInterpolation.
+	interpolation bool
+	expected      Expected
+	closers       []Expected
+	groups        map[int]*parsedGroup
+	reuse         *reuseState
+	metrics       Metrics
+	logger        Logger
+	logLevel      LogLevel
+	// signatures are the functions declared by WithSignatures.
+	signatures map[string]Signature
+	// maxBytes and maxTokens are the limits set by WithInputLimits.
+	maxBytes  int
+	maxTokens int
+}
+
+// maxNestingDepth bounds parser recursion so pathological input such as
+// thousands of nested parentheses fails cleanly instead of exhausting the
+// goroutine stack.
+const maxNestingDepth = 1000
+
+type ParseOption func(*Lexer)
+
+// Permissive makes the lexer silently skip characters it does not
+// recognise instead of reporting them.
+func Permissive() ParseOption {
+	return func(l *Lexer) {
+		l.permissive = true
+	}
+}
+
+// SyntaxError spans the source bytes [Pos, End) it refers to.
+type SyntaxError struct {
+	Pos int
+	End int
+	Msg string
+	// cause is the error wrapped, if any.
+	cause error
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
 }
 
 type InfixExpression struct {
 	lhs Expression
 	rhs Expression
-	op  string
+	op  OpKind
+	pos int
 }
 
 type PrefixExpression struct {
-	op  string
+	op  OpKind
 	rhs Expression
+	pos int
+}
+
+type CallExpression struct {
+	name string
+	args []Expression
+	pos  int
+}
+
+func (i CallExpression) getExpressionValue() string {
+	return i.name
+}
+
+func (i CallExpression) getPosition() int {
+	return i.pos
+}
+
+func (i InfixExpression) getPosition() int {
+	return i.pos
+}
+
+func (i PrefixExpression) getPosition() int {
+	return i.pos
 }
 
 func (i PrefixExpression) getExpressionValue() string {
-	return i.op + strconv.Itoa(evalExpression(i.rhs))
+	return i.op.String() + i.rhs.getExpressionValue()
 }
 
 func (i InfixExpression) getExpressionValue() string {
 	return ""
 }
 
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
+
 func New(input string) *Lexer {
-	var buffer bytes.Buffer
-	for _, char := range input {
-		buffer.WriteByte(byte(char))
+	l := &Lexer{}
+	l.lex(input)
+	return l
+}
+
+var lexerPool = sync.Pool{
+	New: func() interface{} {
+		return &Lexer{}
+	},
+}
+
+func releaseLexer(l *Lexer) {
+	tokens := l.tokens[:cap(l.tokens)]
+	for i := range tokens {
+		tokens[i] = Token{}
+	}
+	l.tokens = tokens[:0]
+	l.prev = nil
+	l.arena = nil
+	l.permissive = false
+	l.partial = false
+	l.envReferences = false
+	l.excel = false
+	l.interpolation = false
+	l.closers = l.closers[:0]
+	l.groups = nil
+	l.reuse = nil
+	l.metrics = nil
+	l.logger = nil
+	l.signatures = nil
+	l.maxBytes = 0
+	l.maxTokens = 0
+	lexerPool.Put(l)
+}
+
+// lex tokenizes input into l, reusing the storage of any previous tokens.
+func (l *Lexer) lex(input string) {
+	l.checkBytes(len(input))
+	l.tokens = l.scan(input, 0, len(input), l.tokens[:0])
+	l.checkTokens(l.tokens)
+	if l.logging(LogDebug) {
+		for _, tok := range l.tokens {
+			l.logf(LogDebug, tok.Pos, "lexed %s '%s'", tok.Kind, tok.Lit)
+		}
 	}
-	charArray := buffer.Bytes()
-	tokenArray := make(TokenArray, 0)
-	for _, c := range charArray {
+	l.tokens.Reverse()
+	l.reset(len(input))
+}
+
+// scan appends the tokens of input[from:to] to tokenArray in source order.
+// Positions are offsets into the whole of input.
+func (l *Lexer) scan(input string, from int, to int, tokenArray TokenArray) TokenArray {
+	for i := from; i < to; i++ {
+		l.checkTokens(tokenArray)
+		c := input[i]
 		if c == ' ' || c == '\r' || c == '\t' || c == '\n' {
 			continue
+		} else if literal, size := scanLiteral(input, i, to, tokenArray); size > 0 {
+			tokenArray = literal
+			i += size - 1
+		} else if excel, size := l.scanExcel(input, i, to, tokenArray); size > 0 {
+			tokenArray = excel
+			i += size - 1
 		} else if c >= '0' && c <= '9' {
-			intValue, err := strconv.Atoi(string(c))
+			start := i
+			for i+1 < to && input[i+1] >= '0' && input[i+1] <= '9' {
+				i++
+			}
+			if size := fractionLength(input[i+1 : to]); size > 0 {
+				i += size
+				floatValue, err := strconv.ParseFloat(input[start:i+1], 64)
+				if err != nil {
+					panic(SyntaxError{Pos: start, End: i + 1, Msg: "float literal out of range"})
+				}
+				tokenArray = append(tokenArray, Token{Kind: Float, Lit: input[start : i+1], Float: floatValue, Pos: start, End: i + 1})
+				continue
+			}
+			intValue, err := strconv.ParseInt(input[start:i+1], 10, 64)
 			if err != nil {
-				panic("This should not have happened")
+				panic(SyntaxError{Pos: start, End: i + 1, Msg: "integer literal out of range"})
 			}
-			tokenArray = append(tokenArray, IntegerToken{
-				value: int32(intValue),
-			})
-		} else if c == '+' || c == '-' || c == '*' || c == '/' {
-			tokenArray = append(tokenArray, OperatorToken{
-				literal: string(c),
-			})
+			tokenArray = append(tokenArray, Token{Kind: Integer, Lit: input[start : i+1], Int: intValue, Pos: start, End: i + 1})
+		} else if size := l.envReferenceAt(input[i:to]); size > 0 {
+			tokenArray = append(tokenArray, Token{Kind: Identifier, Lit: input[i : i+size], Pos: i, End: i + size})
+			i += size - 1
+		} else if op, size := operatorAt(input[i:to]); op != NoOp {
+			tokenArray = append(tokenArray, Token{Kind: Operand, Lit: input[i : i+size], Op: op, Pos: i, End: i + size})
+			i += size - 1
+		} else if isIdentifierStart(c) {
+			start := i
+			i += qualifiedLength(input[i:to]) - 1
+			kind := Identifier
+			if input[start:i+1] == "_" {
+				kind = Placeholder
+			}
+			tokenArray = append(tokenArray, Token{Kind: kind, Lit: input[start : i+1], Pos: start, End: i + 1})
+		} else if block, size := l.scanBlock(input, i, to, tokenArray); size > 0 {
+			tokenArray = block
+			i += size - 1
+		} else if interpolated, size := l.scanInterpolated(input, i, to, tokenArray); size > 0 {
+			tokenArray = interpolated
+			i += size - 1
+		} else if c == '"' {
+			start := i
+			for i+1 < to && input[i+1] != '"' {
+				i++
+			}
+			if i+1 >= to {
+				panic(SyntaxError{Pos: start, End: to, Msg: "unterminated string literal"})
+			}
+			i++
+			tokenArray = append(tokenArray, Token{Kind: StringLiteral, Lit: input[start+1 : i], Pos: start, End: i + 1})
+		} else {
+			_, size := utf8.DecodeRuneInString(input[i:to])
+			if !l.permissive {
+				tokenArray = append(tokenArray, Token{Kind: Illegal, Lit: input[i : i+size], Pos: i, End: i + size})
+			} else if l.logging(LogWarn) {
+				l.logf(LogWarn, i, "skipped unrecognised character '%s'", input[i:i+size])
+			}
+			i += size - 1
 		}
 	}
-	tokenArray.Reverse()
-	l := &Lexer{
-		tokens: tokenArray,
+	return tokenArray
+}
+
+// fractionLength returns the length of the fraction and exponent that s,
+// the text after the digits of a number, starts with, as ".05" or "e-3", or
+// 0 if it starts with neither. A dot not followed by a digit is not part of
+// the number.
+func fractionLength(s string) int {
+	digits := func(from int) int {
+		i := from
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - from
 	}
-	return l
+	n := 0
+	if len(s) > 1 && s[0] == '.' {
+		if d := digits(1); d > 0 {
+			n = 1 + d
+		}
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		sign := 0
+		if n+1 < len(s) && (s[n+1] == '+' || s[n+1] == '-') {
+			sign = 1
+		}
+		if d := digits(n + 1 + sign); d > 0 {
+			n += 1 + sign + d
+		}
+	}
+	return n
+}
+
+// formatFloatLiteral writes v as a literal that lexes back to v, keeping a
+// fraction or exponent so that it does not read as an integer.
+func formatFloatLiteral(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// reset prepares l to parse its tokens from the start of an input of the
+// given length.
+func (l *Lexer) reset(end int) {
+	l.end = end
+	l.prev = nil
+	l.depth = 0
+	l.holes = 0
+	l.expected = 0
+	l.closers = l.closers[:0]
 }
 
-func (l *Lexer) next() Token {
+func (l *Lexer) next() *Token {
 	if len(l.tokens) == 0 {
 		return nil
 	}
-	lastToken := l.tokens[len(l.tokens)-1]
+	lastToken := &l.tokens[len(l.tokens)-1]
+	if lastToken.Kind == Illegal {
+		panic(lastToken.errorf("unexpected character '%s'", lastToken.Lit))
+	}
 	l.tokens = l.tokens[:len(l.tokens)-1]
+	l.prev = lastToken
 	return lastToken
 }
 
-func (l *Lexer) peek() Token {
-	if len(l.tokens) < 1 {
-		return nil
+// endError reports running out of tokens, naming the token left dangling.
+func (l *Lexer) endError() SyntaxError {
+	if l.prev == nil {
+		return SyntaxError{Pos: l.end, End: l.end, Msg: "empty expression"}
 	}
-	return l.tokens[len(l.tokens)-1]
+	return l.prev.errorf("unexpected end of expression after '%s'", l.prev.getExpressionValue())
 }
 
-func parse(l *Lexer, min_bp int) Expression {
-	operatorBindingPowerMap := map[string][]int{
-		"+": {1, 2},
-		"-": {1, 2},
-		"*": {3, 4},
-		"/": {3, 4},
+func (l *Lexer) peekPosition() int {
+	if len(l.tokens) < 1 {
+		return l.end
 	}
+	return l.tokens[len(l.tokens)-1].Pos
+}
 
-	prefixBindingPowerMap := map[string][]int{
-		"+": {0, 5},
-		"-": {0, 5},
+func (l *Lexer) peek() *Token {
+	if len(l.tokens) < 1 {
+		return nil
 	}
+	tok := &l.tokens[len(l.tokens)-1]
+	if tok.Kind == Illegal {
+		panic(tok.errorf("unexpected character '%s'", tok.Lit))
+	}
+	return tok
+}
+
+func parse(l *Lexer, min_bp int) Expression {
 	var lhs Expression
+	l.depth += 1
+	if l.depth > maxNestingDepth {
+		panic(SyntaxError{Pos: l.peekPosition(), End: l.peekPosition(), Msg: "expression nested too deeply"})
+	}
 
 	lhsExpr := l.next()
+	if lhsExpr == nil {
+		if !l.partial {
+			panic(l.endError())
+		}
+		l.expect(ExpectOperand)
+		hole := Hole{index: l.holes, pos: l.end}
+		l.holes += 1
+		l.depth -= 1
+		return hole
+	}
 	switch lhsExpr.getTokenType() {
-	case Integer:
-		lhs, _ = lhsExpr.(IntegerToken)
+	case Integer, Float, Identifier, StringLiteral:
+		lhs = lhsExpr.node()
+		break
+	case Placeholder:
+		lhs = Hole{index: l.holes, pos: lhsExpr.Pos}
+		l.holes += 1
 		break
 	case Operand:
-		lhs_prefix_token, _ := lhsExpr.(OperatorToken)
-		if lhs_prefix_token.literal == "(" {
-			l.next()
-
-			expr := parse(l, 0)
-			if !(l.peek().getExpressionValue() == ")") {
-				panic("Expected right paren")
-			}
-			lhs = expr
-
+		rule := prefixRules[lhsExpr.Op]
+		if rule.fn == nil {
+			panic(unexpectedOperator(lhsExpr, "a prefix"))
 		}
-		r_bp := prefixBindingPowerMap[lhs_prefix_token.literal][1]
-		rhs := parse(l, r_bp)
-		lhs = PrefixExpression{
-			op:  lhs_prefix_token.literal,
-			rhs: rhs,
+		if l.logging(LogDebug) {
+			l.logf(LogDebug, lhsExpr.Pos, "'%s' starts a prefix rule", lhsExpr.Lit)
 		}
+		lhs = rule.fn((*Parser)(l))
 		break
 	}
 	for {
 		if l.peek() == nil {
+			if l.partial {
+				l.expect(ExpectOperator | l.closer())
+			}
 			break
 		}
-		op, ok := l.peek().(OperatorToken)
-		if !ok {
-			panic("Integer should be followed by an operand")
+		op := l.peek()
+		if op.Kind != Operand {
+			panic(op.errorf("expected operator, found %s", op.Kind))
 		}
-		l_bp, r_bp := operatorBindingPowerMap[op.literal][0], operatorBindingPowerMap[op.literal][1]
-		if l_bp < min_bp {
+		rule := infixRules[op.Op]
+		if rule.fn == nil {
+			// Symbols with no rule at all, such as ')' and ',', close
+			// whatever construct the caller is parsing.
+			if prefixRules[op.Op].fn != nil {
+				panic(unexpectedOperator(op, "an infix"))
+			}
+			if l.logging(LogDebug) {
+				l.logf(LogDebug, op.Pos, "'%s' closes the operand", op.Lit)
+			}
 			break
 		}
+		if rule.lbp < min_bp {
+			if l.logging(LogDebug) {
+				l.logf(LogDebug, op.Pos, "'%s' ends the operand: left binding power %d < %d", op.Lit, rule.lbp, min_bp)
+			}
+			break
+		}
+		if l.logging(LogDebug) {
+			l.logf(LogDebug, op.Pos, "'%s' takes the operand: left binding power %d >= %d", op.Lit, rule.lbp, min_bp)
+		}
 		l.next()
+		lhs = rule.fn((*Parser)(l), lhs)
+	}
+	l.depth -= 1
+	return lhs
+}
 
-		rhs := parse(l, r_bp)
-		lhs = InfixExpression{
-			lhs: lhs,
-			rhs: rhs,
-			op:  op.literal,
+// parseGroup is the prefix rule for '('.
+func parseGroup(p *Parser) Expression {
+	l := (*Lexer)(p)
+	open := l.prev
+	if group, ok := l.reusedGroup(open, false); ok {
+		return group.expr
+	}
+	holes := l.holes
+	l.closers = append(l.closers, ExpectCloseParen)
+	inner := parse(l, minBindingPower)
+	l.closers = l.closers[:len(l.closers)-1]
+	if l.partial && l.peek() == nil {
+		return inner
+	}
+	if !l.peek().isOp(RParenOp) {
+		panic(open.errorf("expected right paren"))
+	}
+	l.next()
+	l.recordGroup(open, holes, parsedGroup{expr: inner})
+	return inner
+}
+
+// unexpectedOperator describes an operator token found where it cannot be
+// used, role being "a prefix" or "an infix".
+func unexpectedOperator(tok *Token, role string) SyntaxError {
+	switch tok.Op {
+	case RParenOp:
+		return tok.errorf("unmatched ')'")
+	case LParenOp, CommaOp:
+		return tok.errorf("unexpected '%s'", tok.Op)
+	}
+	return tok.errorf("'%s' cannot be used as %s operator", tok.Op, role)
+}
+
+// parseCallRule is the infix rule for '(', calling the identifier before it.
+func parseCallRule(p *Parser, lhs Expression) Expression {
+	l := (*Lexer)(p)
+	callee, ok := lhs.(IdentifierToken)
+	if !ok {
+		panic(l.prev.errorf("only identifiers can be called"))
+	}
+	return parseCall(l, callee)
+}
+
+// parseCall parses the arguments of a call after its '(', passing piped
+// before them as the operand of |> is.
+func parseCall(l *Lexer, callee IdentifierToken, piped ...Expression) Expression {
+	open := l.prev
+	var buffer [8]Expression
+	args := append(buffer[:0], piped...)
+	if group, ok := l.reusedGroup(open, true); ok {
+		return newCall(l, callee, append(args, group.args...))
+	}
+	holes := l.holes
+	if l.peek().isOp(RParenOp) {
+		l.next()
+		return newCall(l, callee, args)
+	}
+	if l.partial && l.peek() == nil {
+		l.expect(ExpectOperand | ExpectCloseParen)
+		return newCall(l, callee, args)
+	}
+	l.closers = append(l.closers, ExpectComma|ExpectCloseParen)
+	defer func() {
+		l.closers = l.closers[:len(l.closers)-1]
+	}()
+	for {
+		args = append(args, parse(l, minBindingPower))
+		tok := l.next()
+		if tok == nil {
+			if l.partial {
+				return newCall(l, callee, args)
+			}
+			panic(l.endError())
+		}
+		if tok.isOp(RParenOp) {
+			call := newCall(l, callee, args)
+			l.recordGroup(open, holes, parsedGroup{call: true, args: call.args[len(piped):]})
+			return call
+		}
+		if !tok.isOp(CommaOp) {
+			panic(tok.errorf("expected ',' or ')'"))
 		}
 	}
-	return lhs
 }
 
-func evalPrefix(e PrefixExpression) int {
-	switch e.op {
-	case "+":
-		return (1) * evalExpression(e.rhs)
-	case "-":
-		return (-1) * evalExpression(e.rhs)
+func newCall(l *Lexer, callee IdentifierToken, args []Expression) *CallExpression {
+	call := l.arena.newCall()
+	*call = CallExpression{
+		name: callee.name,
+		args: l.arena.newArgs(len(args)),
+		pos:  callee.pos,
+	}
+	copy(call.args, args)
+	if signature, ok := l.signatures[callee.name]; ok && !l.partial {
+		if msg := signature.arityError(callee.name, len(args)); msg != "" {
+			panic(SyntaxError{Pos: callee.pos, End: l.prev.End, Msg: msg})
+		}
+	}
+	return call
+}
+
+// Parse lexes and parses src, returning syntax errors instead of panicking.
+// No input makes Parse panic. The returned Expression is immutable and may be
+// evaluated concurrently.
+func Parse(src string, opts ...ParseOption) (Expression, error) {
+	l := lexerPool.Get().(*Lexer)
+	defer releaseLexer(l)
+	for _, opt := range opts {
+		opt(l)
+	}
+	return parseSource(l, src)
+}
+
+// parseSource parses all of src with l, recovering the parser's panics.
+func parseSource(l *Lexer, src string) (expr Expression, err error) {
+	if l.metrics != nil {
+		defer l.reportMetrics(time.Now(), &expr, &err)
+	}
+	defer catchSyntaxError(&expr, &err)
+	l.lex(src)
+	if l.metrics != nil {
+		l.metrics.Count(MetricTokensLexed, int64(len(l.tokens)))
+	}
+	return parseAll(l), nil
+}
+
+func (l *Lexer) reportMetrics(start time.Time, expr *Expression, err *error) {
+	l.metrics.Observe(MetricParseDuration, time.Since(start))
+	if *err != nil {
+		l.metrics.Count(MetricParseErrors, 1)
+		return
+	}
+	l.metrics.Count(MetricNodesParsed, int64(countNodes(*expr)))
+}
+
+// catchSyntaxError is deferred to turn the parser's SyntaxError panics into
+// a returned error. Any other panic is a bug and is not recovered.
+func catchSyntaxError(expr *Expression, err *error) {
+	if r := recover(); r != nil {
+		syntaxErr, ok := r.(SyntaxError)
+		if !ok {
+			panic(r)
+		}
+		*expr = nil
+		*err = syntaxErr
+	}
+}
+
+// parseAll parses the lexed tokens, requiring all of them to be consumed.
+func parseAll(l *Lexer) Expression {
+	expr := parse(l, minBindingPower)
+	if l.peek() != nil {
+		if l.peek().isOp(RParenOp) {
+			panic(l.peek().errorf("unmatched ')'"))
+		}
+		panic(l.peek().errorf("unexpected %s '%s'", l.peek().Kind, l.peek().getExpressionValue()))
+	}
+	return expr
+}
+
+// parseLexed parses tokens already loaded into l, recovering the parser's
+// panics.
+func parseLexed(l *Lexer) (expr Expression, err error) {
+	defer catchSyntaxError(&expr, &err)
+	return parseAll(l), nil
+}
+
+// ParseTokens parses tokens produced by another tokenizer, given in source
+// order, without lexing. Tokens should be filled in as the lexer fills them,
+// except that an Operand with no Op is resolved from its Lit. Permissive
+// drops Illegal tokens. The tokens slice is not modified.
+func ParseTokens(tokens []Token, opts ...ParseOption) (Expression, error) {
+	l := lexerPool.Get().(*Lexer)
+	defer releaseLexer(l)
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.maxTokens > 0 && len(tokens) > l.maxTokens {
+		return nil, tooManyTokens(l.maxTokens, tokens[l.maxTokens].Pos, tokens[len(tokens)-1].End)
 	}
-	panic("Should not reach here")
+	end := 0
+	for i := len(tokens) - 1; i >= 0; i-- {
+		tok := tokens[i]
+		if tok.Kind == Illegal && l.permissive {
+			if l.logging(LogWarn) {
+				l.logf(LogWarn, tok.Pos, "skipped unrecognised character '%s'", tok.Lit)
+			}
+			continue
+		}
+		if tok.Kind == Operand && tok.Op == NoOp {
+			tok.Op = lookupOperator(tok.Lit)
+			if tok.Op == NoOp {
+				return nil, tok.errorf("unknown operator '%s'", tok.Lit)
+			}
+		}
+		if tok.End > end {
+			end = tok.End
+		}
+		l.tokens = append(l.tokens, tok)
+	}
+	l.reset(end)
+	return parseLexed(l)
 }
 
-func evalExpression(e Expression) int {
-	operationFunctionMap := map[string]func(int, int) int{
-		"+": func(a, b int) int { return a + b },
-		"-": func(a, b int) int { return a - b },
-		"*": func(a, b int) int { return a * b },
-		"/": func(a, b int) int { return a / b },
+// intPow raises a to the power b by squaring, wrapping on overflow like the
+// other integer operators.
+func intPow(a int64, b int64) (Value, error) {
+	if b < 0 {
+		return Value{}, fmt.Errorf("negative integer exponent")
+	}
+	result := int64(1)
+	for b > 0 {
+		if b&1 == 1 {
+			result *= a
+		}
+		a *= a
+		b >>= 1
 	}
+	return IntValue(result), nil
+}
+
+func lookupVariable(name string, pos int, env Env) (Value, error) {
+	return lookupLocal(name, pos, nil, env)
+}
+
+func callFunction(name string, pos int, env Env, args []Value) (Value, error) {
+	fn, ok := env[name]
+	if !ok {
+		if builtin, ok := builtinFunctions[name]; ok {
+			value, err := builtin(args)
+			if assertion, ok := err.(AssertionError); ok {
+				assertion.Pos = pos
+				return Value{}, assertion
+			}
+			return value, err
+		}
+		err := evalError(pos, nil, CodeUndefinedFunction, name)
+		err.Suggestion = suggestFunction(name, env)
+		return Value{}, err
+	}
+	if fn.Kind() != FuncKind {
+		return Value{}, evalError(pos, nil, CodeNotFunction, name)
+	}
+	return fn.Func()(args)
+}
+
+type Env map[string]Value
+
+type memoEntry struct {
+	expr  Expression
+	value Value
+}
+
+// evaluation holds the state of one tree-walking evaluation.
+type evaluation struct {
+	env   Env
+	memo  map[uint64]memoEntry
+	holes func(index int) (Value, bool)
+	// steps collects the reductions made when tracing, and is nil
+	// otherwise.
+	steps    *[]TraceStep
+	observer EvalObserver
+	// output receives what print and debug write, if set.
+	output io.Writer
+	// locals are the variables bound by the lets being evaluated.
+	locals *scope
+	// stepLimit is the number of nodes that may be evaluated if positive,
+	// and recursionLimit how deep calls of functions defined in the
+	// language may nest. budget counts against them.
+	stepLimit      int
+	recursionLimit int
+	budget         *evalBudget
+	// ctx stops the evaluation once done, if set by EvalContext.
+	ctx context.Context
+}
+
+// evalBudget counts the nodes an evaluation has evaluated and how deep its
+// calls of functions defined in the language nest. The calls share their
+// evaluation's budget, and may run concurrently once a function escapes
+// it, so it is counted atomically.
+type evalBudget struct {
+	steps atomic.Int64
+	calls atomic.Int32
+}
+
+func evalExpression(e Expression, env Env) (Value, error) {
+	ev := &evaluation{
+		env: env,
+	}
+	return ev.eval(e)
+}
+
+func (ev *evaluation) eval(e Expression) (Value, error) {
+	if err := ev.step(e); err != nil {
+		return Value{}, err
+	}
+	if ev.observer != nil {
+		return ev.evalObserved(e)
+	}
+	return ev.evalUnobserved(e)
+}
+
+func (ev *evaluation) evalObserved(e Expression) (Value, error) {
+	start := time.Now()
+	ev.observer.OnNodeStart(e)
+	value, err := ev.evalUnobserved(e)
+	ev.observer.OnNodeResult(e, value, err, time.Since(start))
+	return value, err
+}
+
+func (ev *evaluation) evalUnobserved(e Expression) (Value, error) {
+	if ev.memo != nil {
+		switch e.(type) {
+		case *PrefixExpression, *InfixExpression:
+			return ev.evalMemoized(e)
+		}
+	}
+	return ev.evalNode(e)
+}
+
+func (ev *evaluation) evalNode(e Expression) (Value, error) {
 	switch v := e.(type) {
 	case IntegerToken:
-		return int(v.value)
-	case PrefixExpression:
-		return evalPrefix(v)
-	case InfixExpression:
-		return operationFunctionMap[v.op](evalExpression(v.lhs), evalExpression(v.rhs))
+		return IntValue(int64(v.value)), nil
+	case FloatToken:
+		return FloatValue(v.value), nil
+	case StringToken:
+		return StringValue(v.value), nil
+	case Hole:
+		if ev.holes != nil {
+			if value, ok := ev.holes(v.index); ok {
+				return value, nil
+			}
+		}
+		return Value{}, fmt.Errorf("unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case IdentifierToken:
+		value, err := lookupLocal(v.name, v.pos, ev.locals, ev.env)
+		if err != nil {
+			return Value{}, err
+		}
+		ev.record(v, nil, value)
+		return value, nil
+	case *PrefixExpression:
+		return ev.evalPrefix(v)
+	case *InfixExpression:
+		return ev.evalInfix(v)
+	case *CallExpression:
+		return ev.evalCall(v)
 	}
-	return 0
+	return Value{}, fmt.Errorf("cannot evaluate %T", e)
 }
 
-func main() {
-	var a string
-	in := bufio.NewReader(os.Stdin)
-	a, err := in.ReadString('\n')
+// evalMemoized reuses the result of an identical pure subtree evaluated
+// earlier in the same evaluation.
+func (ev *evaluation) evalMemoized(e Expression) (Value, error) {
+	if !isPure(e) {
+		return ev.evalNode(e)
+	}
+	key := hashExpr(e)
+	if entry, ok := ev.memo[key]; ok && equalExpr(entry.expr, e) {
+		return entry.value, nil
+	}
+	value, err := ev.evalNode(e)
+	if err != nil {
+		return Value{}, err
+	}
+	ev.memo[key] = memoEntry{
+		expr:  e,
+		value: value,
+	}
+	return value, nil
+}
+
+func (ev *evaluation) evalPrefix(e *PrefixExpression) (Value, error) {
+	rhs, err := ev.eval(e.rhs)
+	if err != nil {
+		return Value{}, err
+	}
+	var value Value
+	if name := operatorFunction(e.op, false); name != "" {
+		value, err = callOperator(name, e.pos, ev.env, []Value{rhs})
+	} else {
+		value, err = applyPrefixAt(e.op, rhs, e.pos)
+	}
 	if err != nil {
-		panic("Error reading string")
+		return Value{}, err
+	}
+	ev.record(e, []Value{rhs}, value)
+	return value, nil
+}
+
+func (ev *evaluation) evalInfix(e *InfixExpression) (Value, error) {
+	lhs, err := ev.eval(e.lhs)
+	if err != nil {
+		return Value{}, err
+	}
+	if isShortCircuit(e) && shortCircuits(e.op, lhs) {
+		ev.record(e, []Value{lhs}, lhs)
+		return lhs, nil
+	}
+	rhs, err := ev.eval(e.rhs)
+	if err != nil {
+		return Value{}, err
+	}
+	var value Value
+	if name := operatorFunction(e.op, true); name != "" {
+		value, err = callOperator(name, e.pos, ev.env, []Value{lhs, rhs})
+	} else {
+		value, err = applyInfixAt(e.op, lhs, rhs, e.pos)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	ev.record(e, []Value{lhs, rhs}, value)
+	return value, nil
+}
+
+func (ev *evaluation) evalCall(e *CallExpression) (Value, error) {
+	if isTry(e) {
+		return ev.evalTry(e)
+	}
+	if isLet(e) {
+		return ev.evalLet(e)
+	}
+	if isLambda(e) {
+		return ev.evalLambda(e)
+	}
+	if ev.isOutputCall(e) {
+		return ev.evalOutput(e)
+	}
+	args := make([]Value, len(e.args))
+	for i, arg := range e.args {
+		value, err := ev.eval(arg)
+		if err != nil {
+			return Value{}, err
+		}
+		args[i] = value
+	}
+	value, err := callLocal(e.name, e.pos, ev.locals, ev.env, args)
+	if err != nil {
+		return Value{}, err
+	}
+	ev.record(e, args, value)
+	return value, nil
+}
+
+func main() {
+	if len(os.Args) > 2 && os.Args[1] == "-operators" {
+		if err := LoadOperatorsFile(os.Args[2]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Args = append(os.Args[:1], os.Args[3:]...)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBenchCommand(os.Stdout, os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		if err := runWatch(os.Args[2:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+			os.Exit(exitIOError)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "csv" {
+		os.Exit(runCSV(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "jsonl" {
+		os.Exit(runJSONLines(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runScriptCommand(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		os.Exit(runSnapshot(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "repl" {
+		if err := runREPL(os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+			os.Exit(exitIOError)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "minify" {
+		in := bufio.NewReader(os.Stdin)
+		src, err := in.ReadString('\n')
+		if err != nil && src == "" {
+			fmt.Printf("reading input: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		minified, err := Minify(src)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitParseError)
+		}
+		fmt.Println(minified)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		in := bufio.NewReader(os.Stdin)
+		src, err := in.ReadString('\n')
+		if err != nil && src == "" {
+			fmt.Printf("reading input: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		failed := false
+		for _, issue := range Lint(src) {
+			fmt.Println(issue)
+			failed = failed || issue.Severity == SeverityError
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
 	}
-	lexer := New(a)
-	parsed := parse(lexer, 0)
-	fmt.Println(evalExpression(parsed))
+	os.Exit(runEval(os.Args[1:], os.Stdin, os.Stdout))
 }
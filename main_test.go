@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFloatLiterals(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   Value
+		format string
+	}{
+		{"0.05", FloatValue(0.05), "0.05"},
+		{"1.50", FloatValue(1.5), "1.5"},
+		{"1e3", FloatValue(1000), "1000.0"},
+		{"2.5E-1", FloatValue(0.25), "0.25"},
+		{"1e+21", FloatValue(1e21), "1e+21"},
+		{"-0.5 * 4", FloatValue(-2), "-0.5 * 4"},
+		{"1.0 == 1", BoolValue(true), "1.0 == 1"},
+		{"2 ^ 0.5 * 2 ^ 0.5", FloatValue(2.0000000000000004), "2 ^ 0.5 * 2 ^ 0.5"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.format {
+			t.Errorf("Format(%s) = %s, want %s", tt.src, got, tt.format)
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(nil, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestFloatLiteralErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1e400", "float literal out of range"},
+		{"1.", "unexpected character '.'"},
+		{"1.e5", "unexpected character '.'"},
+		{"1e", "expected operator"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err == nil {
+			_, err = NewEvaluator(nil).Eval(e)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s fails with %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestFoldFloats(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"x * (0.5 + 0.25)", "x * 0.75"},
+		{"x + (1 - 1.5)", "x + -0.5"},
+		{"x + 1 / 0.0", "x + 1 / 0.0"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Format(Fold(e)); got != tt.want {
+			t.Errorf("Fold(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+// syntaxErrorTest is a source that fails to parse with a message spanning
+// the source bytes [pos, end).
+type syntaxErrorTest struct {
+	src      string
+	msg      string
+	pos, end int
+}
+
+func checkSyntaxErrors(t *testing.T, tests []syntaxErrorTest, opts ...ParseOption) {
+	t.Helper()
+	for _, tt := range tests {
+		_, err := Parse(tt.src, opts...)
+		syntaxErr, ok := err.(SyntaxError)
+		if !ok {
+			t.Errorf("Parse(%q) = %v, want a SyntaxError", tt.src, err)
+			continue
+		}
+		if syntaxErr.Error() != tt.msg || syntaxErr.Pos != tt.pos || syntaxErr.End != tt.end {
+			t.Errorf("Parse(%q) fails with %q at %d-%d, want %q at %d-%d", tt.src, syntaxErr, syntaxErr.Pos, syntaxErr.End, tt.msg, tt.pos, tt.end)
+		}
+	}
+}
+
+func TestMisplacedOperators(t *testing.T) {
+	checkSyntaxErrors(t, []syntaxErrorTest{
+		{"*5", "'*' cannot be used as a prefix operator at column 1", 0, 1},
+		{"1 + / 2", "'/' cannot be used as a prefix operator at column 5", 4, 5},
+		{")", "unmatched ')' at column 1", 0, 1},
+		{"1 + )", "unmatched ')' at column 5", 4, 5},
+		{"(1 + 2))", "unmatched ')' at column 8", 7, 8},
+		{"f(,)", "unexpected ',' at column 3", 2, 3},
+	})
+}
+
+func TestIncompleteExpressions(t *testing.T) {
+	checkSyntaxErrors(t, []syntaxErrorTest{
+		{"", "empty expression at column 1", 0, 0},
+		{"+", "unexpected end of expression after '+' at column 1", 0, 1},
+		{"3 +", "unexpected end of expression after '+' at column 3", 2, 3},
+		{"(", "unexpected end of expression after '(' at column 1", 0, 1},
+		{"f(1,", "unexpected end of expression after ',' at column 4", 3, 4},
+		{"3 4", "expected operator, found integer at column 3", 2, 3},
+		{"1 2 3", "expected operator, found integer at column 3", 2, 3},
+		{"x y", "expected operator, found identifier at column 3", 2, 3},
+		{`1 "s"`, "expected operator, found string at column 3", 2, 5},
+	})
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	checkSyntaxErrors(t, []syntaxErrorTest{
+		{"3 $ 4", "unexpected character '$' at column 3", 2, 3},
+		{"a @ b", "unexpected character '@' at column 3", 2, 3},
+		{"x + 1 ~", "unexpected character '~' at column 7", 6, 7},
+	})
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"3 $+ 4", IntValue(7)},
+		{"$ 2 * 3 @", IntValue(6)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src, Permissive())
+		if err != nil {
+			t.Errorf("Parse(%q, Permissive()): %v", tt.src, err)
+			continue
+		}
+		if got, err := NewEvaluator(nil).Eval(e); err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+	// Dropping a character in permissive mode may still leave an error.
+	checkSyntaxErrors(t, []syntaxErrorTest{
+		{"3 $ 4", "expected operator, found integer at column 5", 4, 5},
+	}, Permissive())
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	programMagic   = "PRTC"
+	programVersion = 6
+)
+
+var ErrProgramVersion = errors.New("unsupported program version")
+
+func writeUvarint(buf *bytes.Buffer, x uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutUvarint(scratch[:], x)])
+}
+
+func writeVarint(buf *bytes.Buffer, x int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutVarint(scratch[:], x)])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func writeValue(buf *bytes.Buffer, v Value) error {
+	buf.WriteByte(byte(v.Kind()))
+	switch v.Kind() {
+	case IntKind:
+		writeVarint(buf, v.Int())
+	case FloatKind:
+		writeUvarint(buf, math.Float64bits(v.Float()))
+	case BoolKind:
+		if v.Bool() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case StringKind:
+		writeString(buf, v.Str())
+	case ListKind:
+		writeUvarint(buf, uint64(len(v.List())))
+		for _, item := range v.List() {
+			if err := writeValue(buf, item); err != nil {
+				return err
+			}
+		}
+	case MapKind:
+		keys := make([]string, 0, len(v.Map()))
+		for k := range v.Map() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeUvarint(buf, uint64(len(keys)))
+		for _, k := range keys {
+			writeString(buf, k)
+			if err := writeValue(buf, v.Map()[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot serialize %s value", v.Kind())
+	}
+	return nil
+}
+
+// MarshalBinary encodes the program in a versioned format suitable for
+// caching compiled expressions outside the process.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(programMagic)
+	writeUvarint(&buf, programVersion)
+	if err := p.write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write encodes the program after the header, followed by the programs of
+// its try calls and of the right operands of its && and ||.
+func (p *Program) write(buf *bytes.Buffer) error {
+	writeUvarint(buf, uint64(len(p.Consts)))
+	for _, c := range p.Consts {
+		if err := writeValue(buf, c); err != nil {
+			return err
+		}
+	}
+	writeUvarint(buf, uint64(len(p.Names)))
+	for _, name := range p.Names {
+		writeString(buf, name)
+	}
+	writeUvarint(buf, uint64(p.Temps))
+	writeUvarint(buf, uint64(len(p.Code)))
+	for _, ins := range p.Code {
+		buf.WriteByte(byte(ins.Op))
+		writeUvarint(buf, uint64(ins.A))
+		writeUvarint(buf, uint64(ins.B))
+		writeUvarint(buf, uint64(ins.Pos))
+	}
+	writeUvarint(buf, uint64(len(p.Tries)))
+	for _, programs := range p.Tries {
+		for _, program := range programs {
+			if err := program.write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	writeUvarint(buf, uint64(len(p.Lazy)))
+	for _, program := range p.Lazy {
+		if err := program.write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type programReader struct {
+	r *bytes.Reader
+}
+
+func (pr programReader) uvarint() (uint64, error) {
+	return binary.ReadUvarint(pr.r)
+}
+
+// length reads a count and rejects ones that cannot fit in the remaining
+// input, so corrupt data cannot trigger huge allocations.
+func (pr programReader) length() (int, error) {
+	n, err := pr.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(pr.r.Len()) {
+		return 0, fmt.Errorf("length %d exceeds remaining input", n)
+	}
+	return int(n), nil
+}
+
+func (pr programReader) int() (int, error) {
+	n, err := pr.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("operand %d out of range", n)
+	}
+	return int(n), nil
+}
+
+func (pr programReader) string() (string, error) {
+	n, err := pr.length()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (pr programReader) value() (Value, error) {
+	kind, err := pr.r.ReadByte()
+	if err != nil {
+		return Value{}, err
+	}
+	switch Kind(kind) {
+	case IntKind:
+		i, err := binary.ReadVarint(pr.r)
+		return IntValue(i), err
+	case FloatKind:
+		bits, err := pr.uvarint()
+		return FloatValue(math.Float64frombits(bits)), err
+	case BoolKind:
+		b, err := pr.r.ReadByte()
+		return BoolValue(b != 0), err
+	case StringKind:
+		s, err := pr.string()
+		return StringValue(s), err
+	case ListKind:
+		n, err := pr.length()
+		if err != nil {
+			return Value{}, err
+		}
+		items := make([]Value, n)
+		for i := range items {
+			if items[i], err = pr.value(); err != nil {
+				return Value{}, err
+			}
+		}
+		return ListValue(items), nil
+	case MapKind:
+		n, err := pr.length()
+		if err != nil {
+			return Value{}, err
+		}
+		m := make(map[string]Value, n)
+		for i := 0; i < n; i++ {
+			k, err := pr.string()
+			if err != nil {
+				return Value{}, err
+			}
+			if m[k], err = pr.value(); err != nil {
+				return Value{}, err
+			}
+		}
+		return MapValue(m), nil
+	}
+	return Value{}, fmt.Errorf("cannot deserialize %s value", Kind(kind))
+}
+
+// program decodes what Program.write encodes.
+func (pr programReader) program() (*Program, error) {
+	decoded := &Program{}
+	n, err := pr.length()
+	if err != nil {
+		return nil, err
+	}
+	decoded.Consts = make([]Value, n)
+	for i := range decoded.Consts {
+		if decoded.Consts[i], err = pr.value(); err != nil {
+			return nil, err
+		}
+	}
+	if n, err = pr.length(); err != nil {
+		return nil, err
+	}
+	decoded.Names = make([]string, n)
+	for i := range decoded.Names {
+		if decoded.Names[i], err = pr.string(); err != nil {
+			return nil, err
+		}
+	}
+	if decoded.Temps, err = pr.int(); err != nil {
+		return nil, err
+	}
+	if n, err = pr.length(); err != nil {
+		return nil, err
+	}
+	decoded.Code = make([]Instruction, n)
+	for i := range decoded.Code {
+		op, err := pr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		decoded.Code[i].Op = Opcode(op)
+		if decoded.Code[i].A, err = pr.int(); err != nil {
+			return nil, err
+		}
+		if decoded.Code[i].B, err = pr.int(); err != nil {
+			return nil, err
+		}
+		if decoded.Code[i].Pos, err = pr.int(); err != nil {
+			return nil, err
+		}
+	}
+	if n, err = pr.length(); err != nil {
+		return nil, err
+	}
+	decoded.Tries = make([][2]*Program, n)
+	for i := range decoded.Tries {
+		for j := range decoded.Tries[i] {
+			if decoded.Tries[i][j], err = pr.program(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if n, err = pr.length(); err != nil {
+		return nil, err
+	}
+	decoded.Lazy = make([]*Program, n)
+	for i := range decoded.Lazy {
+		if decoded.Lazy[i], err = pr.program(); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+func (p *Program) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(programMagic)) {
+		return errors.New("not a compiled program")
+	}
+	pr := programReader{r: bytes.NewReader(data[len(programMagic):])}
+	version, err := pr.uvarint()
+	if err != nil {
+		return err
+	}
+	if version != programVersion {
+		return fmt.Errorf("%w %d", ErrProgramVersion, version)
+	}
+	decoded, err := pr.program()
+	if err != nil {
+		return err
+	}
+	if pr.r.Len() != 0 {
+		return errors.New("trailing data after program")
+	}
+	if err := decoded.verify(); err != nil {
+		return err
+	}
+	*p = *decoded
+	return nil
+}
+
+// verify checks operand indexes and stack effects so a decoded program can
+// be run without risk of panicking.
+func (p *Program) verify() error {
+	for _, programs := range p.Tries {
+		for _, program := range programs {
+			if err := program.verify(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, program := range p.Lazy {
+		if err := program.verify(); err != nil {
+			return err
+		}
+	}
+	depth, binds := 0, 0
+	stored := make([]bool, p.Temps)
+	for i, ins := range p.Code {
+		limit := len(p.Names)
+		switch ins.Op {
+		case OpConst:
+			limit = len(p.Consts)
+		case OpPrefix, OpInfix:
+			limit = len(opKindSymbols)
+		case OpStore, OpTemp:
+			limit = p.Temps
+		case OpTry:
+			limit = len(p.Tries)
+		case OpLogic:
+			limit = len(p.Lazy)
+		case OpUnbind:
+			limit = 1
+		}
+		if ins.A >= limit {
+			return fmt.Errorf("instruction %d: operand %d out of range", i, ins.A)
+		}
+		switch ins.Op {
+		case OpConst, OpLoad, OpTry:
+			depth += 1
+		case OpPrefix:
+			if depth < 1 {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+		case OpLogic:
+			if depth < 1 {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+			if ins.B != int(AndOp) && ins.B != int(OrOp) {
+				return fmt.Errorf("instruction %d: '%s' is not a logical operator", i, OpKind(ins.B))
+			}
+		case OpInfix:
+			if depth < 2 {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+			depth -= 1
+		case OpCall:
+			if depth < ins.B {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+			depth += 1 - ins.B
+		case OpStore:
+			if depth < 1 {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+			stored[ins.A] = true
+		case OpTemp:
+			if !stored[ins.A] {
+				return fmt.Errorf("instruction %d: temporary #%d read before it is stored", i, ins.A)
+			}
+			depth += 1
+		case OpBind:
+			if depth < 1 {
+				return fmt.Errorf("instruction %d: stack underflow", i)
+			}
+			depth -= 1
+			binds += 1
+		case OpUnbind:
+			if binds < 1 {
+				return fmt.Errorf("instruction %d: unbind without a local", i)
+			}
+			binds -= 1
+		default:
+			return fmt.Errorf("instruction %d: invalid opcode %s", i, ins.Op)
+		}
+	}
+	if depth != 1 {
+		return fmt.Errorf("program leaves %d values on the stack", depth)
+	}
+	if binds != 0 {
+		return fmt.Errorf("program leaves %d locals bound", binds)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProgramRoundTrip(t *testing.T) {
+	env := Env{
+		"x":   IntValue(4),
+		"s":   StringValue("ab"),
+		"inc": FuncValue(func(args []Value) (Value, error) { return applyInfix(AddOp, args[0], IntValue(1)) }),
+	}
+	tests := []string{
+		"x * 2 + 1",
+		"x / 2.5 - -x",
+		`s + "c" == "abc"`,
+		"inc(inc(x)) ^ 2",
+		"{ t = x + 1; t * t }",
+		"try(x / 0, -1)",
+		"x > 3 && s != \"\"",
+	}
+	for _, src := range tests {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err := Compile(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := program.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%s): %v", src, err)
+		}
+		var decoded Program
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary(%s): %v", src, err)
+		}
+		want, wantErr := program.Run(env)
+		got, err := decoded.Run(env)
+		if !got.Equal(want) || (err == nil) != (wantErr == nil) {
+			t.Errorf("decoded %s = %s, %v, want %s, %v", src, got, err, want, wantErr)
+		}
+		if disassembly := decoded.Disassemble(); disassembly != program.Disassemble() {
+			t.Errorf("decoded %s disassembles to\n%s\nwant\n%s", src, disassembly, program.Disassemble())
+		}
+	}
+}
+
+func TestUnmarshalRejectsBadPrograms(t *testing.T) {
+	e, err := Parse("{ t = x + 1; try(t / x, 0) * t }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Program
+	if err := decoded.UnmarshalBinary([]byte("JUNK")); err == nil {
+		t.Errorf("decoded a program without the magic number")
+	}
+	future := append([]byte(programMagic), byte(programVersion+1))
+	if err := decoded.UnmarshalBinary(append(future, data[len(future):]...)); !errors.Is(err, ErrProgramVersion) {
+		t.Errorf("decoding version %d fails with %v, want ErrProgramVersion", programVersion+1, err)
+	}
+	if err := decoded.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("decoded a program with trailing data")
+	}
+	for n := len(programMagic); n < len(data); n++ {
+		if err := decoded.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("decoded the first %d of %d bytes", n, len(data))
+		}
+	}
+	// Flipping any byte must give an error or a program that still runs.
+	for i := len(programMagic) + 1; i < len(data); i++ {
+		corrupt := append([]byte(nil), data...)
+		corrupt[i] ^= 0x55
+		var p Program
+		if p.UnmarshalBinary(corrupt) == nil {
+			p.Run(Env{"x": IntValue(1)})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// maxCachedPatterns bounds the compiled regular expressions kept for
+// reuse; the cache is emptied when it fills up.
+const maxCachedPatterns = 256
+
+var patternCache struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// compilePattern returns the compiled form of pattern, compiling it only
+// the first time it is used so a predicate matching many values compiles
+// it once.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternCache.Lock()
+	defer patternCache.Unlock()
+	if re, ok := patternCache.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	if len(patternCache.patterns) >= maxCachedPatterns || patternCache.patterns == nil {
+		patternCache.patterns = make(map[string]*regexp.Regexp)
+	}
+	patternCache.patterns[pattern] = re
+	return re, nil
+}
+
+func init() {
+	match := func(want bool) InfixImpl {
+		return func(lhs, rhs Value) (Value, error) {
+			re, err := compilePattern(rhs.Str())
+			if err != nil {
+				return Value{}, err
+			}
+			return BoolValue(re.MatchString(lhs.Str()) == want), nil
+		}
+	}
+	setInfix(MatchOp, StringKind, StringKind, BoolKind, match(true))
+	setInfix(NotMatchOp, StringKind, StringKind, BoolKind, match(false))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	env := Env{"name": StringValue("foobar"), "prefix": StringValue("foo"), "n": IntValue(1)}
+	tests := []struct {
+		src  string
+		want Value
+		err  string
+	}{
+		{src: `name =~ "^foo.*"`, want: BoolValue(true)},
+		{src: `name =~ "^bar"`, want: BoolValue(false)},
+		{src: `name !~ "^bar"`, want: BoolValue(true)},
+		{src: `name =~ prefix + "b"`, want: BoolValue(true)},
+		{src: `name =~ "o{2}" && name !~ "z"`, want: BoolValue(true)},
+		{src: `name =~ ""`, want: BoolValue(true)},
+		{src: `name =~ "("`, err: "invalid pattern \"(\": error parsing regexp: missing closing ): `(` at column 6"},
+		{src: `n =~ "1"`, err: "operator '=~' not defined for int and string at column 3"},
+		{src: `name !~ n`, err: "operator '!~' not defined for string and int at column 6"},
+	}
+	for _, tt := range tests {
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(mustParse(t, tt.src))
+			if errorString(err) != tt.err || err == nil && !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s, %q", tt.src, b, got, err, tt.want, tt.err)
+			}
+		}
+	}
+	if kind, errs := Check(mustParse(t, `name =~ prefix`), Schema{"name": StringKind, "prefix": StringKind}); kind != BoolKind || len(errs) > 0 {
+		t.Errorf("Check(name =~ prefix) = %s, %v, want bool", kind, errs)
+	}
+}
+
+func TestPatternCache(t *testing.T) {
+	first, err := compilePattern("^cached$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := compilePattern("^cached$"); again != first {
+		t.Errorf("a cached pattern was compiled again")
+	}
+	for i := 0; i < maxCachedPatterns; i++ {
+		if _, err := compilePattern(fmt.Sprintf("^%d$", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patternCache.Lock()
+	n := len(patternCache.patterns)
+	patternCache.Unlock()
+	if n > maxCachedPatterns {
+		t.Errorf("the cache holds %d patterns, more than %d", n, maxCachedPatterns)
+	}
+	if _, err := compilePattern("["); err == nil {
+		t.Errorf("an invalid pattern compiled")
+	}
+}
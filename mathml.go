@@ -0,0 +1,144 @@
+package main
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// mathMLOperators are the symbols typeset for operators spelled otherwise
+// in source.
+var mathMLOperators = map[OpKind]string{
+	SubOp: "−",
+	MulOp: "×",
+	EqOp:  "=",
+	NeOp:  "≠",
+	LeOp:  "≤",
+	GeOp:  "≥",
+	AndOp: "∧",
+	OrOp:  "∨",
+	NotOp: "¬",
+}
+
+// MathML renders e as presentation MathML, so formulas can be shown in web
+// pages without images. Division becomes a fraction and ^ a superscript;
+// other operators are written inline, with parentheses where Format would
+// need them.
+func MathML(e Expression) string {
+	var sb strings.Builder
+	sb.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	writeMathML(&sb, e)
+	sb.WriteString("</math>")
+	return sb.String()
+}
+
+func writeMathML(sb *strings.Builder, e Expression) {
+	switch v := e.(type) {
+	case IntegerToken:
+		sb.WriteString("<mn>" + strconv.FormatInt(v.value, 10) + "</mn>")
+	case FloatToken:
+		sb.WriteString("<mn>" + formatFloatLiteral(v.value) + "</mn>")
+	case StringToken:
+		sb.WriteString("<ms>" + html.EscapeString(v.value) + "</ms>")
+	case IdentifierToken:
+		sb.WriteString("<mi>" + html.EscapeString(v.name) + "</mi>")
+	case Hole:
+		sb.WriteString("<mi>_</mi>")
+	case *PrefixExpression:
+		r_bp, _ := prefixBindingPower(v.op)
+		sb.WriteString("<mrow>")
+		writeMathMLOperator(sb, v.op)
+		writeMathMLOperand(sb, v.rhs, needsParens(v.rhs, r_bp, false) && !isFraction(v.rhs))
+		sb.WriteString("</mrow>")
+	case *InfixExpression:
+		l_bp, r_bp, _ := infixBindingPower(v.op)
+		switch v.op {
+		case DivOp:
+			sb.WriteString("<mfrac>")
+			writeMathMLOperand(sb, v.lhs, false)
+			writeMathMLOperand(sb, v.rhs, false)
+			sb.WriteString("</mfrac>")
+		case PowOp:
+			// Any operator in the base is parenthesised, as (-x)^2 would
+			// otherwise look like -x^2.
+			parens := false
+			switch v.lhs.(type) {
+			case *PrefixExpression, *InfixExpression:
+				parens = true
+			}
+			sb.WriteString("<msup>")
+			writeMathMLOperand(sb, v.lhs, parens)
+			writeMathMLOperand(sb, v.rhs, false)
+			sb.WriteString("</msup>")
+		default:
+			sb.WriteString("<mrow>")
+			writeMathMLOperand(sb, v.lhs, needsParens(v.lhs, l_bp, true) && !isFraction(v.lhs))
+			writeMathMLOperator(sb, v.op)
+			writeMathMLOperand(sb, v.rhs, needsParens(v.rhs, r_bp, false) && !isFraction(v.rhs))
+			sb.WriteString("</mrow>")
+		}
+	case *CallExpression:
+		if isLambda(v) {
+			writeMathMLLambda(sb, v)
+			return
+		}
+		if isLet(v) {
+			writeMathML(sb, inlineLet(v))
+			return
+		}
+		sb.WriteString("<mrow><mi>" + html.EscapeString(v.name) + "</mi><mo>&#x2061;</mo><mrow><mo>(</mo>")
+		for i, arg := range v.args {
+			if i > 0 {
+				sb.WriteString("<mo>,</mo>")
+			}
+			writeMathML(sb, arg)
+		}
+		sb.WriteString("<mo>)</mo></mrow></mrow>")
+	}
+}
+
+// writeMathMLLambda writes a lambda as a mapping, x ↦ x + 1.
+func writeMathMLLambda(sb *strings.Builder, e *CallExpression) {
+	params := lambdaParams(e)
+	sb.WriteString("<mrow>")
+	if len(params) != 1 {
+		sb.WriteString("<mrow><mo>(</mo>")
+	}
+	for i, param := range params {
+		if i > 0 {
+			sb.WriteString("<mo>,</mo>")
+		}
+		sb.WriteString("<mi>" + html.EscapeString(param.name) + "</mi>")
+	}
+	if len(params) != 1 {
+		sb.WriteString("<mo>)</mo></mrow>")
+	}
+	sb.WriteString("<mo>&#x21A6;</mo>")
+	writeMathML(sb, lambdaBody(e))
+	sb.WriteString("</mrow>")
+}
+
+// isFraction reports whether e is typeset as a fraction, whose bar groups
+// it without parentheses.
+func isFraction(e Expression) bool {
+	infix, ok := e.(*InfixExpression)
+	return ok && infix.op == DivOp
+}
+
+func writeMathMLOperand(sb *strings.Builder, e Expression, parens bool) {
+	if !parens {
+		writeMathML(sb, e)
+		return
+	}
+	sb.WriteString("<mrow><mo>(</mo>")
+	writeMathML(sb, e)
+	sb.WriteString("<mo>)</mo></mrow>")
+}
+
+func writeMathMLOperator(sb *strings.Builder, op OpKind) {
+	symbol, ok := mathMLOperators[op]
+	if !ok {
+		symbol = html.EscapeString(op.String())
+	}
+	sb.WriteString("<mo>" + symbol + "</mo>")
+}
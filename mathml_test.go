@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"(a + b) * c", "<mrow><mrow><mo>(</mo><mrow><mi>a</mi><mo>+</mo><mi>b</mi></mrow><mo>)</mo></mrow><mo>×</mo><mi>c</mi></mrow>"},
+		{"a - (b - c)", "<mrow><mi>a</mi><mo>−</mo><mrow><mo>(</mo><mrow><mi>b</mi><mo>−</mo><mi>c</mi></mrow><mo>)</mo></mrow></mrow>"},
+		// Fraction bars group without parentheses.
+		{"a / (b + c)", "<mfrac><mi>a</mi><mrow><mi>b</mi><mo>+</mo><mi>c</mi></mrow></mfrac>"},
+		{"(a / b) * c", "<mrow><mfrac><mi>a</mi><mi>b</mi></mfrac><mo>×</mo><mi>c</mi></mrow>"},
+		{"-(a / b)", "<mrow><mo>−</mo><mfrac><mi>a</mi><mi>b</mi></mfrac></mrow>"},
+		{"(-x) ^ 2", "<msup><mrow><mo>(</mo><mrow><mo>−</mo><mi>x</mi></mrow><mo>)</mo></mrow><mn>2</mn></msup>"},
+		{"-(x ^ 2)", "<mrow><mo>−</mo><msup><mi>x</mi><mn>2</mn></msup></mrow>"},
+		{"x ^ (a + 1)", "<msup><mi>x</mi><mrow><mi>a</mi><mo>+</mo><mn>1</mn></mrow></msup>"},
+		{"a != b && !c", "<mrow><mrow><mi>a</mi><mo>≠</mo><mi>b</mi></mrow><mo>∧</mo><mrow><mo>¬</mo><mi>c</mi></mrow></mrow>"},
+		{`"<b>" + x`, "<mrow><ms>&lt;b&gt;</ms><mo>+</mo><mi>x</mi></mrow>"},
+		{"_ - 1.5", "<mrow><mi>_</mi><mo>−</mo><mn>1.5</mn></mrow>"},
+		{"max(x, 2)", "<mrow><mi>max</mi><mo>&#x2061;</mo><mrow><mo>(</mo><mi>x</mi><mo>,</mo><mn>2</mn><mo>)</mo></mrow></mrow>"},
+		{"fn(x, x + 1)", "<mrow><mi>x</mi><mo>&#x21A6;</mo><mrow><mi>x</mi><mo>+</mo><mn>1</mn></mrow></mrow>"},
+		{"fn(x, y, x)", "<mrow><mrow><mo>(</mo><mi>x</mi><mo>,</mo><mi>y</mi><mo>)</mo></mrow><mo>&#x21A6;</mo><mi>x</mi></mrow>"},
+		{"let(y, 2, y * y)", "<mrow><mn>2</mn><mo>×</mo><mn>2</mn></mrow>"},
+	}
+	for _, tt := range tests {
+		got := MathML(mustParse(t, tt.src))
+		want := `<math xmlns="http://www.w3.org/1998/Math/MathML">` + tt.want + "</math>"
+		if got != want {
+			t.Errorf("MathML(%s) =\n%s\nwant\n%s", tt.src, got, want)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(got))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("MathML(%s) is not well-formed: %v", tt.src, err)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// countingObserver counts the nodes evaluated.
+type countingObserver struct {
+	nodes int
+}
+
+func (o *countingObserver) OnNodeStart(e Expression) {
+	o.nodes++
+}
+
+func (o *countingObserver) OnNodeResult(e Expression, value Value, err error, duration time.Duration) {
+}
+
+func TestMemoization(t *testing.T) {
+	tests := []struct {
+		src          string
+		want         Value
+		nodes, calls int
+	}{
+		// (a + b) is evaluated once, saving its two operands.
+		{"(a + b) * (a + b)", IntValue(25), 5, 0},
+		{"(a + b) * (a + b) - (a + b)", IntValue(20), 7, 0},
+		// Calls may have side effects, so they are made every time.
+		{"f(a) * f(a)", IntValue(16), 5, 2},
+		{"(f(a) + b) * (f(a) + b)", IntValue(49), 9, 2},
+		// The value a let binds is shared with the rest of the expression,
+		// while its body depends on what it binds.
+		{"{ t = a + b; t * t } + (a + b)", IntValue(30), 9, 0},
+		{"{ a = 1; a + b } + (a + b)", IntValue(9), 9, 0},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := 0
+		env := Env{
+			"a": IntValue(2),
+			"b": IntValue(3),
+			"f": FuncValue(func(args []Value) (Value, error) {
+				calls++
+				return applyInfix(MulOp, args[0], args[0])
+			}),
+		}
+		observer := &countingObserver{}
+		got, err := NewEvaluator(env, WithMemoization(), WithObserver(observer)).Eval(e)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+		if observer.nodes != tt.nodes || calls != tt.calls {
+			t.Errorf("%s evaluated %d nodes and made %d calls, want %d and %d", tt.src, observer.nodes, calls, tt.nodes, tt.calls)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Catalog holds the text of error messages by code, as formats for
+// fmt.Sprintf of the arguments listed with each code. Formats may use
+// explicit argument indexes, as in %[2]s, where a language orders the
+// arguments differently.
+type Catalog map[string]string
+
+// The codes of the messages Localize translates.
+const (
+	// CodeAtColumn places a message (string) at a column (int).
+	CodeAtColumn = "at_column"
+	// CodeDidYouMean follows a message (string) with a suggested name
+	// (string).
+	CodeDidYouMean = "did_you_mean"
+	// CodeUndefinedVariable is for a variable name (string).
+	CodeUndefinedVariable = "undefined_variable"
+	// CodeUndefinedFunction is for a function name (string).
+	CodeUndefinedFunction = "undefined_function"
+	// CodeNotFunction is for a name (string) called but not a function.
+	CodeNotFunction = "not_function"
+	// CodeDivisionByZero has no arguments.
+	CodeDivisionByZero = "division_by_zero"
+	// CodePrefixMismatch is for an operator (string) and operand kind
+	// (string).
+	CodePrefixMismatch = "prefix_mismatch"
+	// CodeInfixMismatch is for an operator (string) and the kinds of its
+	// left and right operands (strings).
+	CodeInfixMismatch = "infix_mismatch"
+)
+
+// English is the catalog errors are reported in.
+var English = Catalog{
+	CodeAtColumn:          "%s at column %d",
+	CodeDidYouMean:        "%s; did you mean '%s'?",
+	CodeUndefinedVariable: "undefined variable '%s'",
+	CodeUndefinedFunction: "undefined function '%s'",
+	CodeNotFunction:       "'%s' is not a function",
+	CodeDivisionByZero:    "division by zero",
+	CodePrefixMismatch:    "operator '%s' not defined for %s",
+	CodeInfixMismatch:     "operator '%s' not defined for %s and %s",
+}
+
+// message is a message by code, to be formatted from a catalog.
+type message struct {
+	code string
+	args []interface{}
+}
+
+// format formats m from catalog, or from English if catalog lacks it.
+func (m *message) format(catalog Catalog) string {
+	text, ok := catalog[m.code]
+	if !ok {
+		text = English[m.code]
+	}
+	return fmt.Sprintf(text, m.args...)
+}
+
+// evalError returns an EvalError at pos with the message of code, which
+// belongs to category if it is not nil.
+func evalError(pos int, category error, code string, args ...interface{}) EvalError {
+	m := &message{code: code, args: args}
+	return EvalError{Pos: pos, Msg: m.format(English), Err: category, message: m}
+}
+
+// Localize returns the message of err from catalog. Messages catalog does
+// not have, and errors without a code, such as those of host functions,
+// are given in English.
+func Localize(err error, catalog Catalog) string {
+	at := func(msg string, pos int) string {
+		return (&message{code: CodeAtColumn, args: []interface{}{msg, pos + 1}}).format(catalog)
+	}
+	var evalErr EvalError
+	var syntaxErr SyntaxError
+	var typeErr TypeError
+	var category *categoryError
+	switch {
+	case errors.As(err, &evalErr):
+		msg := evalErr.Msg
+		if evalErr.message != nil {
+			msg = evalErr.message.format(catalog)
+		} else if evalErr.Err != nil {
+			msg = Localize(evalErr.Err, catalog)
+		}
+		return didYouMean(at(msg, evalErr.Pos), evalErr.Suggestion, catalog)
+	case errors.As(err, &syntaxErr):
+		return at(syntaxErr.Msg, syntaxErr.Pos)
+	case errors.As(err, &typeErr):
+		return didYouMean(at(typeErr.Msg, typeErr.Pos), typeErr.Suggestion, catalog)
+	case errors.As(err, &category):
+		return category.message.format(catalog)
+	case err == ErrDivisionByZero:
+		return (&message{code: CodeDivisionByZero}).format(catalog)
+	}
+	return err.Error()
+}
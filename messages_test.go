@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+var german = Catalog{
+	CodeAtColumn:          "%s in Spalte %d",
+	CodeDidYouMean:        "%s; meinten Sie '%s'?",
+	CodeUndefinedVariable: "Variable '%s' ist nicht definiert",
+	CodeUndefinedFunction: "Funktion '%s' ist nicht definiert",
+	CodeDivisionByZero:    "Division durch null",
+	CodeInfixMismatch:     "Operator '%[1]s' ist für %[3]s rechts von %[2]s nicht definiert",
+}
+
+func TestLocalize(t *testing.T) {
+	env := Env{
+		"total": IntValue(1),
+		"s":     StringValue("a"),
+		"fail": FuncValue(func(args []Value) (Value, error) {
+			return Value{}, errors.New("host failure")
+		}),
+	}
+	tests := []struct {
+		src, english, german string
+	}{
+		{"1 / 0", "division by zero at column 3", "Division durch null in Spalte 3"},
+		{"totl + 1", "undefined variable 'totl' at column 1; did you mean 'total'?",
+			"Variable 'totl' ist nicht definiert in Spalte 1; meinten Sie 'total'?"},
+		{"nothing(1)", "undefined function 'nothing' at column 1", "Funktion 'nothing' ist nicht definiert in Spalte 1"},
+		{"s * 2", "operator '*' not defined for string and int at column 3",
+			"Operator '*' ist für int rechts von string nicht definiert in Spalte 3"},
+		// Messages the catalog lacks are in English.
+		{"-s", "operator '-' not defined for string at column 1", "operator '-' not defined for string in Spalte 1"},
+		{"total(1)", "'total' is not a function at column 1", "'total' is not a function in Spalte 1"},
+	}
+	for _, tt := range tests {
+		for _, b := range allBackends {
+			_, err := NewEvaluator(env, WithBackend(b)).Eval(mustParse(t, tt.src))
+			if errorString(err) != tt.english {
+				t.Errorf("%s with the %s backend = %v, want %s", tt.src, b, err, tt.english)
+			}
+			if got := Localize(err, german); got != tt.german {
+				t.Errorf("Localize(%v) with the %s backend = %s, want %s", err, b, got, tt.german)
+			}
+			if got := Localize(err, English); got != tt.english {
+				t.Errorf("Localize(%v, English) with the %s backend = %s", err, b, got)
+			}
+		}
+	}
+	_, err := NewEvaluator(env).Eval(mustParse(t, "fail()"))
+	if got := Localize(err, german); got != "host failure" {
+		t.Errorf("Localize(%v) = %s", err, got)
+	}
+	_, err = Parse("1 +")
+	if got := Localize(err, german); got != "unexpected end of expression after '+' in Spalte 3" {
+		t.Errorf("Localize(%v) = %s", err, got)
+	}
+	if got := Localize(ErrDivisionByZero, german); got != "Division durch null" {
+		t.Errorf("Localize(ErrDivisionByZero) = %s", got)
+	}
+}
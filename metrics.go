@@ -0,0 +1,71 @@
+package main
+
+import "time"
+
+// Metrics receives counters and timings from the parser, evaluator and
+// cache, so embedders can export them to any metrics system without this
+// package depending on one. Implementations must be safe for concurrent
+// use.
+type Metrics interface {
+	// Count adds delta to the counter name with the values of labels.
+	Count(name string, delta int64, labels ...Label)
+	// Observe records one duration for the timing name.
+	Observe(name string, d time.Duration)
+}
+
+// Label is a dimension of a counter and its value, such as the kind of an
+// error.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// The metric names reported. MetricEvals counts the expressions evaluated,
+// and MetricEvalErrors those that failed, labelled with the kind of error:
+// undefined_variable, division_by_zero, type_mismatch, step_limit,
+// recursion_limit, stopped, policy, assertion or other. MetricRequests
+// counts the requests a Limiter receives, labelled with their outcome.
+const (
+	MetricTokensLexed   = "tokens_lexed"
+	MetricNodesParsed   = "nodes_parsed"
+	MetricParseErrors   = "parse_errors"
+	MetricParseDuration = "parse_duration"
+	MetricEvalDuration  = "eval_duration"
+	MetricEvals         = "evals"
+	MetricEvalErrors    = "eval_errors"
+	MetricRequests      = "requests"
+	MetricCacheHits     = "cache_hits"
+	MetricCacheMisses   = "cache_misses"
+)
+
+// WithParseMetrics reports the tokens lexed, nodes parsed, syntax errors and
+// time taken by each parse to m.
+func WithParseMetrics(m Metrics) ParseOption {
+	return func(l *Lexer) {
+		l.metrics = m
+	}
+}
+
+// WithEvalMetrics reports each Eval, the time it took and its errors, by
+// kind, to m.
+func WithEvalMetrics(m Metrics) EvalOption {
+	return func(ev *Evaluator) {
+		ev.metrics = m
+	}
+}
+
+// SetMetrics reports the cache's hits and misses to m, along with the parse
+// metrics of the misses. It must not be called while the cache is in use.
+func (c *Cache) SetMetrics(m Metrics) {
+	c.metrics = m
+}
+
+// countNodes returns the number of nodes in e.
+func countNodes(e Expression) int {
+	nodes := 0
+	walk(e, 1, func(e Expression, depth int) bool {
+		nodes += 1
+		return true
+	})
+	return nodes
+}
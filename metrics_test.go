@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics totals the counters and counts the timings it is given.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	timings  map[string]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{counters: make(map[string]int64), timings: make(map[string]int)}
+}
+
+func (m *recordingMetrics) Count(name string, delta int64, labels ...Label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *recordingMetrics) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timings[name]++
+}
+
+func TestParseMetrics(t *testing.T) {
+	m := newRecordingMetrics()
+	Parse("a + f(b, 2)", WithParseMetrics(m))
+	Parse("1 +", WithParseMetrics(m))
+	want := map[string]int64{MetricTokensLexed: 10, MetricNodesParsed: 5, MetricParseErrors: 1}
+	for name, n := range want {
+		if m.counters[name] != n {
+			t.Errorf("%s = %d, want %d", name, m.counters[name], n)
+		}
+	}
+	if m.timings[MetricParseDuration] != 2 {
+		t.Errorf("%d parses timed, want 2", m.timings[MetricParseDuration])
+	}
+}
+
+func TestCacheMetrics(t *testing.T) {
+	m := newRecordingMetrics()
+	c := NewCache(2)
+	c.SetMetrics(m)
+	for _, src := range []string{"a + 1", "a + 1", "b * 2", "a + 1"} {
+		c.Eval(src, Env{"a": IntValue(1), "b": IntValue(2)})
+	}
+	if m.counters[MetricCacheHits] != 2 || m.counters[MetricCacheMisses] != 2 {
+		t.Errorf("%d hits and %d misses, want 2 and 2", m.counters[MetricCacheHits], m.counters[MetricCacheMisses])
+	}
+	// The misses are parsed with the cache's metrics.
+	if m.counters[MetricNodesParsed] != 6 {
+		t.Errorf("%d nodes parsed, want 6", m.counters[MetricNodesParsed])
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import "math/rand"
+
+type MutationKind int
+
+const (
+	// SwapOperator replaces an operator by another of the same arity.
+	SwapOperator MutationKind = iota
+	// TweakLiteral nudges an integer or shortens or fills in a string.
+	TweakLiteral
+	// SwapOperands exchanges the operands of an operator.
+	SwapOperands
+	// Reassociate regroups (a OP b) OP2 c as a OP (b OP2 c) or back.
+	Reassociate
+	// ReplaceVariable reads another variable of the expression instead.
+	ReplaceVariable
+)
+
+var mutationKindNames = [...]string{
+	SwapOperator:    "swap operator",
+	TweakLiteral:    "tweak literal",
+	SwapOperands:    "swap operands",
+	Reassociate:     "reassociate",
+	ReplaceVariable: "replace variable",
+}
+
+func (k MutationKind) String() string {
+	if k < 0 || int(k) >= len(mutationKindNames) {
+		return "unknown"
+	}
+	return mutationKindNames[k]
+}
+
+// Mutation is a variant of an expression with one small change of the given
+// kind at Path, as reported by Diff. Expr is the whole mutated tree.
+type Mutation struct {
+	Kind MutationKind
+	Path string
+	Expr Expression
+}
+
+// mutationAttempts bounds the search for a mutant that differs from the
+// original once normalized.
+const mutationAttempts = 20
+
+// Mutate returns a random mutant of e for mutation testing of systems that
+// consume formulas, leaving e itself unchanged. Mutants that only reorder
+// the operands of a commutative operator are skipped, but a mutant may
+// still compute the same thing, as x * 1 does for x * 2 when x is 0. It
+// reports false if e has nothing to mutate, as for a lone variable.
+func Mutate(e Expression, rng *rand.Rand) (Mutation, bool) {
+	var sites []mutationSite
+	collectMutationSites(e, "", func(n Expression) Expression { return n }, &sites)
+	variables := Variables(e)
+	normal := normalize(e, nil)
+	for attempt := 0; attempt < mutationAttempts && len(sites) > 0; attempt++ {
+		site := sites[rng.Intn(len(sites))]
+		kind, mutated, ok := mutateNode(site.expr, variables, rng)
+		if !ok {
+			continue
+		}
+		mutant := site.rebuild(mutated)
+		if equalExpr(normalize(mutant, nil), normal) {
+			continue
+		}
+		return Mutation{Kind: kind, Path: site.path, Expr: mutant}, true
+	}
+	return Mutation{}, false
+}
+
+// mutationSite is a node of the tree being mutated. rebuild returns a copy
+// of the whole tree with the node replaced, sharing everything off the path
+// to it.
+type mutationSite struct {
+	path    string
+	expr    Expression
+	rebuild func(n Expression) Expression
+}
+
+func collectMutationSites(e Expression, path string, rebuild func(n Expression) Expression, sites *[]mutationSite) {
+	*sites = append(*sites, mutationSite{path: path, expr: e, rebuild: rebuild})
+	switch v := e.(type) {
+	case *PrefixExpression:
+		collectMutationSites(v.rhs, childPath(path, "rhs"), func(n Expression) Expression {
+			c := *v
+			c.rhs = n
+			return rebuild(&c)
+		}, sites)
+	case *InfixExpression:
+		collectMutationSites(v.lhs, childPath(path, "lhs"), func(n Expression) Expression {
+			c := *v
+			c.lhs = n
+			return rebuild(&c)
+		}, sites)
+		collectMutationSites(v.rhs, childPath(path, "rhs"), func(n Expression) Expression {
+			c := *v
+			c.rhs = n
+			return rebuild(&c)
+		}, sites)
+	case *CallExpression:
+		for i, arg := range v.args {
+			i := i
+			if isLambda(v) && i < len(v.args)-1 || isLet(v) && i == 0 {
+				// Parameters name what the body reads, not values.
+				continue
+			}
+			collectMutationSites(arg, argPath(path, i), func(n Expression) Expression {
+				c := *v
+				c.args = append([]Expression(nil), v.args...)
+				c.args[i] = n
+				return rebuild(&c)
+			}, sites)
+		}
+	}
+}
+
+// mutateNode applies a random mutation applicable to e itself.
+func mutateNode(e Expression, variables []string, rng *rand.Rand) (MutationKind, Expression, bool) {
+	switch v := e.(type) {
+	case IntegerToken:
+		value := v.value - 1
+		switch {
+		case v.value != 0 && rng.Intn(3) == 0:
+			value = 0
+		case rng.Intn(2) == 0:
+			value = v.value + 1
+		}
+		// Going below zero needs a negated literal, as the parser builds.
+		tweaked, ok := valueNode(IntValue(value), v.pos)
+		return TweakLiteral, tweaked, ok
+	case FloatToken:
+		value := v.value - 1
+		switch {
+		case v.value != 0 && rng.Intn(3) == 0:
+			value = 0
+		case rng.Intn(2) == 0:
+			value = v.value + 1
+		}
+		tweaked, ok := valueNode(FloatValue(value), v.pos)
+		return TweakLiteral, tweaked, ok
+	case StringToken:
+		c := v
+		if v.value == "" {
+			c.value = "a"
+		} else {
+			c.value = v.value[:len(v.value)-1]
+		}
+		return TweakLiteral, c, true
+	case IdentifierToken:
+		if len(variables) < 2 {
+			return 0, nil, false
+		}
+		c := v
+		for c.name == v.name {
+			c.name = variables[rng.Intn(len(variables))]
+		}
+		return ReplaceVariable, c, true
+	case *PrefixExpression:
+		if v.op != AddOp && v.op != SubOp {
+			return 0, nil, false
+		}
+		c := *v
+		c.op = AddOp
+		if v.op == AddOp {
+			c.op = SubOp
+		}
+		return SwapOperator, &c, true
+	case *InfixExpression:
+		switch rng.Intn(3) {
+		case 0:
+			return SwapOperands, &InfixExpression{lhs: v.rhs, rhs: v.lhs, op: v.op, pos: v.pos}, true
+		case 1:
+			if regrouped, ok := reassociate(v); ok {
+				return Reassociate, regrouped, true
+			}
+		}
+		var ops []OpKind
+		for op, rule := range infixRules {
+			if rule.binary && OpKind(op) != v.op {
+				ops = append(ops, OpKind(op))
+			}
+		}
+		if len(ops) == 0 {
+			return 0, nil, false
+		}
+		c := *v
+		c.op = ops[rng.Intn(len(ops))]
+		return SwapOperator, &c, true
+	}
+	return 0, nil, false
+}
+
+// reassociate rotates (a OP b) OP2 c to a OP (b OP2 c), or a OP (b OP2 c)
+// to (a OP b) OP2 c when the left operand is not an operator.
+func reassociate(v *InfixExpression) (Expression, bool) {
+	if lhs, ok := v.lhs.(*InfixExpression); ok {
+		return &InfixExpression{
+			lhs: lhs.lhs,
+			rhs: &InfixExpression{lhs: lhs.rhs, rhs: v.rhs, op: v.op, pos: v.pos},
+			op:  lhs.op,
+			pos: lhs.pos,
+		}, true
+	}
+	if rhs, ok := v.rhs.(*InfixExpression); ok {
+		return &InfixExpression{
+			lhs: &InfixExpression{lhs: v.lhs, rhs: rhs.lhs, op: v.op, pos: v.pos},
+			rhs: rhs.rhs,
+			op:  rhs.op,
+			pos: rhs.pos,
+		}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMutate(t *testing.T) {
+	sources := []string{
+		"a + b * 2",
+		`s == "abc" && n > 3`,
+		"(x - y) / (x + 1)",
+		"max(a, 10) ^ -b",
+		"{ t = a * 2; t + b }",
+	}
+	rng := rand.New(rand.NewSource(3))
+	for _, src := range sources {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds := make(map[MutationKind]bool)
+		for i := 0; i < 100; i++ {
+			m, ok := Mutate(e, rng)
+			if !ok {
+				t.Fatalf("Mutate(%s) found nothing to mutate", src)
+			}
+			kinds[m.Kind] = true
+			if got := Format(e); got != Format(mustParse(t, src)) {
+				t.Fatalf("Mutate changed %s to %s", src, got)
+			}
+			if equalExpr(normalize(m.Expr, nil), normalize(e, nil)) {
+				t.Errorf("%s mutant %s of %s is the same once normalized", m.Kind, Format(m.Expr), src)
+			}
+			if err := CheckRoundTrip(m.Expr); err != nil {
+				t.Errorf("%s mutant of %s: %v", m.Kind, src, err)
+			}
+			if !strings.HasPrefix(Diff(e, m.Expr)[0].Path, m.Path) {
+				t.Errorf("%s mutant %s of %s differs at %s, not under %s", m.Kind, Format(m.Expr), src, Diff(e, m.Expr)[0].Path, m.Path)
+			}
+			if let, ok := m.Expr.(*CallExpression); ok && isLet(let) && letName(let) != "t" {
+				t.Errorf("mutant %s renames the let of %s", Format(m.Expr), src)
+			}
+		}
+		if len(kinds) < 2 {
+			t.Errorf("mutants of %s were all of kind %v", src, kinds)
+		}
+	}
+	for _, src := range []string{"x", "f()"} {
+		if m, ok := Mutate(mustParse(t, src), rng); ok {
+			t.Errorf("Mutate(%s) = %s, want nothing to mutate", src, Format(m.Expr))
+		}
+	}
+}
+
+func mustParse(t *testing.T, src string) Expression {
+	t.Helper()
+	e, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
@@ -0,0 +1,37 @@
+package main
+
+import "fmt"
+
+// AddNamespace adds each of members to env as namespace.name, such as
+// math.sqrt, so that host APIs can be given names that would otherwise
+// collide. A namespace may itself be qualified, as in mycorp.tax.
+func (env Env) AddNamespace(namespace string, members Env) {
+	if qualifiedLength(namespace) != len(namespace) || namespace == "" {
+		panic(fmt.Sprintf("namespace %q is not a qualified name", namespace))
+	}
+	for name, value := range members {
+		if qualifiedLength(name) != len(name) || name == "" {
+			panic(fmt.Sprintf("member %q of namespace %q is not a qualified name", name, namespace))
+		}
+		env[namespace+"."+name] = value
+	}
+}
+
+// qualifiedLength returns the length of the name at the start of s, which
+// is an identifier or identifiers joined by dots, or 0 if there is none. A
+// dot is only part of a name when it is not an operator and an identifier
+// follows it, so a.b is one name but a. is not.
+func qualifiedLength(s string) int {
+	n := identifierLength(s)
+	for n > 0 && n+1 < len(s) && s[n] == '.' {
+		if op, _ := operatorAt(s[n:]); op != NoOp {
+			break
+		}
+		m := identifierLength(s[n+1:])
+		if m == 0 {
+			break
+		}
+		n += 1 + m
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	env := Env{"rate": FloatValue(0.5)}
+	env.AddNamespace("math", Env{
+		"sqrt": FuncValue(func(args []Value) (Value, error) {
+			return FloatValue(math.Sqrt(args[0].Float())), nil
+		}),
+	})
+	env.AddNamespace("mycorp.tax", Env{"rate": FloatValue(0.25), "eu.rate": FloatValue(0.2)})
+	tests := []struct {
+		src, formatted string
+		want           Value
+	}{
+		{"math.sqrt(16.0)", "math.sqrt(16.0)", FloatValue(4)},
+		{"mycorp.tax.rate * 100", "mycorp.tax.rate * 100", FloatValue(25)},
+		{"mycorp.tax.eu.rate + rate", "mycorp.tax.eu.rate + rate", FloatValue(0.7)},
+		{"math.sqrt(mycorp.tax.rate)", "math.sqrt(mycorp.tax.rate)", FloatValue(0.5)},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		if got := Format(e); got != tt.formatted {
+			t.Errorf("Parse(%s) = %s, want %s", tt.src, got, tt.formatted)
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	if _, err := NewEvaluator(env).Eval(mustParse(t, "math.cbrt(8.0)")); err == nil {
+		t.Errorf("math.cbrt(8.0) did not fail")
+	}
+}
+
+func TestQualifiedLength(t *testing.T) {
+	tests := map[string]int{
+		"math.sqrt(x)": 9,
+		"a.b.c + 1":    5,
+		"a. + 1":       1,
+		"a.":           1,
+		"a.1":          1,
+		"rate":         4,
+		"1.5":          0,
+		".a":           0,
+	}
+	for s, want := range tests {
+		if got := qualifiedLength(s); got != want {
+			t.Errorf("qualifiedLength(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestAddNamespacePanics(t *testing.T) {
+	tests := map[string]func(){
+		"empty namespace":   func() { Env{}.AddNamespace("", Env{"f": IntValue(1)}) },
+		"invalid namespace": func() { Env{}.AddNamespace("my corp", Env{"f": IntValue(1)}) },
+		"invalid member":    func() { Env{}.AddNamespace("math", Env{"1f": IntValue(1)}) },
+	}
+	for name, fn := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AddNamespace with an %s did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestCompleteQualified(t *testing.T) {
+	s := newReplSession()
+	s.env.AddNamespace("str", Env{"upper": FuncValue(nil), "lower": FuncValue(nil)})
+	word, candidates := s.complete("1 + str.u")
+	if word != "str.u" || !equalStrings(candidates, []string{"str.upper("}) {
+		t.Errorf(`complete("1 + str.u") = %q, %q`, word, candidates)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PolishNotation writes e in prefix notation, each operator before its
+// operands, as in "* + a b c" for (a + b) * c. Since operators have no
+// fixed arity, prefix operators and calls are written with theirs, as in
+// "-/1 x" and "max/2 a b".
+func PolishNotation(e Expression) string {
+	var sb strings.Builder
+	writePolish(&sb, e)
+	return sb.String()
+}
+
+func writePolish(sb *strings.Builder, e Expression) {
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	switch v := e.(type) {
+	case *PrefixExpression:
+		sb.WriteString(v.op.String() + "/1")
+		writePolish(sb, v.rhs)
+	case *InfixExpression:
+		sb.WriteString(v.op.String())
+		writePolish(sb, v.lhs)
+		writePolish(sb, v.rhs)
+	case *CallExpression:
+		sb.WriteString(v.name + "/" + strconv.Itoa(len(v.args)))
+		for _, arg := range v.args {
+			writePolish(sb, arg)
+		}
+	default:
+		sb.WriteString(e.getExpressionValue())
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPolishNotation(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"(a + b) * c", "* + a b c"},
+		{"1 - 2 - 3", "- - 1 2 3"},
+		{"2 ^ 3 ^ 2", "^ 2 ^ 3 2"},
+		{"-x + 1", "+ -/1 x 1"},
+		{"max(a, b * 2)", "max/2 a * b 2"},
+		{"f()", "f/0"},
+		{`"s" + 1.5`, `+ "s" 1.5`},
+		{"let(y, 1, y)", "let/3 y 1 y"},
+	}
+	for _, tt := range tests {
+		if got := PolishNotation(mustParse(t, tt.src)); got != tt.want {
+			t.Errorf("PolishNotation(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPNFlag(t *testing.T) {
+	var out bytes.Buffer
+	if code := runEval([]string{"-pn"}, strings.NewReader("(a + b) * -c\n"), &out); code != exitOK || out.String() != "* + a b -/1 c\n" {
+		t.Errorf("-pn exits with %d printing %q", code, out.String())
+	}
+	out.Reset()
+	if code := runEval([]string{"-pn"}, strings.NewReader("a +\n"), &out); code != exitParseError {
+		t.Errorf("-pn of a bad expression exits with %d printing %q", code, out.String())
+	}
+}
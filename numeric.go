@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The rounding functions and sign return a value of the kind they are
+// given: integers are already whole, so only round with negative digits
+// changes them. Rounding is away from zero on ties.
+func init() {
+	addBuiltin("floor", "floor(x number) number", docEntry{"the greatest whole number not above x", "floor(x)"},
+		roundingFunction("floor", math.Floor))
+	addBuiltin("ceil", "ceil(x number) number", docEntry{"the least whole number not below x", "ceil(x)"},
+		roundingFunction("ceil", math.Ceil))
+	addBuiltin("trunc", "trunc(x number) number", docEntry{"x with its fraction removed, rounding towards zero", "trunc(x)"},
+		roundingFunction("trunc", math.Trunc))
+	addBuiltin("round", "round(x number[, digits int]) number", docEntry{"x rounded to digits decimal places, or to tens, hundreds and so on if digits is negative", "round(1234, -2)"},
+		func(args []Value) (Value, error) {
+			if err := numericArgs("round", args, 1, 2); err != nil {
+				return Value{}, err
+			}
+			digits := int64(0)
+			if len(args) == 2 {
+				if args[1].Kind() != IntKind {
+					return Value{}, fmt.Errorf("round: digits must be an integer, not %s", args[1].Kind())
+				}
+				digits = args[1].Int()
+			}
+			if args[0].Kind() == IntKind {
+				return roundInt(args[0].Int(), digits)
+			}
+			return FloatValue(roundFloat(args[0].Float(), digits)), nil
+		})
+	addBuiltin("sign", "sign(x number) number", docEntry{"-1, 0 or 1 as x is negative, zero or positive", "sign(-5)"},
+		func(args []Value) (Value, error) {
+			if err := numericArgs("sign", args, 1, 1); err != nil {
+				return Value{}, err
+			}
+			x, _ := args[0].AsFloat()
+			s := 0
+			switch {
+			case x > 0:
+				s = 1
+			case x < 0:
+				s = -1
+			case math.IsNaN(x):
+				return args[0], nil
+			}
+			if args[0].Kind() == IntKind {
+				return IntValue(int64(s)), nil
+			}
+			return FloatValue(float64(s)), nil
+		})
+	addBuiltin("clamp", "clamp(x, lo, hi number) number", docEntry{"x limited to between lo and hi", "clamp(x, 0, 100)"},
+		func(args []Value) (Value, error) {
+			if err := numericArgs("clamp", args, 3, 3); err != nil {
+				return Value{}, err
+			}
+			x, _ := args[0].AsFloat()
+			lo, _ := args[1].AsFloat()
+			hi, _ := args[2].AsFloat()
+			switch {
+			case lo > hi:
+				return Value{}, fmt.Errorf("clamp: lo %s is greater than hi %s", args[1], args[2])
+			case x < lo:
+				return args[1], nil
+			case x > hi:
+				return args[2], nil
+			}
+			return args[0], nil
+		})
+	addBuiltin("lerp", "lerp(a, b, t number) float", docEntry{"the value t of the way from a to b, which is a when t is 0 and b when t is 1", "lerp(0, 10, t)"},
+		floatFunction("lerp", 3, 3, func(xs []float64) (float64, error) {
+			return xs[0] + (xs[1]-xs[0])*xs[2], nil
+		}))
+}
+
+// numericArgs checks that a function is given between min and max numbers.
+func numericArgs(name string, args []Value, min int, max int) error {
+	switch {
+	case len(args) < min || len(args) > max:
+		if min == 1 && max == 1 {
+			return fmt.Errorf("%s takes one argument", name)
+		}
+		if min == max {
+			return fmt.Errorf("%s takes %d arguments", name, min)
+		}
+		return fmt.Errorf("%s takes %d to %d arguments", name, min, max)
+	case !args[0].IsNumeric():
+		return fmt.Errorf("%s takes numbers, not %s", name, args[0].Kind())
+	}
+	for _, arg := range args[1:] {
+		if !arg.IsNumeric() {
+			return fmt.Errorf("%s takes numbers, not %s", name, arg.Kind())
+		}
+	}
+	return nil
+}
+
+// roundingFunction applies fn to a float argument and returns an integer
+// argument unchanged.
+func roundingFunction(name string, fn func(float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		if err := numericArgs(name, args, 1, 1); err != nil {
+			return Value{}, err
+		}
+		if args[0].Kind() == IntKind {
+			return args[0], nil
+		}
+		return FloatValue(fn(args[0].Float())), nil
+	}
+}
+
+func roundFloat(x float64, digits int64) float64 {
+	if digits > 308 || digits < -308 {
+		if digits > 0 {
+			return x
+		}
+		return math.Copysign(0, x)
+	}
+	scale := math.Pow(10, float64(digits))
+	if scaled := x * scale; !math.IsInf(scaled, 0) {
+		return math.Round(scaled) / scale
+	}
+	return x
+}
+
+// roundInt rounds x to a multiple of 10^-digits if digits is negative.
+func roundInt(x int64, digits int64) (Value, error) {
+	if digits >= 0 {
+		return IntValue(x), nil
+	}
+	if digits < -18 {
+		return IntValue(0), nil
+	}
+	unit := int64(1)
+	for i := int64(0); i < -digits; i++ {
+		unit *= 10
+	}
+	q, r := x/unit, x%unit
+	if r >= unit-r {
+		q++
+	} else if -r >= unit+r {
+		q--
+	}
+	if q > math.MaxInt64/unit || q < math.MinInt64/unit {
+		return Value{}, fmt.Errorf("round overflows an integer")
+	}
+	return IntValue(q * unit), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestNumericFunctions(t *testing.T) {
+	checkEvalCases(t, nil, []evalCase{
+		{src: "floor(2.7)", want: FloatValue(2)},
+		{src: "floor(-2.2)", want: FloatValue(-3)},
+		{src: "floor(5)", want: IntValue(5)},
+		{src: "ceil(2.2)", want: FloatValue(3)},
+		{src: "ceil(-2.7)", want: FloatValue(-2)},
+		{src: "trunc(-2.7)", want: FloatValue(-2)},
+		{src: "trunc(7)", want: IntValue(7)},
+		{src: "round(2.5)", want: FloatValue(3)},
+		{src: "round(-2.5)", want: FloatValue(-3)},
+		{src: "round(3.14159, 2)", want: FloatValue(3.14)},
+		{src: "round(1234.5, -2)", want: FloatValue(1200)},
+		{src: "round(1234, -2)", want: IntValue(1200)},
+		{src: "round(1250, -2)", want: IntValue(1300)},
+		{src: "round(-1250, -2)", want: IntValue(-1300)},
+		{src: "round(-1249, -2)", want: IntValue(-1200)},
+		{src: "round(7, 3)", want: IntValue(7)},
+		{src: "round(7, -19)", want: IntValue(0)},
+		{src: "round(1.5, 400)", want: FloatValue(1.5)},
+		{src: "round(9223372036854775807, -1)", err: "round overflows an integer"},
+		{src: "round(1.5, 1.0)", err: "round: digits must be an integer, not float"},
+		{src: "sign(-5)", want: IntValue(-1)},
+		{src: "sign(0)", want: IntValue(0)},
+		{src: "sign(0.5)", want: FloatValue(1)},
+		{src: "clamp(5, 0, 10)", want: IntValue(5)},
+		{src: "clamp(-5, 0, 10)", want: IntValue(0)},
+		{src: "clamp(15, 0, 10.5)", want: FloatValue(10.5)},
+		{src: "clamp(1, 10, 0)", err: "clamp: lo 10 is greater than hi 0"},
+		{src: "lerp(0, 10, 0.25)", want: FloatValue(2.5)},
+		{src: "lerp(10, 0, 1)", want: FloatValue(0)},
+		{src: "lerp(0, 10, 2)", want: FloatValue(20)},
+		{src: "floor()", err: "floor takes one argument"},
+		{src: "clamp(1, 2)", err: "clamp takes 3 arguments"},
+		{src: "round(1, 2, 3)", err: "round takes 1 to 2 arguments"},
+		{src: `sign("a")`, err: "sign takes numbers, not string"},
+		{src: `clamp(1, 2, "3")`, err: "clamp takes numbers, not string"},
+	})
+}
+
+// Math.round rounds ties towards positive infinity, so round is not
+// translated to it.
+func TestRoundIsNotMathRound(t *testing.T) {
+	if got, err := JS(mustParse(t, "round(x) + floor(x)")); err != nil || got != "round(x) + Math.floor(x)" {
+		t.Errorf("JS(round(x) + floor(x)) = %s, %v", got, err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// The number theory functions take integers. Unlike the arithmetic
+// operators, which wrap, they fail with an error when a result does not fit
+// in an integer.
+func init() {
+	addBuiltin("gcd", "gcd(a, b int, ...) int", docEntry{"the greatest common divisor of its arguments, which is never negative", "gcd(12, 18)"},
+		intFunction("gcd", 2, -1, func(xs []int64) (Value, error) {
+			g := uint64(0)
+			for _, x := range xs {
+				g = gcd(g, absInt(x))
+			}
+			return checkedInt("gcd", g)
+		}))
+	addBuiltin("lcm", "lcm(a, b int, ...) int", docEntry{"the least common multiple of its arguments, or 0 if any is 0", "lcm(4, 6)"},
+		intFunction("lcm", 2, -1, func(xs []int64) (Value, error) {
+			l := uint64(1)
+			for _, x := range xs {
+				a := absInt(x)
+				if a == 0 {
+					return IntValue(0), nil
+				}
+				hi, lo := bits.Mul64(l/gcd(l, a), a)
+				if hi != 0 {
+					return Value{}, fmt.Errorf("lcm overflows an integer")
+				}
+				l = lo
+			}
+			return checkedInt("lcm", l)
+		}))
+	addBuiltin("isprime", "isprime(n int) bool", docEntry{"whether n is a prime number", "isprime(97)"},
+		intFunction("isprime", 1, 1, func(xs []int64) (Value, error) {
+			// ProbablyPrime is exact below 2^64.
+			return BoolValue(xs[0] > 1 && big.NewInt(xs[0]).ProbablyPrime(0)), nil
+		}))
+	addBuiltin("factorial", "factorial(n int) int", docEntry{"the product of the integers from 1 to n", "factorial(5)"},
+		intFunction("factorial", 1, 1, func(xs []int64) (Value, error) {
+			if xs[0] < 0 {
+				return Value{}, fmt.Errorf("factorial of negative number %d", xs[0])
+			}
+			return permutations("factorial", xs[0], xs[0])
+		}))
+	addBuiltin("nCr", "nCr(n, r int) int", docEntry{"the number of ways to choose r of n items, in any order", "nCr(5, 2)"},
+		intFunction("nCr", 2, 2, func(xs []int64) (Value, error) {
+			n, r := xs[0], xs[1]
+			if n < 0 || r < 0 {
+				return Value{}, fmt.Errorf("nCr takes non-negative integers")
+			}
+			if r > n {
+				return IntValue(0), nil
+			}
+			if r > n-r {
+				r = n - r
+			}
+			// C(n, i+1) = C(n, i) * (n-i) / (i+1) exactly, and C(n, i) grows
+			// with i up to r, so the loop stops at the first overflow.
+			c := uint64(1)
+			for i := int64(0); i < r; i++ {
+				hi, lo := bits.Mul64(c, uint64(n-i))
+				if hi >= uint64(i+1) {
+					return Value{}, fmt.Errorf("nCr overflows an integer")
+				}
+				c, _ = bits.Div64(hi, lo, uint64(i+1))
+				if c > math.MaxInt64 {
+					return Value{}, fmt.Errorf("nCr overflows an integer")
+				}
+			}
+			return IntValue(int64(c)), nil
+		}))
+	addBuiltin("nPr", "nPr(n, r int) int", docEntry{"the number of ways to arrange r of n items in order", "nPr(5, 2)"},
+		intFunction("nPr", 2, 2, func(xs []int64) (Value, error) {
+			n, r := xs[0], xs[1]
+			if n < 0 || r < 0 {
+				return Value{}, fmt.Errorf("nPr takes non-negative integers")
+			}
+			if r > n {
+				return IntValue(0), nil
+			}
+			return permutations("nPr", n, r)
+		}))
+}
+
+// intFunction checks that a function is given between min and max integer
+// arguments, or at least min if max is negative, and applies fn to them.
+func intFunction(name string, min int, max int, fn func(xs []int64) (Value, error)) Function {
+	return func(args []Value) (Value, error) {
+		switch {
+		case max < 0 && len(args) < min:
+			return Value{}, fmt.Errorf("%s takes at least %d arguments", name, min)
+		case max >= 0 && (len(args) < min || len(args) > max):
+			if min == max {
+				return Value{}, fmt.Errorf("%s takes %d arguments", name, min)
+			}
+			return Value{}, fmt.Errorf("%s takes %d to %d arguments", name, min, max)
+		}
+		xs := make([]int64, len(args))
+		for i, arg := range args {
+			if arg.Kind() != IntKind {
+				return Value{}, fmt.Errorf("%s takes integers, not %s", name, arg.Kind())
+			}
+			xs[i] = arg.Int()
+		}
+		return fn(xs)
+	}
+}
+
+// permutations returns n * (n-1) * ... * (n-r+1), which overflows within
+// 64 factors unless it reaches 1 first.
+func permutations(name string, n int64, r int64) (Value, error) {
+	p := uint64(1)
+	for i := int64(0); i < r; i++ {
+		hi, lo := bits.Mul64(p, uint64(n-i))
+		if hi != 0 || lo > math.MaxInt64 {
+			return Value{}, fmt.Errorf("%s overflows an integer", name)
+		}
+		p = lo
+	}
+	return IntValue(int64(p)), nil
+}
+
+func checkedInt(name string, x uint64) (Value, error) {
+	if x > math.MaxInt64 {
+		return Value{}, fmt.Errorf("%s overflows an integer", name)
+	}
+	return IntValue(int64(x)), nil
+}
+
+// absInt returns |x|, which fits in a uint64 even for math.MinInt64.
+func absInt(x int64) uint64 {
+	if x < 0 {
+		return uint64(-(x + 1)) + 1
+	}
+	return uint64(x)
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestNumberTheory(t *testing.T) {
+	checkEvalCases(t, nil, []evalCase{
+		{src: "gcd(12, 18)", want: IntValue(6)},
+		{src: "gcd(-12, 18, 8)", want: IntValue(2)},
+		{src: "gcd(0, 0)", want: IntValue(0)},
+		{src: "gcd(-9223372036854775807 - 1, 0)", err: "gcd overflows an integer"},
+		{src: "lcm(4, 6)", want: IntValue(12)},
+		{src: "lcm(4, -6, 10)", want: IntValue(60)},
+		{src: "lcm(4, 0)", want: IntValue(0)},
+		{src: "lcm(4294967296, 4294967295)", err: "lcm overflows an integer"},
+		{src: "isprime(97)", want: BoolValue(true)},
+		{src: "isprime(91)", want: BoolValue(false)},
+		{src: "isprime(2)", want: BoolValue(true)},
+		{src: "isprime(1)", want: BoolValue(false)},
+		{src: "isprime(-7)", want: BoolValue(false)},
+		{src: "isprime(9223372036854775783)", want: BoolValue(true)},
+		{src: "factorial(0)", want: IntValue(1)},
+		{src: "factorial(5)", want: IntValue(120)},
+		{src: "factorial(20)", want: IntValue(2432902008176640000)},
+		{src: "factorial(21)", err: "factorial overflows an integer"},
+		{src: "factorial(-1)", err: "factorial of negative number -1"},
+		{src: "nCr(5, 2)", want: IntValue(10)},
+		{src: "nCr(5, 0)", want: IntValue(1)},
+		{src: "nCr(2, 5)", want: IntValue(0)},
+		{src: "nCr(66, 33)", want: IntValue(7219428434016265740)},
+		{src: "nCr(67, 33)", err: "nCr overflows an integer"},
+		{src: "nCr(-1, 1)", err: "nCr takes non-negative integers"},
+		{src: "nPr(5, 2)", want: IntValue(20)},
+		{src: "nPr(5, 6)", want: IntValue(0)},
+		{src: "nPr(100, 10)", err: "nPr overflows an integer"},
+		{src: "gcd(1)", err: "gcd takes at least 2 arguments"},
+		{src: "nCr(1, 2, 3)", err: "nCr takes 2 arguments"},
+		{src: "isprime(7.0)", err: "isprime takes integers, not float"},
+	})
+}
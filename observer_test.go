@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordingObserver records the evaluation events it is told about.
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) OnNodeStart(e Expression) {
+	o.events = append(o.events, "start "+Format(e))
+}
+
+func (o *recordingObserver) OnNodeResult(e Expression, value Value, err error, duration time.Duration) {
+	if duration < 0 {
+		o.events = append(o.events, fmt.Sprintf("negative duration for %s", Format(e)))
+	}
+	if err != nil {
+		o.events = append(o.events, "fail "+Format(e))
+		return
+	}
+	o.events = append(o.events, fmt.Sprintf("end %s = %s", Format(e), value))
+}
+
+func TestObserver(t *testing.T) {
+	env := Env{
+		"x": IntValue(4),
+		"f": FuncValue(func(args []Value) (Value, error) { return args[0], nil }),
+	}
+	tests := []struct {
+		src    string
+		events []string
+	}{
+		{"f(x) - 1", []string{
+			"start f(x) - 1",
+			"start f(x)",
+			"start x",
+			"end x = 4",
+			"end f(x) = 4",
+			"start 1",
+			"end 1 = 1",
+			"end f(x) - 1 = 3",
+		}},
+		{"x / 0 + y", []string{
+			"start x / 0 + y",
+			"start x / 0",
+			"start x",
+			"end x = 4",
+			"start 0",
+			"end 0 = 0",
+			"fail x / 0",
+			"fail x / 0 + y",
+		}},
+	}
+	for _, tt := range tests {
+		observer := &recordingObserver{}
+		for _, b := range allBackends {
+			observer.events = nil
+			NewEvaluator(env, WithObserver(observer), WithBackend(b)).Eval(mustParse(t, tt.src))
+			if !equalStrings(observer.events, tt.events) {
+				t.Errorf("with the %s backend, observing %s gives %q, want %q", b, tt.src, observer.events, tt.events)
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// OperatorSpec declares an operator in a grammar file. Precedence levels
+// are those of RegisterOperator, and Aliases are other spellings as for
+// RegisterAlias. Doc and Example document the operator as for
+// DocumentOperator.
+// Eval names the function that evaluates the operator, looked up in the
+// environment and then among operatorFunctions; an empty Eval keeps the
+// built-in evaluation of an existing operator.
+type OperatorSpec struct {
+	Symbol        string   `json:"symbol"`
+	Arity         int      `json:"arity"`
+	Precedence    int      `json:"precedence"`
+	Associativity string   `json:"associativity"`
+	Eval          string   `json:"eval"`
+	Aliases       []string `json:"aliases"`
+	Doc           string   `json:"doc"`
+	Example       string   `json:"example"`
+}
+
+type GrammarSpec struct {
+	Operators []OperatorSpec `json:"operators"`
+}
+
+// operatorFunctions are the functions operators may be evaluated with
+// without the host providing them.
+var operatorFunctions = map[string]Function{
+	"mod": func(args []Value) (Value, error) {
+		a, b, err := intOperands("mod", args)
+		if err != nil {
+			return Value{}, err
+		}
+		if b == 0 {
+			return Value{}, ErrDivisionByZero
+		}
+		return IntValue(a % b), nil
+	},
+	"pow": func(args []Value) (Value, error) {
+		if len(args) != 2 || !args[0].IsNumeric() || !args[1].IsNumeric() {
+			return Value{}, fmt.Errorf("pow takes two numbers")
+		}
+		a, _ := args[0].AsFloat()
+		b, _ := args[1].AsFloat()
+		return FloatValue(math.Pow(a, b)), nil
+	},
+	"min": func(args []Value) (Value, error) {
+		return pick(args, func(a, b float64) bool { return a < b })
+	},
+	"max": func(args []Value) (Value, error) {
+		return pick(args, func(a, b float64) bool { return a > b })
+	},
+}
+
+// functionSignatures describes the arguments of operatorFunctions, for
+// hints while typing a call.
+var functionSignatures = map[string]string{
+	"mod": "mod(a, b int) int",
+	"pow": "pow(x, y number) float",
+	"min": "min(a, b number) number",
+	"max": "max(a, b number) number",
+}
+
+func intOperands(name string, args []Value) (int64, int64, error) {
+	if len(args) != 2 || args[0].Kind() != IntKind || args[1].Kind() != IntKind {
+		return 0, 0, fmt.Errorf("%s takes two integers", name)
+	}
+	return args[0].Int(), args[1].Int(), nil
+}
+
+// pick returns the first of two numbers unless better prefers the second.
+func pick(args []Value, better func(a, b float64) bool) (Value, error) {
+	if len(args) != 2 || !args[0].IsNumeric() || !args[1].IsNumeric() {
+		return Value{}, fmt.Errorf("expected two numbers")
+	}
+	a, _ := args[0].AsFloat()
+	b, _ := args[1].AsFloat()
+	if better(b, a) {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// operatorFunction returns the name of the function evaluating op in the
+// given position, or "" if op is evaluated natively.
+func operatorFunction(op OpKind, infix bool) string {
+	if op < 0 || int(op) >= len(opKindSymbols) {
+		return ""
+	}
+	if infix {
+		return infixRules[op].eval
+	}
+	return prefixRules[op].eval
+}
+
+func callOperator(name string, pos int, env Env, args []Value) (Value, error) {
+	if _, ok := env[name]; ok {
+		return callFunction(name, pos, env, args)
+	}
+	fn, ok := operatorFunctions[name]
+	if !ok {
+		return Value{}, evalError(pos, nil, CodeUndefinedFunction, name)
+	}
+	return fn(args)
+}
+
+// LoadOperators reads a JSON GrammarSpec from r and registers its
+// operators, so DSLs can define operators without recompiling. Nothing is
+// registered unless the whole spec is valid. Like the Register functions,
+// it must not be called while parsing.
+func LoadOperators(r io.Reader) error {
+	var spec GrammarSpec
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return fmt.Errorf("reading operators: %w", err)
+	}
+	// spellings maps each symbol and alias in the spec to the symbol it
+	// spells, so two operators cannot claim the same text.
+	spellings := make(map[string]string)
+	for i, op := range spec.Operators {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("operator %d: %w", i+1, err)
+		}
+		if symbol, ok := spellings[op.Symbol]; ok && symbol != op.Symbol {
+			return fmt.Errorf("operator %d: symbol %q is already an alias of '%s'", i+1, op.Symbol, symbol)
+		}
+		spellings[op.Symbol] = op.Symbol
+		for _, alias := range op.Aliases {
+			if symbol, ok := spellings[alias]; ok && symbol != op.Symbol {
+				return fmt.Errorf("operator %d: alias %q already spells '%s'", i+1, alias, symbol)
+			}
+			spellings[alias] = op.Symbol
+		}
+	}
+	for _, op := range spec.Operators {
+		op.register()
+	}
+	return nil
+}
+
+func LoadOperatorsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadOperators(f)
+}
+
+func (s OperatorSpec) validate() error {
+	if !isOperatorSymbol(s.Symbol) {
+		return fmt.Errorf("symbol %q must be ASCII punctuation", s.Symbol)
+	}
+	if op := lookupOperator(s.Symbol); op != NoOp && op.String() != s.Symbol {
+		return fmt.Errorf("symbol %q is already an alias of '%s'", s.Symbol, op)
+	}
+	switch s.Symbol {
+	case "(", ")", ",":
+		return fmt.Errorf("symbol %q cannot be redefined", s.Symbol)
+	}
+	if s.Arity != 1 && s.Arity != 2 {
+		return fmt.Errorf("arity must be 1 or 2, not %d", s.Arity)
+	}
+	if s.Precedence < -3 {
+		return fmt.Errorf("precedence must be at least -3")
+	}
+	switch s.Associativity {
+	case "", "left":
+	case "right":
+		if s.Arity == 1 {
+			return fmt.Errorf("prefix operators have no associativity")
+		}
+	default:
+		return fmt.Errorf("associativity must be \"left\" or \"right\", not %q", s.Associativity)
+	}
+	if s.Eval == "" {
+		if !isBuiltinOperator(s.Symbol, s.Arity) {
+			return fmt.Errorf("'%s' needs an eval function", s.Symbol)
+		}
+	}
+	for _, alias := range s.Aliases {
+		if !isOperatorAlias(alias) {
+			return fmt.Errorf("invalid alias %q", alias)
+		}
+		if op := lookupOperator(alias); op != NoOp && op.String() != s.Symbol {
+			return fmt.Errorf("alias %q already spells '%s'", alias, op)
+		}
+	}
+	return nil
+}
+
+// isBuiltinOperator reports whether symbol is natively evaluated with the
+// given arity.
+func isBuiltinOperator(symbol string, arity int) bool {
+	op := lookupOperator(symbol)
+	if arity == 1 {
+		return op == AddOp || op == SubOp || op == NotOp
+	}
+	switch op {
+	case AddOp, SubOp, MulOp, DivOp, PowOp, MatchOp, NotMatchOp,
+		EqOp, NeOp, LtOp, LeOp, GtOp, GeOp, AndOp, OrOp, ShlOp, ShrOp:
+		return true
+	}
+	return false
+}
+
+func (s OperatorSpec) register() {
+	defer func() {
+		for _, alias := range s.Aliases {
+			RegisterAlias(alias, s.Symbol)
+		}
+		if s.Doc != "" || s.Example != "" {
+			DocumentOperator(s.Symbol, s.Arity, s.Doc, s.Example)
+		}
+	}()
+	if s.Arity == 1 {
+		bp, _ := bindingPowers(s.Precedence, LeftAssociative)
+		op := RegisterPrefix(s.Symbol, bp, nil)
+		prefixRules[op].eval = s.Eval
+		return
+	}
+	assoc := LeftAssociative
+	if s.Associativity == "right" {
+		assoc = RightAssociative
+	}
+	op := RegisterOperator(s.Symbol, s.Precedence, assoc, nil)
+	infixRules[op].eval = s.Eval
+}
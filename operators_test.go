@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadOperators(t *testing.T) {
+	defer saveGrammar()()
+	err := LoadOperators(strings.NewReader(`{"operators": [
+		{"symbol": "%%", "arity": 2, "precedence": 2, "eval": "mod", "aliases": ["⊘"], "doc": "remainder"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{"7 %% 3", "7 ⊘ 3"} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", src, err)
+		}
+		got, err := NewEvaluator(nil).Eval(e)
+		if err != nil || !got.Equal(IntValue(1)) {
+			t.Errorf("%s = %s, %v, want 1", src, got, err)
+		}
+	}
+}
+
+func TestLoadOperatorsRejectsInvalidSpecs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"shared alias", `{"operators": [
+			{"symbol": "<~", "arity": 2, "precedence": 1, "eval": "min", "aliases": ["≼"]},
+			{"symbol": "~>", "arity": 2, "precedence": 1, "eval": "max", "aliases": ["≼"]}
+		]}`, `alias "≼" already spells '<~'`},
+		{"alias of another symbol", `{"operators": [
+			{"symbol": "<~", "arity": 2, "precedence": 1, "eval": "min"},
+			{"symbol": "~>", "arity": 2, "precedence": 1, "eval": "max", "aliases": ["<~"]}
+		]}`, `alias "<~" already spells '<~'`},
+		{"symbol already an alias", `{"operators": [
+			{"symbol": "~>", "arity": 2, "precedence": 1, "eval": "max", "aliases": ["<~"]},
+			{"symbol": "<~", "arity": 2, "precedence": 1, "eval": "min"}
+		]}`, `symbol "<~" is already an alias of '~>'`},
+		{"alias of a registered operator", `{"operators": [
+			{"symbol": "<~", "arity": 2, "precedence": 1, "eval": "min", "aliases": ["×"]}
+		]}`, `alias "×" already spells '*'`},
+		{"registered alias as symbol", `{"operators": [
+			{"symbol": "**", "arity": 2, "precedence": 1, "eval": "min"}
+		]}`, `symbol "**" is already an alias of '^'`},
+		{"missing eval", `{"operators": [
+			{"symbol": "<~", "arity": 2, "precedence": 1}
+		]}`, "needs an eval function"},
+		{"bad arity", `{"operators": [
+			{"symbol": "<~", "arity": 3, "precedence": 1, "eval": "min"}
+		]}`, "arity must be 1 or 2"},
+		{"unknown field", `{"operators": [
+			{"symbol": "<~", "arity": 2, "precedence": 1, "eval": "min", "bp": 3}
+		]}`, "unknown field"},
+	}
+	for _, tt := range tests {
+		err := LoadOperators(strings.NewReader(tt.spec))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: LoadOperators = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		for _, symbol := range []string{"<~", "~>", "≼"} {
+			if op := lookupOperator(symbol); op != NoOp {
+				t.Errorf("%s: %q registered by an invalid spec", tt.name, symbol)
+			}
+		}
+	}
+}
+
+func TestLoadOperatorsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operators.json")
+	spec := `{"operators": [
+		{"symbol": "^^", "arity": 2, "precedence": 4, "associativity": "right", "eval": "pow"},
+		{"symbol": "?", "arity": 1, "precedence": 3, "eval": "neg"}
+	]}`
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer saveGrammar()()
+	if err := LoadOperatorsFile(path); err != nil {
+		t.Fatal(err)
+	}
+	env := Env{
+		"neg": FuncValue(func(args []Value) (Value, error) {
+			return applyPrefix(SubOp, args[0])
+		}),
+	}
+	tests := []struct {
+		src    string
+		parens string
+		want   Value
+	}{
+		{"2 ^^ 3 ^^ 2", "(2 ^^ (3 ^^ 2))", FloatValue(512)},
+		{"?2 * 3", "((?2) * 3)", IntValue(-6)},
+		{"1 + ?2 ^^ 2", "(1 + (?(2 ^^ 2)))", FloatValue(-3)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := parenthesize(e); got != tt.parens {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.parens)
+		}
+		if got, err := NewEvaluator(env).Eval(e); err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+	e, err := Parse("?1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEvaluator(nil).Eval(e); err == nil || !strings.Contains(err.Error(), "neg") {
+		t.Errorf("?1 without neg fails with %v", err)
+	}
+	if err := LoadOperatorsFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("loaded operators from a missing file")
+	}
+}
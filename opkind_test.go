@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestOpKinds(t *testing.T) {
+	for op := AddOp; op <= ArrowOp; op++ {
+		symbol := op.String()
+		if got := lookupOperator(symbol); got != op {
+			t.Errorf("lookupOperator(%q) = %s, want %s", symbol, got, op)
+		}
+		if got, size := operatorAt(symbol + "1"); got != op || size != len(symbol) {
+			t.Errorf("operatorAt(%q) = %s, %d, want %s, %d", symbol+"1", got, size, op, len(symbol))
+		}
+	}
+	tests := []struct {
+		text string
+		want OpKind
+	}{
+		{"", NoOp},
+		{"**", PowOp},
+		{"×", MulOp},
+		{"<=>", NoOp},
+		{"@", NoOp},
+	}
+	for _, tt := range tests {
+		if got := lookupOperator(tt.text); got != tt.want {
+			t.Errorf("lookupOperator(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+	if got := OpKind(-1).String(); got != "op(-1)" {
+		t.Errorf("OpKind(-1).String() = %s, want op(-1)", got)
+	}
+}
+
+func TestDispatchOutOfRange(t *testing.T) {
+	if o, _ := infixOverload(OpKind(1000), IntKind, IntKind); o.infix != nil {
+		t.Errorf("found an implementation of an unknown operator")
+	}
+	if o := prefixOverload(SubOp, Kind(100)); o.prefix != nil {
+		t.Errorf("found an implementation for an unknown kind")
+	}
+	if _, err := applyInfix(OpKind(1000), IntValue(1), IntValue(2)); err == nil {
+		t.Errorf("applied an unknown operator")
+	}
+}
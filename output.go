@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithOutput sends what print and debug write to w. They write nothing
+// otherwise, or when a backend other than the tree walker evaluates them,
+// so WithOutput makes the Evaluator walk the tree. Both return their
+// argument, and an environment defining either name replaces it as usual.
+func WithOutput(w io.Writer) EvalOption {
+	return func(ev *Evaluator) {
+		ev.output = w
+	}
+}
+
+func init() {
+	addBuiltin("print", "print(x) any", docEntry{"x, after writing it to the evaluator's output, with strings unquoted", `print("total: " + str(total))`},
+		outputFunction("print"))
+	addBuiltin("debug", "debug(x) any", docEntry{"x, after writing its source and value to the evaluator's output", "debug(price * qty)"},
+		outputFunction("debug"))
+}
+
+// outputFunction returns the argument of print or debug, which is all they
+// do without an output to write to.
+func outputFunction(name string) Function {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return Value{}, fmt.Errorf("%s takes one argument", name)
+		}
+		return args[0], nil
+	}
+}
+
+// isOutputCall reports whether e is a call of print or debug that writes
+// to the evaluation's output.
+func (ev *evaluation) isOutputCall(e *CallExpression) bool {
+	if ev.output == nil || len(e.args) != 1 || (e.name != "print" && e.name != "debug") {
+		return false
+	}
+	_, defined := ev.env[e.name]
+	return !defined
+}
+
+func (ev *evaluation) evalOutput(e *CallExpression) (Value, error) {
+	value, err := ev.eval(e.args[0])
+	if err != nil {
+		return Value{}, err
+	}
+	if e.name == "debug" {
+		fmt.Fprintf(ev.output, "%s = %s\n", Format(e.args[0]), value)
+	} else if text, err := value.AsString(); err == nil {
+		fmt.Fprintln(ev.output, text)
+	} else {
+		fmt.Fprintln(ev.output, value)
+	}
+	ev.record(e, []Value{value}, value)
+	return value, nil
+}
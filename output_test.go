@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	env := Env{"price": FloatValue(2.5), "qty": IntValue(4), "name": StringValue("tea")}
+	tests := []struct {
+		src     string
+		want    Value
+		printed string
+	}{
+		{`print("total: " + str(price * qty))`, StringValue("total: 10"), "total: 10\n"},
+		{"print(qty) + 1", IntValue(5), "4\n"},
+		{"debug(price * qty) / 2", FloatValue(5), "price * qty = 10\n"},
+		{"debug(name)", StringValue("tea"), `name = "tea"` + "\n"},
+		{"print(print(qty))", IntValue(4), "4\n4\n"},
+		{`debug(print("a") + "b")`, StringValue("ab"), "a\n" + `print("a") + "b" = "ab"` + "\n"},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		for _, b := range allBackends {
+			var out strings.Builder
+			got, err := NewEvaluator(env, WithBackend(b), WithOutput(&out)).Eval(e)
+			if err != nil || !got.Equal(tt.want) || out.String() != tt.printed {
+				t.Errorf("%s with the %s backend = %s, %v printing %q, want %s printing %q", tt.src, b, got, err, out.String(), tt.want, tt.printed)
+			}
+			// Without an output they only return their argument.
+			if got, err := NewEvaluator(env, WithBackend(b)).Eval(e); err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend and no output = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	if _, err := NewEvaluator(nil).Eval(mustParse(t, "print(1, 2)")); err == nil || !strings.Contains(err.Error(), "print takes one argument") {
+		t.Errorf("print(1, 2) = %v", err)
+	}
+}
+
+func TestOutputDefersToEnv(t *testing.T) {
+	env := Env{"print": FuncValue(func(args []Value) (Value, error) {
+		return IntValue(0), nil
+	})}
+	var out strings.Builder
+	got, err := NewEvaluator(env, WithOutput(&out)).Eval(mustParse(t, "print(7)"))
+	if err != nil || !got.Equal(IntValue(0)) || out.Len() != 0 {
+		t.Errorf("print(7) with print in the environment = %s, %v printing %q", got, err, out.String())
+	}
+}
+
+func TestOutputInCommands(t *testing.T) {
+	got := replTranscript(t, "debug(2 * 3) + 1\nprint(1 / 0)\n")
+	want := "2 * 3 = 6\n_1 = 7\ndivision by zero at column 9\n"
+	if got != want {
+		t.Errorf("transcript is\n%s\nwant\n%s", got, want)
+	}
+	var out strings.Builder
+	if code := runEval(nil, strings.NewReader(`print("hi") + "!"`+"\n"), &out); code != exitOK || out.String() != "hi\n\"hi!\"\n" {
+		t.Errorf("eval exits with %d printing %q", code, out.String())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// Expected is a set of token classes that may come next in the input.
+type Expected int
+
+const (
+	ExpectOperand Expected = 1 << iota
+	ExpectOperator
+	ExpectComma
+	ExpectCloseParen
+	ExpectEnd
+)
+
+var expectedNames = []struct {
+	flag Expected
+	name string
+}{
+	{ExpectOperand, "operand"},
+	{ExpectOperator, "operator"},
+	{ExpectComma, "','"},
+	{ExpectCloseParen, "')'"},
+	{ExpectEnd, "end of input"},
+}
+
+func (e Expected) String() string {
+	var names []string
+	for _, n := range expectedNames {
+		if e&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "nothing"
+	}
+	return strings.Join(names, "|")
+}
+
+// expect records what may follow the end of the input. Only the innermost
+// expression reaching the end records, so outer levels do not overwrite it.
+func (l *Lexer) expect(e Expected) {
+	if l.expected == 0 {
+		l.expected = e
+	}
+}
+
+// closer returns what may close the innermost open group or call.
+func (l *Lexer) closer() Expected {
+	if len(l.closers) == 0 {
+		return ExpectEnd
+	}
+	return l.closers[len(l.closers)-1]
+}
+
+// ParsePartial parses src as the text before an editor's cursor. Missing
+// operands become holes and unclosed groups and calls are closed, so a
+// best-effort tree is returned along with what may be typed next. Errors
+// other than running out of input are still reported.
+func ParsePartial(src string, opts ...ParseOption) (Expression, Expected, error) {
+	l := lexerPool.Get().(*Lexer)
+	defer releaseLexer(l)
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.partial = true
+	expr, err := parseSource(l, src)
+	if err != nil {
+		return nil, 0, err
+	}
+	return expr, l.expected, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestParsePartial(t *testing.T) {
+	tests := []struct {
+		src      string
+		format   string
+		expected string
+	}{
+		{"", "_", "operand"},
+		{"1 +", "1 + _", "operand"},
+		{"(a", "a", "operator|')'"},
+		{"f(a", "f(a)", "operator|','|')'"},
+		{"f(a,", "f(a, _)", "operand"},
+		{"1 + 2", "1 + 2", "operator|end of input"},
+		{"(1 + 2) *", "(1 + 2) * _", "operand"},
+	}
+	for _, tt := range tests {
+		e, expected, err := ParsePartial(tt.src)
+		if err != nil {
+			t.Errorf("ParsePartial(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Format(e); got != tt.format || expected.String() != tt.expected {
+			t.Errorf("ParsePartial(%q) = %s, %s, want %s, %s", tt.src, got, expected, tt.format, tt.expected)
+		}
+	}
+	if _, _, err := ParsePartial("1 + )"); err == nil {
+		t.Errorf("ParsePartial(1 + )) did not fail")
+	}
+}
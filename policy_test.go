@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{
+		AllowOperators: []string{"+", "-", "*", "<", "&&"},
+		AllowFunctions: []string{"min", "max"},
+		DenyFunctions:  []string{"max"},
+	}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"min(a, 2) * -b", ""},
+		{"a / b", "operator '/' is not permitted at column 3"},
+		{"a + max(1, 2)", "function 'max' is not permitted at column 5"},
+		{"1 < 2 && sqrt(4) < 3", "function 'sqrt' is not permitted at column 10"},
+		{"{ t = a * 2; t + t }", ""},
+		{"{ sq = fn(x, x * x); sq(a) }", ""},
+		{"{ sq = fn(x, x ^ 2); sq(a) }", "operator '^' is not permitted at column 16"},
+		{"{ sq = fn(x, x * x); sq(a) } + sq(a)", "function 'sq' is not permitted at column 32"},
+		{"try(min(a), max(a))", "function 'max' is not permitted at column 13"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = policy.Check(e)
+		if got := errorString(err); got != tt.want {
+			t.Errorf("Check(%s) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestWithPolicy(t *testing.T) {
+	e, err := Parse("a * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := Env{"a": IntValue(3)}
+	if _, err := NewEvaluator(env, WithPolicy(Policy{DenyOperators: []string{"*"}})).Eval(e); err == nil {
+		t.Errorf("a * 2 evaluated despite the policy denying '*'")
+	}
+	if got, err := NewEvaluator(env, WithPolicy(Policy{DenyOperators: []string{"/"}})).Eval(e); err != nil || !got.Equal(IntValue(6)) {
+		t.Errorf("a * 2 = %s, %v, want 6", got, err)
+	}
+}
+
+// errorString returns err's message, or "" for a nil error.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,61 @@
+package main
+
+// The assertions below let tests of operators and functions registered on
+// top of the built-in grammar check that the printer, parser and evaluators
+// agree on it.
+
+// TB is the part of testing.TB the assertions use. A *testing.T satisfies
+// it, and the command does not link the testing package.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertRoundTrip fails t unless src parses with opts to a tree that Format
+// and MinifyExpr both render as text parsing back to the same tree.
+func AssertRoundTrip(t TB, src string, opts ...ParseOption) {
+	t.Helper()
+	e, err := Parse(src, opts...)
+	if err != nil {
+		t.Errorf("parsing %s: %v", src, err)
+		return
+	}
+	for _, text := range []string{Format(e), MinifyExpr(e)} {
+		parsed, err := Parse(text, opts...)
+		if err != nil {
+			t.Errorf("round trip of %s through %s: %v", src, text, err)
+		} else if !equalExpr(e, parsed) {
+			t.Errorf("round trip of %s: %s reparsed as %s", src, text, Format(parsed))
+		}
+	}
+}
+
+// AssertEvalEquals fails t unless src, parsed with opts, evaluates to want
+// against env with every backend, and its formatted text does too.
+func AssertEvalEquals(t TB, env Env, src string, want Value, opts ...ParseOption) {
+	t.Helper()
+	e, err := Parse(src, opts...)
+	if err != nil {
+		t.Errorf("parsing %s: %v", src, err)
+		return
+	}
+	for _, b := range []Backend{TreeBackend, StackBackend, RegisterBackend} {
+		got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+		if err != nil {
+			t.Errorf("%s with the %s backend: %v", src, b, err)
+		} else if !got.Equal(want) {
+			t.Errorf("%s with the %s backend = %s, want %s", src, b, got, want)
+		}
+	}
+	text := Format(e)
+	formatted, err := Parse(text, opts...)
+	if err != nil {
+		t.Errorf("parsing %s, formatted from %s: %v", text, src, err)
+		return
+	}
+	if got, err := NewEvaluator(env).Eval(formatted); err != nil {
+		t.Errorf("%s, formatted from %s: %v", text, src, err)
+	} else if !got.Equal(want) {
+		t.Errorf("%s, formatted from %s, = %s, want %s", text, src, got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingTB records the failures reported to it instead of failing.
+type recordingTB struct {
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	AssertRoundTrip(t, "a - (b - c) * 2 ^ -x")
+	AssertRoundTrip(t, "{ t = a * b; t + t }")
+	AssertEvalEquals(t, Env{"a": IntValue(3)}, "{ t = a * 2; t - 1 }", IntValue(5))
+	AssertEvalEquals(t, nil, "7 / 2.0", FloatValue(3.5))
+
+	r := &recordingTB{}
+	AssertRoundTrip(r, "1 +")
+	AssertEvalEquals(r, nil, "1 + 1", IntValue(3))
+	if len(r.failures) != 5 || !strings.HasPrefix(r.failures[0], "parsing 1 +") || !strings.Contains(r.failures[1], "= 2, want 3") {
+		t.Errorf("failures reported: %q", r.failures)
+	}
+}
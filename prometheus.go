@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prometheusBuckets are the upper bounds in seconds of the histogram
+// buckets timings are counted in, from a microsecond to a second.
+var prometheusBuckets = []float64{1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1, 1}
+
+// PrometheusMetrics is a Metrics that keeps what it is given in memory and
+// serves it in the Prometheus text format, for mounting at /metrics. Each
+// counter is exported as prattcalc_<name>_total, with its labels, as in
+// prattcalc_eval_errors_total{kind="division_by_zero"}, and each timing as
+// a histogram in seconds named prattcalc_<name>_seconds, so the cache's hit
+// rate, for one, is the rate of cache_hits over that of both cache_hits and
+// cache_misses.
+type PrometheusMetrics struct {
+	mu sync.Mutex
+	// counters holds the counters by name and then by their labels as
+	// written in the text format.
+	counters map[string]map[string]int64
+	timings  map[string]*histogram
+}
+
+type histogram struct {
+	buckets []int64
+	count   int64
+	sum     float64
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		counters: make(map[string]map[string]int64),
+		timings:  make(map[string]*histogram),
+	}
+}
+
+func (m *PrometheusMetrics) Count(name string, delta int64, labels ...Label) {
+	key := prometheusLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counter, ok := m.counters[name]
+	if !ok {
+		counter = make(map[string]int64)
+		m.counters[name] = counter
+	}
+	counter[key] += delta
+}
+
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusLabels writes labels as they follow a metric's name in the text
+// format, in order of name, or returns "" if there are none.
+func prometheusLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	pairs := make([]string, len(sorted))
+	for i, label := range sorted {
+		pairs[i] = label.Name + `="` + prometheusEscaper.Replace(label.Value) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *PrometheusMetrics) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.timings[name]
+	if !ok {
+		h = &histogram{buckets: make([]int64, len(prometheusBuckets))}
+		m.timings[name] = h
+	}
+	seconds := d.Seconds()
+	for i, bound := range prometheusBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format, in order
+// of name.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	m.mu.Lock()
+	counters := make([]string, 0, len(m.counters))
+	for name := range m.counters {
+		counters = append(counters, name)
+	}
+	sort.Strings(counters)
+	for _, name := range counters {
+		metric := "prattcalc_" + name + "_total"
+		fmt.Fprintf(&sb, "# TYPE %s counter\n", metric)
+		for _, labels := range sortedKeys(m.counters[name]) {
+			fmt.Fprintf(&sb, "%s%s %d\n", metric, labels, m.counters[name][labels])
+		}
+	}
+	timings := make([]string, 0, len(m.timings))
+	for name := range m.timings {
+		timings = append(timings, name)
+	}
+	sort.Strings(timings)
+	for _, name := range timings {
+		h := m.timings[name]
+		metric := "prattcalc_" + name + "_seconds"
+		fmt.Fprintf(&sb, "# TYPE %s histogram\n", metric)
+		for i, bound := range prometheusBuckets {
+			fmt.Fprintf(&sb, "%s_bucket{le=\"%s\"} %d\n", metric, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&sb, "%s_bucket{le=\"+Inf\"} %d\n", metric, h.count)
+		fmt.Fprintf(&sb, "%s_sum %s\n%s_count %d\n", metric, strconv.FormatFloat(h.sum, 'g', -1, 64), metric, h.count)
+	}
+	m.mu.Unlock()
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
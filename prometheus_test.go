@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	m := NewPrometheusMetrics()
+	ev := NewEvaluator(Env{"s": StringValue(`a"b`)}, WithEvalMetrics(m))
+	for _, src := range []string{"1 + 1", "1 / 0", "2 / 0", "missing", "s + 1"} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev.Eval(e)
+	}
+	m.Count("custom", 1, Label{Name: "z", Value: "1"}, Label{Name: "a", Value: "x\"y\\z\n"})
+	var sb strings.Builder
+	if _, err := m.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		"# TYPE prattcalc_custom_total counter\nprattcalc_custom_total{a=\"x\\\"y\\\\z\\n\",z=\"1\"} 1\n",
+		"# TYPE prattcalc_eval_errors_total counter\n" +
+			"prattcalc_eval_errors_total{kind=\"division_by_zero\"} 2\n" +
+			"prattcalc_eval_errors_total{kind=\"type_mismatch\"} 1\n" +
+			"prattcalc_eval_errors_total{kind=\"undefined_variable\"} 1\n",
+		"# TYPE prattcalc_evals_total counter\nprattcalc_evals_total 5\n",
+		"prattcalc_eval_duration_seconds_count 5\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	m := NewPrometheusMetrics()
+	now := time.Unix(0, 0)
+	l := NewLimiter(m, PerClientRate(1, 1), LimitMetrics(m))
+	l.now = func() time.Time {
+		return now
+	}
+	serve(l, "10.0.0.1:1000")
+	serve(l, "10.0.0.1:1000")
+	w := serve(l, "10.0.0.2:1000")
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics request = %d", w.Code)
+	}
+	for _, want := range []string{
+		"prattcalc_requests_total{outcome=\"rate_limited\"} 1\n",
+		"prattcalc_requests_total{outcome=\"served\"} 2\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled selector locating subexpressions, in a small subset
+// of XPath:
+//
+//	//infix[op='/']/rhs[kind='literal' and value=0]
+//
+// Steps are separated by / for children or // for descendants; a query
+// without a leading slash searches all descendants. A step matches nodes
+// by kind (infix, prefix, call, literal, identifier, hole), by their role
+// under their parent (lhs, rhs, arg), or * for any node. Predicates in
+// brackets compare attributes with =, !=, <, <=, > and >=, combined with
+// and and or, where and binds tighter. The attributes are kind, role, op,
+// name, value, depth (from 1 at the root), index (of an argument), args
+// (the argument count of a call) and pos. A bare attribute is true when
+// the node has it.
+type Query struct {
+	src   string
+	steps []queryStep
+}
+
+type queryStep struct {
+	descendant bool
+	test       string
+	predicates []queryPredicate
+}
+
+// queryPredicate is a disjunction of conjunctions of comparisons.
+type queryPredicate [][]queryComparison
+
+type queryComparison struct {
+	attr  string
+	op    string
+	value queryValue
+}
+
+// queryValue is an attribute value or a literal in a query. Attributes that
+// a node lacks are represented by ok being false.
+type queryValue struct {
+	str     string
+	num     int64
+	numeric bool
+	ok      bool
+}
+
+// queryNode is an expression with its place in the tree being searched.
+// Paths are those reported by Diff.
+type queryNode struct {
+	expr  Expression
+	path  string
+	role  string
+	index int
+	depth int
+}
+
+// Find returns the subexpressions of e matching query, in source order.
+func Find(e Expression, query string) ([]Expression, error) {
+	q, err := CompileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Find(e), nil
+}
+
+// CompileQuery parses a query for repeated use. Syntax errors are reported
+// as a SyntaxError spanning the offending part of the query.
+func CompileQuery(query string) (*Query, error) {
+	p := queryParser{src: query}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return &Query{src: query, steps: p.steps}, nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+// Find returns the subexpressions of e matching q, in source order.
+func (q *Query) Find(e Expression) []Expression {
+	root := queryNode{expr: e, role: "root", depth: 1}
+	// The search starts above the root, which is its only child.
+	current := []queryNode{{depth: 0}}
+	for _, step := range q.steps {
+		seen := make(map[string]bool)
+		var next []queryNode
+		for _, n := range current {
+			var candidates []queryNode
+			if n.expr == nil {
+				candidates = []queryNode{root}
+				if step.descendant {
+					candidates = append(candidates, queryDescendants(root)...)
+				}
+			} else if step.descendant {
+				candidates = queryDescendants(n)
+			} else {
+				candidates = queryChildren(n)
+			}
+			for _, c := range candidates {
+				if !seen[c.path] && step.matches(c) {
+					seen[c.path] = true
+					next = append(next, c)
+				}
+			}
+		}
+		current = next
+	}
+	matched := make(map[string]bool)
+	for _, n := range current {
+		matched[n.path] = true
+	}
+	// Report matches in source order, which is the preorder of the tree.
+	results := make([]Expression, 0, len(matched))
+	for _, n := range append([]queryNode{root}, queryDescendants(root)...) {
+		if matched[n.path] {
+			results = append(results, n.expr)
+		}
+	}
+	return results
+}
+
+func queryChildren(n queryNode) []queryNode {
+	switch v := n.expr.(type) {
+	case *PrefixExpression:
+		return []queryNode{{expr: v.rhs, path: childPath(n.path, "rhs"), role: "rhs", depth: n.depth + 1}}
+	case *InfixExpression:
+		return []queryNode{
+			{expr: v.lhs, path: childPath(n.path, "lhs"), role: "lhs", depth: n.depth + 1},
+			{expr: v.rhs, path: childPath(n.path, "rhs"), role: "rhs", depth: n.depth + 1},
+		}
+	case *CallExpression:
+		nodes := make([]queryNode, len(v.args))
+		for i, arg := range v.args {
+			nodes[i] = queryNode{expr: arg, path: argPath(n.path, i), role: "arg", index: i, depth: n.depth + 1}
+		}
+		return nodes
+	}
+	return nil
+}
+
+// queryDescendants returns the descendants of n in preorder.
+func queryDescendants(n queryNode) []queryNode {
+	var nodes []queryNode
+	for _, child := range queryChildren(n) {
+		nodes = append(nodes, child)
+		nodes = append(nodes, queryDescendants(child)...)
+	}
+	return nodes
+}
+
+func (s queryStep) matches(n queryNode) bool {
+	if s.test != "*" && s.test != n.role && s.test != queryKind(n.expr) {
+		return false
+	}
+	for _, pred := range s.predicates {
+		if !pred.matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p queryPredicate) matches(n queryNode) bool {
+	for _, conj := range p {
+		all := true
+		for _, cmp := range conj {
+			if !cmp.matches(n) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+func (c queryComparison) matches(n queryNode) bool {
+	attr := queryAttribute(n, c.attr)
+	if !attr.ok {
+		return false
+	}
+	if c.op == "" {
+		return true
+	}
+	if attr.numeric && c.value.numeric {
+		a, b := attr.num, c.value.num
+		switch c.op {
+		case "=":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		}
+		return false
+	}
+	// Only numbers are ordered, and a number never equals text.
+	equal := attr.numeric == c.value.numeric && attr.str == c.value.str
+	switch c.op {
+	case "=":
+		return equal
+	case "!=":
+		return !equal
+	}
+	return false
+}
+
+func queryKind(e Expression) string {
+	switch e.(type) {
+	case *InfixExpression:
+		return "infix"
+	case *PrefixExpression:
+		return "prefix"
+	case *CallExpression:
+		return "call"
+	case IntegerToken, FloatToken, StringToken:
+		return "literal"
+	case IdentifierToken:
+		return "identifier"
+	case Hole:
+		return "hole"
+	}
+	return ""
+}
+
+func queryText(s string) queryValue {
+	return queryValue{str: s, ok: true}
+}
+
+func queryNumber(i int64) queryValue {
+	return queryValue{num: i, numeric: true, ok: true}
+}
+
+func queryAttribute(n queryNode, attr string) queryValue {
+	switch attr {
+	case "kind":
+		return queryText(queryKind(n.expr))
+	case "role":
+		return queryText(n.role)
+	case "depth":
+		return queryNumber(int64(n.depth))
+	case "pos":
+		return queryNumber(int64(n.expr.getPosition()))
+	}
+	switch v := n.expr.(type) {
+	case *InfixExpression:
+		if attr == "op" {
+			return queryText(v.op.String())
+		}
+	case *PrefixExpression:
+		if attr == "op" {
+			return queryText(v.op.String())
+		}
+	case *CallExpression:
+		switch attr {
+		case "name":
+			return queryText(v.name)
+		case "args":
+			return queryNumber(int64(len(v.args)))
+		}
+	case IdentifierToken:
+		if attr == "name" {
+			return queryText(v.name)
+		}
+	case IntegerToken:
+		if attr == "value" {
+			return queryNumber(v.value)
+		}
+	case StringToken:
+		if attr == "value" {
+			return queryText(v.value)
+		}
+	case Hole:
+		if attr == "index" {
+			return queryNumber(int64(v.index))
+		}
+	}
+	if attr == "index" && n.role == "arg" {
+		return queryNumber(int64(n.index))
+	}
+	return queryValue{}
+}
+
+var queryAttributes = map[string]bool{
+	"kind": true, "role": true, "op": true, "name": true, "value": true,
+	"depth": true, "index": true, "args": true, "pos": true,
+}
+
+var queryTests = map[string]bool{
+	"*": true, "infix": true, "prefix": true, "call": true, "literal": true,
+	"identifier": true, "hole": true, "lhs": true, "rhs": true, "arg": true,
+}
+
+type queryParser struct {
+	src   string
+	pos   int
+	steps []queryStep
+}
+
+func (p *queryParser) errorf(start int, format string, args ...interface{}) error {
+	end := p.pos
+	if end <= start {
+		end = start + 1
+	}
+	return SyntaxError{Pos: start, End: end, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *queryParser) parse() error {
+	p.skipSpace()
+	first := true
+	for first || p.pos < len(p.src) {
+		step := queryStep{descendant: first}
+		if strings.HasPrefix(p.src[p.pos:], "//") {
+			step.descendant = true
+			p.pos += 2
+		} else if strings.HasPrefix(p.src[p.pos:], "/") {
+			step.descendant = false
+			p.pos++
+		} else if !first {
+			return p.errorf(p.pos, "expected '/'")
+		}
+		first = false
+		p.skipSpace()
+		start := p.pos
+		test := p.word()
+		if test == "" && p.pos < len(p.src) && p.src[p.pos] == '*' {
+			p.pos++
+			test = "*"
+		}
+		if test == "" {
+			return p.errorf(start, "expected a node test")
+		}
+		if !queryTests[test] {
+			return p.errorf(start, "unknown node test '%s'", test)
+		}
+		step.test = test
+		p.skipSpace()
+		for p.pos < len(p.src) && p.src[p.pos] == '[' {
+			p.pos++
+			pred, err := p.predicate()
+			if err != nil {
+				return err
+			}
+			step.predicates = append(step.predicates, pred)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return nil
+}
+
+func (p *queryParser) word() string {
+	start := p.pos
+	for p.pos < len(p.src) && isIdentifierChar(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// predicate parses the rest of a bracketed predicate after the '['.
+func (p *queryParser) predicate() (queryPredicate, error) {
+	var pred queryPredicate
+	var conj []queryComparison
+	for {
+		cmp, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		conj = append(conj, cmp)
+		p.skipSpace()
+		start := p.pos
+		switch word := p.word(); word {
+		case "and":
+			continue
+		case "or":
+			pred = append(pred, conj)
+			conj = nil
+			continue
+		case "":
+		default:
+			return nil, p.errorf(start, "expected 'and', 'or' or ']'")
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+			return nil, p.errorf(p.pos, "expected ']'")
+		}
+		p.pos++
+		p.skipSpace()
+		return append(pred, conj), nil
+	}
+}
+
+func (p *queryParser) comparison() (queryComparison, error) {
+	p.skipSpace()
+	start := p.pos
+	attr := p.word()
+	if attr == "" {
+		return queryComparison{}, p.errorf(start, "expected an attribute")
+	}
+	if !queryAttributes[attr] {
+		return queryComparison{}, p.errorf(start, "unknown attribute '%s'", attr)
+	}
+	p.skipSpace()
+	cmp := queryComparison{attr: attr}
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			cmp.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if cmp.op == "" {
+		return cmp, nil
+	}
+	p.skipSpace()
+	value, err := p.literal()
+	if err != nil {
+		return queryComparison{}, err
+	}
+	cmp.value = value
+	return cmp, nil
+}
+
+func (p *queryParser) literal() (queryValue, error) {
+	start := p.pos
+	if p.pos < len(p.src) && (p.src[p.pos] == '\'' || p.src[p.pos] == '"') {
+		quote := p.src[p.pos]
+		end := strings.IndexByte(p.src[p.pos+1:], quote)
+		if end < 0 {
+			p.pos = len(p.src)
+			return queryValue{}, p.errorf(start, "unterminated string")
+		}
+		p.pos += end + 2
+		return queryText(p.src[start+1 : p.pos-1]), nil
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	num, err := strconv.ParseInt(p.src[start:p.pos], 10, 64)
+	if err != nil {
+		return queryValue{}, p.errorf(start, "expected a string or integer")
+	}
+	return queryNumber(num), nil
+}
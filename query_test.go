@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestFind(t *testing.T) {
+	src := "a / 0 + f(b / (c - 1), 2) * -x"
+	e, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"//infix[op='/']/rhs[kind='literal' and value=0]", []string{"0"}},
+		{"//infix[op='/']", []string{"a / 0", "b / (c - 1)"}},
+		{"call/arg[index=1]", []string{"2"}},
+		{"call[name='f' and args=2]", []string{"f(b / (c - 1), 2)"}},
+		{"identifier", []string{"a", "b", "c", "x"}},
+		{"//prefix/rhs", []string{"x"}},
+		{"/infix/lhs", []string{"a / 0"}},
+		{"literal[value>1 or value=0]", []string{"0", "2"}},
+		{"*[depth=2]", []string{"a / 0", "f(b / (c - 1), 2) * -x"}},
+		{"hole", nil},
+	}
+	for _, tt := range tests {
+		found, err := Find(e, tt.query)
+		if err != nil {
+			t.Errorf("Find(%s): %v", tt.query, err)
+			continue
+		}
+		got := make([]string, len(found))
+		for i, f := range found {
+			got[i] = Format(f)
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("Find(%s) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	for _, query := range []string{"", "infix[", "infix[op=]", "//", "infix[op='/'", "frob"} {
+		if _, err := CompileQuery(query); !isSyntaxError(err) {
+			t.Errorf("CompileQuery(%q) fails with %v, want a SyntaxError", query, err)
+		}
+	}
+}
+
+func isSyntaxError(err error) bool {
+	_, ok := err.(SyntaxError)
+	return ok
+}
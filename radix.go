@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// radixPrefixes are the prefixes hex, bin and oct write, which parseint
+// accepts.
+var radixPrefixes = map[int]string{16: "0x", 2: "0b", 8: "0o"}
+
+func init() {
+	for _, f := range []struct {
+		name string
+		base int
+		doc  docEntry
+	}{
+		{"hex", 16, docEntry{"x written in hexadecimal, as 0xff", "hex(255)"}},
+		{"bin", 2, docEntry{"x written in binary, as 0b101", "bin(5)"}},
+		{"oct", 8, docEntry{"x written in octal, as 0o17", "oct(15)"}},
+	} {
+		addBuiltin(f.name, f.name+"(x int) string", f.doc, radixFunction(f.name, f.base))
+	}
+	addBuiltin("parseint", "parseint(s string[, base int]) int", docEntry{"the integer s spells in base 2 to 36, or in the base its 0x, 0b or 0o prefix gives if base is omitted", `parseint("ff", 16)`},
+		func(args []Value) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return Value{}, fmt.Errorf("parseint takes 1 or 2 arguments")
+			}
+			if args[0].Kind() != StringKind {
+				return Value{}, fmt.Errorf("parseint takes a string, not %s", args[0].Kind())
+			}
+			s := strings.TrimSpace(args[0].Str())
+			base := 10
+			if len(args) == 1 {
+				base = radixOf(s)
+			} else {
+				if args[1].Kind() != IntKind {
+					return Value{}, fmt.Errorf("parseint: base must be an integer, not %s", args[1].Kind())
+				}
+				if b := args[1].Int(); b < 2 || b > 36 {
+					return Value{}, fmt.Errorf("parseint: base must be between 2 and 36, not %d", b)
+				}
+				base = int(args[1].Int())
+			}
+			i, err := strconv.ParseInt(trimRadixPrefix(s, base), base, 64)
+			if err != nil {
+				return Value{}, fmt.Errorf("parseint: %q is not an integer", args[0].Str())
+			}
+			return IntValue(i), nil
+		})
+}
+
+// radixFunction writes its one integer argument in base with the base's
+// prefix, after the sign.
+func radixFunction(name string, base int) Function {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return Value{}, fmt.Errorf("%s takes one argument", name)
+		}
+		if args[0].Kind() != IntKind {
+			return Value{}, fmt.Errorf("%s takes an integer, not %s", name, args[0].Kind())
+		}
+		x := args[0].Int()
+		sign := ""
+		if x < 0 {
+			sign = "-"
+		}
+		return StringValue(sign + radixPrefixes[base] + strconv.FormatUint(absInt(x), base)), nil
+	}
+}
+
+// radixOf returns the base whose prefix s has after its sign, or 10.
+func radixOf(s string) int {
+	s = strings.TrimLeft(s, "+-")
+	for base, prefix := range radixPrefixes {
+		if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			return base
+		}
+	}
+	return 10
+}
+
+// trimRadixPrefix removes the prefix of base from s, keeping its sign, so
+// that what hex, bin and oct write can be parsed in their base.
+func trimRadixPrefix(s string, base int) string {
+	prefix, ok := radixPrefixes[base]
+	if !ok {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		s = s[len(prefix):]
+	}
+	return sign + s
+}
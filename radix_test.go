@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestRadix(t *testing.T) {
+	checkEvalCases(t, nil, []evalCase{
+		{src: "hex(255)", want: StringValue("0xff")},
+		{src: "bin(5)", want: StringValue("0b101")},
+		{src: "oct(15)", want: StringValue("0o17")},
+		{src: "hex(-255)", want: StringValue("-0xff")},
+		{src: "hex(0)", want: StringValue("0x0")},
+		{src: "hex(-9223372036854775807 - 1)", want: StringValue("-0x8000000000000000")},
+		{src: `parseint("ff", 16)`, want: IntValue(255)},
+		{src: `parseint("0xFF", 16)`, want: IntValue(255)},
+		{src: `parseint("0b1", 16)`, want: IntValue(177)},
+		{src: `parseint(" -0b101 ")`, want: IntValue(-5)},
+		{src: `parseint("0o17")`, want: IntValue(15)},
+		{src: `parseint("42")`, want: IntValue(42)},
+		{src: `parseint("zz", 36)`, want: IntValue(1295)},
+		{src: "parseint(hex(-123456))", want: IntValue(-123456)},
+		{src: "parseint(bin(-9223372036854775807 - 1))", want: IntValue(-9223372036854775807 - 1)},
+		{src: `parseint("0x")`, err: `parseint: "0x" is not an integer`},
+		{src: `parseint("12", 2)`, err: `parseint: "12" is not an integer`},
+		{src: `parseint("1", 37)`, err: "parseint: base must be between 2 and 36, not 37"},
+		{src: `parseint("1", 2.0)`, err: "parseint: base must be an integer, not float"},
+		{src: "parseint(1)", err: "parseint takes a string, not int"},
+		{src: "parseint()", err: "parseint takes 1 or 2 arguments"},
+		{src: "hex(1.5)", err: "hex takes an integer, not float"},
+		{src: "oct(1, 2)", err: "oct takes one argument"},
+	})
+}
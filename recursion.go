@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// DefaultRecursionLimit is how deep calls of functions defined in the
+// language may nest unless WithRecursionLimit says otherwise.
+const DefaultRecursionLimit = 1000
+
+// RecursionLimitError stops an evaluation whose calls of functions defined
+// in the language, by scripts or as lambdas, nest more than Limit deep, as
+// a recursive function without a base case does before it would exhaust
+// the stack. Function names the function whose call went over the limit.
+// try does not recover from it.
+type RecursionLimitError struct {
+	Function string
+	Limit    int
+}
+
+func (e RecursionLimitError) Error() string {
+	return fmt.Sprintf("calling %s nests calls more than %d deep", e.Function, e.Limit)
+}
+
+// WithRecursionLimit lets calls of functions defined in the language nest
+// n deep instead of DefaultRecursionLimit.
+func WithRecursionLimit(n int) EvalOption {
+	return func(ev *Evaluator) {
+		ev.recursionLimit = n
+	}
+}
+
+// enter counts a call of the function name against the recursion limit,
+// returning the function that ends it.
+func (ev *evaluation) enter(name string) (func(), error) {
+	limit := ev.recursionLimit
+	if limit <= 0 {
+		limit = DefaultRecursionLimit
+	}
+	if ev.budget.calls.Add(1) > int32(limit) {
+		ev.budget.calls.Add(-1)
+		return nil, RecursionLimitError{Function: name, Limit: limit}
+	}
+	return func() {
+		ev.budget.calls.Add(-1)
+	}, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OperatorInfo describes an operator as currently registered, for help
+// screens. A prefix operator has Arity 1 and binds its operand with
+// RightBindingPower; an infix operator has Arity 2. Eval names the function
+// evaluating an operator loaded with LoadOperators, and Aliases are its
+// other spellings.
+type OperatorInfo struct {
+	Symbol            string
+	Arity             int
+	LeftBindingPower  int
+	RightBindingPower int
+	Associativity     Associativity
+	Eval              string
+	Aliases           []string
+	Doc               string
+	Example           string
+}
+
+type operatorKey struct {
+	op    OpKind
+	arity int
+}
+
+// docEntry documents an operator or function with a description and an
+// example expression using it.
+type docEntry struct {
+	doc     string
+	example string
+}
+
+// operatorDocs documents the operators, starting with the built-in ones.
+var operatorDocs = map[operatorKey]docEntry{
+	{AddOp, 1}:      {"the number itself", "+x"},
+	{SubOp, 1}:      {"negation", "-x"},
+	{AddOp, 2}:      {"addition, or concatenation of strings", "1 + 2"},
+	{SubOp, 2}:      {"subtraction", "5 - 3"},
+	{MulOp, 2}:      {"multiplication", "2 * 3"},
+	{DivOp, 2}:      {"division, truncating when both operands are integers", "7 / 2"},
+	{PowOp, 2}:      {"exponentiation", "2 ^ 10"},
+	{MatchOp, 2}:    {"whether the string matches the regular expression", `name =~ "^foo.*"`},
+	{NotMatchOp, 2}: {"whether the string does not match the regular expression", `name !~ "^foo.*"`},
+	{EqOp, 2}:       {"whether the values are equal", "x == 1"},
+	{NeOp, 2}:       {"whether the values differ", "x != 1"},
+	{LtOp, 2}:       {"whether the number or string is less", "x < 10"},
+	{LeOp, 2}:       {"whether the number or string is less or equal", "x <= 10"},
+	{GtOp, 2}:       {"whether the number or string is greater", "x > 0"},
+	{GeOp, 2}:       {"whether the number or string is greater or equal", "x >= 0"},
+	{AndOp, 2}:      {"whether both are true, skipping the right when the left is false", "x > 0 && x < 10"},
+	{OrOp, 2}:       {"whether either is true, skipping the right when the left is true", "x < 0 || x > 10"},
+	{NotOp, 1}:      {"logical negation", "!done"},
+	{ShlOp, 2}:      {"the integer shifted left", "1 << 4"},
+	{ShrOp, 2}:      {"the integer shifted right, keeping its sign", "256 >> 2"},
+	{PipeOp, 2}:     {"the function called with the value as its first argument", "x |> round(2)"},
+	{ConcatOp, 2}:   {"in spreadsheet formulas, the values joined as strings", `A1 & " items"`},
+	{ArrowOp, 2}:    {"the function of the parameters of fn on the left returning the right", "fn(x) => x > 0"},
+}
+
+// functionDocs documents functions by name, starting with operatorFunctions.
+var functionDocs = map[string]docEntry{
+	"mod": {"the remainder of dividing a by b", "mod(7, 3)"},
+	"pow": {"x raised to the power y", "pow(2, 0.5)"},
+	"min": {"the smaller of a and b", "min(3, 4)"},
+	"max": {"the larger of a and b", "max(3, 4)"},
+}
+
+// DocumentOperator sets the description and example shown for the
+// operator symbol of the given arity, 1 for prefix or 2 for infix. Like the
+// Register functions, it must not be called while parsing.
+func DocumentOperator(symbol string, arity int, doc string, example string) {
+	op := lookupOperator(symbol)
+	if op == NoOp {
+		panic(fmt.Sprintf("documentation of unknown operator %q", symbol))
+	}
+	operatorDocs[operatorKey{op, arity}] = docEntry{doc, example}
+}
+
+// DocumentFunction sets the description and example shown for the function
+// name, which hosts provide in their environments. Like DocumentOperator, it
+// is meant to be called at startup.
+func DocumentFunction(name string, doc string, example string) {
+	functionDocs[name] = docEntry{doc, example}
+}
+
+// Operators returns the registered operators, loosest first, including
+// those added by the Register functions and LoadOperators. Parentheses and
+// commas, which group and call rather than operate, are left out.
+func Operators() []OperatorInfo {
+	aliases := operatorAliases()
+	var infos []OperatorInfo
+	for i, symbol := range opKindSymbols {
+		op := OpKind(i)
+		if op == LParenOp {
+			continue
+		}
+		if rule := prefixRules[op]; rule.fn != nil {
+			infos = append(infos, OperatorInfo{
+				Symbol:            symbol,
+				Arity:             1,
+				RightBindingPower: rule.bp,
+				Eval:              rule.eval,
+				Aliases:           aliases[op],
+				Doc:               operatorDocs[operatorKey{op, 1}].doc,
+				Example:           operatorDocs[operatorKey{op, 1}].example,
+			})
+		}
+		if rule := infixRules[op]; rule.fn != nil {
+			infos = append(infos, OperatorInfo{
+				Symbol:            symbol,
+				Arity:             2,
+				LeftBindingPower:  rule.lbp,
+				RightBindingPower: rule.rbp,
+				Associativity:     rule.associativity(),
+				Eval:              rule.eval,
+				Aliases:           aliases[op],
+				Doc:               operatorDocs[operatorKey{op, 2}].doc,
+				Example:           operatorDocs[operatorKey{op, 2}].example,
+			})
+		}
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].bindingPower() < infos[j].bindingPower()
+	})
+	return infos
+}
+
+// bindingPower is how tightly the operator holds its left operand, or its
+// operand for a prefix operator, which orders the table.
+func (o OperatorInfo) bindingPower() int {
+	if o.Arity == 1 {
+		return o.RightBindingPower
+	}
+	return o.LeftBindingPower
+}
+
+// operatorAliases returns the spellings of each operator other than its
+// symbol.
+func operatorAliases() map[OpKind][]string {
+	aliases := make(map[OpKind][]string)
+	for c, op := range operatorKinds {
+		if op != NoOp && string(rune(c)) != op.String() {
+			aliases[op] = append(aliases[op], string(rune(c)))
+		}
+	}
+	for _, texts := range longOperators {
+		for _, t := range texts {
+			if t.text != t.op.String() {
+				aliases[t.op] = append(aliases[t.op], t.text)
+			}
+		}
+	}
+	return aliases
+}
+
+// formatOperators renders infos as the table :ops prints.
+func formatOperators(infos []OperatorInfo) string {
+	lines := []string{"op     arity  lbp  rbp  associativity      doc"}
+	for _, o := range infos {
+		symbol := o.Symbol
+		if len(o.Aliases) > 0 {
+			symbol += " " + strings.Join(o.Aliases, " ")
+		}
+		assoc := ""
+		if o.Arity == 2 {
+			assoc = o.Associativity.String()
+		}
+		doc := o.Doc
+		if o.Eval != "" {
+			doc = strings.TrimSpace(doc + " (evaluated by " + o.Eval + ")")
+		}
+		lines = append(lines, fmt.Sprintf("%-6s %5d %4d %4d  %-17s  %s", symbol, o.Arity, o.LeftBindingPower, o.RightBindingPower, assoc, doc))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describe documents name, an operator symbol or a function, for :doc.
+func describe(name string) string {
+	var sections []string
+	for _, o := range Operators() {
+		if o.Symbol != name && !containsString(o.Aliases, name) {
+			continue
+		}
+		usage := o.Symbol + "x  (prefix)"
+		if o.Arity == 2 {
+			usage = "x " + o.Symbol + " y  (infix, " + o.Associativity.String() + ")"
+		}
+		if o.Eval != "" {
+			usage += ", evaluated by " + o.Eval
+		}
+		sections = append(sections, formatDoc(usage, docEntry{o.Doc, o.Example}))
+	}
+	entry, documented := functionDocs[name]
+	if signature, ok := functionSignatures[name]; ok || documented {
+		if !ok {
+			signature = name + "(...)"
+		}
+		sections = append(sections, formatDoc(signature, entry))
+	}
+	if len(sections) == 0 {
+		return "no documentation for " + name
+	}
+	return strings.Join(sections, "\n")
+}
+
+func formatDoc(usage string, entry docEntry) string {
+	text := usage
+	if entry.doc != "" {
+		text += "\n  " + entry.doc
+	}
+	if entry.example != "" {
+		text += "\n  example: " + entry.example
+	}
+	return text
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
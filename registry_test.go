@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// findOperator returns the entry of Operators for symbol with arity.
+func findOperator(infos []OperatorInfo, symbol string, arity int) (OperatorInfo, bool) {
+	for _, o := range infos {
+		if o.Symbol == symbol && o.Arity == arity {
+			return o, true
+		}
+	}
+	return OperatorInfo{}, false
+}
+
+func TestOperators(t *testing.T) {
+	infos := Operators()
+	for i := 1; i < len(infos); i++ {
+		if infos[i].bindingPower() < infos[i-1].bindingPower() {
+			t.Errorf("%s is listed after the looser %s", infos[i-1].Symbol, infos[i].Symbol)
+		}
+	}
+	tests := []OperatorInfo{
+		{Symbol: "+", Arity: 2, LeftBindingPower: 1, RightBindingPower: 2, Associativity: LeftAssociative},
+		{Symbol: "-", Arity: 1, RightBindingPower: 5, Aliases: []string{"−"}},
+		{Symbol: "^", Arity: 2, LeftBindingPower: 7, RightBindingPower: 7, Associativity: RightAssociative, Aliases: []string{"**"}},
+		{Symbol: "&&", Arity: 2, LeftBindingPower: -3, RightBindingPower: -2, Associativity: LeftAssociative},
+	}
+	for _, want := range tests {
+		got, ok := findOperator(infos, want.Symbol, want.Arity)
+		if !ok {
+			t.Errorf("%s/%d is not listed", want.Symbol, want.Arity)
+			continue
+		}
+		if got.LeftBindingPower != want.LeftBindingPower || got.RightBindingPower != want.RightBindingPower ||
+			got.Associativity != want.Associativity || !equalStrings(got.Aliases, want.Aliases) || got.Doc == "" {
+			t.Errorf("%s/%d is listed as %+v, want %+v", want.Symbol, want.Arity, got, want)
+		}
+	}
+	for _, symbol := range []string{"(", ")", ","} {
+		if _, ok := findOperator(infos, symbol, 2); ok {
+			t.Errorf("%s is listed as an operator", symbol)
+		}
+	}
+}
+
+func TestOperatorsListsRegistered(t *testing.T) {
+	defer saveGrammar()()
+	RegisterOperator("<=>", 0, RightAssociative, nil)
+	got, ok := findOperator(Operators(), "<=>", 2)
+	if !ok || got.Associativity != RightAssociative || got.LeftBindingPower != got.RightBindingPower {
+		t.Errorf("registered <=> is listed as %+v, %t", got, ok)
+	}
+	table := formatOperators(Operators())
+	if !strings.HasPrefix(table, "op     arity  lbp  rbp  associativity      doc\n") || !strings.Contains(table, "\n<=>        2") {
+		t.Errorf(":ops table is\n%s", table)
+	}
+	if got := replTranscript(t, ":ops\n"); got != table+"\n" {
+		t.Errorf(":ops prints\n%s\nwant\n%s", got, table)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := map[string]string{
+		"&&": "x && y  (infix, left associative)\n  whether both are true, skipping the right when the left is false\n  example: x > 0 && x < 10",
+		"+": "x + y  (infix, left associative)\n  addition, or concatenation of strings\n  example: 1 + 2\n" +
+			"+x  (prefix)\n  the number itself\n  example: +x",
+		"**":      "x ^ y  (infix, right associative)\n  exponentiation\n  example: 2 ^ 10",
+		"mod":     "mod(a, b int) int\n  the remainder of dividing a by b\n  example: mod(7, 3)",
+		"nothing": "no documentation for nothing",
+	}
+	for name, want := range tests {
+		if got := describe(name); got != want {
+			t.Errorf("describe(%q) =\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	defer saveGrammar()()
+	RegisterOperator("<=>", 0, LeftAssociative, nil)
+	DocumentOperator("<=>", 2, "the sign of a minus b", "1 <=> 2")
+	DocumentFunction("discount", "price less the rate", "discount(100, 0.2)")
+	defer delete(functionDocs, "discount")
+	got := replTranscript(t, ":doc <=>\n:doc discount\n:doc\n")
+	want := "x <=> y  (infix, left associative)\n  the sign of a minus b\n  example: 1 <=> 2\n" +
+		"discount(...)\n  price less the rate\n  example: discount(100, 0.2)\n" +
+		"usage: :doc name\n"
+	if got != want {
+		t.Errorf("transcript is\n%s\nwant\n%s", got, want)
+	}
+	if info, _ := findOperator(Operators(), "<=>", 2); info.Doc != "the sign of a minus b" || info.Example != "1 <=> 2" {
+		t.Errorf("Operators lists <=> as %+v", info)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("documenting an unknown operator did not panic")
+		}
+	}()
+	DocumentOperator("<~>", 2, "", "")
+}
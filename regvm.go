@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type RegOpcode byte
+
+const (
+	RegLoad RegOpcode = iota
+	RegPrefix
+	RegInfix
+	RegCall
+	RegTry
+	RegBind
+	RegUnbind
+	RegLogic
+)
+
+var regOpcodeNames = map[RegOpcode]string{
+	RegLoad:   "load",
+	RegPrefix: "prefix",
+	RegInfix:  "infix",
+	RegCall:   "call",
+	RegTry:    "try",
+	RegBind:   "bind",
+	RegUnbind: "unbind",
+	RegLogic:  "logic",
+}
+
+func (o RegOpcode) String() string {
+	if name, ok := regOpcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("op(%d)", byte(o))
+}
+
+// RegInstruction writes its result to register Dst. Operands B and C are
+// registers when non-negative and constant -1-k, Consts[k], otherwise.
+// A indexes Names for RegLoad and RegCall, is the OpKind of RegPrefix
+// and RegInfix, and indexes Tries for RegTry and Lazy for RegLogic.
+// RegPrefix reads B and RegInfix reads B and C; RegCall passes the C
+// operands starting at Args[B]; RegLogic applies the OpKind C to B and,
+// unless B decides the result, the value of its program. RegBind binds operand B to the local variable named by A and
+// RegUnbind removes the innermost local; neither writes to Dst. Pos is the
+// source column reported in runtime errors.
+type RegInstruction struct {
+	Op  RegOpcode
+	Dst int
+	A   int
+	B   int
+	C   int
+	Pos int
+}
+
+// RegProgram is an expression compiled for a register machine, as an
+// alternative to the stack machine of Program. Constants are used in place
+// rather than loaded, each variable is loaded once into a register kept for
+// later reads, and temporaries are reused as soon as they are consumed. A
+// RegProgram is never modified after compilation and may be run
+// concurrently.
+type RegProgram struct {
+	Code      []RegInstruction
+	Consts    []Value
+	Names     []string
+	Args      []int
+	Registers int
+	// Result is the operand holding the value of the expression.
+	Result int
+	// Tries holds the programs of each try call for RegTry, which runs the
+	// first and, if it fails, the second.
+	Tries [][2]*RegProgram
+	// Lazy holds the programs of the right operands of && and || for
+	// RegLogic.
+	Lazy []*RegProgram
+}
+
+type regCompiler struct {
+	compileSettings
+	program     *RegProgram
+	nameIndexes map[string]int
+	// variables maps each variable loaded so far to its register.
+	variables map[string]int
+	pinned    map[int]bool
+	free      []int
+	subexprs  *subexpressions
+	// constants maps the names bound to constants by the lets being
+	// compiled to their values.
+	constants map[string]Value
+	// blocks counts the let bodies being compiled, where repeated
+	// subexpressions are not shared.
+	blocks int
+}
+
+// CompileRegisters compiles e for the register machine, folding constants
+// and computing repeated subexpressions once as Compile does. A repeated
+// subexpression keeps its register for its later occurrences to read.
+func CompileRegisters(e Expression, opts ...CompileOption) (*RegProgram, error) {
+	var s compileSettings
+	s.apply(opts)
+	return compileRegistersWith(e, s)
+}
+
+func compileRegistersWith(e Expression, s compileSettings) (*RegProgram, error) {
+	c := &regCompiler{
+		compileSettings: s,
+		program:         &RegProgram{},
+		nameIndexes:     make(map[string]int),
+		variables:       make(map[string]int),
+		constants:       make(map[string]Value),
+		pinned:          make(map[int]bool),
+	}
+	if !c.noCSE {
+		c.subexprs = findSubexpressions(e)
+	}
+	result, err := c.compile(e)
+	if err != nil {
+		return nil, err
+	}
+	c.program.Result = result
+	return c.program, nil
+}
+
+func (c *regCompiler) name(name string) int {
+	if index, ok := c.nameIndexes[name]; ok {
+		return index
+	}
+	c.program.Names = append(c.program.Names, name)
+	c.nameIndexes[name] = len(c.program.Names) - 1
+	return len(c.program.Names) - 1
+}
+
+func (c *regCompiler) constant(value Value) int {
+	c.program.Consts = append(c.program.Consts, value)
+	return -len(c.program.Consts)
+}
+
+// replaceConstants adds the folded value of the constant operands, which
+// it removes if they were the last constants added. The constant a let
+// binds for RegBind may come between them.
+func (c *regCompiler) replaceConstants(value Value, operands ...int) int {
+	n := len(c.program.Consts)
+	last := true
+	for i, o := range operands {
+		last = last && -1-o == n-len(operands)+i
+	}
+	if last {
+		c.program.Consts = c.program.Consts[:n-len(operands)]
+	}
+	return c.constant(value)
+}
+
+// alloc returns a free register, reusing released temporaries first.
+func (c *regCompiler) alloc() int {
+	if n := len(c.free); n > 0 {
+		r := c.free[n-1]
+		c.free = c.free[:n-1]
+		return r
+	}
+	c.program.Registers++
+	return c.program.Registers - 1
+}
+
+// release frees operand once its value has been consumed, unless it is a
+// constant or a variable's register.
+func (c *regCompiler) release(operand int) {
+	if operand >= 0 && !c.pinned[operand] {
+		c.free = append(c.free, operand)
+	}
+}
+
+func (c *regCompiler) emit(ins RegInstruction) int {
+	c.program.Code = append(c.program.Code, ins)
+	return ins.Dst
+}
+
+func (c *regCompiler) compile(e Expression) (int, error) {
+	if c.blocks > 0 {
+		return c.compileNode(e)
+	}
+	entry := c.subexprs.repeated(e)
+	if entry == nil {
+		return c.compileNode(e)
+	}
+	if entry.slot >= 0 {
+		return entry.slot, nil
+	}
+	r, err := c.compileNode(e)
+	if err != nil {
+		return 0, err
+	}
+	c.pinned[r] = true
+	entry.slot = r
+	return r, nil
+}
+
+// compileNode emits the code computing e and returns the operand holding
+// it. Operands are compiled before they are released, and released before
+// the destination is allocated, so an instruction may overwrite an operand
+// it reads.
+func (c *regCompiler) compileNode(e Expression) (int, error) {
+	switch v := e.(type) {
+	case IntegerToken:
+		return c.constant(IntValue(int64(v.value))), nil
+	case FloatToken:
+		return c.constant(FloatValue(v.value)), nil
+	case StringToken:
+		return c.constant(StringValue(v.value)), nil
+	case IdentifierToken:
+		// Evaluation order is fixed, so the first read of a variable comes
+		// before every other and is where a missing variable is reported.
+		if value, ok := c.constants[v.name]; ok {
+			return c.constant(value), nil
+		}
+		if r, ok := c.variables[v.name]; ok {
+			return r, nil
+		}
+		r := c.alloc()
+		c.variables[v.name] = r
+		c.pinned[r] = true
+		return c.emit(RegInstruction{Op: RegLoad, Dst: r, A: c.name(v.name), Pos: v.pos}), nil
+	case Hole:
+		return 0, fmt.Errorf("cannot compile unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case *PrefixExpression:
+		rhs, err := c.compile(v.rhs)
+		if err != nil {
+			return 0, err
+		}
+		if rhs < 0 {
+			if value, ok := c.foldConstant(v.op, c.program.Consts[-1-rhs]); ok {
+				return c.replaceConstants(value, rhs), nil
+			}
+		}
+		c.release(rhs)
+		return c.emit(RegInstruction{Op: RegPrefix, Dst: c.alloc(), A: int(v.op), B: rhs, Pos: v.pos}), nil
+	case *InfixExpression:
+		if isShortCircuit(v) {
+			return c.compileShortCircuit(v)
+		}
+		lhs, err := c.compile(v.lhs)
+		if err != nil {
+			return 0, err
+		}
+		rhs, err := c.compile(v.rhs)
+		if err != nil {
+			return 0, err
+		}
+		if lhs < 0 && rhs < 0 {
+			if value, ok := c.foldConstant(v.op, c.program.Consts[-1-lhs], c.program.Consts[-1-rhs]); ok {
+				return c.replaceConstants(value, lhs, rhs), nil
+			}
+		}
+		c.release(lhs)
+		c.release(rhs)
+		return c.emit(RegInstruction{Op: RegInfix, Dst: c.alloc(), A: int(v.op), B: lhs, C: rhs, Pos: v.pos}), nil
+	case *CallExpression:
+		if isTry(v) {
+			return c.compileTry(v)
+		}
+		if isLet(v) {
+			return c.compileLet(v)
+		}
+		if isLambda(v) {
+			return 0, fmt.Errorf("cannot compile fn at column %d", v.pos+1)
+		}
+		args := make([]int, len(v.args))
+		for i, arg := range v.args {
+			operand, err := c.compile(arg)
+			if err != nil {
+				return 0, err
+			}
+			args[i] = operand
+		}
+		for _, operand := range args {
+			c.release(operand)
+		}
+		start := len(c.program.Args)
+		c.program.Args = append(c.program.Args, args...)
+		return c.emit(RegInstruction{Op: RegCall, Dst: c.alloc(), A: c.name(v.name), B: start, C: len(args), Pos: v.pos}), nil
+	}
+	return 0, fmt.Errorf("cannot compile %T", e)
+}
+
+var registerPool = sync.Pool{
+	New: func() interface{} {
+		registers := make([]Value, 0, 16)
+		return &registers
+	},
+}
+
+func (p *RegProgram) Run(env Env) (Value, error) {
+	return p.run(env, nil)
+}
+
+// run runs the program with the local variables bound around it, as for
+// the programs of try calls in a let.
+func (p *RegProgram) run(env Env, locals *scope) (Value, error) {
+	pooled := registerPool.Get().(*[]Value)
+	if cap(*pooled) < p.Registers {
+		*pooled = make([]Value, 0, p.Registers)
+	}
+	r := (*pooled)[:p.Registers]
+	defer func() {
+		for i := range r {
+			r[i] = Value{}
+		}
+		registerPool.Put(pooled)
+	}()
+	operand := func(o int) Value {
+		if o < 0 {
+			return p.Consts[-1-o]
+		}
+		return r[o]
+	}
+	for _, ins := range p.Code {
+		var value Value
+		var err error
+		switch ins.Op {
+		case RegLoad:
+			value, err = lookupLocal(p.Names[ins.A], ins.Pos, locals, env)
+		case RegPrefix:
+			if name := operatorFunction(OpKind(ins.A), false); name != "" {
+				value, err = callOperator(name, ins.Pos, env, []Value{operand(ins.B)})
+			} else {
+				value, err = applyPrefixAt(OpKind(ins.A), operand(ins.B), ins.Pos)
+			}
+		case RegInfix:
+			if name := operatorFunction(OpKind(ins.A), true); name != "" {
+				value, err = callOperator(name, ins.Pos, env, []Value{operand(ins.B), operand(ins.C)})
+			} else {
+				value, err = applyInfixAt(OpKind(ins.A), operand(ins.B), operand(ins.C), ins.Pos)
+			}
+		case RegCall:
+			args := make([]Value, ins.C)
+			for i, o := range p.Args[ins.B : ins.B+ins.C] {
+				args[i] = operand(o)
+			}
+			value, err = callLocal(p.Names[ins.A], ins.Pos, locals, env, args)
+		case RegTry:
+			if value, err = p.Tries[ins.A][0].run(env, locals); recoverable(err) {
+				value, err = p.Tries[ins.A][1].run(env, locals)
+			}
+		case RegLogic:
+			value, err = applyShortCircuit(OpKind(ins.C), operand(ins.B), func() (Value, error) {
+				return p.Lazy[ins.A].run(env, locals)
+			}, ins.Pos)
+		case RegBind:
+			locals = &scope{name: p.Names[ins.A], value: operand(ins.B), parent: locals}
+			continue
+		case RegUnbind:
+			locals = locals.parent
+			continue
+		default:
+			return Value{}, fmt.Errorf("invalid opcode %s", ins.Op)
+		}
+		if err != nil {
+			return Value{}, err
+		}
+		r[ins.Dst] = value
+	}
+	return operand(p.Result), nil
+}
+
+// Disassemble renders the program one instruction per line, with registers
+// as r0, r1, ... and constants by value.
+func (p *RegProgram) Disassemble() string {
+	var sb strings.Builder
+	operand := func(o int) string {
+		if o < 0 {
+			return p.Consts[-1-o].String()
+		}
+		return fmt.Sprintf("r%d", o)
+	}
+	for i, ins := range p.Code {
+		switch ins.Op {
+		case RegBind:
+			fmt.Fprintf(&sb, "%04d %-6s %s = %s\n", i, ins.Op, p.Names[ins.A], operand(ins.B))
+			continue
+		case RegUnbind:
+			fmt.Fprintf(&sb, "%04d %s\n", i, ins.Op)
+			continue
+		}
+		fmt.Fprintf(&sb, "%04d %-6s r%d =", i, ins.Op, ins.Dst)
+		switch ins.Op {
+		case RegLoad:
+			fmt.Fprintf(&sb, " %s", p.Names[ins.A])
+		case RegPrefix:
+			fmt.Fprintf(&sb, " %s%s", OpKind(ins.A), operand(ins.B))
+		case RegInfix:
+			fmt.Fprintf(&sb, " %s %s %s", operand(ins.B), OpKind(ins.A), operand(ins.C))
+		case RegCall:
+			args := make([]string, ins.C)
+			for j, o := range p.Args[ins.B : ins.B+ins.C] {
+				args[j] = operand(o)
+			}
+			fmt.Fprintf(&sb, " %s(%s)", p.Names[ins.A], strings.Join(args, ", "))
+		case RegTry:
+			fmt.Fprintf(&sb, " #%d", ins.A)
+		case RegLogic:
+			fmt.Fprintf(&sb, " %s %s #%d", operand(ins.B), OpKind(ins.C), ins.A)
+		}
+		sb.WriteByte('\n')
+	}
+	fmt.Fprintf(&sb, "result %s\n", operand(p.Result))
+	for i, programs := range p.Tries {
+		fmt.Fprintf(&sb, "try #%d:\n%sdefault #%d:\n%s", i, indent(programs[0].Disassemble()), i, indent(programs[1].Disassemble()))
+	}
+	for i, program := range p.Lazy {
+		fmt.Fprintf(&sb, "right #%d:\n%s", i, indent(program.Disassemble()))
+	}
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestCompileRegisters(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// x is loaded once and its register read again.
+		{"x * x + 1", "0000 load   r0 = x\n0001 infix  r1 = r0 * r0\n0002 infix  r1 = r1 + 1\nresult r1\n"},
+		{"f(x, 2) + x", "0000 load   r0 = x\n0001 call   r1 = f(r0, 2)\n0002 infix  r1 = r1 + r0\nresult r1\n"},
+		{"2 * 3 + x", "0000 load   r0 = x\n0001 infix  r1 = 6 + r0\nresult r1\n"},
+		{"let(y, x + 1, y * y)", "0000 load   r0 = x\n0001 infix  r1 = r0 + 1\n0002 bind   y = r1\n0003 infix  r2 = r1 * r1\n0004 unbind\nresult r2\n"},
+		{"a && b", "0000 load   r0 = a\n0001 logic  r1 = r0 && #0\nresult r1\nright #0:\n    0000 load   r0 = b\n    result r0\n"},
+	}
+	for _, tt := range tests {
+		program, err := CompileRegisters(mustParse(t, tt.src))
+		if err != nil {
+			t.Errorf("CompileRegisters(%s) = %v", tt.src, err)
+			continue
+		}
+		if got := program.Disassemble(); got != tt.want {
+			t.Errorf("CompileRegisters(%s) =\n%swant\n%s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestBackendsAgree(t *testing.T) {
+	env := Env{"x": IntValue(0), "y": FloatValue(2.5), "s": StringValue("a")}
+	sources := []string{
+		"x * x + 1",
+		"(x + y) * (y - x) - y",
+		"s + s == \"aa\"",
+		"let(n, y * 2, n * n)",
+		"try(1 / x, -1)",
+		"x > 0 && 1 / x > 1",
+		"1 / x",
+		"s * 2",
+		"missing + 1",
+		"f(1)",
+	}
+	for _, src := range sources {
+		e := mustParse(t, src)
+		want, wantErr := evalExpression(e, env)
+		for _, backend := range allBackends {
+			program, err := CompileBackend(e, backend)
+			if err != nil {
+				t.Errorf("CompileBackend(%s, %s) = %v", src, backend, err)
+				continue
+			}
+			got, err := program.Run(env)
+			if errorString(err) != errorString(wantErr) || wantErr == nil && (!got.Equal(want) || got.Kind() != want.Kind()) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s, %v", src, backend, got, err, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestBackendNames(t *testing.T) {
+	for b, want := range map[Backend]string{TreeBackend: "tree", StackBackend: "stack", RegisterBackend: "register", Backend(7): "backend(7)"} {
+		if got := b.String(); got != want {
+			t.Errorf("Backend(%d).String() = %q, want %q", int(b), got, want)
+		}
+	}
+	if _, err := CompileBackend(mustParse(t, "1"), Backend(7)); errorString(err) != "unknown backend backend(7)" {
+		t.Errorf("CompileBackend with an unknown backend = %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// EnvRegistry holds the Env a long-running host evaluates against and
+// reloads its variables from a JSON file, as read by ReadVars, without
+// restarting. A reload builds a new Env and swaps it in whole, so
+// evaluations already running against the old one finish with the values
+// they started with. Functions are Go values the file cannot hold, so they
+// are bound in the base Env given to NewEnvRegistry, and a variable of the
+// file replaces one of the base of the same name. An EnvRegistry is safe
+// for concurrent use.
+type EnvRegistry struct {
+	// reloading serializes reloads, so the file read last is the one
+	// swapped in, and mu guards env.
+	reloading sync.Mutex
+	mu        sync.RWMutex
+	base      Env
+	path      string
+	env       Env
+}
+
+// NewEnvRegistry returns an EnvRegistry of the functions and constants of
+// base and the variables of the file at path, failing if the file cannot be
+// read. base must not be modified afterwards.
+func NewEnvRegistry(base Env, path string) (*EnvRegistry, error) {
+	r := &EnvRegistry{base: base, path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Env returns the current Env. It must not be modified; Reload replaces it
+// rather than changing it, so it can be evaluated against while one runs.
+func (r *EnvRegistry) Env() Env {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.env
+}
+
+// Reload reads the file again and swaps in an Env of base and its
+// variables. If the file cannot be read the current Env is kept.
+func (r *EnvRegistry) Reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	env := make(Env, len(r.base))
+	for name, value := range r.base {
+		env[name] = value
+	}
+	if err := env.ReadVars(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%s: %w", r.path, err)
+	}
+	r.mu.Lock()
+	r.env = env
+	r.mu.Unlock()
+	return nil
+}
+
+// ReloadOn reloads the registry each time the process receives one of
+// signals, typically syscall.SIGHUP, passing the error of a failed reload
+// to report if it is not nil. It returns a function that stops listening,
+// which may be called more than once.
+func (r *EnvRegistry) ReloadOn(report func(error), signals ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, signals...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := r.Reload(); err != nil && report != nil {
+					report(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// ServeHTTP reloads the registry on a POST, for mounting at an admin
+// endpoint, answering with the number of names bound or the error that
+// kept the current Env.
+func (r *EnvRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "reload with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "reloaded %d names\n", len(r.Env()))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestEnvRegistryReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vars.json")
+	write := func(x int) {
+		if err := os.WriteFile(path, []byte(fmt.Sprintf(`{"x": %d}`, x)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+	r, err := NewEnvRegistry(Env{"y": IntValue(2)}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := r.Env()
+	write(3)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Env()["x"].Equal(IntValue(3)) || !r.Env()["y"].Equal(IntValue(2)) || !old["x"].Equal(IntValue(1)) {
+		t.Errorf("after reloading, x = %s, y = %s and the old x = %s", r.Env()["x"], r.Env()["y"], old["x"])
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil || !r.Env()["x"].Equal(IntValue(3)) {
+		t.Errorf("a failed reload = %v and leaves x = %s", err, r.Env()["x"])
+	}
+}
+
+func TestEnvRegistryConcurrentReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vars.json")
+	if err := os.WriteFile(path, []byte(`{"x": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewEnvRegistry(Env{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if !r.Env()["x"].Equal(IntValue(0)) {
+		t.Errorf("x = %s", r.Env()["x"])
+	}
+}
+
+func TestEnvRegistryStopTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vars.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewEnvRegistry(Env{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := r.ReloadOn(nil, os.Interrupt)
+	stop()
+	stop()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	replPrompt         = "> "
+	continuationPrompt = ". "
+)
+
+// runREPL evaluates lines from stdin until it ends. On a terminal lines are
+// edited with history recall and search, and the history is kept in
+// ~/.prattcalc_history between sessions.
+func runREPL(args []string) error {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	historySize := flags.Int("history-size", defaultHistorySize, "number of lines of history to keep")
+	historyPath := flags.String("history", defaultHistoryPath(), "history file, or empty to keep no history")
+	vars := addVarFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	env, err := vars.load()
+	if err != nil {
+		return err
+	}
+	session := newReplSession()
+	for name, value := range env {
+		session.env[name] = value
+	}
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return session.readLines(bufio.NewReader(os.Stdin), os.Stdout)
+	}
+	history := NewHistory(*historyPath, *historySize)
+	if err := history.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "loading history: %v\n", err)
+	}
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return session.readLines(bufio.NewReader(os.Stdin), os.Stdout)
+	}
+	defer restore()
+	editor := newLineEditor(os.Stdin, os.Stdout, history)
+	editor.complete = session.complete
+	editor.hint = session.hint
+	for {
+		entry, err := session.readEntry(editor.ReadLine)
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		// The history file holds one entry per line.
+		history.Add(strings.ReplaceAll(entry, "\n", " "))
+		if err := history.Save(); err != nil {
+			fmt.Fprintf(os.Stdout, "saving history: %v\n", err)
+		}
+		output, quit := session.run(entry)
+		if output != "" {
+			fmt.Fprintln(os.Stdout, output)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// readEntry reads an entry of one or more lines with read. While the input
+// so far is an incomplete expression, such as one ending in an operator or
+// with a paren left open, it keeps reading lines with the continuation
+// prompt. An entry cut short by the end of input is returned as it is so
+// its error is reported.
+func (s *replSession) readEntry(read func(prompt string) (string, error)) (string, error) {
+	entry, err := read(replPrompt)
+	if err != nil {
+		return "", err
+	}
+	for incomplete(entry) {
+		line, err := read(continuationPrompt)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		entry += "\n" + line
+	}
+	return entry, nil
+}
+
+// incomplete reports whether src is the start of a valid expression that
+// cannot end yet.
+func incomplete(src string) bool {
+	if strings.TrimSpace(src) == "" || strings.HasPrefix(strings.TrimSpace(src), ":") {
+		return false
+	}
+	_, expected, err := ParsePartial(src)
+	return err == nil && expected&ExpectEnd == 0
+}
+
+// replSession is the state kept between the lines of a REPL session.
+// Results are bound to ans and to _1, _2 and so on in the order they were
+// computed, so calculations can be chained as in ans * 2. The functions
+// operators can be evaluated with are also callable by name.
+type replSession struct {
+	env      Env
+	results  int
+	settings replSettings
+	// functions holds the line each variable bound to a lambda came from,
+	// so :save can write the function as source.
+	functions map[string]string
+}
+
+// replSettings are the limits :set changes for the lines evaluated after.
+type replSettings struct {
+	Steps     int `json:"steps"`
+	Recursion int `json:"recursion"`
+}
+
+// replSettingNames are the settings :set knows, with what they limit.
+var replSettingNames = map[string]func(*replSettings) *int{
+	"steps":     func(s *replSettings) *int { return &s.Steps },
+	"recursion": func(s *replSettings) *int { return &s.Recursion },
+}
+
+func newReplSession() *replSession {
+	s := &replSession{
+		env:       make(Env),
+		settings:  replSettings{Recursion: DefaultRecursionLimit},
+		functions: make(map[string]string),
+	}
+	for name, fn := range operatorFunctions {
+		s.env[name] = FuncValue(fn)
+	}
+	return s
+}
+
+type replCommand struct {
+	help string
+	run  func(s *replSession, arg string) (string, bool)
+}
+
+// replCommands are the lines starting with ':' that control the session
+// rather than being evaluated. Their run functions return what to print and
+// whether to end the session.
+var replCommands map[string]replCommand
+
+func init() {
+	replCommands = map[string]replCommand{
+		":help": {"list the commands", func(s *replSession, arg string) (string, bool) {
+			names := make([]string, 0, len(replCommands))
+			for name := range replCommands {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			lines := make([]string, len(names))
+			for i, name := range names {
+				lines[i] = fmt.Sprintf("%-8s %s", name, replCommands[name].help)
+			}
+			return strings.Join(lines, "\n"), false
+		}},
+		":vars": {"list the variables and their values", func(s *replSession, arg string) (string, bool) {
+			var lines []string
+			for _, name := range sortedNames(s.env) {
+				if s.env[name].Kind() != FuncKind {
+					lines = append(lines, name+" = "+s.env[name].String())
+				}
+			}
+			return strings.Join(lines, "\n"), false
+		}},
+		":doc": {"describe an operator or function, as :doc mod", func(s *replSession, arg string) (string, bool) {
+			if arg == "" {
+				return "usage: :doc name", false
+			}
+			return describe(arg), false
+		}},
+		":ops": {"list the operators, loosest first", func(s *replSession, arg string) (string, bool) {
+			return formatOperators(Operators()), false
+		}},
+		":set": {"show the settings, or change one, as :set steps 1000", func(s *replSession, arg string) (string, bool) {
+			if arg == "" {
+				var lines []string
+				for _, name := range []string{"recursion", "steps"} {
+					lines = append(lines, fmt.Sprintf("%s = %d", name, *replSettingNames[name](&s.settings)))
+				}
+				return strings.Join(lines, "\n"), false
+			}
+			name, text, _ := strings.Cut(arg, " ")
+			setting, ok := replSettingNames[name]
+			if !ok {
+				return fmt.Sprintf("unknown setting %s, try steps or recursion", name), false
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(text))
+			if err != nil || n < 0 {
+				return "usage: :set " + name + " n, with n a whole number", false
+			}
+			*setting(&s.settings) = n
+			return fmt.Sprintf("%s = %d", name, n), false
+		}},
+		":save": {"save the variables, functions and settings to a file, as :save session.json", func(s *replSession, arg string) (string, bool) {
+			if arg == "" {
+				return "usage: :save file", false
+			}
+			skipped, err := s.save(arg)
+			if err != nil {
+				return err.Error(), false
+			}
+			if len(skipped) > 0 {
+				return fmt.Sprintf("saved %s without %s, which cannot be serialized", arg, strings.Join(skipped, ", ")), false
+			}
+			return "saved " + arg, false
+		}},
+		":load": {"restore a session saved to a file", func(s *replSession, arg string) (string, bool) {
+			if arg == "" {
+				return "usage: :load file", false
+			}
+			if err := s.load(arg); err != nil {
+				return err.Error(), false
+			}
+			return "loaded " + arg, false
+		}},
+		":quit": {"end the session", func(s *replSession, arg string) (string, bool) {
+			return "", true
+		}},
+	}
+}
+
+func sortedNames(env Env) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run handles one line, returning what to print and whether the session
+// has ended.
+func (s *replSession) run(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ":") {
+		return s.eval(line), false
+	}
+	name, arg, _ := strings.Cut(line, " ")
+	command, ok := replCommands[name]
+	if !ok {
+		return fmt.Sprintf("unknown command %s, try :help", name), false
+	}
+	return command.run(s, strings.TrimSpace(arg))
+}
+
+// readLines evaluates the entries of in without line editing or prompts,
+// for input that is not a terminal.
+func (s *replSession) readLines(in *bufio.Reader, out io.Writer) error {
+	read := func(prompt string) (string, error) {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimSuffix(line, "\n"), err
+	}
+	for {
+		entry, err := s.readEntry(read)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		output, quit := s.run(entry)
+		if output != "" {
+			fmt.Fprintln(out, output)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// evaluator returns an Evaluator over the session's variables with its
+// settings, printing to out.
+func (s *replSession) evaluator(out io.Writer) *Evaluator {
+	return NewEvaluator(s.env, WithOutput(out), WithStepLimit(s.settings.Steps), WithRecursionLimit(s.settings.Recursion))
+}
+
+// eval evaluates line and binds its result, returning the result with the
+// name it was bound to, or the error raised.
+func (s *replSession) eval(line string) string {
+	expr, err := Parse(line)
+	if err != nil {
+		return err.Error()
+	}
+	var printed strings.Builder
+	value, err := s.evaluator(&printed).Eval(expr)
+	if err != nil {
+		return printed.String() + err.Error()
+	}
+	s.results += 1
+	name := "_" + strconv.Itoa(s.results)
+	s.env[name] = value
+	s.env["ans"] = value
+	if value.Kind() == FuncKind {
+		s.functions[name] = line
+		s.functions["ans"] = line
+	} else {
+		delete(s.functions, "ans")
+	}
+	return printed.String() + name + " = " + value.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// replTranscript feeds input to a new session and returns what it printed.
+func replTranscript(t *testing.T, input string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := newReplSession().readLines(bufio.NewReader(strings.NewReader(input)), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestReplResults(t *testing.T) {
+	got := replTranscript(t, "2 + 3\nans * 2\n1 / 0\n_1 + _2\nans\n")
+	want := "_1 = 5\n_2 = 10\ndivision by zero at column 3\n_3 = 15\n_4 = 15\n"
+	if got != want {
+		t.Errorf("transcript is\n%s\nwant\n%s", got, want)
+	}
+	if got := replTranscript(t, "ans\n"); !strings.Contains(got, "undefined variable 'ans'") {
+		t.Errorf("ans before any result gives %q", got)
+	}
+}
+
+func TestReplContinuation(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"1 +", true},
+		{"max(1,", true},
+		{"(1 + 2", true},
+		{"1 + 2", false},
+		{"1 + )", false},
+		{"", false},
+		{":help", false},
+	}
+	for _, tt := range tests {
+		if got := incomplete(tt.src); got != tt.want {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.src, got, tt.want)
+		}
+	}
+	var prompts []string
+	lines := []string{"(1 +", "2", ") * 3", "4"}
+	read := func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+	entry, err := newReplSession().readEntry(read)
+	if err != nil || entry != "(1 +\n2\n) * 3" {
+		t.Errorf("readEntry = %q, %v, want three lines", entry, err)
+	}
+	if want := []string{replPrompt, continuationPrompt, continuationPrompt}; !equalStrings(prompts, want) {
+		t.Errorf("prompts = %q, want %q", prompts, want)
+	}
+	got := replTranscript(t, "max(1,\n  2) *\n3\n1 +\n")
+	want := "_1 = 6\nunexpected end of expression after '+' at column 3\n"
+	if got != want {
+		t.Errorf("transcript is\n%s\nwant\n%s", got, want)
+	}
+}
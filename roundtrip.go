@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+var randomNames = []string{"a", "b", "x", "y", "total"}
+
+var randomInfixOps = []OpKind{AddOp, SubOp, MulOp, DivOp, PowOp, EqOp, LtOp, GeOp, AndOp, OrOp, ShlOp}
+
+var randomPrefixOps = []OpKind{AddOp, SubOp, NotOp}
+
+// Random generates an arbitrary expression at most depth levels deep, using
+// only constructs the parser can produce, for property-based tests.
+func Random(rng *rand.Rand, depth int) Expression {
+	if depth <= 1 || rng.Intn(4) == 0 {
+		switch rng.Intn(3) {
+		case 0:
+			return IntegerToken{value: int64(rng.Intn(100))}
+		case 1:
+			return IdentifierToken{name: randomNames[rng.Intn(len(randomNames))]}
+		default:
+			return StringToken{value: randomNames[rng.Intn(len(randomNames))]}
+		}
+	}
+	switch rng.Intn(6) {
+	case 0:
+		return &PrefixExpression{
+			op:  randomPrefixOps[rng.Intn(len(randomPrefixOps))],
+			rhs: Random(rng, depth-1),
+		}
+	case 1:
+		args := make([]Expression, rng.Intn(4))
+		for i := range args {
+			args[i] = Random(rng, depth-1)
+		}
+		return &CallExpression{
+			name: randomNames[rng.Intn(len(randomNames))],
+			args: args,
+		}
+	}
+	return &InfixExpression{
+		lhs: Random(rng, depth-1),
+		rhs: Random(rng, depth-1),
+		op:  randomInfixOps[rng.Intn(len(randomInfixOps))],
+	}
+}
+
+// CheckRoundTrip reports an error unless formatting e and parsing the
+// result yields a tree equal to e.
+func CheckRoundTrip(e Expression) error {
+	src := Format(e)
+	parsed, err := Parse(src)
+	if err != nil {
+		return fmt.Errorf("round trip of %s: %w", src, err)
+	}
+	if !equalExpr(e, parsed) {
+		return fmt.Errorf("round trip of %s: reparsed as %s", src, Format(parsed))
+	}
+	return nil
+}
+
+// CheckFoldEquivalence reports an error unless e and Fold(e) evaluate to
+// the same value, or both fail, against env.
+func CheckFoldEquivalence(e Expression, env Env) error {
+	want, wantErr := evalExpression(e, env)
+	folded := Fold(e)
+	got, gotErr := evalExpression(folded, env)
+	if (wantErr == nil) != (gotErr == nil) {
+		return fmt.Errorf("folding %s to %s: error changed from %v to %v", Format(e), Format(folded), wantErr, gotErr)
+	}
+	if wantErr == nil && !want.Equal(got) {
+		return fmt.Errorf("folding %s to %s: value changed from %s to %s", Format(e), Format(folded), want, got)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRandomRoundTrips(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	env := Env{
+		"a":     IntValue(3),
+		"b":     IntValue(-2),
+		"x":     FloatValue(0.5),
+		"y":     BoolValue(true),
+		"total": IntValue(10),
+	}
+	for i := 0; i < 2000; i++ {
+		e := Random(rng, 6)
+		if err := CheckRoundTrip(e); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckFoldEquivalence(e, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckRoundTripReportsDifferences(t *testing.T) {
+	// A negative literal formats as a negation, which parses as a prefix
+	// expression.
+	e := &InfixExpression{op: AddOp, lhs: IdentifierToken{name: "a"}, rhs: IntegerToken{value: -1}}
+	if err := CheckRoundTrip(e); err == nil || !strings.Contains(err.Error(), "reparsed as") {
+		t.Errorf("CheckRoundTrip(a + (-1 literal)) = %v, want a difference", err)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import "fmt"
+
+// Rule is a named predicate: an expression that evaluates to a bool.
+type Rule struct {
+	Name string
+	Expr string
+}
+
+// RuleSet is a set of rules compiled together so they can be matched
+// against many events cheaply. Identical subexpressions are compiled once
+// across all the rules and evaluated at most once per event, so functions
+// in a rule set's Env must return the same result for the same arguments
+// while an event is matched.
+//
+// Rules combine predicates with all(...), any(...) and not(x), or with &&,
+// || and !, which short-circuit: all and && stop at the first false
+// operand and any and || at the first true one. An Env may define
+// functions with these names instead.
+type RuleSet struct {
+	env   Env
+	nodes []ruleNode
+	rules []compiledRule
+	// index finds the nodes already compiled for a subexpression.
+	index map[uint64][]int
+}
+
+type compiledRule struct {
+	name string
+	root int
+}
+
+type ruleNodeKind int
+
+const (
+	ruleConstant ruleNodeKind = iota
+	ruleVariable
+	rulePrefix
+	ruleInfix
+	ruleCall
+	ruleAll
+	ruleAny
+	ruleNot
+	// ruleTree nodes are evaluated by walking the tree, for the calls
+	// whose arguments are not all evaluated first.
+	ruleTree
+)
+
+// ruleNode is one distinct subexpression of a rule set. args are the
+// indexes of its operands, which always precede it in RuleSet.nodes.
+type ruleNode struct {
+	kind  ruleNodeKind
+	expr  Expression
+	value Value
+	args  []int
+}
+
+// ruleResult caches the evaluation of a node for one event.
+type ruleResult struct {
+	done  bool
+	value Value
+	err   error
+}
+
+// NewRuleSet parses and compiles rules, which are matched against events
+// with env supplying functions and any variables events do not.
+func NewRuleSet(rules []Rule, env Env) (*RuleSet, error) {
+	rs := &RuleSet{env: env, index: make(map[uint64][]int)}
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %s: defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+		expr, err := Parse(rule.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		root, err := rs.compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		rs.rules = append(rs.rules, compiledRule{name: rule.Name, root: root})
+	}
+	rs.index = nil
+	return rs, nil
+}
+
+// compile returns the index of the node for e, adding nodes for it and its
+// operands unless an identical subexpression was compiled before.
+func (rs *RuleSet) compile(e Expression) (int, error) {
+	key := hashExpr(e)
+	for _, i := range rs.index[key] {
+		if equalExpr(rs.nodes[i].expr, e) {
+			return i, nil
+		}
+	}
+	node := ruleNode{expr: e}
+	var operands []Expression
+	switch v := e.(type) {
+	case IntegerToken:
+		node.kind, node.value = ruleConstant, IntValue(v.value)
+	case FloatToken:
+		node.kind, node.value = ruleConstant, FloatValue(v.value)
+	case StringToken:
+		node.kind, node.value = ruleConstant, StringValue(v.value)
+	case IdentifierToken:
+		node.kind = ruleVariable
+	case *PrefixExpression:
+		node.kind, operands = rulePrefix, []Expression{v.rhs}
+		if v.op == NotOp && operatorFunction(v.op, false) == "" {
+			node.kind = ruleNot
+		}
+	case *InfixExpression:
+		node.kind, operands = ruleInfix, []Expression{v.lhs, v.rhs}
+		if isShortCircuit(v) {
+			node.kind = ruleAll
+			if v.op == OrOp {
+				node.kind = ruleAny
+			}
+		}
+	case *CallExpression:
+		if isTry(v) || isLet(v) || isLambda(v) {
+			node.kind = ruleTree
+			break
+		}
+		node.kind, operands = ruleCall, v.args
+		if _, defined := rs.env[v.name]; !defined {
+			switch {
+			case v.name == "all":
+				node.kind = ruleAll
+			case v.name == "any":
+				node.kind = ruleAny
+			case v.name == "not" && len(v.args) == 1:
+				node.kind = ruleNot
+			}
+		}
+	case Hole:
+		return 0, fmt.Errorf("unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	default:
+		return 0, fmt.Errorf("cannot compile %T", e)
+	}
+	for _, operand := range operands {
+		i, err := rs.compile(operand)
+		if err != nil {
+			return 0, err
+		}
+		node.args = append(node.args, i)
+	}
+	rs.nodes = append(rs.nodes, node)
+	i := len(rs.nodes) - 1
+	rs.index[key] = append(rs.index[key], i)
+	return i, nil
+}
+
+// Match returns the names of the rules event satisfies, in the order the
+// rules were given. Event fields are bound as variables, converted as
+// JSON values are. A rule that fails to evaluate or does not evaluate to a
+// bool does not fire. Match may be called concurrently.
+func (rs *RuleSet) Match(event map[string]interface{}) []string {
+	m := ruleMatch{rs: rs, results: make([]ruleResult, len(rs.nodes)), event: make(Env, len(event))}
+	for name, raw := range event {
+		if value, err := valueFromJSON(raw); err == nil {
+			m.event[name] = value
+		}
+	}
+	var fired []string
+	for _, rule := range rs.rules {
+		if value, err := m.eval(rule.root); err == nil && value.Kind() == BoolKind && value.Bool() {
+			fired = append(fired, rule.name)
+		}
+	}
+	return fired
+}
+
+// ruleMatch is the state of matching one event.
+type ruleMatch struct {
+	rs      *RuleSet
+	event   Env
+	results []ruleResult
+	// env holds the event fields over the rule set's Env once a ruleTree
+	// node needs them together.
+	env Env
+}
+
+func (m *ruleMatch) eval(i int) (Value, error) {
+	result := &m.results[i]
+	if !result.done {
+		result.value, result.err = m.evalNode(&m.rs.nodes[i])
+		result.done = true
+	}
+	return result.value, result.err
+}
+
+func (m *ruleMatch) evalNode(node *ruleNode) (Value, error) {
+	switch node.kind {
+	case ruleConstant:
+		return node.value, nil
+	case ruleVariable:
+		v := node.expr.(IdentifierToken)
+		if value, ok := m.event[v.name]; ok {
+			return value, nil
+		}
+		return lookupVariable(v.name, v.pos, m.rs.env)
+	case ruleAll, ruleAny:
+		// all is false at the first false argument, any true at the first
+		// true one; the remaining arguments are not evaluated.
+		stop := node.kind == ruleAny
+		for _, arg := range node.args {
+			b, err := m.evalBool(arg)
+			if err != nil {
+				return Value{}, err
+			}
+			if b == stop {
+				return BoolValue(stop), nil
+			}
+		}
+		return BoolValue(!stop), nil
+	case ruleNot:
+		b, err := m.evalBool(node.args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		return BoolValue(!b), nil
+	case ruleTree:
+		if m.env == nil {
+			m.env = make(Env, len(m.rs.env)+len(m.event))
+			for name, value := range m.rs.env {
+				m.env[name] = value
+			}
+			for name, value := range m.event {
+				m.env[name] = value
+			}
+		}
+		return evalExpression(node.expr, m.env)
+	}
+	args := make([]Value, len(node.args))
+	for j, arg := range node.args {
+		value, err := m.eval(arg)
+		if err != nil {
+			return Value{}, err
+		}
+		args[j] = value
+	}
+	switch v := node.expr.(type) {
+	case *PrefixExpression:
+		if name := operatorFunction(v.op, false); name != "" {
+			return callOperator(name, v.pos, m.rs.env, args)
+		}
+		return applyPrefix(v.op, args[0])
+	case *InfixExpression:
+		if name := operatorFunction(v.op, true); name != "" {
+			return callOperator(name, v.pos, m.rs.env, args)
+		}
+		return applyInfix(v.op, args[0], args[1])
+	case *CallExpression:
+		return callFunction(v.name, v.pos, m.rs.env, args)
+	}
+	return Value{}, fmt.Errorf("cannot evaluate %T", node.expr)
+}
+
+func (m *ruleMatch) evalBool(i int) (bool, error) {
+	value, err := m.eval(i)
+	if err != nil {
+		return false, err
+	}
+	if value.Kind() != BoolKind {
+		pos := m.rs.nodes[i].expr.getPosition()
+		return false, fmt.Errorf("expected bool, got %s at column %d", value.Kind(), pos+1)
+	}
+	return value.Bool(), nil
+}
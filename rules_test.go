@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleSet(t *testing.T) {
+	calls := 0
+	env := Env{
+		"score": FuncValue(func(args []Value) (Value, error) {
+			calls++
+			return args[0], nil
+		}),
+		"limit": IntValue(10),
+	}
+	rs, err := NewRuleSet([]Rule{
+		{"big", "score(amount) > limit"},
+		{"huge", "score(amount) > limit * 10"},
+		{"flagged", `country == "XX" && score(amount) > 0`},
+		{"either", "any(vip, not(score(amount) > limit))"},
+		{"bad", `amount + "x"`},
+		{"tried", "try(amount / zero, 0) == 0"},
+	}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event map[string]interface{}
+		want  []string
+	}{
+		{map[string]interface{}{"amount": 50, "country": "US", "vip": false, "zero": 0}, []string{"big", "tried"}},
+		{map[string]interface{}{"amount": 500.0, "country": "XX", "vip": true}, []string{"big", "huge", "flagged", "either", "tried"}},
+		{map[string]interface{}{"amount": 5, "country": "XX", "vip": false}, []string{"flagged", "either", "tried"}},
+		{map[string]interface{}{"country": "US"}, []string{"tried"}},
+	}
+	for _, tt := range tests {
+		calls = 0
+		got := rs.Match(tt.event)
+		if !equalStrings(got, tt.want) {
+			t.Errorf("Match(%v) = %q, want %q", tt.event, got, tt.want)
+		}
+		if _, ok := tt.event["amount"]; ok && calls != 1 {
+			t.Errorf("Match(%v) called score %d times, want once", tt.event, calls)
+		}
+	}
+
+	calls = 0
+	rs, err = NewRuleSet([]Rule{
+		{"and", `country == "XX" && score(amount) > 0`},
+		{"all", `all(country == "XX", score(amount) > 0)`},
+		{"or", `country == "US" || score(amount) > 0`},
+	}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.Match(map[string]interface{}{"amount": 1, "country": "US"}); !equalStrings(got, []string{"or"}) || calls != 0 {
+		t.Errorf("short-circuiting rules fire %q and call score %d times, want [\"or\"] and none", got, calls)
+	}
+}
+
+func TestRuleSetSharesSubexpressions(t *testing.T) {
+	one, err := NewRuleSet([]Rule{{"a", "price * qty > 100"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := NewRuleSet([]Rule{{"a", "price * qty > 100"}, {"b", "price * qty < 10 || price * qty > 100"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b adds only 10, < and || to the nodes of a.
+	if len(both.nodes) != len(one.nodes)+3 {
+		t.Errorf("rule set has %d nodes, want %d", len(both.nodes), len(one.nodes)+3)
+	}
+}
+
+func TestNewRuleSetErrors(t *testing.T) {
+	tests := []struct {
+		rules []Rule
+		want  string
+	}{
+		{[]Rule{{"a", "1"}, {"a", "2"}}, "rule a: defined twice"},
+		{[]Rule{{"a", "1 +"}}, "rule a: unexpected end of expression"},
+		{[]Rule{{"a", "x"}, {"b", "_ > 1"}}, "rule b: unfilled hole #1 at column 1"},
+	}
+	for _, tt := range tests {
+		if _, err := NewRuleSet(tt.rules, nil); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("NewRuleSet(%v) = %v, want %q", tt.rules, err, tt.want)
+		}
+	}
+}
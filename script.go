@@ -0,0 +1,605 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Script is a sequence of statements run in order against an
+// environment, for calculations that do not fit in one expression.
+// Statements are separated by newlines or semicolons, and # starts a
+// comment running to the end of the line. A statement is one of
+//
+//	name = expr                 assigns the value of expr to name
+//	name(params) = expr         defines the function name
+//	for name in a..b { ... }    runs the body with name = a, a+1, ..., b
+//	while cond { ... }          runs the body for as long as cond is true
+//	include "file"              runs the script in file here
+//	expr                        evaluates expr
+//
+// and the value of a script is that of the last expression statement run.
+// Functions are variables like any other, so a function can be passed to
+// another or assigned a lambda, and its body reads its parameters and the
+// variables of the environment as they are when it is called. A lambda
+// returned by a function keeps the parameters of the call that made it.
+// A loop can run for ever, so a script from an untrusted source should be
+// run by an Evaluator with WithStepLimit or a deadline, which bound the
+// whole run: each iteration of a loop counts as a step. Calls of functions
+// nest no deeper than the Evaluator's recursion limit.
+//
+// An included script runs with the same environment, so what it assigns
+// and defines is there for the statements after the include. Its file is
+// looked for relative to the directory of the including script, or the
+// working directory for a script not read from a file, and then in the
+// directories given by WithIncludePath. A script including itself, however
+// indirectly, is an error.
+type Script struct {
+	statements []statement
+	// path is the file the script was read from, if any.
+	path string
+}
+
+type statement struct {
+	line int
+	run  func(r *scriptRun) error
+}
+
+// ScriptError is an error in a script, located by the line of the
+// statement it occurred in and the file of the script, if it was read
+// from one.
+type ScriptError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e ScriptError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ParseScript parses the statements of src. Errors are ScriptErrors.
+func ParseScript(src string) (*Script, error) {
+	src = stripComments(src)
+	statements, err := parseStatements(src, 0, len(src))
+	if err != nil {
+		return nil, err
+	}
+	return &Script{statements: statements}, nil
+}
+
+// ParseScriptFile parses the script in the file at path.
+func ParseScriptFile(path string) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseScript(string(src))
+	if err != nil {
+		if scriptErr, ok := err.(ScriptError); ok {
+			scriptErr.Path = path
+			err = scriptErr
+		}
+		return nil, err
+	}
+	s.path = path
+	return s, nil
+}
+
+// WithIncludePath looks for the files scripts include in dirs, in order,
+// after the directory of the including script.
+func WithIncludePath(dirs ...string) EvalOption {
+	return func(ev *Evaluator) {
+		ev.includePath = dirs
+	}
+}
+
+// stripComments blanks out the comments of src, keeping offsets.
+func stripComments(src string) string {
+	b := []byte(src)
+	for k := 0; k < len(b); k++ {
+		switch b[k] {
+		case '"':
+			for k++; k < len(b) && b[k] != '"' && b[k] != '\n'; k++ {
+			}
+		case '#':
+			for ; k < len(b) && b[k] != '\n'; k++ {
+				b[k] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+func parseStatements(src string, from int, to int) ([]statement, error) {
+	var statements []statement
+	for _, s := range splitScript(src, from, to) {
+		for s[0] < s[1] && strings.IndexByte(" \t\r\n", src[s[0]]) >= 0 {
+			s[0]++
+		}
+		for s[1] > s[0] && strings.IndexByte(" \t\r\n", src[s[1]-1]) >= 0 {
+			s[1]--
+		}
+		if s[0] == s[1] {
+			continue
+		}
+		line := 1 + strings.Count(src[:s[0]], "\n")
+		run, err := parseStatement(src, s[0], s[1])
+		if err != nil {
+			if _, ok := err.(ScriptError); !ok {
+				err = ScriptError{Line: line, Err: err}
+			}
+			return nil, err
+		}
+		statements = append(statements, statement{line: line, run: run})
+	}
+	return statements, nil
+}
+
+// splitScript returns the bounds of the statements separated by newlines
+// or semicolons in src[from:to], outside groups, blocks and strings.
+func splitScript(src string, from int, to int) [][2]int {
+	var statements [][2]int
+	depth := 0
+	start := from
+	for k := from; k < to; k++ {
+		switch src[k] {
+		case '"':
+			for k++; k < to && src[k] != '"'; k++ {
+			}
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ';', '\n':
+			if depth == 0 {
+				statements = append(statements, [2]int{start, k})
+				start = k + 1
+			}
+		}
+	}
+	return append(statements, [2]int{start, to})
+}
+
+// parseStatement parses the statement at src[from:to], which has no
+// surrounding space.
+func parseStatement(src string, from int, to int) (func(r *scriptRun) error, error) {
+	text := src[from:to]
+	if name, params, value, ok := definition(text); ok {
+		return parseDefinition(name, params, src, to-len(value), to)
+	}
+	if name, value, ok := assignment(text); ok {
+		e, err := parseAt(src, to-len(value), to)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *scriptRun) error {
+			v, err := r.eval(e)
+			if err != nil {
+				return err
+			}
+			r.env[name] = v
+			return nil
+		}, nil
+	}
+	if rest, ok := keyword(text, "for"); ok {
+		return parseFor(src, to-len(rest), to)
+	}
+	if rest, ok := keyword(text, "while"); ok {
+		return parseWhile(src, to-len(rest), to)
+	}
+	if rest, ok := keyword(text, "include"); ok {
+		e, err := parseAt(src, to-len(rest), to)
+		if err != nil {
+			return nil, err
+		}
+		file, ok := e.(StringToken)
+		if !ok {
+			return nil, fmt.Errorf("expected include \"file\"")
+		}
+		return func(r *scriptRun) error {
+			return r.include(file.value)
+		}, nil
+	}
+	e, err := parseAt(src, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *scriptRun) error {
+		v, err := r.eval(e)
+		if err != nil {
+			return err
+		}
+		r.result = v
+		return nil
+	}, nil
+}
+
+// parseAt parses the expression at src[from:to], placing it at the column
+// it starts at in its line so that errors give the columns of the script.
+func parseAt(src string, from int, to int) (Expression, error) {
+	line := strings.LastIndexByte(src[:from], '\n') + 1
+	return Parse(strings.Repeat(" ", from-line) + src[from:to])
+}
+
+// assignment splits the statement name = value.
+func assignment(text string) (name string, value string, ok bool) {
+	n := identifierLength(text)
+	rest := strings.TrimLeft(text[n:], " \t")
+	if n == 0 || !strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "==") || strings.HasPrefix(rest, "=~") || strings.HasPrefix(rest, "=>") {
+		return "", "", false
+	}
+	return text[:n], rest[1:], true
+}
+
+// definition splits the statement name(params) = body. It reports false
+// for anything else, such as the comparison f(x) == y.
+func definition(text string) (name string, params []IdentifierToken, body string, ok bool) {
+	n := identifierLength(text)
+	if n == 0 || n == len(text) || text[n] != '(' {
+		return "", nil, "", false
+	}
+	end := strings.IndexByte(text, ')')
+	if end < 0 {
+		return "", nil, "", false
+	}
+	if strings.TrimSpace(text[n+1:end]) != "" {
+		for _, param := range strings.Split(text[n+1:end], ",") {
+			param = strings.TrimSpace(param)
+			if param == "" || identifierLength(param) != len(param) {
+				return "", nil, "", false
+			}
+			params = append(params, IdentifierToken{name: param})
+		}
+	}
+	rest := strings.TrimLeft(text[end+1:], " \t")
+	if !strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "==") || strings.HasPrefix(rest, "=~") || strings.HasPrefix(rest, "=>") {
+		return "", nil, "", false
+	}
+	return text[:n], params, rest[1:], true
+}
+
+// parseDefinition parses the body at src[from:to] of the function name and
+// returns the statement defining it.
+func parseDefinition(name string, params []IdentifierToken, src string, from int, to int) (func(r *scriptRun) error, error) {
+	seen := make(map[string]bool, len(params))
+	for _, param := range params {
+		if seen[param.name] {
+			return nil, fmt.Errorf("duplicate parameter '%s' of %s", param.name, name)
+		}
+		seen[param.name] = true
+	}
+	body, err := parseAt(src, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *scriptRun) error {
+		if r.policy != nil {
+			if err := r.policy.Check(body); err != nil {
+				return err
+			}
+		}
+		r.env[name] = r.function(name, params, body, nil)
+		return nil
+	}, nil
+}
+
+// keyword reports whether text starts with the keyword kw followed by
+// space, returning what follows it.
+func keyword(text string, kw string) (string, bool) {
+	if !strings.HasPrefix(text, kw) || len(text) == len(kw) || strings.IndexByte(" \t\r\n", text[len(kw)]) < 0 {
+		return "", false
+	}
+	return strings.TrimLeft(text[len(kw):], " \t\r\n"), true
+}
+
+// loopBody splits src[from:to] into the header before a body in braces
+// ending it, returning where the body starts, and the statements of the
+// body.
+func loopBody(src string, from int, to int, loop string) (int, []statement, error) {
+	open := -1
+	depth := 0
+	for k := from; k < to; k++ {
+		switch src[k] {
+		case '"':
+			for k++; k < to && src[k] != '"'; k++ {
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '{':
+			if depth == 0 && closingBrace(src, k+1, to) == to-1 {
+				open = k
+				k = to
+			}
+		}
+	}
+	if open < 0 {
+		return 0, nil, fmt.Errorf("expected { body } ending %s", loop)
+	}
+	body, err := parseStatements(src, open+1, to-1)
+	return open, body, err
+}
+
+// parseFor parses the rest of a for statement, name in a..b { body }.
+func parseFor(src string, from int, to int) (func(r *scriptRun) error, error) {
+	open, body, err := loopBody(src, from, to, "for")
+	if err != nil {
+		return nil, err
+	}
+	header := src[from:open]
+	n := identifierLength(header)
+	rest, ok := keyword(strings.TrimLeft(header[n:], " \t\r\n"), "in")
+	if n == 0 || !ok {
+		return nil, fmt.Errorf("expected for name in a..b")
+	}
+	name := header[:n]
+	dots := rangeDots(rest)
+	if dots < 0 {
+		return nil, fmt.Errorf("expected a range a..b after in")
+	}
+	dots += open - len(rest)
+	first, err := parseAt(src, open-len(rest), dots)
+	if err != nil {
+		return nil, err
+	}
+	last, err := parseAt(src, dots+2, open)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *scriptRun) error {
+		a, err := r.eval(first)
+		if err != nil {
+			return err
+		}
+		b, err := r.eval(last)
+		if err != nil {
+			return err
+		}
+		if a.Kind() != IntKind || b.Kind() != IntKind {
+			return fmt.Errorf("for takes a range of ints, not %s..%s", a.Kind(), b.Kind())
+		}
+		for i := a.Int(); i <= b.Int(); i++ {
+			if err := r.step(first); err != nil {
+				return err
+			}
+			r.env[name] = IntValue(i)
+			if err := r.exec(body); err != nil {
+				return err
+			}
+			if i == b.Int() {
+				break
+			}
+		}
+		return nil
+	}, nil
+}
+
+// rangeDots returns the offset of the .. of the range in text, outside
+// groups and strings, or -1.
+func rangeDots(text string) int {
+	depth := 0
+	for k := 0; k+1 < len(text); k++ {
+		switch text[k] {
+		case '"':
+			for k++; k < len(text) && text[k] != '"'; k++ {
+			}
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case '.':
+			if depth == 0 && text[k+1] == '.' {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// parseWhile parses the rest of a while statement, cond { body }.
+func parseWhile(src string, from int, to int) (func(r *scriptRun) error, error) {
+	open, body, err := loopBody(src, from, to, "while")
+	if err != nil {
+		return nil, err
+	}
+	cond, err := parseAt(src, from, open)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *scriptRun) error {
+		for {
+			v, err := r.eval(cond)
+			if err != nil {
+				return err
+			}
+			if v.Kind() != BoolKind {
+				return fmt.Errorf("while condition is %s, not bool", v.Kind())
+			}
+			if !v.Bool() {
+				return nil
+			}
+			if err := r.exec(body); err != nil {
+				return err
+			}
+		}
+	}, nil
+}
+
+// scriptRun is the state of running a script: one tree-walking
+// evaluation, so that the step limit and deadline apply to the whole run,
+// and the value of the last expression statement.
+type scriptRun struct {
+	*evaluation
+	policy      *Policy
+	includePath []string
+	result      Value
+	// path is the file of the script running, and including the absolute
+	// paths of the scripts including it, outermost first, as well as it.
+	path      string
+	including []string
+	// included holds the scripts included so far by absolute path.
+	included map[string]*Script
+}
+
+// Run runs s with the environment of ev, which its assignments modify, and
+// returns the value of the last expression statement run. Scripts are run
+// by walking the tree whatever the backend.
+func (ev *Evaluator) Run(s *Script) (Value, error) {
+	return ev.RunContext(context.Background(), s)
+}
+
+// RunContext runs s like Run, stopping with an error wrapping ctx.Err()
+// once ctx is done.
+func (ev *Evaluator) RunContext(ctx context.Context, s *Script) (Value, error) {
+	r := &scriptRun{
+		evaluation:  ev.newEvaluation(),
+		policy:      ev.policy,
+		includePath: ev.includePath,
+		path:        s.path,
+		included:    make(map[string]*Script),
+	}
+	r.ctx = ctx
+	if r.env == nil {
+		r.env = make(Env)
+	}
+	if s.path != "" {
+		abs, err := filepath.Abs(s.path)
+		if err != nil {
+			return Value{}, err
+		}
+		r.including = []string{abs}
+	}
+	err := r.exec(s.statements)
+	return r.result, err
+}
+
+// include runs the script in the file name, found as Script describes.
+// Its expression statements do not change the value of the includer.
+func (r *scriptRun) include(name string) error {
+	path, err := r.find(name)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for i, including := range r.including {
+		if including == abs {
+			cycle := append(r.including[i:len(r.including):len(r.including)], abs)
+			return fmt.Errorf("include cycle: %s", strings.Join(cycle, " includes "))
+		}
+	}
+	s, ok := r.included[abs]
+	if !ok {
+		if s, err = ParseScriptFile(path); err != nil {
+			return err
+		}
+		r.included[abs] = s
+	}
+	outer, result := r.path, r.result
+	r.path = path
+	r.including = append(r.including, abs)
+	err = r.exec(s.statements)
+	r.including = r.including[:len(r.including)-1]
+	r.path, r.result = outer, result
+	return err
+}
+
+// find returns the path of the file name, looked for relative to the
+// running script and then in the include path.
+func (r *scriptRun) find(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	dirs := append([]string{filepath.Dir(r.path)}, r.includePath...)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find %q in %s", name, strings.Join(dirs, ", "))
+}
+
+func (r *scriptRun) exec(statements []statement) error {
+	for _, s := range statements {
+		if err := s.run(r); err != nil {
+			if _, ok := err.(ScriptError); !ok {
+				err = ScriptError{Path: r.path, Line: s.line, Err: err}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// eval evaluates e with the variables assigned so far. Memoized values are
+// kept for one expression, since assignments change what they would be.
+func (r *scriptRun) eval(e Expression) (Value, error) {
+	if r.policy != nil {
+		if err := r.policy.Check(e); err != nil {
+			return Value{}, err
+		}
+	}
+	if r.memo != nil {
+		r.memo = make(map[uint64]memoEntry)
+	}
+	return r.evaluation.eval(e)
+}
+
+// runScriptCommand implements the run command, which runs a script file
+// and prints its value.
+func runScriptCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(out)
+	steps := flags.Int("steps", 0, "stop the script after `n` evaluation steps, or 0 for no limit")
+	recursion := flags.Int("recursion", DefaultRecursionLimit, "let calls of the script's functions nest `n` deep")
+	includePath := flags.String("include-path", "", "look for included files in these `dirs`, separated as in PATH")
+	varArgs := addVarFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return flagsExitCode(err)
+	}
+	vars, err := varArgs.load()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return varsExitCode(err)
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(out, "usage: run [-steps n] [-recursion n] [-include-path dirs] [-vars file] [-var name=value] script")
+		return exitParseError
+	}
+	script, err := ParseScriptFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(out, err)
+		if errors.As(err, new(ScriptError)) {
+			return exitParseError
+		}
+		return exitIOError
+	}
+	var dirs []string
+	if *includePath != "" {
+		dirs = filepath.SplitList(*includePath)
+	}
+	value, err := NewEvaluator(vars, WithStepLimit(*steps), WithRecursionLimit(*recursion), WithIncludePath(dirs...), WithOutput(out)).Run(script)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return exitEvalError
+	}
+	fmt.Fprintln(out, value)
+	return exitOK
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runScriptSource(t *testing.T, src string, env Env, opts ...EvalOption) (Value, error) {
+	t.Helper()
+	script, err := ParseScript(src)
+	if err != nil {
+		t.Fatalf("ParseScript(%q): %v", src, err)
+	}
+	return NewEvaluator(env, opts...).Run(script)
+}
+
+func TestScriptLoops(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"total = 0\nfor i in 1..10 { total = total + i }\ntotal", "55"},
+		{"total = 0; for i in 1..10 { total = total + i }; total", "55"},
+		{"n = 0\nfor i in 3..1 { n = n + 1 }\nn", "0"},
+		{"n = 0\nfor i in 1..3 {\n  for j in 1..i { n = n + j }\n}\nn", "10"},
+		{"x = 1\nwhile x < 100 { x = x * 2 }\nx", "128"},
+		{"# powers of two\nx = 1 # start\nwhile x < 5 {\n  x = x * 2\n}\nx", "8"},
+		{"s = \"\"\nfor i in 1..3 { s = s + \"#\" }\ns", `"###"`},
+		{"n = 2\nfor i in n - 1..n * 2 { last = i }\nlast", "4"},
+		{"x = 3\nx == 3", "true"},
+	}
+	for _, tt := range tests {
+		got, err := runScriptSource(t, tt.src, Env{})
+		if err != nil {
+			t.Errorf("Run(%q): %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Run(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"x = 1\ny = x + )", "line 2: unmatched ')' at column 9"},
+		{"for i in 1..3 x = i", "line 1: expected { body } ending for"},
+		{"for i 1..3 { }", "line 1: expected for name in a..b"},
+		{"for i in 3 { }", "line 1: expected a range a..b after in"},
+		{"x = 1\nfor i in 1..2 {\n  y = x +\n}", "line 3: "},
+	}
+	for _, tt := range tests {
+		if _, err := ParseScript(tt.src); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("ParseScript(%q) = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+	runtime := []struct {
+		src  string
+		want string
+	}{
+		{"x = 1\nwhile x { x = 0 }", "line 2: while condition is int, not bool"},
+		{"for i in 1..2.5 { }", "line 1: for takes a range of ints, not int..float"},
+		{"n = 0\nfor i in 1..3 {\n  n = n + missing\n}", "line 3: undefined variable 'missing' at column 11"},
+	}
+	for _, tt := range runtime {
+		if _, err := runScriptSource(t, tt.src, Env{}); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("Run(%q) = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestScriptStepLimit(t *testing.T) {
+	for _, src := range []string{
+		"x = 0\nwhile x >= 0 { x = x + 1 }",
+		"for i in 1..1000000000 { }",
+	} {
+		_, err := runScriptSource(t, src, Env{}, WithStepLimit(1000))
+		if !errors.Is(err, ErrStepLimit) {
+			t.Errorf("Run(%q) = %v, want the step limit", src, err)
+		}
+	}
+	got, err := runScriptSource(t, "total = 0\nfor i in 1..10 { total = total + i }\ntotal", Env{}, WithStepLimit(1000))
+	if err != nil || got.String() != "55" {
+		t.Errorf("Run within the limit = %s, %v", got, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	script, err := ParseScript("while 1 < 2 { }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEvaluator(Env{}).RunContext(ctx, script); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunContext = %v, want the deadline", err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sum.calc")
+	if err := os.WriteFile(path, []byte("total = 0\nfor i in 1..n { total = total + i }\ntotal\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runScriptCommand([]string{"-var", "n=4", path}, &out); code != exitOK || out.String() != "10\n" {
+		t.Errorf("run = %d, %q", code, out.String())
+	}
+	out.Reset()
+	if code := runScriptCommand([]string{"-steps", "20", "-var", "n=100", path}, &out); code != exitEvalError || !strings.Contains(out.String(), "step limit") {
+		t.Errorf("run -steps = %d, %q", code, out.String())
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	parity := "even(n) = n == 0 || odd(n - 1)\nodd(n) = n != 0 && even(n - 1)\n"
+	got, err := runScriptSource(t, parity+"even(10) && odd(7)", Env{})
+	if err != nil || got.String() != "true" {
+		t.Errorf("even(10) && odd(7) = %s, %v", got, err)
+	}
+	_, err = runScriptSource(t, parity+"even(5000)", Env{})
+	var limit RecursionLimitError
+	if !errors.As(err, &limit) || limit.Function != "even" || limit.Limit != DefaultRecursionLimit {
+		t.Errorf("even(5000) = %v, want the recursion limit in even", err)
+	}
+	if got, err := runScriptSource(t, parity+"even(5000)", Env{}, WithRecursionLimit(6000)); err != nil || got.String() != "true" {
+		t.Errorf("even(5000) with a higher limit = %s, %v", got, err)
+	}
+	_, err = runScriptSource(t, "f = fn(n) => n <= 0 || f(n - 1)\nf(50)", Env{}, WithRecursionLimit(10))
+	if !errors.As(err, &limit) || limit.Function != "fn at column 5" || limit.Limit != 10 {
+		t.Errorf("a recursive lambda = %v, want the recursion limit", err)
+	}
+	env := Env{}
+	if _, err := runScriptSource(t, "loop(n) = loop(n + 1)", env); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Parse("try(loop(0), 0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range allBackends {
+		if _, err := NewEvaluator(env, WithBackend(b)).Eval(e); !errors.As(err, &limit) || limit.Function != "loop" {
+			t.Errorf("try with the %s backend = %v, want the recursion limit", b, err)
+		}
+	}
+}
+
+func writeScripts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"main.calc":       "include \"lib/common.calc\"\nsquare(3) * rate",
+		"lib/common.calc": "square(x) = x * x\nrate = 2\n99",
+		"search.calc":     "include \"common.calc\"\nsquare(rate)",
+		"diamond.calc":    "include \"left.calc\"\ninclude \"right.calc\"\nl + r",
+		"left.calc":       "include \"lib/common.calc\"\nl = square(2)",
+		"right.calc":      "include \"lib/common.calc\"\nr = square(3)",
+		"first.calc":      "1\ninclude \"lib/common.calc\"",
+		"a.calc":          "include \"b.calc\"",
+		"b.calc":          "include \"a.calc\"",
+		"self.calc":       "x = 1\ninclude \"self.calc\"",
+		"bad.calc":        "include \"lib/bad.calc\"",
+		"lib/bad.calc":    "x = 1\ny = nope",
+		"broken.calc":     "x = 1\ninclude \"lib/broken.calc\"",
+		"lib/broken.calc": "x = )",
+	})
+	tests := []struct {
+		file string
+		path []string
+		want string
+	}{
+		{"main.calc", nil, "18"},
+		{"search.calc", []string{filepath.Join(dir, "lib")}, "4"},
+		{"diamond.calc", nil, "13"},
+		{"first.calc", nil, "1"},
+	}
+	for _, tt := range tests {
+		script, err := ParseScriptFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewEvaluator(Env{}, WithIncludePath(tt.path...)).Run(script)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s = %s, %v, want %s", tt.file, got, err, tt.want)
+		}
+	}
+	failures := []struct {
+		file string
+		want string
+	}{
+		{"search.calc", `search.calc:1: cannot find "common.calc" in ` + dir},
+		{"a.calc", "include cycle: " + filepath.Join(dir, "a.calc") + " includes " + filepath.Join(dir, "b.calc") + " includes " + filepath.Join(dir, "a.calc")},
+		{"self.calc", "self.calc:2: include cycle"},
+		{"bad.calc", filepath.Join("lib", "bad.calc") + ":2: undefined variable 'nope' at column 5"},
+		{"broken.calc", filepath.Join("lib", "broken.calc") + ":1: "},
+	}
+	for _, tt := range failures {
+		script, err := ParseScriptFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewEvaluator(Env{}).Run(script); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s = %v, want %q", tt.file, err, tt.want)
+		}
+	}
+	if _, err := ParseScript("include common"); err == nil || !strings.Contains(err.Error(), `expected include "file"`) {
+		t.Errorf("include without a string = %v", err)
+	}
+	if _, err := ParseScriptFile(filepath.Join(dir, "lib", "broken.calc")); err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "lib", "broken.calc")+":1: ") {
+		t.Errorf("ParseScriptFile = %v, want the error located in the file", err)
+	}
+	var out bytes.Buffer
+	if code := runScriptCommand([]string{"-include-path", filepath.Join(dir, "lib"), filepath.Join(dir, "search.calc")}, &out); code != exitOK || out.String() != "4\n" {
+		t.Errorf("run -include-path = %d, %q", code, out.String())
+	}
+}
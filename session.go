@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// EnvSnapshot is the serializable part of an Env: every variable except
+// functions, which are Go values and must be bound again by the host, and
+// values such as custom ones that have no JSON form.
+type EnvSnapshot struct {
+	Variables map[string]Value `json:"variables"`
+}
+
+// Snapshot copies the variables of env that are not functions. Variables
+// holding values that cannot be serialized, such as custom values or lists
+// of functions, are left out and their names returned, sorted.
+func (env Env) Snapshot() (EnvSnapshot, []string) {
+	s := EnvSnapshot{Variables: make(map[string]Value)}
+	var skipped []string
+	for _, name := range sortedNames(env) {
+		value := env[name]
+		switch {
+		case value.Kind() == FuncKind:
+		case !serializable(value):
+			skipped = append(skipped, name)
+		default:
+			s.Variables[name] = value
+		}
+	}
+	return s, skipped
+}
+
+// serializable reports whether v and every value in it can be written as
+// JSON.
+func serializable(v Value) bool {
+	switch v.Kind() {
+	case IntKind, FloatKind, BoolKind, StringKind:
+		return true
+	case ListKind:
+		for _, item := range v.List() {
+			if !serializable(item) {
+				return false
+			}
+		}
+		return true
+	case MapKind:
+		for _, item := range v.Map() {
+			if !serializable(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Restore binds the variables of s in env, replacing any with the same name
+// and leaving the others alone.
+func (env Env) Restore(s EnvSnapshot) {
+	for name, value := range s.Variables {
+		env[name] = value
+	}
+}
+
+// jsonValue is the JSON form of a Value, tagged with its kind so integers
+// and floats survive a round trip. Floats that JSON cannot represent are
+// written as the strings "NaN", "+Inf" and "-Inf".
+type jsonValue struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	var payload interface{}
+	switch v.Kind() {
+	case IntKind:
+		payload = v.Int()
+	case FloatKind:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			payload = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		} else {
+			payload = v.Float()
+		}
+	case BoolKind:
+		payload = v.Bool()
+	case StringKind:
+		payload = v.Str()
+	case ListKind:
+		payload = v.List()
+	case MapKind:
+		payload = v.Map()
+	default:
+		return nil, fmt.Errorf("cannot serialize %s value", v.Kind())
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{Kind: v.Kind().String(), Value: raw})
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var jv jsonValue
+	if err := json.Unmarshal(data, &jv); err != nil {
+		return err
+	}
+	var err error
+	switch jv.Kind {
+	case "int":
+		var i int64
+		err = json.Unmarshal(jv.Value, &i)
+		*v = IntValue(i)
+	case "float":
+		var f float64
+		if err = json.Unmarshal(jv.Value, &f); err != nil {
+			var s string
+			if json.Unmarshal(jv.Value, &s) == nil {
+				f, err = strconv.ParseFloat(s, 64)
+			}
+		}
+		*v = FloatValue(f)
+	case "bool":
+		var b bool
+		err = json.Unmarshal(jv.Value, &b)
+		*v = BoolValue(b)
+	case "string":
+		var s string
+		err = json.Unmarshal(jv.Value, &s)
+		*v = StringValue(s)
+	case "list":
+		var l []Value
+		err = json.Unmarshal(jv.Value, &l)
+		*v = ListValue(l)
+	case "map":
+		var m map[string]Value
+		err = json.Unmarshal(jv.Value, &m)
+		*v = MapValue(m)
+	default:
+		return fmt.Errorf("cannot deserialize %q value", jv.Kind)
+	}
+	return err
+}
+
+// sessionVersion is bumped when the session file format changes.
+const sessionVersion = 1
+
+// sessionFile is what :save writes. Results is the number of results
+// computed, so numbering continues after :load. Functions holds the lines
+// that defined the variables bound to lambdas, which :load evaluates
+// again, and Settings those changed with :set.
+type sessionFile struct {
+	Version int `json:"version"`
+	EnvSnapshot
+	Functions map[string]string `json:"functions,omitempty"`
+	Settings  *replSettings     `json:"settings,omitempty"`
+	Results   int               `json:"results"`
+}
+
+// save writes the session to path, returning the names of the variables
+// it had to leave out.
+func (s *replSession) save(path string) ([]string, error) {
+	snapshot, skipped := s.env.Snapshot()
+	functions := make(map[string]string)
+	for name, src := range s.functions {
+		if s.env[name].Kind() == FuncKind {
+			functions[name] = src
+		}
+	}
+	data, err := json.MarshalIndent(sessionFile{
+		Version:     sessionVersion,
+		EnvSnapshot: snapshot,
+		Functions:   functions,
+		Settings:    &s.settings,
+		Results:     s.results,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return skipped, os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// load restores a session saved by save into s, keeping variables not
+// in the file.
+func (s *replSession) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f sessionFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("reading session: %w", err)
+	}
+	if f.Version != sessionVersion {
+		return fmt.Errorf("unsupported session version %d", f.Version)
+	}
+	s.env.Restore(f.EnvSnapshot)
+	if f.Settings != nil {
+		s.settings = *f.Settings
+	}
+	names := make([]string, 0, len(f.Functions))
+	for name := range f.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		src := f.Functions[name]
+		expr, err := Parse(src)
+		if err != nil {
+			return fmt.Errorf("function %s: %w", name, err)
+		}
+		value, err := s.evaluator(io.Discard).Eval(expr)
+		if err != nil {
+			return fmt.Errorf("function %s: %w", name, err)
+		}
+		s.env[name] = value
+		s.functions[name] = src
+	}
+	if f.Results > s.results {
+		s.results = f.Results
+	}
+	return nil
+}
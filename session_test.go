@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValueJSON(t *testing.T) {
+	values := []Value{
+		IntValue(3),
+		FloatValue(3),
+		FloatValue(math.Inf(-1)),
+		BoolValue(true),
+		StringValue("a\nb"),
+		ListValue([]Value{IntValue(1), StringValue("x")}),
+		MapValue(map[string]Value{"k": FloatValue(0.5)}),
+	}
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", v, err)
+		}
+		var decoded Value
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if !decoded.Equal(v) || decoded.Kind() != v.Kind() {
+			t.Errorf("%s round trips as %s %s", v, decoded.Kind(), decoded)
+		}
+	}
+	nan, err := json.Marshal(FloatValue(math.NaN()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Value
+	if err := json.Unmarshal(nan, &decoded); err != nil || !math.IsNaN(decoded.Float()) {
+		t.Errorf("NaN round trips as %s, %v", decoded, err)
+	}
+	if _, err := json.Marshal(FuncValue(nil)); err == nil {
+		t.Errorf("serialized a function")
+	}
+	if err := json.Unmarshal([]byte(`{"kind":"date","value":1}`), &decoded); err == nil {
+		t.Errorf("deserialized an unknown kind")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	env := Env{
+		"x":     IntValue(1),
+		"f":     FuncValue(nil),
+		"s":     StringValue("a"),
+		"color": CustomValue(struct{ R, G, B uint8 }{255, 0, 0}),
+		"fs":    ListValue([]Value{IntValue(1), FuncValue(nil)}),
+	}
+	snapshot, skipped := env.Snapshot()
+	if len(snapshot.Variables) != 2 {
+		t.Errorf("snapshot has %d variables, want x and s", len(snapshot.Variables))
+	}
+	if want := []string{"color", "fs"}; !equalStrings(skipped, want) {
+		t.Errorf("snapshot skipped %q, want %q", skipped, want)
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Errorf("Marshal(snapshot): %v", err)
+	}
+	env["x"] = IntValue(2)
+	restored := Env{"y": IntValue(3)}
+	restored.Restore(snapshot)
+	if !restored["x"].Equal(IntValue(1)) || !restored["y"].Equal(IntValue(3)) || !restored["s"].Equal(StringValue("a")) {
+		t.Errorf("restored %v", restored)
+	}
+}
+
+func TestSaveAndLoadSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	s := newReplSession()
+	s.run("2 * 21")
+	s.env["rate"] = FloatValue(0.25)
+	if out, _ := s.run(":save " + path); out != "saved "+path {
+		t.Fatalf(":save printed %q", out)
+	}
+	loaded := newReplSession()
+	if out, _ := loaded.run(":load " + path); out != "loaded "+path {
+		t.Fatalf(":load printed %q", out)
+	}
+	if out, _ := loaded.run("ans * rate + _1"); out != "_2 = 52.5" {
+		t.Errorf("after :load, ans * rate + _1 printed %q, want _2 = 52.5", out)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := loaded.run(":load " + path); !strings.Contains(out, "unsupported session version 99") {
+		t.Errorf(":load of a newer session printed %q", out)
+	}
+}
+
+func TestSaveSessionFunctionsAndSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	s := newReplSession()
+	s.env["color"] = CustomValue("red")
+	for _, line := range []string{":set steps 500", ":set recursion 20", "1", "fn(x) => x * _1 + 1"} {
+		s.run(line)
+	}
+	want := "saved " + path + " without color, which cannot be serialized"
+	if out, _ := s.run(":save " + path); out != want {
+		t.Fatalf(":save printed %q, want %q", out, want)
+	}
+	loaded := newReplSession()
+	if out, _ := loaded.run(":load " + path); out != "loaded "+path {
+		t.Fatalf(":load printed %q", out)
+	}
+	if out, _ := loaded.run("_2(4) + ans(1)"); out != "_3 = 7" {
+		t.Errorf("after :load, _2(4) + ans(1) printed %q, want _3 = 7", out)
+	}
+	if out, _ := loaded.run(":set"); out != "recursion = 20\nsteps = 500" {
+		t.Errorf("after :load, :set printed %q", out)
+	}
+	if _, ok := loaded.env["color"]; ok {
+		t.Errorf("loaded the custom value")
+	}
+}
+
+func TestReplSet(t *testing.T) {
+	got := replTranscript(t, ":set steps 3\n1 + 2 * 3\n:set steps 0\n1 + 2 * 3\n:set depth 1\n:set steps -1\n")
+	want := "steps = 3\n" +
+		"step limit exceeded: more than 3 steps at column 5\n" +
+		"steps = 0\n" +
+		"_1 = 7\n" +
+		"unknown setting depth, try steps or recursion\n" +
+		"usage: :set steps n, with n a whole number\n"
+	if got != want {
+		t.Errorf("transcript is\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnyKind stands for a value of any kind in a Signature.
+const AnyKind = unknownKind
+
+// Signature declares a function to the parser and type checker, which
+// cannot otherwise know what a function takes or returns without calling
+// it. A call must pass one argument of each of Params, except that the last
+// Optional of them may be left out, and that if Variadic is set the last
+// may be repeated or left out. A FloatKind parameter also accepts integers.
+type Signature struct {
+	Result   Kind
+	Params   []Kind
+	Optional int
+	Variadic bool
+}
+
+// WithSignatures rejects calls of the functions declared in signatures with
+// the wrong number of arguments as syntax errors. Their kinds are checked
+// by the type checker, as Validate does with ValidateOptions.Functions.
+func WithSignatures(signatures map[string]Signature) ParseOption {
+	return func(l *Lexer) {
+		l.signatures = signatures
+	}
+}
+
+// arityError describes why n arguments do not suit s, or is "" if they do.
+func (s Signature) arityError(name string, n int) string {
+	least, most := len(s.Params)-s.Optional, len(s.Params)
+	if s.Variadic && s.Optional == 0 {
+		least -= 1
+	}
+	switch {
+	case s.Variadic && n < least:
+		return fmt.Sprintf("'%s' takes at least %s, not %d", name, arguments(least), n)
+	case s.Variadic || n >= least && n <= most:
+		return ""
+	case least == most:
+		return fmt.Sprintf("'%s' takes %s, not %d", name, arguments(most), n)
+	case least+1 == most:
+		return fmt.Sprintf("'%s' takes %d or %s, not %d", name, least, arguments(most), n)
+	}
+	return fmt.Sprintf("'%s' takes %d to %s, not %d", name, least, arguments(most), n)
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// param returns the kind of argument i of a call, which must be in range.
+func (s Signature) param(i int) Kind {
+	if len(s.Params) == 0 {
+		return AnyKind
+	}
+	if i >= len(s.Params) {
+		return s.Params[len(s.Params)-1]
+	}
+	return s.Params[i]
+}
+
+// parseSignature reads a signature written as :doc shows those of the
+// built-ins, such as "round(x number[, digits int]) number". As in Go, a
+// kind applies to the untyped parameters before it, though not across the
+// '[' starting the optional ones, and a parameter left untyped takes any
+// value. "..." repeats the parameter before it. It reports false for text
+// that is not written so, such as that of the special form fn.
+func parseSignature(text string) (Signature, bool) {
+	open, close := strings.IndexByte(text, '('), strings.LastIndexByte(text, ')')
+	if open < 0 || close < open {
+		return Signature{}, false
+	}
+	result, ok := signatureKind(strings.TrimSpace(text[close+1:]), true)
+	if !ok {
+		return Signature{}, false
+	}
+	s := Signature{Result: result}
+	list := strings.TrimSpace(strings.ReplaceAll(text[open+1:close], "[,", ",["))
+	if list == "" {
+		return s, true
+	}
+	// untyped is the first parameter no kind has been given to yet.
+	optional, untyped := -1, 0
+	fields := strings.Split(list, ",")
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "[") && optional < 0 {
+			optional, untyped = len(s.Params), len(s.Params)
+			field = field[1:]
+		}
+		field = strings.TrimSuffix(field, "]")
+		if field == "..." {
+			if len(s.Params) == 0 || i != len(fields)-1 {
+				return Signature{}, false
+			}
+			s.Params = append(s.Params, s.Params[len(s.Params)-1])
+			s.Variadic = true
+			continue
+		}
+		words := strings.Fields(field)
+		if len(words) == 0 || len(words) > 2 || !isSignatureName(words[0]) {
+			return Signature{}, false
+		}
+		if len(words) == 1 {
+			s.Params = append(s.Params, AnyKind)
+			continue
+		}
+		kind, ok := signatureKind(words[1], false)
+		if !ok {
+			return Signature{}, false
+		}
+		s.Params = append(s.Params, kind)
+		for j := untyped; j < len(s.Params); j++ {
+			s.Params[j] = kind
+		}
+		untyped = len(s.Params)
+	}
+	if optional >= 0 {
+		s.Optional = len(s.Params) - optional
+	}
+	return s, true
+}
+
+func isSignatureName(name string) bool {
+	if !isIdentifierStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isIdentifierChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// signatureKind returns the kind a word of a signature stands for. A number
+// parameter takes a FloatKind, which accepts integers, but a number result
+// may be either, so is not known.
+func signatureKind(word string, result bool) (Kind, bool) {
+	switch word {
+	case "any":
+		return AnyKind, true
+	case "number":
+		if result {
+			return AnyKind, true
+		}
+		return FloatKind, true
+	}
+	for _, kind := range []Kind{IntKind, FloatKind, BoolKind, StringKind, ListKind, MapKind, FuncKind} {
+		if word == kind.String() {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// checkCall checks the arguments of a call of a declared function and
+// returns its result kind.
+func (c *typeChecker) checkCall(e *CallExpression, s Signature, args []Kind) Kind {
+	if msg := s.arityError(e.name, len(args)); msg != "" {
+		c.errorf(e.pos, "%s", msg)
+		return s.Result
+	}
+	for i, arg := range args {
+		want := s.param(i)
+		if want == AnyKind || arg == unknownKind || arg == want || want == FloatKind && arg == IntKind {
+			continue
+		}
+		c.errorf(e.args[i].getPosition(), "argument %d of '%s' must be %s, not %s", i+1, e.name, want, arg)
+	}
+	return s.Result
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithSignatures(t *testing.T) {
+	signatures := map[string]Signature{
+		"discount": {Result: FloatKind, Params: []Kind{FloatKind, IntKind}},
+		"join":     {Result: StringKind, Params: []Kind{StringKind, StringKind}, Variadic: true},
+		"now":      {Result: IntKind},
+		"id":       {Result: AnyKind, Params: []Kind{AnyKind}},
+		"fee":      {Result: FloatKind, Params: []Kind{FloatKind, FloatKind}, Optional: 1},
+		"total":    {Result: FloatKind, Params: []Kind{FloatKind, FloatKind, FloatKind}, Optional: 2},
+	}
+	tests := map[string]string{
+		"discount(price, 2)":      "",
+		"discount(price)":         "'discount' takes 2 arguments, not 1 at column 1",
+		"1 + discount(1, 2, 3)":   "'discount' takes 2 arguments, not 3 at column 5",
+		"join(sep)":               "",
+		"join(sep, a, b, c)":      "",
+		"join()":                  "'join' takes at least 1 argument, not 0 at column 1",
+		"now()":                   "",
+		"now(1)":                  "'now' takes 0 arguments, not 1 at column 1",
+		"id(id(1))":               "",
+		"id(discount(1))":         "'discount' takes 2 arguments, not 1 at column 4",
+		"undeclared(1, 2, 3, 4)":  "",
+		"fee(1) + fee(1, 2)":      "",
+		"fee(1, 2, 3)":            "'fee' takes 1 or 2 arguments, not 3 at column 1",
+		"total()":                 "'total' takes 1 to 3 arguments, not 0 at column 1",
+		"discount(price, 2) * 2)": "unmatched ')' at column 23",
+	}
+	for src, want := range tests {
+		if _, err := Parse(src, WithSignatures(signatures)); errorString(err) != want {
+			t.Errorf("Parse(%s) = %v, want %q", src, err, want)
+		}
+	}
+	// Without the option calls are not checked until they are evaluated.
+	if _, err := Parse("discount(price)"); err != nil {
+		t.Errorf("Parse(discount(price)) without signatures = %v", err)
+	}
+	// Nor are the calls of partial input, whose arguments may be to come.
+	if _, _, err := ParsePartial("discount(price", WithSignatures(signatures)); err != nil {
+		t.Errorf("ParsePartial(discount(price) = %v", err)
+	}
+}
+
+func TestSignatureKinds(t *testing.T) {
+	functions := map[string]Signature{
+		"discount": {Result: FloatKind, Params: []Kind{FloatKind, IntKind}},
+		"join":     {Result: StringKind, Params: []Kind{StringKind}, Variadic: true},
+		"id":       {Result: AnyKind, Params: []Kind{AnyKind}},
+	}
+	env := Env{"qty": IntValue(2), "sku": StringValue("a"), "ok": BoolValue(true)}
+	tests := []struct {
+		src    string
+		kind   Kind
+		errors []string
+	}{
+		{"discount(qty, qty)", FloatKind, nil},
+		{`join(sku, "-", sku) + "!"`, StringKind, nil},
+		{"join()", StringKind, nil},
+		{"id(ok)", AnyKind, nil},
+		{"discount(qty, 1.5)", FloatKind, []string{"argument 2 of 'discount' must be int, not float at column 15"}},
+		{"join(sku, qty, ok)", StringKind, []string{
+			"argument 2 of 'join' must be string, not int at column 11",
+			"argument 3 of 'join' must be string, not bool at column 16",
+		}},
+	}
+	for _, tt := range tests {
+		kind, errs := DryRun(mustParse(t, tt.src), env, functions)
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = err.Error()
+		}
+		if kind != tt.kind || !equalStrings(got, tt.errors) {
+			t.Errorf("DryRun(%s) = %s, %q, want %s, %q", tt.src, kind, got, tt.kind, tt.errors)
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		text string
+		want Signature
+	}{
+		{"now() int", Signature{Result: IntKind}},
+		{"int(x) int", Signature{Result: IntKind, Params: []Kind{AnyKind}}},
+		{"clamp(x, lo, hi number) number", Signature{Result: AnyKind, Params: []Kind{FloatKind, FloatKind, FloatKind}}},
+		{"sumif(xs list, criterion) number", Signature{Result: AnyKind, Params: []Kind{ListKind, AnyKind}}},
+		{"round(x number[, digits int]) number", Signature{Result: AnyKind, Params: []Kind{FloatKind, IntKind}, Optional: 1}},
+		{"rotl(x, n int[, width int]) int", Signature{Result: IntKind, Params: []Kind{IntKind, IntKind, IntKind}, Optional: 1}},
+		{"pmt(rate, nper, pv number[, fv, when number]) float", Signature{Result: FloatKind, Params: []Kind{FloatKind, FloatKind, FloatKind, FloatKind, FloatKind}, Optional: 2}},
+		{"gcd(a, b int, ...) int", Signature{Result: IntKind, Params: []Kind{IntKind, IntKind, IntKind}, Variadic: true}},
+	}
+	for _, tt := range tests {
+		if got, ok := parseSignature(tt.text); !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSignature(%q) = %+v, %t, want %+v", tt.text, got, ok, tt.want)
+		}
+	}
+	for _, text := range []string{"fn(params..., body) func", "f(x) quantity", "f(x int", "f(..., x) int", "f(x y z) int"} {
+		if got, ok := parseSignature(text); ok {
+			t.Errorf("parseSignature(%q) = %+v", text, got)
+		}
+	}
+	// Every built-in but fn, whose parameters are not values, is declared.
+	for name, text := range functionSignatures {
+		if _, builtin := builtinFunctions[name]; !builtin || name == "fn" {
+			continue
+		}
+		if _, ok := builtinSignatures[name]; !ok {
+			t.Errorf("built-in %s has no signature in %q", name, text)
+		}
+	}
+}
+
+func TestCheckBuiltinCalls(t *testing.T) {
+	schema := Schema{"x": IntKind, "xs": ListKind}
+	tests := []struct {
+		src    string
+		kind   Kind
+		errors []string
+	}{
+		{"hex(x) + \"h\"", StringKind, nil},
+		{"isprime(x) && x > 2", BoolKind, nil},
+		{"gcd(x, 4, 6) * 2", IntKind, nil},
+		{"mean(xs) / 2", FloatKind, nil},
+		{"round(1.25, 1)", AnyKind, nil},
+		{"hex(1) * 2", AnyKind, []string{"operator '*' not defined for string and int at column 8"}},
+		{"isprime(x) + 1", AnyKind, []string{"operator '+' not defined for bool and int at column 12"}},
+		{"gcd(1.5, 2)", IntKind, []string{"argument 1 of 'gcd' must be int, not float at column 5"}},
+		{"popcount(\"a\")", IntKind, []string{"argument 1 of 'popcount' must be int, not string at column 10"}},
+		{"gcd(x)", IntKind, []string{"'gcd' takes at least 2 arguments, not 1 at column 1"}},
+		{"round(1.5, 1, 2)", AnyKind, []string{"'round' takes 1 or 2 arguments, not 3 at column 1"}},
+	}
+	for _, tt := range tests {
+		kind, errs := Check(mustParse(t, tt.src), schema)
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = err.Error()
+		}
+		if kind != tt.kind || !equalStrings(got, tt.errors) {
+			t.Errorf("Check(%s) = %s, %q, want %s, %q", tt.src, kind, got, tt.kind, tt.errors)
+		}
+	}
+	// A function of the same name in the schema is not the built-in.
+	if _, errs := Check(mustParse(t, "hex(1) * 2"), Schema{"hex": FuncKind}); len(errs) != 0 {
+		t.Errorf("Check(hex(1) * 2) with hex in the schema = %v", errs)
+	}
+}
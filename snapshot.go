@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DumpAST writes e as an indented tree, each node on its own line above its
+// operands, which unlike formatted source shows how precedence grouped it.
+func DumpAST(e Expression) string {
+	var sb strings.Builder
+	dumpAST(&sb, e, 0)
+	return sb.String()
+}
+
+func dumpAST(sb *strings.Builder, e Expression, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	switch v := e.(type) {
+	case IntegerToken:
+		sb.WriteString("int " + strconv.FormatInt(v.value, 10) + "\n")
+	case FloatToken:
+		sb.WriteString("float " + formatFloatLiteral(v.value) + "\n")
+	case StringToken:
+		sb.WriteString("string " + strconv.Quote(v.value) + "\n")
+	case IdentifierToken:
+		sb.WriteString("ident " + v.name + "\n")
+	case Hole:
+		sb.WriteString("hole " + strconv.Itoa(v.index+1) + "\n")
+	case *PrefixExpression:
+		sb.WriteString("prefix " + v.op.String() + "\n")
+		dumpAST(sb, v.rhs, depth+1)
+	case *InfixExpression:
+		sb.WriteString("infix " + v.op.String() + "\n")
+		dumpAST(sb, v.lhs, depth+1)
+		dumpAST(sb, v.rhs, depth+1)
+	case *CallExpression:
+		sb.WriteString("call " + v.name + "\n")
+		for _, arg := range v.args {
+			dumpAST(sb, arg, depth+1)
+		}
+	default:
+		fmt.Fprintf(sb, "%T\n", e)
+	}
+}
+
+// Snapshot dumps the tree of each expression of corpus, or its syntax
+// error, in a record headed by "== " and the expression, to be stored and
+// compared with CompareSnapshots after the grammar changes.
+func Snapshot(corpus []string, opts ...ParseOption) string {
+	var sb strings.Builder
+	for _, src := range corpus {
+		sb.WriteString("== " + src + "\n")
+		e, err := Parse(src, opts...)
+		if err != nil {
+			sb.WriteString("error " + err.Error() + "\n")
+			continue
+		}
+		sb.WriteString(DumpAST(e))
+	}
+	return sb.String()
+}
+
+type snapshotRecord struct {
+	src   string
+	lines []string
+}
+
+func snapshotRecords(snapshot string) []snapshotRecord {
+	var records []snapshotRecord
+	for _, line := range strings.Split(strings.TrimSuffix(snapshot, "\n"), "\n") {
+		if strings.HasPrefix(line, "== ") {
+			records = append(records, snapshotRecord{src: line[3:]})
+		} else if len(records) > 0 {
+			records[len(records)-1].lines = append(records[len(records)-1].lines, line)
+		}
+	}
+	return records
+}
+
+// CompareSnapshots returns the records of current that differ from those of
+// stored for the same expression, or that only one of them has, each as a
+// line "--- " and the expression followed by the stored lines prefixed with
+// '-' and the current ones with '+'. It returns "" if they agree.
+func CompareSnapshots(stored string, current string) string {
+	old := make(map[string][]string)
+	for _, r := range snapshotRecords(stored) {
+		old[r.src] = r.lines
+	}
+	var sb strings.Builder
+	changed := func(src string, was []string, is []string) {
+		sb.WriteString("--- " + src + "\n")
+		for _, line := range was {
+			sb.WriteString("-" + line + "\n")
+		}
+		for _, line := range is {
+			sb.WriteString("+" + line + "\n")
+		}
+	}
+	for _, r := range snapshotRecords(current) {
+		was, ok := old[r.src]
+		delete(old, r.src)
+		if !ok || strings.Join(was, "\n") != strings.Join(r.lines, "\n") {
+			changed(r.src, was, r.lines)
+		}
+	}
+	for _, r := range snapshotRecords(stored) {
+		if _, ok := old[r.src]; ok {
+			changed(r.src, r.lines, nil)
+		}
+	}
+	return sb.String()
+}
+
+// runSnapshot implements the snapshot command, which compares the trees of
+// the expressions in a corpus file, one a line, with those stored in a
+// snapshot file, or with -update stores them. Trees that changed are
+// reported as parse errors.
+func runSnapshot(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	flags.SetOutput(out)
+	update := flags.Bool("update", false, "write the snapshot file instead of comparing with it")
+	if err := flags.Parse(args); err != nil {
+		return flagsExitCode(err)
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(out, "usage: snapshot [-update] corpus snapshot")
+		return exitParseError
+	}
+	corpus, err := readCorpus(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return exitIOError
+	}
+	current := Snapshot(corpus)
+	stored, err := os.ReadFile(flags.Arg(1))
+	if *update || errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(flags.Arg(1), []byte(current), 0o644); err != nil {
+			fmt.Fprintln(out, err)
+			return exitIOError
+		}
+		fmt.Fprintf(out, "wrote %d snapshots to %s\n", len(corpus), flags.Arg(1))
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return exitIOError
+	}
+	if diff := CompareSnapshots(string(stored), current); diff != "" {
+		fmt.Fprint(out, diff)
+		return exitParseError
+	}
+	return exitOK
+}
+
+// readCorpus reads the expressions of a corpus file, skipping blank lines
+// and comments starting with '#'.
+func readCorpus(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var corpus []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			corpus = append(corpus, line)
+		}
+	}
+	return corpus, scanner.Err()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDumpAST(t *testing.T) {
+	got := DumpAST(mustParse(t, `-a * f(1.5, "s") + _`))
+	want := "infix +\n" +
+		"  infix *\n" +
+		"    prefix -\n" +
+		"      ident a\n" +
+		"    call f\n" +
+		"      float 1.5\n" +
+		"      string \"s\"\n" +
+		"  hole 1\n"
+	if got != want {
+		t.Errorf("DumpAST is\n%swant\n%s", got, want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	got := Snapshot([]string{"1 + 2", "1 +"})
+	want := "== 1 + 2\ninfix +\n  int 1\n  int 2\n" +
+		"== 1 +\nerror unexpected end of expression after '+' at column 3\n"
+	if got != want {
+		t.Errorf("Snapshot is\n%swant\n%s", got, want)
+	}
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	stored := Snapshot([]string{"a - b", "a ^ b ^ c", "gone"})
+	if diff := CompareSnapshots(stored, stored); diff != "" {
+		t.Errorf("a snapshot differs from itself:\n%s", diff)
+	}
+	current := strings.Replace(Snapshot([]string{"a ^ b ^ c", "a - b", "new"}), "infix -", "infix +", 1)
+	want := "--- a - b\n-infix -\n-  ident a\n-  ident b\n+infix +\n+  ident a\n+  ident b\n" +
+		"--- new\n+ident new\n" +
+		"--- gone\n-ident gone\n"
+	if diff := CompareSnapshots(stored, current); diff != want {
+		t.Errorf("CompareSnapshots is\n%swant\n%s", diff, want)
+	}
+}
+
+func TestSnapshotCommand(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus")
+	snapshot := filepath.Join(dir, "snapshot")
+	run := func(args ...string) (int, string) {
+		var out strings.Builder
+		code := runSnapshot(args, &out)
+		return code, out.String()
+	}
+	if err := os.WriteFile(corpus, []byte("# arithmetic\n1 + 2\n\n  a * b  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := run(corpus, snapshot); code != exitOK || out != "wrote 2 snapshots to "+snapshot+"\n" {
+		t.Errorf("first run exits with %d printing %q", code, out)
+	}
+	if code, out := run(corpus, snapshot); code != exitOK || out != "" {
+		t.Errorf("unchanged run exits with %d printing %q", code, out)
+	}
+	if err := os.WriteFile(corpus, []byte("1 + 2\na * b\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := run(corpus, snapshot); code != exitParseError || out != "--- c\n+ident c\n" {
+		t.Errorf("changed run exits with %d printing %q", code, out)
+	}
+	if code, _ := run("-update", corpus, snapshot); code != exitOK {
+		t.Errorf("-update exits with %d", code)
+	}
+	if code, out := run(corpus, snapshot); code != exitOK || out != "" {
+		t.Errorf("run after -update exits with %d printing %q", code, out)
+	}
+	if code, out := run(corpus); code != exitParseError || out != "usage: snapshot [-update] corpus snapshot\n" {
+		t.Errorf("run without a snapshot exits with %d printing %q", code, out)
+	}
+	if code, _ := run(filepath.Join(dir, "missing"), snapshot); code != exitIOError {
+		t.Errorf("run with a missing corpus exits with %d", code)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect selects the quoting and functions SQL generates.
+type SQLDialect int
+
+const (
+	PostgreSQL SQLDialect = iota
+	MySQL
+	SQLite
+)
+
+var sqlDialectNames = []string{
+	PostgreSQL: "postgresql",
+	MySQL:      "mysql",
+	SQLite:     "sqlite",
+}
+
+func (d SQLDialect) String() string {
+	if d < 0 || int(d) >= len(sqlDialectNames) {
+		return fmt.Sprintf("dialect(%d)", int(d))
+	}
+	return sqlDialectNames[d]
+}
+
+// Precedence levels of the operators generated for other languages, from
+// loosest. SQL and JavaScript both rank them as this package does, except
+// that they rank some comparisons above others and SQL ranks NOT below
+// them, so comparisons are parenthesised inside each other and NOT has its
+// own level.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precComparison
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+// SQL converts e to a SQL expression for dialect, so filters entered by
+// users can be pushed down into a WHERE clause instead of evaluated in Go.
+// Variables become quoted column names and strings quoted literals;
+// functions are called by name. ^ becomes POWER, &&, || and ! become AND,
+// OR and NOT, and + becomes concatenation when both operands are known to
+// be strings, which is when they are built from string literals: a column
+// is assumed to be a number. Parentheses are added where SQL would group
+// operands differently. SQL divides integers as this package does in
+// PostgreSQL and SQLite, but MySQL's / always gives a decimal, so there /
+// of operands known to be integers becomes DIV. Columns may still be
+// divided to a decimal in MySQL.
+func SQL(e Expression, dialect SQLDialect) (string, error) {
+	if dialect < 0 || int(dialect) >= len(sqlDialectNames) {
+		return "", fmt.Errorf("unknown SQL dialect %s", dialect)
+	}
+	s, _, _, err := sqlExpr(e, dialect)
+	return s, err
+}
+
+// sqlExpr returns the SQL for e with its precedence and kind, which is
+// unknownKind unless it is fixed by literals.
+func sqlExpr(e Expression, d SQLDialect) (string, int, Kind, error) {
+	switch v := e.(type) {
+	case IntegerToken:
+		return strconv.FormatInt(v.value, 10), precPrimary, IntKind, nil
+	case FloatToken:
+		return formatFloatLiteral(v.value), precPrimary, FloatKind, nil
+	case StringToken:
+		return sqlString(v.value, d), precPrimary, StringKind, nil
+	case IdentifierToken:
+		return sqlIdentifier(v.name, d), precPrimary, unknownKind, nil
+	case Hole:
+		return "", 0, 0, fmt.Errorf("cannot convert unfilled hole #%d at column %d", v.index+1, v.pos+1)
+	case *PrefixExpression:
+		rhs, prec, kind, err := sqlExpr(v.rhs, d)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if name := operatorFunction(v.op, false); name != "" {
+			return sqlCall(name, v.pos, rhs)
+		}
+		if v.op == NotOp {
+			if prec < precNot {
+				rhs = "(" + rhs + ")"
+			}
+			return "NOT " + rhs, precNot, BoolKind, nil
+		}
+		if v.op != SubOp && v.op != AddOp {
+			return "", 0, 0, fmt.Errorf("operator '%s' has no SQL equivalent at column %d", v.op, v.pos+1)
+		}
+		// Parenthesise nested signs too, since -- starts a comment.
+		if prec <= precUnary {
+			rhs = "(" + rhs + ")"
+		}
+		return v.op.String() + rhs, precUnary, kind, nil
+	case *InfixExpression:
+		lhs, lhsPrec, lhsKind, err := sqlExpr(v.lhs, d)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		rhs, rhsPrec, rhsKind, err := sqlExpr(v.rhs, d)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if name := operatorFunction(v.op, true); name != "" {
+			return sqlCall(name, v.pos, lhs, rhs)
+		}
+		if v.op == PowOp {
+			return "POWER(" + lhs + ", " + rhs + ")", precPrimary, unknownKind, nil
+		}
+		if v.op == MatchOp || v.op == NotMatchOp {
+			return sqlMatch(v.op, lhs, lhsPrec, rhs, rhsPrec, d), precComparison, BoolKind, nil
+		}
+		if v.op == AddOp && lhsKind == StringKind && rhsKind == StringKind {
+			if d == MySQL {
+				return "CONCAT(" + lhs + ", " + rhs + ")", precPrimary, StringKind, nil
+			}
+			return joinBinary(lhs, lhsPrec, "||", rhs, rhsPrec, precAdditive), precAdditive, StringKind, nil
+		}
+		if symbol, ok := sqlComparisons[v.op]; ok {
+			return joinComparison(lhs, lhsPrec, symbol, rhs, rhsPrec), precComparison, BoolKind, nil
+		}
+		prec := precAdditive
+		switch v.op {
+		case AddOp, SubOp:
+		case MulOp, DivOp:
+			prec = precMultiplicative
+		case AndOp:
+			return joinBinary(lhs, lhsPrec, "AND", rhs, rhsPrec, precAnd), precAnd, BoolKind, nil
+		case OrOp:
+			return joinBinary(lhs, lhsPrec, "OR", rhs, rhsPrec, precOr), precOr, BoolKind, nil
+		default:
+			return "", 0, 0, fmt.Errorf("operator '%s' has no SQL equivalent at column %d", v.op, v.pos+1)
+		}
+		kind := unknownKind
+		if lhsKind == IntKind && rhsKind == IntKind {
+			kind = IntKind
+		}
+		symbol := v.op.String()
+		if v.op == DivOp && kind == IntKind && d == MySQL {
+			symbol = "DIV"
+		}
+		return joinBinary(lhs, lhsPrec, symbol, rhs, rhsPrec, prec), prec, kind, nil
+	case *CallExpression:
+		if isTry(v) || isLambda(v) {
+			return "", 0, 0, fmt.Errorf("%s has no SQL equivalent at column %d", v.name, v.pos+1)
+		}
+		if isLet(v) {
+			return sqlExpr(inlineLet(v), d)
+		}
+		args := make([]string, len(v.args))
+		for i, arg := range v.args {
+			s, _, _, err := sqlExpr(arg, d)
+			if err != nil {
+				return "", 0, 0, err
+			}
+			args[i] = s
+		}
+		return sqlCall(v.name, v.pos, args...)
+	}
+	return "", 0, 0, fmt.Errorf("cannot convert %T", e)
+}
+
+// sqlCall calls a function, whose name is written unquoted as SQL
+// function names are, so it must be a plain identifier.
+func sqlCall(name string, pos int, args ...string) (string, int, Kind, error) {
+	if identifierLength(name) != len(name) {
+		return "", 0, 0, fmt.Errorf("'%s' is not a SQL function name at column %d", name, pos+1)
+	}
+	return name + "(" + strings.Join(args, ", ") + ")", precPrimary, unknownKind, nil
+}
+
+// sqlMatch matches with ~ in PostgreSQL and REGEXP elsewhere, which SQLite
+// provides only once a regexp function is loaded. Each database has its own
+// regular expression syntax, which agrees with Go's for common patterns.
+func sqlMatch(op OpKind, lhs string, lhsPrec int, rhs string, rhsPrec int, d SQLDialect) string {
+	if d == PostgreSQL {
+		symbol := "~"
+		if op == NotMatchOp {
+			symbol = "!~"
+		}
+		return joinBinary(lhs, lhsPrec, symbol, rhs, rhsPrec, precComparison)
+	}
+	symbol := "REGEXP"
+	if op == NotMatchOp {
+		symbol = "NOT REGEXP"
+	}
+	return joinBinary(lhs, lhsPrec, symbol, rhs, rhsPrec, precComparison)
+}
+
+// joinComparison joins the operands of a comparison, parenthesising any
+// operand that is a comparison too, since other languages rank comparisons
+// differently from each other.
+func joinComparison(lhs string, lhsPrec int, op string, rhs string, rhsPrec int) string {
+	if lhsPrec <= precComparison {
+		lhs = "(" + lhs + ")"
+	}
+	if rhsPrec <= precComparison {
+		rhs = "(" + rhs + ")"
+	}
+	return lhs + " " + op + " " + rhs
+}
+
+// joinBinary joins the operands of a left-associative operator of
+// precedence prec, parenthesising those that would be grouped differently.
+func joinBinary(lhs string, lhsPrec int, op string, rhs string, rhsPrec int, prec int) string {
+	if lhsPrec < prec {
+		lhs = "(" + lhs + ")"
+	}
+	if rhsPrec <= prec {
+		rhs = "(" + rhs + ")"
+	}
+	return lhs + " " + op + " " + rhs
+}
+
+// sqlComparisons spells the comparisons in SQL.
+var sqlComparisons = map[OpKind]string{
+	EqOp: "=",
+	NeOp: "<>",
+	LtOp: "<",
+	LeOp: "<=",
+	GtOp: ">",
+	GeOp: ">=",
+}
+
+func sqlString(s string, d SQLDialect) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if d == MySQL {
+		// MySQL also treats backslashes in strings as escapes.
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + s + "'"
+}
+
+func sqlIdentifier(name string, d SQLDialect) string {
+	if d == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
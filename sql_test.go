@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestSQL(t *testing.T) {
+	tests := []struct {
+		src                   string
+		postgres, mysql, lite string
+	}{
+		{`price * qty > 100 && region == "EU"`,
+			`"price" * "qty" > 100 AND "region" = 'EU'`,
+			"`price` * `qty` > 100 AND `region` = 'EU'",
+			`"price" * "qty" > 100 AND "region" = 'EU'`},
+		{"a - (b - c)", `"a" - ("b" - "c")`, "`a` - (`b` - `c`)", `"a" - ("b" - "c")`},
+		{"a || b && c", `"a" OR "b" AND "c"`, "`a` OR `b` AND `c`", `"a" OR "b" AND "c"`},
+		{"(a || b) && c", `("a" OR "b") AND "c"`, "(`a` OR `b`) AND `c`", `("a" OR "b") AND "c"`},
+		// NOT ranks below comparisons in SQL.
+		{"!a && b", `NOT "a" AND "b"`, "NOT `a` AND `b`", `NOT "a" AND "b"`},
+		{"!(a < b)", `NOT "a" < "b"`, "NOT `a` < `b`", `NOT "a" < "b"`},
+		{"!a < b", `(NOT "a") < "b"`, "(NOT `a`) < `b`", `(NOT "a") < "b"`},
+		{"a < b == c", `("a" < "b") = "c"`, "(`a` < `b`) = `c`", `("a" < "b") = "c"`},
+		{"a != b", `"a" <> "b"`, "`a` <> `b`", `"a" <> "b"`},
+		// Nested signs would start a comment.
+		{"-(-x)", `-(-"x")`, "-(-`x`)", `-(-"x")`},
+		{"2 ^ x", `POWER(2, "x")`, "POWER(2, `x`)", `POWER(2, "x")`},
+		{`"a" + "b" + "c"`, `'a' || 'b' || 'c'`, `CONCAT(CONCAT('a', 'b'), 'c')`, `'a' || 'b' || 'c'`},
+		{`a + "b"`, `"a" + 'b'`, "`a` + 'b'", `"a" + 'b'`},
+		{`"it's" == name`, `'it''s' = "name"`, "'it''s' = `name`", `'it''s' = "name"`},
+		{`s == "a\b"`, `"s" = 'a\b'`, "`s` = 'a\\\\b'", `"s" = 'a\b'`},
+		{`name =~ "^a"`, `"name" ~ '^a'`, "`name` REGEXP '^a'", `"name" REGEXP '^a'`},
+		{`name !~ "^a"`, `"name" !~ '^a'`, "`name` NOT REGEXP '^a'", `"name" NOT REGEXP '^a'`},
+		{"let(y, x + 1, y * y)", `("x" + 1) * ("x" + 1)`, "(`x` + 1) * (`x` + 1)", `("x" + 1) * ("x" + 1)`},
+		{"f(x, 1.5)", `f("x", 1.5)`, "f(`x`, 1.5)", `f("x", 1.5)`},
+		// MySQL's / gives a decimal even of integers.
+		{"7 / 2 + x", `7 / 2 + "x"`, "7 DIV 2 + `x`", `7 / 2 + "x"`},
+		{"(7 - 1) / -2 * 3", `(7 - 1) / -2 * 3`, "(7 - 1) DIV -2 * 3", `(7 - 1) / -2 * 3`},
+		{"x / 2 + 1.5 / 2", `"x" / 2 + 1.5 / 2`, "`x` / 2 + 1.5 / 2", `"x" / 2 + 1.5 / 2`},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		for d, want := range map[SQLDialect]string{PostgreSQL: tt.postgres, MySQL: tt.mysql, SQLite: tt.lite} {
+			if got, err := SQL(e, d); err != nil || got != want {
+				t.Errorf("SQL(%s, %s) = %s, %v, want %s", tt.src, d, got, err, want)
+			}
+		}
+	}
+}
+
+func TestSQLErrors(t *testing.T) {
+	tests := map[string]string{
+		"try(x, 1)":    "try has no SQL equivalent at column 1",
+		"1 + fn(x, x)": "fn has no SQL equivalent at column 5",
+		"_ + 1":        "cannot convert unfilled hole #1 at column 1",
+		"a << 1":       "operator '<<' has no SQL equivalent at column 3",
+	}
+	for src, want := range tests {
+		if _, err := SQL(mustParse(t, src), PostgreSQL); errorString(err) != want {
+			t.Errorf("SQL(%s) = %v, want %s", src, err, want)
+		}
+	}
+	if _, err := SQL(mustParse(t, "1"), SQLDialect(5)); errorString(err) != "unknown SQL dialect dialect(5)" {
+		t.Errorf("SQL with an unknown dialect = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The statistics functions take a list of numbers, such as a variable
+// bound to a JSON array, or the numbers themselves as arguments.
+func init() {
+	addBuiltin("mean", "mean(xs list) float", docEntry{"the arithmetic mean of xs", "mean(prices)"},
+		statFunction("mean", 1, func(xs []float64) float64 {
+			return mean(xs)
+		}))
+	addBuiltin("median", "median(xs list) float", docEntry{"the middle value of xs, or the mean of the middle two", "median(prices)"},
+		statFunction("median", 1, func(xs []float64) float64 {
+			return percentile(xs, 50)
+		}))
+	addBuiltin("variance", "variance(xs list) float", docEntry{"the sample variance of xs, dividing by one less than their number", "variance(prices)"},
+		statFunction("variance", 2, variance))
+	addBuiltin("stddev", "stddev(xs list) float", docEntry{"the sample standard deviation of xs", "stddev(prices)"},
+		statFunction("stddev", 2, func(xs []float64) float64 {
+			return math.Sqrt(variance(xs))
+		}))
+	addBuiltin("percentile", "percentile(xs list, p number) float", docEntry{"the value below which p percent of xs fall, interpolating between neighbours", "percentile(prices, 90)"},
+		func(args []Value) (Value, error) {
+			if len(args) != 2 || !args[1].IsNumeric() {
+				return Value{}, fmt.Errorf("percentile takes a list and a number")
+			}
+			p, _ := args[1].AsFloat()
+			if p < 0 || p > 100 || math.IsNaN(p) {
+				return Value{}, fmt.Errorf("percentile must be between 0 and 100, not %v", p)
+			}
+			xs, err := statValues("percentile", args[:1], 1)
+			if err != nil {
+				return Value{}, err
+			}
+			return FloatValue(percentile(xs, p)), nil
+		})
+	addBuiltin("sumif", "sumif(xs list, criterion) number", docEntry{"the sum of the numbers in xs the criterion function accepts or, if criterion is not a function, that equal it", "sumif(prices, fn(p) => p > 0)"},
+		func(args []Value) (Value, error) {
+			matches, err := matching("sumif", args)
+			if err != nil {
+				return Value{}, err
+			}
+			sum := IntValue(0)
+			for _, x := range matches {
+				if !x.IsNumeric() {
+					return Value{}, fmt.Errorf("sumif takes numbers, not %s", x.Kind())
+				}
+				if sum, err = applyInfix(AddOp, sum, x); err != nil {
+					return Value{}, err
+				}
+			}
+			return sum, nil
+		})
+	addBuiltin("countif", "countif(xs list, criterion) int", docEntry{"the number of values in xs the criterion function accepts or, if criterion is not a function, that equal it", "countif(scores, fn(s) => s >= 50)"},
+		func(args []Value) (Value, error) {
+			matches, err := matching("countif", args)
+			if err != nil {
+				return Value{}, err
+			}
+			return IntValue(int64(len(matches))), nil
+		})
+}
+
+// matching returns the values of the list args[0] that args[1] accepts:
+// a function must return a bool for each, and any other value must equal
+// them.
+func matching(name string, args []Value) ([]Value, error) {
+	if len(args) != 2 || args[0].Kind() != ListKind {
+		return nil, fmt.Errorf("%s takes a list and a criterion", name)
+	}
+	criterion := args[1]
+	var matches []Value
+	for _, x := range args[0].List() {
+		if criterion.Kind() != FuncKind {
+			if x.Equal(criterion) {
+				matches = append(matches, x)
+			}
+			continue
+		}
+		ok, err := criterion.Func()([]Value{x})
+		if err != nil {
+			return nil, err
+		}
+		if ok.Kind() != BoolKind {
+			return nil, fmt.Errorf("%s: criterion returned %s, not bool", name, ok.Kind())
+		}
+		if ok.Bool() {
+			matches = append(matches, x)
+		}
+	}
+	return matches, nil
+}
+
+// statFunction applies fn to the numbers its arguments give, of which
+// there must be at least min.
+func statFunction(name string, min int, fn func(xs []float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		xs, err := statValues(name, args, min)
+		if err != nil {
+			return Value{}, err
+		}
+		return FloatValue(fn(xs)), nil
+	}
+}
+
+// statValues returns the numbers of a single list argument, or of the
+// arguments themselves.
+func statValues(name string, args []Value, min int) ([]float64, error) {
+	if len(args) == 1 && args[0].Kind() == ListKind {
+		args = args[0].List()
+	}
+	if len(args) < min {
+		return nil, fmt.Errorf("%s needs at least %d numbers", name, min)
+	}
+	xs := make([]float64, len(args))
+	for i, arg := range args {
+		if !arg.IsNumeric() {
+			return nil, fmt.Errorf("%s takes numbers, not %s", name, arg.Kind())
+		}
+		xs[i], _ = arg.AsFloat()
+	}
+	return xs, nil
+}
+
+func mean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func variance(xs []float64) float64 {
+	m := mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return sum / float64(len(xs)-1)
+}
+
+// percentile interpolates linearly between the closest ranks of xs, which
+// it sorts.
+func percentile(xs []float64, p float64) float64 {
+	sort.Float64s(xs)
+	rank := p / 100 * float64(len(xs)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(xs) {
+		return xs[len(xs)-1]
+	}
+	return xs[lower] + (rank-float64(lower))*(xs[lower+1]-xs[lower])
+}
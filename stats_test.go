@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatistics(t *testing.T) {
+	env := Env{"xs": ListValue([]Value{IntValue(2), IntValue(4), IntValue(4), IntValue(4), IntValue(5), IntValue(5), IntValue(7), IntValue(9)})}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"mean(xs)", FloatValue(5)},
+		{"median(xs)", FloatValue(4.5)},
+		{"mean(1, 2)", FloatValue(1.5)},
+		{"percentile(xs, 0)", FloatValue(2)},
+		{"percentile(xs, 100)", FloatValue(9)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := NewEvaluator(env).Eval(e); err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestConditionalAggregates(t *testing.T) {
+	env := Env{
+		"xs":      ListValue([]Value{IntValue(-2), IntValue(3), IntValue(0), IntValue(5)}),
+		"ys":      ListValue([]Value{FloatValue(0.5), IntValue(1), FloatValue(-1.5)}),
+		"answers": ListValue([]Value{StringValue("yes"), StringValue("no"), StringValue("yes")}),
+		"limit":   IntValue(2),
+	}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"sumif(xs, fn(x) => x > 0)", IntValue(8)},
+		{"sumif(xs, fn(x) => x > limit)", IntValue(8)},
+		{"{ limit = 4; sumif(xs, fn(x) => x > limit) }", IntValue(5)},
+		{"sumif(ys, fn(y) => y > 0)", FloatValue(1.5)},
+		{"sumif(xs, fn(x) => x > 100)", IntValue(0)},
+		{"sumif(xs, 3)", IntValue(3)},
+		{"countif(xs, fn(x) => x > 0)", IntValue(2)},
+		{`countif(answers, "yes")`, IntValue(2)},
+		{`countif(answers, fn(a) => a != "yes")`, IntValue(1)},
+		{"countif(xs, fn(x) => x * x > limit * limit)", IntValue(2)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	failures := []struct {
+		src  string
+		want string
+	}{
+		{"sumif(xs, fn(x) => x)", "criterion returned int, not bool"},
+		{`sumif(answers, fn(a) => a == "yes")`, "sumif takes numbers, not string"},
+		{"countif(limit, fn(x) => x > 0)", "countif takes a list and a criterion"},
+		{"countif(xs, fn(x, y) => x > y)", "takes 2 arguments, not 1"},
+		{"sumif(xs, fn(x) => x / 0 > 1)", "division by zero"},
+	}
+	for _, tt := range failures {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if _, err := NewEvaluator(env).Eval(e); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s fails with %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStepLimit is wrapped by the error of an evaluation stopped by
+// WithStepLimit. try does not recover from it.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// WithStepLimit stops an evaluation with an error wrapping ErrStepLimit
+// once it has evaluated more than n nodes, counting each time a node is
+// evaluated, so untrusted expressions cannot run unbounded. Steps are
+// counted by walking the tree, so WithStepLimit makes the Evaluator walk
+// it whatever the backend.
+func WithStepLimit(n int) EvalOption {
+	return func(ev *Evaluator) {
+		ev.stepLimit = n
+	}
+}
+
+// step counts the evaluation of e against the step limit, if there is one,
+// and stops the evaluation if its context is done.
+func (ev *evaluation) step(e Expression) error {
+	if ev.ctx != nil && ev.ctx.Err() != nil {
+		return fmt.Errorf("evaluation stopped at column %d: %w", e.getPosition()+1, ev.ctx.Err())
+	}
+	if ev.stepLimit <= 0 {
+		return nil
+	}
+	if ev.budget.steps.Add(1) > int64(ev.stepLimit) {
+		return fmt.Errorf("%w: more than %d steps at column %d", ErrStepLimit, ev.stepLimit, e.getPosition()+1)
+	}
+	return nil
+}
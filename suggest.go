@@ -0,0 +1,121 @@
+package main
+
+// suggest returns the name among candidates closest to name by edit
+// distance, if one is close enough to be a likely misspelling of it, or "".
+// Ties go to the alphabetically first so suggestions do not depend on map
+// order.
+func suggest(name string, candidates func(yield func(string))) string {
+	best, bestDistance := "", len(name)/3+1
+	candidates(func(candidate string) {
+		if candidate == name || isResultName(candidate) {
+			return
+		}
+		d := editDistance(name, candidate)
+		if d < bestDistance || d == bestDistance && best != "" && candidate < best {
+			best, bestDistance = candidate, d
+		}
+	})
+	return best
+}
+
+// editDistance returns the number of single byte insertions, deletions,
+// substitutions and swaps of adjacent bytes that turn a into b.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	var beforePrevious []int
+	for i := 1; i <= len(a); i++ {
+		beforePrevious, previous, row = previous, row, beforePrevious
+		if row == nil {
+			row = make([]int, len(b)+1)
+		}
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := previous[j-1] + cost
+			if previous[j]+1 < d {
+				d = previous[j] + 1
+			}
+			if row[j-1]+1 < d {
+				d = row[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && beforePrevious[j-2]+1 < d {
+				d = beforePrevious[j-2] + 1
+			}
+			row[j] = d
+		}
+	}
+	return row[len(b)]
+}
+
+// suggestVariable suggests a variable of locals or env for a missing name.
+func suggestVariable(name string, locals *scope, env Env) string {
+	return suggest(name, func(yield func(string)) {
+		for s := locals; s != nil; s = s.parent {
+			yield(s.name)
+		}
+		for candidate, value := range env {
+			if value.Kind() != FuncKind {
+				yield(candidate)
+			}
+		}
+	})
+}
+
+// suggestFunction suggests a function of env or a builtin for a missing
+// name.
+func suggestFunction(name string, env Env) string {
+	return suggest(name, func(yield func(string)) {
+		for candidate, value := range env {
+			if value.Kind() == FuncKind {
+				yield(candidate)
+			}
+		}
+		for candidate := range builtinFunctions {
+			yield(candidate)
+		}
+	})
+}
+
+// suggestVariable suggests a variable of the schema for a missing name.
+func (c *typeChecker) suggestVariable(name string) string {
+	return suggest(name, func(yield func(string)) {
+		for candidate, kind := range c.schema {
+			if kind != FuncKind {
+				yield(candidate)
+			}
+		}
+	})
+}
+
+// suggestFunction suggests a function of the schema, a declared function
+// or a builtin for a missing name.
+func (c *typeChecker) suggestFunction(name string) string {
+	return suggest(name, func(yield func(string)) {
+		for candidate, kind := range c.schema {
+			if kind == FuncKind {
+				yield(candidate)
+			}
+		}
+		for candidate := range c.functions {
+			yield(candidate)
+		}
+		for candidate := range builtinFunctions {
+			yield(candidate)
+		}
+	})
+}
+
+// didYouMean appends a suggestion from catalog to msg if there is one.
+func didYouMean(msg string, suggestion string, catalog Catalog) string {
+	if suggestion == "" {
+		return msg
+	}
+	return (&message{code: CodeDidYouMean, args: []interface{}{msg, suggestion}}).format(catalog)
+}
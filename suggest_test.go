@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"qty", "qty", 0},
+		{"", "abc", 3},
+		{"qty", "qtty", 1},
+		{"qty", "qt", 1},
+		{"qty", "qtz", 1},
+		{"qyt", "qty", 1},
+		{"total_price", "totalprice", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := func(candidates ...string) func(yield func(string)) {
+		return func(yield func(string)) {
+			for _, c := range candidates {
+				yield(c)
+			}
+		}
+	}
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"total_prce", []string{"total_price", "tax"}, "total_price"},
+		{"qyt", []string{"qty", "x"}, "qty"},
+		{"x", []string{"y"}, ""},
+		{"ab", []string{"ab", "ax"}, ""},
+		{"abc", []string{"abd", "abe"}, "abd"},
+		{"abc", []string{"abe", "abd"}, "abd"},
+		{"_3", []string{"_1", "_2"}, ""},
+		{"price", []string{"size"}, ""},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.name, names(tt.candidates...)); got != tt.want {
+			t.Errorf("suggest(%q, %q) = %q, want %q", tt.name, tt.candidates, got, tt.want)
+		}
+	}
+}
+
+func TestEvalSuggestions(t *testing.T) {
+	env := Env{
+		"total_price": IntValue(10),
+		"discount":    FuncValue(func(args []Value) (Value, error) { return args[0], nil }),
+	}
+	tests := map[string]string{
+		"total_prce * 2":             "undefined variable 'total_prce' at column 1; did you mean 'total_price'?",
+		"discont(1)":                 "undefined function 'discont' at column 1; did you mean 'discount'?",
+		"popcont(7)":                 "undefined function 'popcont' at column 1; did you mean 'popcount'?",
+		"let(rate, 2, rat * 3)":      "undefined variable 'rat' at column 14; did you mean 'rate'?",
+		"unrelated + total_price":    "undefined variable 'unrelated' at column 1",
+		"total_price(1)":             "'total_price' is not a function at column 1",
+		"let(x, 1, total_pric + x)":  "undefined variable 'total_pric' at column 11; did you mean 'total_price'?",
+		"let(count, 1, cuont + 1)":   "undefined variable 'cuont' at column 15; did you mean 'count'?",
+		"let(count, 1, 2) + count":   "undefined variable 'count' at column 20",
+		"discount(totl_price) + 1.0": "undefined variable 'totl_price' at column 10; did you mean 'total_price'?",
+	}
+	for src, want := range tests {
+		if _, err := NewEvaluator(env).Eval(mustParse(t, src)); errorString(err) != want {
+			t.Errorf("%s = %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestJSONSuggestion(t *testing.T) {
+	var out strings.Builder
+	runEval([]string{"-json", "-var", "qty=2"}, strings.NewReader("qyt * 2\n"), &out)
+	want := `{"input":"qyt * 2","error":{"code":"eval","pos":0,"message":"undefined variable 'qyt'","suggestion":"qty"}}` + "\n"
+	if out.String() != want {
+		t.Errorf("-json printed\n%swant\n%s", out.String(), want)
+	}
+}
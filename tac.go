@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TACStep is one three-address instruction: Dest is assigned the result of
+// applying Op to Args, or of calling the function Op when Call is set.
+// Args are temporaries, literals or variables, so nested operations have
+// already been lowered into earlier steps.
+type TACStep struct {
+	Dest string
+	Op   string
+	Args []string
+	Call bool
+}
+
+// String shows the step as "t2 = t1 + 4", "t1 = -x" or "t3 = f(t1, t2)".
+func (s TACStep) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.Dest)
+	sb.WriteString(" = ")
+	switch {
+	case s.Call:
+		sb.WriteString(s.Op)
+		sb.WriteByte('(')
+		sb.WriteString(strings.Join(s.Args, ", "))
+		sb.WriteByte(')')
+	case len(s.Args) == 1:
+		sb.WriteString(s.Op)
+		sb.WriteString(s.Args[0])
+	case len(s.Args) == 2:
+		sb.WriteString(s.Args[0])
+		sb.WriteByte(' ')
+		sb.WriteString(s.Op)
+		sb.WriteByte(' ')
+		sb.WriteString(s.Args[1])
+	default:
+		sb.WriteString(s.Op)
+	}
+	return sb.String()
+}
+
+// TAC lowers e to three-address code with numbered temporaries, one step
+// per operator or call in evaluation order, as in t1 = 2 * 3; t2 = t1 + 4.
+// The last step assigns the value of e; an expression with no operators is
+// copied into t1 so there is always one.
+//
+// The steps have no jumps, so they show what is computed from what rather
+// than what runs: the right operand of && and || is lowered before the
+// operator although evaluation skips it once the left operand decides the
+// result. try, which evaluates its default only if its expression fails,
+// cannot be lowered so and is an error. A let is lowered as its value and
+// then its body, which reads the value's operand where it names the let.
+func TAC(e Expression) ([]TACStep, error) {
+	g := &tacGenerator{locals: make(map[string]string)}
+	result, err := g.lower(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.steps) == 0 {
+		g.emit(TACStep{Op: result})
+	}
+	return g.steps, nil
+}
+
+type tacGenerator struct {
+	steps []TACStep
+	// locals maps the names bound by enclosing lets to their operands.
+	locals map[string]string
+}
+
+// lower emits the steps computing e and returns the operand holding its
+// value.
+func (g *tacGenerator) lower(e Expression) (string, error) {
+	switch v := e.(type) {
+	case IdentifierToken:
+		if operand, ok := g.locals[v.name]; ok {
+			return operand, nil
+		}
+	case *PrefixExpression:
+		rhs, err := g.lower(v.rhs)
+		if err != nil {
+			return "", err
+		}
+		return g.emit(TACStep{Op: v.op.String(), Args: []string{rhs}}), nil
+	case *InfixExpression:
+		lhs, err := g.lower(v.lhs)
+		if err != nil {
+			return "", err
+		}
+		rhs, err := g.lower(v.rhs)
+		if err != nil {
+			return "", err
+		}
+		return g.emit(TACStep{Op: v.op.String(), Args: []string{lhs, rhs}}), nil
+	case *CallExpression:
+		if isTry(v) {
+			return "", fmt.Errorf("try has no three-address code at column %d", v.pos+1)
+		}
+		if isLet(v) {
+			return g.lowerLet(v)
+		}
+		if isLambda(v) {
+			// A lambda is a value whose body runs when it is called, so it
+			// is an operand rather than steps.
+			return Format(v), nil
+		}
+		args := make([]string, len(v.args))
+		for i, arg := range v.args {
+			lowered, err := g.lower(arg)
+			if err != nil {
+				return "", err
+			}
+			args[i] = lowered
+		}
+		return g.emit(TACStep{Op: v.name, Args: args, Call: true}), nil
+	}
+	return e.getExpressionValue(), nil
+}
+
+func (g *tacGenerator) lowerLet(e *CallExpression) (string, error) {
+	value, err := g.lower(e.args[1])
+	if err != nil {
+		return "", err
+	}
+	name := letName(e)
+	outer, shadowed := g.locals[name]
+	g.locals[name] = value
+	defer func() {
+		if shadowed {
+			g.locals[name] = outer
+		} else {
+			delete(g.locals, name)
+		}
+	}()
+	return g.lower(e.args[2])
+}
+
+func (g *tacGenerator) emit(step TACStep) string {
+	step.Dest = "t" + strconv.Itoa(len(g.steps)+1)
+	g.steps = append(g.steps, step)
+	return step.Dest
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTAC(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"2 * 3 + 4", "t1 = 2 * 3; t2 = t1 + 4"},
+		{"x", "t1 = x"},
+		{"-x + f(y, 2 * z)", "t1 = -x; t2 = 2 * z; t3 = f(y, t2); t4 = t1 + t3"},
+		{"a && b || !c", "t1 = a && b; t2 = !c; t3 = t1 || t2"},
+		{"let(a, x * 2, a + a)", "t1 = x * 2; t2 = t1 + t1"},
+		{"let(a, 1, let(a, a + 1, a) * a)", "t1 = 1 + 1; t2 = t1 * 1"},
+		{"fn(x, x + 1)", "t1 = fn(x, x + 1)"},
+	}
+	for _, tt := range tests {
+		steps, err := TAC(mustParse(t, tt.src))
+		if err != nil {
+			t.Errorf("TAC(%s) = %v", tt.src, err)
+			continue
+		}
+		lines := make([]string, len(steps))
+		for i, step := range steps {
+			lines[i] = step.String()
+		}
+		if got := strings.Join(lines, "; "); got != tt.want {
+			t.Errorf("TAC(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+	if _, err := TAC(mustParse(t, "1 + try(1 / 0, 2)")); err == nil || err.Error() != "try has no three-address code at column 5" {
+		t.Errorf("TAC of try = %v", err)
+	}
+}
+
+func TestTACFlag(t *testing.T) {
+	tests := []struct {
+		input string
+		code  int
+		out   string
+	}{
+		{"(1 + 2) * x\n", exitOK, "t1 = 1 + 2\nt2 = t1 * x\n"},
+		{"try(x, 1)\n", exitParseError, "try has no three-address code at column 1\n"},
+		{"1 +\n", exitParseError, "unexpected end of expression after '+' at column 3\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if code := runEval([]string{"-tac"}, strings.NewReader(tt.input), &out); code != tt.code || out.String() != tt.out {
+			t.Errorf("-tac %q exits with %d printing %q, want %d and %q", tt.input, code, out.String(), tt.code, tt.out)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import "strconv"
+
+// Bind returns e with each variable named in bindings replaced by the bound
+// expression, so formulas can be assembled from reusable fragments such as
+// Bind(total, map[string]Expression{"price": net}). Substitution is
+// hygienic:
+//   - it is simultaneous, so variables inside a bound expression are not
+//     themselves replaced, even when they share a name with a binding;
+//   - a bound expression stays one operand whatever its precedence, which
+//     Format shows with parentheses where needed;
+//   - function names are not variables, so f(x) keeps calling f, unless f
+//     is a local variable renamed as below;
+//   - names bound by a let or lambda are not replaced where they are
+//     bound, and one that would capture a variable of a bound expression
+//     is renamed;
+//   - holes are renumbered in source order across the result, so holes from
+//     different fragments do not share an index.
+//
+// e and the bound expressions are not modified, and nodes keep the source
+// positions they were parsed with.
+func Bind(e Expression, bindings map[string]Expression) Expression {
+	bound := bind(e, bindings)
+	holes := 0
+	return renumberHoles(bound, &holes)
+}
+
+func bind(e Expression, bindings map[string]Expression) Expression {
+	switch v := e.(type) {
+	case IdentifierToken:
+		if replacement, ok := bindings[v.name]; ok {
+			if r, ok := replacement.(renamedLocal); ok {
+				return r.IdentifierToken
+			}
+			return replacement
+		}
+	case *PrefixExpression:
+		if rhs := bind(v.rhs, bindings); rhs != v.rhs {
+			return &PrefixExpression{op: v.op, rhs: rhs, pos: v.pos}
+		}
+	case *InfixExpression:
+		lhs, rhs := bind(v.lhs, bindings), bind(v.rhs, bindings)
+		if lhs != v.lhs || rhs != v.rhs {
+			return &InfixExpression{lhs: lhs, rhs: rhs, op: v.op, pos: v.pos}
+		}
+	case *CallExpression:
+		if isLet(v) {
+			value := bind(v.args[1], bindings)
+			names, body := bindScope([]IdentifierToken{v.args[0].(IdentifierToken)}, v.args[2], bindings)
+			if value == v.args[1] && body == v.args[2] {
+				return e
+			}
+			return &CallExpression{name: v.name, args: []Expression{names[0], value, body}, pos: v.pos}
+		}
+		if isLambda(v) {
+			params, body := bindScope(lambdaParams(v), lambdaBody(v), bindings)
+			if body == lambdaBody(v) {
+				return e
+			}
+			args := make([]Expression, 0, len(v.args))
+			for _, param := range params {
+				args = append(args, param)
+			}
+			return &CallExpression{name: v.name, args: append(args, body), pos: v.pos}
+		}
+		name := v.name
+		r, changed := bindings[v.name].(renamedLocal)
+		if changed {
+			name = r.name
+		}
+		args := make([]Expression, len(v.args))
+		for i, arg := range v.args {
+			args[i] = bind(arg, bindings)
+			changed = changed || args[i] != arg
+		}
+		if changed {
+			return &CallExpression{name: name, args: args, pos: v.pos}
+		}
+	}
+	return e
+}
+
+// renamedLocal binds a name that bindScope renamed, which is replaced where it
+// is called as well as where it is read, since it may hold a function.
+type renamedLocal struct {
+	IdentifierToken
+}
+
+// bindScope substitutes bindings into body, where params are bound by a
+// let or lambda, as Bind does: the parameters are not replaced, and a
+// parameter that would capture a variable of a replacement is renamed to a
+// name used nowhere else.
+func bindScope(params []IdentifierToken, body Expression, bindings map[string]Expression) ([]IdentifierToken, Expression) {
+	inner := make(map[string]Expression, len(bindings)+len(params))
+	for name, replacement := range bindings {
+		inner[name] = replacement
+	}
+	for _, param := range params {
+		delete(inner, param.name)
+	}
+	taken := make(map[string]bool)
+	for _, name := range Variables(body) {
+		taken[name] = true
+	}
+	captured := make(map[string]bool)
+	for name, replacement := range inner {
+		if !taken[name] {
+			continue
+		}
+		for _, v := range Variables(replacement) {
+			captured[v] = true
+			taken[v] = true
+		}
+	}
+	for _, param := range params {
+		taken[param.name] = true
+	}
+	renamed := make([]IdentifierToken, len(params))
+	for i, param := range params {
+		renamed[i] = param
+		if !captured[param.name] {
+			continue
+		}
+		for n := 1; ; n++ {
+			fresh := param.name + "_" + strconv.Itoa(n)
+			if !taken[fresh] {
+				taken[fresh] = true
+				renamed[i].name = fresh
+				break
+			}
+		}
+		inner[param.name] = renamedLocal{renamed[i]}
+	}
+	return renamed, bind(body, inner)
+}
+
+// renumberHoles numbers the holes of e from *next in source order.
+func renumberHoles(e Expression, next *int) Expression {
+	switch v := e.(type) {
+	case Hole:
+		v.index = *next
+		*next++
+		return v
+	case *PrefixExpression:
+		if rhs := renumberHoles(v.rhs, next); rhs != v.rhs {
+			return &PrefixExpression{op: v.op, rhs: rhs, pos: v.pos}
+		}
+	case *InfixExpression:
+		lhs := renumberHoles(v.lhs, next)
+		rhs := renumberHoles(v.rhs, next)
+		if lhs != v.lhs || rhs != v.rhs {
+			return &InfixExpression{lhs: lhs, rhs: rhs, op: v.op, pos: v.pos}
+		}
+	case *CallExpression:
+		args := make([]Expression, len(v.args))
+		changed := false
+		for i, arg := range v.args {
+			args[i] = renumberHoles(arg, next)
+			changed = changed || args[i] != arg
+		}
+		if changed {
+			return &CallExpression{name: v.name, args: args, pos: v.pos}
+		}
+	}
+	return e
+}
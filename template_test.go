@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		src      string
+		bindings map[string]string
+		want     string
+	}{
+		{"price * qty", map[string]string{"price": "a + b"}, "(a + b) * qty"},
+		{"x + y", map[string]string{"x": "y", "y": "x"}, "y + x"},
+		{"x + y", map[string]string{"x": "y"}, "y + y"},
+		{"f(x)", map[string]string{"f": "g", "x": "1"}, "f(1)"},
+		{"let(x, 2, x + y)", map[string]string{"y": "x * 3"}, "let(x_1, 2, x_1 + x * 3)"},
+		{"let(x, x, x)", map[string]string{"x": "1"}, "let(x, 1, x)"},
+		{"fn(x, x + y)", map[string]string{"y": "x"}, "fn(x_1, x_1 + x)"},
+		{"fn(x, x + y)", map[string]string{"y": "2"}, "fn(x, x + 2)"},
+		{"let(f, fn(a, a), f(y))", map[string]string{"y": "f"}, "let(f_1, fn(a, a), f_1(f))"},
+		{"let(x, 1, x + x_1 + y)", map[string]string{"y": "x"}, "let(x_2, 1, x_2 + x_1 + x)"},
+		{"_ + x", map[string]string{"x": "_ * _"}, "_ + _ * _"},
+	}
+	for _, tt := range tests {
+		e := mustParse(t, tt.src)
+		bindings := make(map[string]Expression, len(tt.bindings))
+		for name, src := range tt.bindings {
+			bindings[name] = mustParse(t, src)
+		}
+		if got := Format(Bind(e, bindings)); got != tt.want {
+			t.Errorf("Bind(%s, %v) = %s, want %s", tt.src, tt.bindings, got, tt.want)
+		}
+		if got := Format(e); got != Format(mustParse(t, tt.src)) {
+			t.Errorf("Bind modified %s to %s", tt.src, got)
+		}
+	}
+}
+
+func TestBindRenumbersHoles(t *testing.T) {
+	e := Bind(mustParse(t, "_ + x - _"), map[string]Expression{"x": mustParse(t, "_ * _")})
+	var got []int
+	walk(e, 0, func(e Expression, depth int) bool {
+		if h, ok := e.(Hole); ok {
+			got = append(got, h.index)
+		}
+		return true
+	})
+	if want := []int{0, 1, 2, 3}; !equalInts(got, want) {
+		t.Errorf("holes are numbered %v, want %v", got, want)
+	}
+}
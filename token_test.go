@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLexTokens(t *testing.T) {
+	l := &Lexer{}
+	l.lex(`ab + 2.5*("s" <= 30)`)
+	want := []Token{
+		{Kind: Identifier, Lit: "ab", Pos: 0, End: 2},
+		{Kind: Operand, Lit: "+", Op: AddOp, Pos: 3, End: 4},
+		{Kind: Float, Lit: "2.5", Float: 2.5, Pos: 5, End: 8},
+		{Kind: Operand, Lit: "*", Op: MulOp, Pos: 8, End: 9},
+		{Kind: Operand, Lit: "(", Op: LParenOp, Pos: 9, End: 10},
+		{Kind: StringLiteral, Lit: "s", Pos: 10, End: 13},
+		{Kind: Operand, Lit: "<=", Op: LeOp, Pos: 14, End: 16},
+		{Kind: Integer, Lit: "30", Int: 30, Pos: 17, End: 19},
+		{Kind: Operand, Lit: ")", Op: RParenOp, Pos: 19, End: 20},
+	}
+	// The lexer keeps its tokens last first, for the parser to pop.
+	got := make([]Token, len(l.tokens))
+	for i, tok := range l.tokens {
+		got[len(got)-1-i] = tok
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lexed\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestLexDoesNotAllocatePerToken(t *testing.T) {
+	src := "a + b * (c - 12) / d ^ 2 + f(x, y, 3.5) - \"s\""
+	l := &Lexer{}
+	l.lex(src)
+	if allocs := testing.AllocsPerRun(100, func() { l.lex(src) }); allocs != 0 {
+		t.Errorf("relexing %s allocates %g times, want 0", src, allocs)
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	tokens := []Token{
+		{Kind: Identifier, Lit: "price", Pos: 0, End: 5},
+		{Kind: Operand, Lit: "*", Pos: 6, End: 7},
+		{Kind: Operand, Lit: "(", Pos: 8, End: 9},
+		{Kind: Integer, Lit: "1", Int: 1, Pos: 9, End: 10},
+		{Kind: Operand, Lit: "+", Op: AddOp, Pos: 11, End: 12},
+		{Kind: Illegal, Lit: "%", Pos: 13, End: 14},
+		{Kind: Float, Lit: "0.2", Float: 0.2, Pos: 15, End: 18},
+		{Kind: Operand, Lit: ")", Pos: 18, End: 19},
+	}
+	original := append([]Token(nil), tokens...)
+	if _, err := ParseTokens(tokens); err == nil || err.Error() != "unexpected character '%' at column 14" {
+		t.Errorf("ParseTokens with an illegal token fails with %v", err)
+	}
+	e, err := ParseTokens(tokens, Permissive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Parse("price * (1 + 0.2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalExpr(e, want) {
+		t.Errorf("ParseTokens = %s, want %s", Format(e), Format(want))
+	}
+	if !reflect.DeepEqual(tokens, original) {
+		t.Errorf("ParseTokens modified its tokens")
+	}
+	if _, err := ParseTokens([]Token{{Kind: Operand, Lit: "?", Pos: 0, End: 1}}); err == nil || err.Error() != "unknown operator '?' at column 1" {
+		t.Errorf("ParseTokens with an unknown operator fails with %v", err)
+	}
+	if _, err := ParseTokens(nil); err == nil {
+		t.Errorf("ParseTokens of no tokens did not fail")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// TraceStep is one reduction made while evaluating: a variable read, an
+// operator applied or a function called, with the values of its operands
+// and the result.
+type TraceStep struct {
+	Expr     Expression
+	Operands []Value
+	Result   Value
+}
+
+// String shows the step with its operands already reduced, as "2 * 3 → 6".
+func (s TraceStep) String() string {
+	var sb strings.Builder
+	switch v := s.Expr.(type) {
+	case IdentifierToken:
+		sb.WriteString(v.name)
+	case *PrefixExpression:
+		sb.WriteString(v.op.String())
+		sb.WriteString(traceOperand(s.Operands[0]))
+	case *InfixExpression:
+		sb.WriteString(traceOperand(s.Operands[0]))
+		sb.WriteByte(' ')
+		sb.WriteString(v.op.String())
+		sb.WriteByte(' ')
+		sb.WriteString(traceOperand(s.Operands[1]))
+	case *CallExpression:
+		sb.WriteString(v.name)
+		sb.WriteByte('(')
+		for i, arg := range s.Operands {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(arg.String())
+		}
+		sb.WriteByte(')')
+	}
+	sb.WriteString(" → ")
+	sb.WriteString(s.Result.String())
+	return sb.String()
+}
+
+// traceOperand parenthesises negative operands, which would otherwise read
+// as if another operator were applied, as in -2 ^ 2.
+func traceOperand(v Value) string {
+	text := v.String()
+	if strings.HasPrefix(text, "-") {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// Trace evaluates e against env like Eval, also returning every reduction
+// in the order it was made, which follows how precedence grouped the
+// source. On failure the steps completed before the error are returned.
+func Trace(e Expression, env Env) ([]TraceStep, Value, error) {
+	steps := make([]TraceStep, 0)
+	ev := &evaluation{
+		env:   env,
+		steps: &steps,
+	}
+	value, err := ev.eval(e)
+	return steps, value, err
+}
+
+func (ev *evaluation) record(e Expression, operands []Value, result Value) {
+	if ev.steps != nil {
+		*ev.steps = append(*ev.steps, TraceStep{Expr: e, Operands: operands, Result: result})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestTrace(t *testing.T) {
+	env := Env{
+		"x": IntValue(3),
+		"double": FuncValue(func(args []Value) (Value, error) {
+			return applyInfix(MulOp, args[0], IntValue(2))
+		}),
+	}
+	tests := []struct {
+		src   string
+		steps []string
+		err   bool
+	}{
+		{"1 + 2 * x", []string{"x → 3", "2 * 3 → 6", "1 + 6 → 7"}, false},
+		{"-x ^ 2", []string{"x → 3", "3 ^ 2 → 9", "-9 → -9"}, false},
+		{"(0 - x) * 2", []string{"x → 3", "0 - 3 → -3", "(-3) * 2 → -6"}, false},
+		{"double(x + 1)", []string{"x → 3", "3 + 1 → 4", "double(4) → 8"}, false},
+		{"x + 1 / 0", []string{"x → 3"}, true},
+	}
+	for _, tt := range tests {
+		steps, _, err := Trace(mustParse(t, tt.src), env)
+		if (err != nil) != tt.err {
+			t.Errorf("Trace(%s) fails with %v", tt.src, err)
+		}
+		got := make([]string, len(steps))
+		for i, step := range steps {
+			got[i] = step.String()
+		}
+		if !equalStrings(got, tt.steps) {
+			t.Errorf("Trace(%s) = %q, want %q", tt.src, got, tt.steps)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// try(expr, default) evaluates to expr unless evaluating it fails, as with
+// division by zero or a missing variable, and then to default. Unlike other
+// calls it evaluates its arguments lazily, so it is handled by every
+// backend rather than called, and a function named try in the environment
+// does not replace it. Only try with two arguments is special; any other
+// call reaches the builtin below and fails.
+func init() {
+	addBuiltin("try", "try(expr, default) any", docEntry{"expr, or default if evaluating expr fails", "try(total / count, 0)"},
+		func(args []Value) (Value, error) {
+			return Value{}, fmt.Errorf("try takes an expression and a default")
+		})
+}
+
+func isTry(e *CallExpression) bool {
+	return e.name == "try" && len(e.args) == 2
+}
+
+func (ev *evaluation) evalTry(e *CallExpression) (Value, error) {
+	value, err := ev.eval(e.args[0])
+	if !recoverable(err) || ev.ctx != nil && ev.ctx.Err() != nil {
+		return value, err
+	}
+	return ev.eval(e.args[1])
+}
+
+// recoverable reports whether try recovers from err, which it does unless
+// the evaluation ran out of the steps or recursion it is allowed.
+func recoverable(err error) bool {
+	return err != nil && !errors.Is(err, ErrStepLimit) && !errors.As(err, new(RecursionLimitError))
+}
+
+// checkTry types a try call as the kind its arguments share, or as unknown
+// if they differ, since either may be the result. Type errors in the
+// expression are what try recovers from, so only those in the default are
+// reported. The expression is typed on its own, and an error reported in it
+// does not mean it always fails: it may come from the default of a nested
+// try.
+func (c *typeChecker) checkTry(e *CallExpression) Kind {
+	guarded := &typeChecker{schema: c.schema, functions: c.functions}
+	kind := guarded.check(e.args[0])
+	fallback := c.check(e.args[1])
+	if kind == fallback {
+		return kind
+	}
+	return unknownKind
+}
+
+// compileTry compiles the expression and default of a try call as separate
+// programs run by OpTry, so that a failure part way through the expression
+// leaves nothing on the stack or in temporaries for the rest of the program
+// to see. An expression that folds to a constant cannot fail and is used
+// directly.
+func (c *compiler) compileTry(e *CallExpression) error {
+	body, err := compileWith(e.args[0], c.compileSettings)
+	if err != nil {
+		return err
+	}
+	if len(body.Code) == 1 && body.Code[0].Op == OpConst && !c.noFold {
+		c.emit(OpConst, c.constant(body.Consts[body.Code[0].A]), 0, e.pos)
+		return nil
+	}
+	fallback, err := compileWith(e.args[1], c.compileSettings)
+	if err != nil {
+		return err
+	}
+	c.program.Tries = append(c.program.Tries, [2]*Program{body, fallback})
+	c.emit(OpTry, len(c.program.Tries)-1, 0, e.pos)
+	return nil
+}
+
+// compileTry compiles a try call for the register machine as
+// compiler.compileTry does, which also keeps the variables loaded by the
+// expression from being read from registers it may not have filled.
+func (c *regCompiler) compileTry(e *CallExpression) (int, error) {
+	body, err := compileRegistersWith(e.args[0], c.compileSettings)
+	if err != nil {
+		return 0, err
+	}
+	if body.Result < 0 && len(body.Code) == 0 && !c.noFold {
+		return c.constant(body.Consts[-1-body.Result]), nil
+	}
+	fallback, err := compileRegistersWith(e.args[1], c.compileSettings)
+	if err != nil {
+		return 0, err
+	}
+	c.program.Tries = append(c.program.Tries, [2]*RegProgram{body, fallback})
+	return c.emit(RegInstruction{Op: RegTry, Dst: c.alloc(), A: len(c.program.Tries) - 1, Pos: e.pos}), nil
+}
+
+// indent indents each line of a nested program's disassembly.
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\n    ") + "\n"
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTry(t *testing.T) {
+	env := Env{"x": IntValue(4), "zero": IntValue(0)}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"try(x / 2, -1)", IntValue(2)},
+		{"try(x / zero, -1)", IntValue(-1)},
+		{"try(missing, x)", IntValue(4)},
+		{"try(try(missing, x / zero), 7)", IntValue(7)},
+		{"try(1, missing)", IntValue(1)},
+		{"1 + try(x / zero, 0) * 2", IntValue(1)},
+		{"{ t = zero; try(x / t, t) }", IntValue(0)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		for _, b := range allBackends {
+			got, err := NewEvaluator(env, WithBackend(b)).Eval(e)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("%s with the %s backend = %s, %v, want %s", tt.src, b, got, err, tt.want)
+			}
+		}
+	}
+	e, _ := Parse("try(x / zero, missing)")
+	for _, b := range allBackends {
+		if _, err := NewEvaluator(env, WithBackend(b)).Eval(e); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("a failing default with the %s backend fails with %v", b, err)
+		}
+	}
+}
+
+func TestCheckTry(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   Kind
+		errors int
+	}{
+		{"try(a / b, 0)", IntKind, 0},
+		{"try(c, 0)", unknownKind, 0},
+		{"try(try(c, x), a)", unknownKind, 0},
+		{`try(a + "s", 0)`, unknownKind, 0},
+		{"try(a, x)", unknownKind, 1},
+		{"try(c, c * 2)", FloatKind, 0},
+	}
+	schema := Schema{"a": IntKind, "b": IntKind, "c": FloatKind}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, errs := Check(e, schema)
+		if kind != tt.want || len(errs) != tt.errors {
+			t.Errorf("Check(%s) = %s, %v, want %s with %d errors", tt.src, kind, errs, tt.want, tt.errors)
+		}
+	}
+}
+
+func TestTryRules(t *testing.T) {
+	rs, err := NewRuleSet([]Rule{
+		{Name: "ratio", Expr: "try(hits / total, 0) > 2"},
+		{Name: "fallback", Expr: "try(hits / total > 2, hits > 10)"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event map[string]interface{}
+		want  []string
+	}{
+		{map[string]interface{}{"hits": 9.0, "total": 3.0}, []string{"ratio", "fallback"}},
+		{map[string]interface{}{"hits": 20.0, "total": "none"}, []string{"fallback"}},
+		{map[string]interface{}{"hits": 20.0}, []string{"fallback"}},
+		{map[string]interface{}{"hits": 1.0}, nil},
+	}
+	for _, tt := range tests {
+		if got := rs.Match(tt.event); !equalStrings(got, tt.want) {
+			t.Errorf("Match(%v) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestTryExports(t *testing.T) {
+	e, err := Parse("1 + try(a / b, 0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SQL(e, PostgreSQL); err == nil || !strings.Contains(err.Error(), "try has no SQL equivalent at column 5") {
+		t.Errorf("SQL fails with %v", err)
+	}
+	if _, err := GoAST(e); err == nil || !strings.Contains(err.Error(), "try has no Go equivalent") {
+		t.Errorf("GoAST fails with %v", err)
+	}
+	if _, err := JS(e); err == nil || !strings.Contains(err.Error(), "try has no JavaScript equivalent") {
+		t.Errorf("JS fails with %v", err)
+	}
+	if _, err := TAC(e); err == nil || !strings.Contains(err.Error(), "try has no three-address code") {
+		t.Errorf("TAC fails with %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import "fmt"
+
+// unknownKind marks a node whose type could not be inferred. It is never
+// reported itself so one mistake does not cascade into many.
+const unknownKind Kind = -1
+
+type Schema map[string]Kind
+
+// TypeError is a problem found at Pos. Suggestion is a name that may have
+// been meant instead of a missing one.
+type TypeError struct {
+	Pos        int
+	Msg        string
+	Suggestion string
+}
+
+func (e TypeError) Error() string {
+	return didYouMean(fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1), e.Suggestion, English)
+}
+
+type typeChecker struct {
+	schema Schema
+	// functions declares the functions called, for Validate and DryRun.
+	functions map[string]Signature
+	errors    []TypeError
+}
+
+// Check infers the type of e against the variable kinds declared in schema
+// and reports every mismatch found, without evaluating anything.
+func Check(e Expression, schema Schema) (Kind, []TypeError) {
+	c := &typeChecker{schema: schema}
+	kind := c.check(e)
+	return kind, c.errors
+}
+
+func (c *typeChecker) errorf(pos int, format string, args ...interface{}) Kind {
+	c.errors = append(c.errors, TypeError{
+		Pos: pos,
+		Msg: fmt.Sprintf(format, args...),
+	})
+	return unknownKind
+}
+
+// undefined reports the missing name at pos, with a suggestion if there is
+// one.
+func (c *typeChecker) undefined(pos int, suggestion string, format string, name string) Kind {
+	c.errorf(pos, format, name)
+	c.errors[len(c.errors)-1].Suggestion = suggestion
+	return unknownKind
+}
+
+func isNumericKind(k Kind) bool {
+	return k == IntKind || k == FloatKind
+}
+
+func (c *typeChecker) check(e Expression) Kind {
+	switch v := e.(type) {
+	case IntegerToken:
+		return IntKind
+	case FloatToken:
+		return FloatKind
+	case StringToken:
+		return StringKind
+	case Hole:
+		return unknownKind
+	case IdentifierToken:
+		kind, ok := c.schema[v.name]
+		if !ok {
+			return c.undefined(v.pos, c.suggestVariable(v.name), "undefined variable '%s'", v.name)
+		}
+		return kind
+	case *PrefixExpression:
+		rhs := c.check(v.rhs)
+		if rhs == unknownKind {
+			return unknownKind
+		}
+		// Custom values may implement Arithmetic, which is only known
+		// when evaluating.
+		if operatorFunction(v.op, false) != "" || rhs == CustomKind {
+			return unknownKind
+		}
+		o := prefixOverload(v.op, rhs)
+		if o.prefix == nil {
+			return c.errorf(v.pos, "operator '%s' not defined for %s", v.op, rhs)
+		}
+		return o.result
+	case *InfixExpression:
+		lhs := c.check(v.lhs)
+		rhs := c.check(v.rhs)
+		if lhs == unknownKind || rhs == unknownKind || operatorFunction(v.op, true) != "" {
+			return unknownKind
+		}
+		if lhs == CustomKind || rhs == CustomKind {
+			return unknownKind
+		}
+		o, _ := infixOverload(v.op, lhs, rhs)
+		if o.infix == nil {
+			return c.errorf(v.pos, "operator '%s' not defined for %s and %s", v.op, lhs, rhs)
+		}
+		return o.result
+	case *CallExpression:
+		if isTry(v) {
+			return c.checkTry(v)
+		}
+		if isLet(v) {
+			return c.checkLet(v)
+		}
+		if isLambda(v) {
+			return c.checkLambda(v)
+		}
+		args := make([]Kind, len(v.args))
+		for i, arg := range v.args {
+			args[i] = c.check(arg)
+		}
+		if signature, ok := c.functions[v.name]; ok {
+			return c.checkCall(v, signature, args)
+		}
+		kind, ok := c.schema[v.name]
+		if _, builtin := builtinFunctions[v.name]; !ok && builtin {
+			if signature, ok := builtinSignatures[v.name]; ok {
+				return c.checkCall(v, signature, args)
+			}
+			return unknownKind
+		}
+		if !ok {
+			return c.undefined(v.pos, c.suggestFunction(v.name), "undefined function '%s'", v.name)
+		}
+		if kind != FuncKind {
+			return c.errorf(v.pos, "'%s' is not a function", v.name)
+		}
+		return unknownKind
+	}
+	return c.errorf(e.getPosition(), "cannot type %T", e)
+}
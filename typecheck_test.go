@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	schema := Schema{"n": IntKind, "x": FloatKind, "ok": BoolKind, "name": StringKind, "f": FuncKind}
+	tests := []struct {
+		src    string
+		kind   Kind
+		errors []string
+	}{
+		{"n + 1", IntKind, nil},
+		{"n * x", FloatKind, nil},
+		{"n < x && ok", BoolKind, nil},
+		{`name + "!"`, StringKind, nil},
+		{`"a" * 2`, unknownKind, []string{"operator '*' not defined for string and int at column 5"}},
+		{"ok == 1", unknownKind, []string{"operator '==' not defined for bool and int at column 4"}},
+		{"-ok", unknownKind, []string{"operator '-' not defined for bool at column 1"}},
+		{"nmae + 1", unknownKind, []string{"undefined variable 'nmae' at column 1; did you mean 'name'?"}},
+		{"m + 1", unknownKind, []string{"undefined variable 'm' at column 1"}},
+		{"n(1)", unknownKind, []string{"'n' is not a function at column 1"}},
+		{`"a" * 2 + -ok`, unknownKind, []string{
+			"operator '*' not defined for string and int at column 5",
+			"operator '-' not defined for bool at column 11",
+		}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, errs := Check(e, schema)
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = err.Error()
+		}
+		if kind != tt.kind || !equalStrings(got, tt.errors) {
+			t.Errorf("Check(%s) = %s, %q, want %s, %q", tt.src, kind, got, tt.kind, tt.errors)
+		}
+	}
+}
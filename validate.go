@@ -0,0 +1,34 @@
+package main
+
+// Diagnostic reports a problem with the source bytes [Pos, End). End equals
+// Pos when only the starting point is known.
+type Diagnostic struct {
+	Pos int
+	End int
+	Msg string
+}
+
+type ValidateOptions struct {
+	Schema Schema
+	// Functions declares functions whose calls are checked against their
+	// signatures.
+	Functions map[string]Signature
+}
+
+// Validate lexes, parses and type-checks src against opts.Schema and
+// opts.Functions without evaluating it, returning every problem found.
+func Validate(src string, opts ValidateOptions) []Diagnostic {
+	expr, err := Parse(src)
+	if err != nil {
+		syntaxErr := err.(SyntaxError)
+		return []Diagnostic{{Pos: syntaxErr.Pos, End: syntaxErr.End, Msg: syntaxErr.Msg}}
+	}
+	c := &typeChecker{schema: opts.Schema, functions: opts.Functions}
+	c.check(expr)
+	typeErrors := c.errors
+	diagnostics := make([]Diagnostic, 0, len(typeErrors))
+	for _, typeErr := range typeErrors {
+		diagnostics = append(diagnostics, Diagnostic{Pos: typeErr.Pos, End: typeErr.Pos, Msg: didYouMean(typeErr.Msg, typeErr.Suggestion, English)})
+	}
+	return diagnostics
+}
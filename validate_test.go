@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	opts := ValidateOptions{
+		Schema: Schema{"price": FloatKind, "qty": IntKind, "sku": StringKind},
+		Functions: map[string]Signature{
+			"discount": {Result: FloatKind, Params: []Kind{FloatKind, IntKind}},
+			"join":     {Result: StringKind, Params: []Kind{StringKind}, Variadic: true},
+		},
+	}
+	tests := []struct {
+		src  string
+		want []Diagnostic
+	}{
+		{"price * qty", nil},
+		{"discount(price, qty) + 1", nil},
+		{`join(sku, "-", sku)`, nil},
+		{"price * (qty", []Diagnostic{{Pos: 8, End: 9, Msg: "expected right paren"}}},
+		{`discount(sku, qty)`, []Diagnostic{{Pos: 9, End: 9, Msg: "argument 1 of 'discount' must be float, not string"}}},
+		{`discount(price)`, []Diagnostic{{Pos: 0, End: 0, Msg: "'discount' takes 2 arguments, not 1"}}},
+		{`price + sku * qyt`, []Diagnostic{
+			{Pos: 14, End: 14, Msg: "undefined variable 'qyt'; did you mean 'qty'?"},
+		}},
+	}
+	for _, tt := range tests {
+		got := Validate(tt.src, opts)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%s) = %+v, want %+v", tt.src, got, tt.want)
+		}
+	}
+}
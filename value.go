@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	IntKind Kind = iota
+	FloatKind
+	BoolKind
+	StringKind
+	ListKind
+	MapKind
+	FuncKind
+	CustomKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case IntKind:
+		return "int"
+	case FloatKind:
+		return "float"
+	case BoolKind:
+		return "bool"
+	case StringKind:
+		return "string"
+	case ListKind:
+		return "list"
+	case MapKind:
+		return "map"
+	case FuncKind:
+		return "func"
+	case CustomKind:
+		return "custom"
+	}
+	return "unknown"
+}
+
+type Function func(args []Value) (Value, error)
+
+// Value is the result of evaluating an expression. Only the field matching
+// kind is meaningful.
+type Value struct {
+	kind Kind
+	i    int64
+	f    float64
+	b    bool
+	s    string
+	l    []Value
+	m    map[string]Value
+	fn   Function
+	x    interface{}
+}
+
+func IntValue(i int64) Value {
+	return Value{kind: IntKind, i: i}
+}
+
+func FloatValue(f float64) Value {
+	return Value{kind: FloatKind, f: f}
+}
+
+func BoolValue(b bool) Value {
+	return Value{kind: BoolKind, b: b}
+}
+
+func StringValue(s string) Value {
+	return Value{kind: StringKind, s: s}
+}
+
+func ListValue(l []Value) Value {
+	return Value{kind: ListKind, l: l}
+}
+
+func MapValue(m map[string]Value) Value {
+	return Value{kind: MapKind, m: m}
+}
+
+func FuncValue(fn Function) Value {
+	return Value{kind: FuncKind, fn: fn}
+}
+
+// CustomValue wraps a value of a type the host defines, such as a color or
+// a date, for functions the host provides to operate on. It prints with
+// fmt's %v and equals custom values that are deeply equal.
+func CustomValue(x interface{}) Value {
+	return Value{kind: CustomKind, x: x}
+}
+
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+func (v Value) Int() int64 {
+	return v.i
+}
+
+func (v Value) Float() float64 {
+	return v.f
+}
+
+func (v Value) Bool() bool {
+	return v.b
+}
+
+func (v Value) Str() string {
+	return v.s
+}
+
+func (v Value) List() []Value {
+	return v.l
+}
+
+func (v Value) Map() map[string]Value {
+	return v.m
+}
+
+func (v Value) Func() Function {
+	return v.fn
+}
+
+func (v Value) Custom() interface{} {
+	return v.x
+}
+
+func (v Value) IsNumeric() bool {
+	return v.kind == IntKind || v.kind == FloatKind
+}
+
+// AsInt converts numeric, bool and numeric-looking string values to int64.
+// Floats are truncated towards zero, and those out of the range of int64,
+// NaN and the infinities cannot be converted.
+func (v Value) AsInt() (int64, error) {
+	switch v.kind {
+	case IntKind:
+		return v.i, nil
+	case FloatKind:
+		// -2^63 is an int64 but 2^63 is not.
+		if math.IsNaN(v.f) || v.f < math.MinInt64 || v.f >= -math.MinInt64 {
+			return 0, fmt.Errorf("cannot convert %s to int", v)
+		}
+		return int64(v.f), nil
+	case BoolKind:
+		if v.b {
+			return 1, nil
+		}
+		return 0, nil
+	case StringKind:
+		i, err := strconv.ParseInt(strings.TrimSpace(v.s), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to int", v.s)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("cannot convert %s to int", v.kind)
+}
+
+func (v Value) AsFloat() (float64, error) {
+	switch v.kind {
+	case IntKind:
+		return float64(v.i), nil
+	case FloatKind:
+		return v.f, nil
+	case BoolKind:
+		if v.b {
+			return 1, nil
+		}
+		return 0, nil
+	case StringKind:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.s), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to float", v.s)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("cannot convert %s to float", v.kind)
+}
+
+func (v Value) AsBool() (bool, error) {
+	switch v.kind {
+	case BoolKind:
+		return v.b, nil
+	case IntKind:
+		return v.i != 0, nil
+	case FloatKind:
+		return v.f != 0, nil
+	case StringKind:
+		b, err := strconv.ParseBool(strings.TrimSpace(v.s))
+		if err != nil {
+			return false, fmt.Errorf("cannot convert %q to bool", v.s)
+		}
+		return b, nil
+	}
+	return false, fmt.Errorf("cannot convert %s to bool", v.kind)
+}
+
+func (v Value) AsString() (string, error) {
+	if v.kind == FuncKind {
+		return "", fmt.Errorf("cannot convert %s to string", v.kind)
+	}
+	if v.kind == StringKind {
+		return v.s, nil
+	}
+	return v.String(), nil
+}
+
+func (v Value) Equal(o Value) bool {
+	if v.IsNumeric() && o.IsNumeric() {
+		if v.kind == IntKind && o.kind == IntKind {
+			return v.i == o.i
+		}
+		a, _ := v.AsFloat()
+		b, _ := o.AsFloat()
+		return a == b
+	}
+	if v.kind != o.kind {
+		return false
+	}
+	switch v.kind {
+	case BoolKind:
+		return v.b == o.b
+	case StringKind:
+		return v.s == o.s
+	case ListKind:
+		if len(v.l) != len(o.l) {
+			return false
+		}
+		for i := range v.l {
+			if !v.l[i].Equal(o.l[i]) {
+				return false
+			}
+		}
+		return true
+	case MapKind:
+		if len(v.m) != len(o.m) {
+			return false
+		}
+		for k, a := range v.m {
+			b, ok := o.m[k]
+			if !ok || !a.Equal(b) {
+				return false
+			}
+		}
+		return true
+	case CustomKind:
+		return reflect.DeepEqual(v.x, o.x)
+	}
+	return false
+}
+
+func (v Value) String() string {
+	switch v.kind {
+	case IntKind:
+		return strconv.FormatInt(v.i, 10)
+	case FloatKind:
+		return strconv.FormatFloat(v.f, 'g', -1, 64)
+	case BoolKind:
+		return strconv.FormatBool(v.b)
+	case StringKind:
+		return strconv.Quote(v.s)
+	case ListKind:
+		items := make([]string, len(v.l))
+		for i, item := range v.l {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case MapKind:
+		keys := make([]string, 0, len(v.m))
+		for k := range v.m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = strconv.Quote(k) + ": " + v.m[k].String()
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case FuncKind:
+		return "<func>"
+	case CustomKind:
+		return fmt.Sprint(v.x)
+	}
+	return "<invalid>"
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValueKinds(t *testing.T) {
+	tests := []struct {
+		v    Value
+		kind Kind
+		str  string
+	}{
+		{IntValue(-3), IntKind, "-3"},
+		{FloatValue(0.5), FloatKind, "0.5"},
+		{BoolValue(true), BoolKind, "true"},
+		{StringValue("a\"b"), StringKind, `"a\"b"`},
+		{ListValue([]Value{IntValue(1), StringValue("x")}), ListKind, `[1, "x"]`},
+		{MapValue(map[string]Value{"b": IntValue(2), "a": BoolValue(false)}), MapKind, `{"a": false, "b": 2}`},
+		{FuncValue(func([]Value) (Value, error) { return Value{}, nil }), FuncKind, "<func>"},
+		{CustomValue(struct{ R, G, B int }{1, 2, 3}), CustomKind, "{1 2 3}"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Kind(); got != tt.kind {
+			t.Errorf("%s has kind %s, want %s", tt.v, got, tt.kind)
+		}
+		if got := tt.v.String(); got != tt.str {
+			t.Errorf("String() = %s, want %s", got, tt.str)
+		}
+	}
+}
+
+func TestValueEqual(t *testing.T) {
+	tests := []struct {
+		a, b Value
+		want bool
+	}{
+		{IntValue(2), IntValue(2), true},
+		{IntValue(2), FloatValue(2), true},
+		{FloatValue(2.5), IntValue(2), false},
+		{IntValue(1), BoolValue(true), false},
+		{StringValue("1"), IntValue(1), false},
+		{ListValue([]Value{IntValue(1)}), ListValue([]Value{FloatValue(1)}), true},
+		{ListValue([]Value{IntValue(1)}), ListValue([]Value{IntValue(1), IntValue(2)}), false},
+		{MapValue(map[string]Value{"a": IntValue(1)}), MapValue(map[string]Value{"a": IntValue(1)}), true},
+		{MapValue(map[string]Value{"a": IntValue(1)}), MapValue(map[string]Value{"b": IntValue(1)}), false},
+		{CustomValue([]int{1, 2}), CustomValue([]int{1, 2}), true},
+		{FuncValue(nil), FuncValue(nil), false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.want {
+			t.Errorf("%s.Equal(%s) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestValueConversions(t *testing.T) {
+	tests := []struct {
+		v      Value
+		i      int64
+		f      float64
+		b      bool
+		s      string
+		errors string
+	}{
+		{v: IntValue(7), i: 7, f: 7, b: true, s: "7"},
+		{v: FloatValue(-2.75), i: -2, f: -2.75, b: true, s: "-2.75"},
+		{v: BoolValue(true), i: 1, f: 1, b: true, s: "true"},
+		{v: StringValue(" 12 "), i: 12, f: 12, s: " 12 ", errors: "b"},
+		{v: StringValue("false"), b: false, s: "false", errors: "if"},
+		{v: ListValue(nil), s: "[]", errors: "ifb"},
+		{v: FuncValue(nil), errors: "ifbs"},
+	}
+	for _, tt := range tests {
+		i, err := tt.v.AsInt()
+		if (err != nil) != strings.Contains(tt.errors, "i") || err == nil && i != tt.i {
+			t.Errorf("%s.AsInt() = %d, %v, want %d", tt.v, i, err, tt.i)
+		}
+		f, err := tt.v.AsFloat()
+		if (err != nil) != strings.Contains(tt.errors, "f") || err == nil && f != tt.f {
+			t.Errorf("%s.AsFloat() = %g, %v, want %g", tt.v, f, err, tt.f)
+		}
+		b, err := tt.v.AsBool()
+		if (err != nil) != strings.Contains(tt.errors, "b") || err == nil && b != tt.b {
+			t.Errorf("%s.AsBool() = %t, %v, want %t", tt.v, b, err, tt.b)
+		}
+		s, err := tt.v.AsString()
+		if (err != nil) != strings.Contains(tt.errors, "s") || err == nil && s != tt.s {
+			t.Errorf("%s.AsString() = %q, %v, want %q", tt.v, s, err, tt.s)
+		}
+	}
+}
+
+func TestApplyInfixPromotes(t *testing.T) {
+	tests := []struct {
+		op       OpKind
+		lhs, rhs Value
+		want     Value
+	}{
+		{AddOp, IntValue(1), IntValue(2), IntValue(3)},
+		{AddOp, IntValue(1), FloatValue(0.5), FloatValue(1.5)},
+		{MulOp, FloatValue(0.5), IntValue(4), FloatValue(2)},
+		{LtOp, IntValue(1), FloatValue(1.5), BoolValue(true)},
+		{EqOp, StringValue("a"), StringValue("a"), BoolValue(true)},
+	}
+	for _, tt := range tests {
+		got, err := applyInfix(tt.op, tt.lhs, tt.rhs)
+		if err != nil || !got.Equal(tt.want) || got.Kind() != tt.want.Kind() {
+			t.Errorf("%s %s %s = %s, %v, want %s", tt.lhs, tt.op, tt.rhs, got, err, tt.want)
+		}
+	}
+	if _, err := applyInfix(MulOp, StringValue("a"), IntValue(2)); err == nil {
+		t.Errorf(`"a" * 2 did not fail`)
+	}
+}
+
+// evalCase is an expression with the value it evaluates to, or a part of
+// the error it fails with.
+type evalCase struct {
+	src  string
+	want Value
+	err  string
+}
+
+// checkEvalCases evaluates each case against env, failing t for those that
+// give a different value or kind of value, or do not fail as expected.
+func checkEvalCases(t *testing.T, env Env, tests []evalCase) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := NewEvaluator(env).Eval(mustParse(t, tt.src))
+		if (err != nil) != (tt.err != "") || err != nil && !strings.Contains(err.Error(), tt.err) ||
+			err == nil && (!got.Equal(tt.want) || got.Kind() != tt.want.Kind()) {
+			t.Errorf("%s = %s, %v, want %s, %q", tt.src, got, err, tt.want, tt.err)
+		}
+	}
+}
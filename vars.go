@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ReadVars reads a JSON object of variables, such as {"rate": 0.2}, into
+// env. Whole numbers become ints and other numbers floats; arrays become
+// lists and nested objects maps. null is rejected since no value stands for
+// it.
+func (env Env) ReadVars(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var vars map[string]interface{}
+	if err := decoder.Decode(&vars); err != nil {
+		return err
+	}
+	for name, raw := range vars {
+		value, err := valueFromJSON(raw)
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		env[name] = value
+	}
+	return nil
+}
+
+// valueFromJSON converts a decoded JSON value to a Value. It also accepts
+// the Go numbers float64, int and int64, and Values, so it can convert maps
+// built by hand.
+func valueFromJSON(raw interface{}) (Value, error) {
+	switch raw := raw.(type) {
+	case Value:
+		return raw, nil
+	case int:
+		return IntValue(int64(raw)), nil
+	case int64:
+		return IntValue(raw), nil
+	case float64:
+		return FloatValue(raw), nil
+	case json.Number:
+		if i, err := raw.Int64(); err == nil {
+			return IntValue(i), nil
+		}
+		f, err := raw.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		return FloatValue(f), nil
+	case bool:
+		return BoolValue(raw), nil
+	case string:
+		return StringValue(raw), nil
+	case []interface{}:
+		items := make([]Value, len(raw))
+		for i, item := range raw {
+			value, err := valueFromJSON(item)
+			if err != nil {
+				return Value{}, err
+			}
+			items[i] = value
+		}
+		return ListValue(items), nil
+	case map[string]interface{}:
+		fields := make(map[string]Value, len(raw))
+		for key, item := range raw {
+			value, err := valueFromJSON(item)
+			if err != nil {
+				return Value{}, err
+			}
+			fields[key] = value
+		}
+		return MapValue(fields), nil
+	}
+	return Value{}, fmt.Errorf("unsupported JSON value %v", raw)
+}
+
+// varFlags collects the -vars and -var flags in the order given, so they
+// can be applied once the command line is parsed. Reading a file then
+// fails as an IO error rather than as a bad flag.
+type varFlags struct {
+	env     Env
+	pending []func() error
+}
+
+// varsFlag is the -vars flag, reading a JSON file of variables into env.
+type varsFlag struct {
+	*varFlags
+}
+
+func (f varsFlag) String() string {
+	return ""
+}
+
+func (f varsFlag) Set(path string) error {
+	f.pending = append(f.pending, func() error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := f.env.ReadVars(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// varFlag is the -var flag, binding one variable given as name=value. The
+// value is read as a CSV cell is: an int, a float, true or false, or else
+// a string.
+type varFlag struct {
+	*varFlags
+}
+
+func (f varFlag) String() string {
+	return ""
+}
+
+func (f varFlag) Set(assignment string) error {
+	name, text, ok := strings.Cut(assignment, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not of the form name=value", assignment)
+	}
+	f.pending = append(f.pending, func() error {
+		f.env[name] = cellValue(text)
+		return nil
+	})
+	return nil
+}
+
+// load applies the flags in order, so later flags override variables set
+// by earlier ones, and returns the env they fill.
+func (f *varFlags) load() (Env, error) {
+	for _, apply := range f.pending {
+		if err := apply(); err != nil {
+			return nil, err
+		}
+	}
+	f.pending = nil
+	return f.env, nil
+}
+
+// addVarFlags defines -vars and -var on flags, both repeatable. The
+// variables they set are read by calling load after parsing flags.
+func addVarFlags(flags *flag.FlagSet) *varFlags {
+	vars := &varFlags{env: make(Env)}
+	flags.Var(varsFlag{vars}, "vars", "read variables from a JSON `file`")
+	flags.Var(varFlag{vars}, "var", "set a variable, as x=3")
+	return vars
+}
+
+// varsExitCode returns the exit code for an error from varFlags.load: an
+// IO error if a file could not be read, and a parse error if it is not a
+// JSON object of variables.
+func varsExitCode(err error) int {
+	if errors.As(err, new(*fs.PathError)) {
+		return exitIOError
+	}
+	return exitParseError
+}
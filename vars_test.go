@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadVars(t *testing.T) {
+	env := make(Env)
+	err := env.ReadVars(strings.NewReader(`{"n": 3, "rate": 0.2, "big": 1e3, "ok": true, "name": "pen",
+		"sizes": [1, 2.5], "order": {"qty": 4, "tags": ["a"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Env{
+		"n":     IntValue(3),
+		"rate":  FloatValue(0.2),
+		"big":   FloatValue(1000),
+		"ok":    BoolValue(true),
+		"name":  StringValue("pen"),
+		"sizes": ListValue([]Value{IntValue(1), FloatValue(2.5)}),
+		"order": MapValue(map[string]Value{"qty": IntValue(4), "tags": ListValue([]Value{StringValue("a")})}),
+	}
+	for name, value := range want {
+		if got, ok := env[name]; !ok || !got.Equal(value) || got.Kind() != value.Kind() {
+			t.Errorf("%s = %s, want %s", name, got, value)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("read %d variables, want %d", len(env), len(want))
+	}
+
+	tests := map[string]string{
+		`{"x": null}`:                    "variable x: unsupported JSON value <nil>",
+		`{"x": [1, null]}`:               "variable x: unsupported JSON value <nil>",
+		`[1, 2]`:                         "cannot unmarshal array",
+		`{"x": 1`:                        "unexpected EOF",
+		`{"x": 99999999999999999999999}`: "",
+	}
+	for input, want := range tests {
+		err := make(Env).ReadVars(strings.NewReader(input))
+		if want == "" {
+			if err != nil {
+				t.Errorf("ReadVars(%s) = %v", input, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ReadVars(%s) = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestVarFlags(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vars.json")
+	if err := os.WriteFile(path, []byte(`{"x": 1, "y": 2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	vars := addVarFlags(flags)
+	err := flags.Parse([]string{"-var", "x=0", "-vars", path, "-var", "y=2.5", "-var", " z = on", "-var", "ok=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := vars.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Env{
+		"x":  IntValue(1),
+		"y":  FloatValue(2.5),
+		"z":  StringValue(" on"),
+		"ok": BoolValue(false),
+	}
+	for name, value := range want {
+		if got := env[name]; !got.Equal(value) || got.Kind() != value.Kind() {
+			t.Errorf("%s = %s, want %s", name, got, value)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"x": null}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		`"x" is not of the form name=value`:  {"-var", "x"},
+		`"=1" is not of the form name=value`: {"-var", "=1"},
+		bad + ": variable x":                 {"-vars", bad},
+		"missing.json":                       {"-vars", filepath.Join(dir, "missing.json")},
+	}
+	for want, args := range tests {
+		flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		vars := addVarFlags(flags)
+		err := flags.Parse(args)
+		if err == nil {
+			_, err = vars.load()
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q gives %v, want %q", args, err, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runWatch evaluates a formula file and evaluates it again whenever it
+// changes, until interrupted. The file is polled rather than watched
+// through the OS so no dependency is needed.
+func runWatch(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := flags.Duration("interval", 250*time.Millisecond, "how often to check the file for changes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: watch [-interval d] file")
+	}
+	path := flags.Arg(0)
+	var last os.FileInfo
+	// failing is set once a failure to stat the file has been reported, so
+	// it is reported once however long it lasts.
+	failing := false
+	for {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			if !failing {
+				fmt.Fprintln(out, err)
+			}
+			failing = true
+			last = nil
+		case last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size():
+			failing = false
+			last = info
+			fmt.Fprintf(out, "--- %s at %s\n", path, info.ModTime().Format("15:04:05"))
+			if err := evalFile(path, out); err != nil {
+				fmt.Fprintln(out, err)
+			}
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// evalFile evaluates the entries of a formula file as the REPL would, in a
+// fresh session, printing each result or error after the line the entry
+// starts on.
+func evalFile(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	in := bufio.NewReader(f)
+	lineNumber := 0
+	read := func(prompt string) (string, error) {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		if err == nil {
+			lineNumber += 1
+		}
+		return strings.TrimSuffix(line, "\n"), err
+	}
+	session := newReplSession()
+	for {
+		entry, err := session.readEntry(read)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start := lineNumber - strings.Count(entry, "\n")
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		output, quit := session.run(entry)
+		fmt.Fprintf(out, "%s:%d: %s\n", path, start, output)
+		if quit {
+			return nil
+		}
+	}
+}
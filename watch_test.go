@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formula.calc")
+	src := "rate = 2\n\n1 + 2\nans *\n  2\n1 / 0\n:quit\n99\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := evalFile(path, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := path + ":1: unexpected character '=' at column 6\n" +
+		path + ":3: _1 = 3\n" +
+		path + ":4: _2 = 6\n" +
+		path + ":6: division by zero at column 3\n" +
+		path + ":7: \n"
+	if out.String() != want {
+		t.Errorf("evalFile printed\n%s\nwant\n%s", out.String(), want)
+	}
+	if err := evalFile(filepath.Join(t.TempDir(), "missing.calc"), &out); err == nil {
+		t.Errorf("evalFile of a missing file did not fail")
+	}
+}
+
+func TestWatchUsage(t *testing.T) {
+	var out strings.Builder
+	for _, args := range [][]string{nil, {"a.calc", "b.calc"}, {"-interval", "soon", "a.calc"}} {
+		if err := runWatch(args, &out); err == nil {
+			t.Errorf("watch %q did not fail", args)
+		}
+	}
+}